@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+type options struct {
+	jsonLog           bool
+	logLevel          string
+	bind              string
+	certFile          string
+	keyFile           string
+	roleBaseARN       string
+	autoDetectBaseARN bool
+}
+
+// logger creates the structured logger passed to the webhook handlers
+func (o *options) logger() *slog.Logger {
+	level := slog.LevelInfo
+	switch o.logLevel {
+	case "debug":
+		level = slog.LevelDebug
+	case "warn":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	if o.jsonLog {
+		return slog.New(slog.NewJSONHandler(os.Stderr, handlerOpts))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, handlerOpts))
+}
+
+func main() {
+	opts := &options{}
+
+	kingpin.Flag("json-log", "Output log in JSON").BoolVar(&opts.jsonLog)
+	kingpin.Flag("level", "Log level: debug, info, warn, error.").Default("info").EnumVar(&opts.logLevel, "debug", "info", "warn", "error")
+	kingpin.Flag("bind", "Address to serve the admission webhook on").Default(":8443").StringVar(&opts.bind)
+	kingpin.Flag("cert", "Webhook TLS certificate path").Required().ExistingFileVar(&opts.certFile)
+	kingpin.Flag("key", "Webhook TLS private key path").Required().ExistingFileVar(&opts.keyFile)
+	kingpin.Flag("role-base-arn", "Base ARN for roles, enables the /mutate webhook patching role names with their ARN. e.g. arn:aws:iam::123456789:role/").StringVar(&opts.roleBaseARN)
+	kingpin.Flag("role-base-arn-autodetect", "Use EC2 metadata service to detect ARN prefix.").BoolVar(&opts.autoDetectBaseARN)
+	kingpin.Parse()
+
+	if opts.jsonLog {
+		log.SetFormatter(&log.JSONFormatter{})
+	}
+	level, _ := log.ParseLevel(opts.logLevel)
+	log.SetLevel(level)
+
+	if opts.autoDetectBaseARN {
+		prefix, err := sts.DetectARNPrefix()
+		if err != nil {
+			log.Fatalf("error detecting arn prefix: %s", err)
+		}
+		opts.roleBaseARN = prefix
+	}
+
+	logger := opts.logger()
+	mux := http.NewServeMux()
+	mux.Handle("/validate", k8s.NewRoleAnnotationValidator(logger))
+	if opts.roleBaseARN != "" {
+		mux.Handle("/mutate", k8s.NewRoleAnnotationMutator(sts.DefaultResolver(opts.roleBaseARN), logger))
+	}
+	mux.HandleFunc("/ping", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("pong")) })
+
+	server := &http.Server{Addr: opts.bind, Handler: mux}
+	go func() {
+		log.Infof("serving admission webhook on %s", opts.bind)
+		if err := server.ListenAndServeTLS(opts.certFile, opts.keyFile); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("error serving admission webhook: %s", err)
+		}
+	}()
+
+	stopChan := make(chan os.Signal, 1)
+	signal.Notify(stopChan, os.Interrupt, syscall.SIGTERM)
+	<-stopChan
+	log.Infof("stopping admission webhook")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+}
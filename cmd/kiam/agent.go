@@ -35,6 +35,7 @@ type agentCommand struct {
 	iptablesRemove bool
 	hostIP         string
 	hostInterface  string
+	retryPolicy    kiamserver.AgentRetryPolicy
 }
 
 func (cmd *agentCommand) Bind(parser parser) {
@@ -48,6 +49,12 @@ func (cmd *agentCommand) Bind(parser parser) {
 	parser.Flag("port", "HTTP port").Default("3100").IntVar(&cmd.ListenPort)
 	parser.Flag("allow-ip-query", "Allow client IP to be specified with ?ip. Development use only.").Default("false").BoolVar(&cmd.AllowIPQuery)
 	parser.Flag("allow-route-regexp", "Only routes matching this regular expression will be proxied").Default("^$").RegexpVar(&cmd.AllowRouteRegexp)
+	parser.Flag("require-imdsv2", "Reject credentials requests without an IMDSv2 session token. Clients must be able to request tokens, e.g. allow the api/token route with --allow-route-regexp.").Default("false").BoolVar(&cmd.RequireIMDSv2)
+	parser.Flag("gzip", "Compress responses with gzip for clients that accept it.").Default("false").BoolVar(&cmd.Gzip)
+	parser.Flag("gzip-min-size", "Responses smaller than this many bytes are not compressed.").Default("1024").IntVar(&cmd.GzipMinSize)
+
+	parser.Flag("server-retry-interval", "Initial interval between retries of calls failing while the server is unavailable.").Default("50ms").DurationVar(&cmd.retryPolicy.RetryInterval)
+	parser.Flag("server-max-retry-duration", "Maximum time spent retrying calls failing while the server is unavailable. Disabled if 0.").Default("5s").DurationVar(&cmd.retryPolicy.MaxRetryDuration)
 
 	parser.Flag("iptables", "Add IPTables rules").Default("false").BoolVar(&cmd.iptables)
 	parser.Flag("iptables-remove", "Remove iptables rules at shutdown").Default("true").BoolVar(&cmd.iptablesRemove)
@@ -86,7 +93,10 @@ func (opts *agentCommand) run() error {
 	ctxGateway, cancelCtxGateway := context.WithTimeout(context.Background(), opts.timeoutKiamGateway)
 	defer cancelCtxGateway()
 
-	b := kiamserver.NewKiamGatewayBuilder().WithAddress(opts.serverAddress).WithKeepAlive(opts.keepaliveParams)
+	b := kiamserver.NewKiamGatewayBuilder().WithLogger(opts.logger()).WithAddress(opts.serverAddress).WithKeepAlive(opts.keepaliveParams)
+	if opts.retryPolicy.MaxRetryDuration > 0 {
+		b.WithRetryPolicy(opts.retryPolicy)
+	}
 	_, err := b.WithTLS(opts.certificatePath, opts.keyPath, opts.caPath)
 	if err != nil {
 		log.Errorf("error configuring TLS: ", err.Error())
@@ -43,7 +43,7 @@ func (cmd *healthCommand) Run() {
 	ctxGateway, cancelCtxGateway := context.WithTimeout(context.Background(), cmd.timeoutKiamGateway)
 	defer cancelCtxGateway()
 
-	b, err := kiamserver.NewKiamGatewayBuilder().WithAddress(cmd.serverAddress).WithKeepAlive(cmd.keepaliveParams).WithTLS(cmd.certificatePath, cmd.keyPath, cmd.caPath)
+	b, err := kiamserver.NewKiamGatewayBuilder().WithLogger(cmd.logger()).WithAddress(cmd.serverAddress).WithKeepAlive(cmd.keepaliveParams).WithTLS(cmd.certificatePath, cmd.keyPath, cmd.caPath)
 	if err != nil {
 		log.Fatalf("error creating server gateway: %s", err.Error())
 	}
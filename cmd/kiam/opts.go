@@ -15,6 +15,8 @@ package main
 
 import (
 	"context"
+	"log/slog"
+	"os"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -33,6 +35,9 @@ func (o *logOptions) bind(parser parser) {
 	parser.Flag("level", "Log level: debug, info, warn, error.").Default("info").EnumVar(&o.logLevel, "debug", "info", "warn", "error")
 }
 
+// configureLogger configures the logrus logger still used by some packages.
+//
+// Deprecated: logrus logging is being replaced by log/slog, use logger.
 func (o *logOptions) configureLogger() {
 	if o.jsonLog {
 		log.SetFormatter(&log.JSONFormatter{})
@@ -50,6 +55,28 @@ func (o *logOptions) configureLogger() {
 	}
 }
 
+// logger creates the structured logger passed to components
+func (o *logOptions) logger() *slog.Logger {
+	handlerOpts := &slog.HandlerOptions{Level: o.level()}
+	if o.jsonLog {
+		return slog.New(slog.NewJSONHandler(os.Stderr, handlerOpts))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, handlerOpts))
+}
+
+func (o *logOptions) level() slog.Level {
+	switch o.logLevel {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
 type telemetryOptions struct {
 	prometheusListen string
 	prometheusSync   time.Duration
@@ -52,13 +52,85 @@ func (o *serverOptions) bind(parser parser) {
 	parser.Flag("kubeconfig", "Path to .kube/config (or empty for in-cluster)").Default("").StringVar(&o.KubeConfig)
 	parser.Flag("sync", "Pod cache sync interval").Default("1m").DurationVar(&o.PodSyncInterval)
 	parser.Flag("role-base-arn", "Base ARN for roles. e.g. arn:aws:iam::123456789:role/").StringVar(&o.RoleBaseARN)
+	parser.Flag("role-account-alias", "Account alias and ID (alias=123456789012) used to resolve alias/role-name roles across multiple accounts. Replaces role-base-arn when set. Can be repeated.").StringMapVar(&o.RoleAccountAliases)
+	parser.Flag("role-partition", "AWS partition of roles resolved with role-account-alias.").Default("aws").StringVar(&o.RolePartition)
+	parser.Flag("role-region-override", "Region substituted into the ARNs of resolved roles, correcting annotations naming the wrong region. Disabled if empty.").Default("").StringVar(&o.RoleRegionOverride)
+	parser.Flag("role-ssm-parameter-prefix", "SSM Parameter Store path containing a parameter for each role name with its ARN, e.g. /kiam/roles reads /kiam/roles/<role>. Disabled if empty.").Default("").StringVar(&o.RoleSSMParameterPrefix)
+	parser.Flag("role-ssm-cache-ttl", "How long roles read from SSM are cached.").Default("5m").DurationVar(&o.RoleSSMCacheTTL)
+	parser.Flag("annotation-kms-key-id", "KMS key decrypting role annotations encrypted with it, prefixed with kms:v1: and base64 encoded. Disabled if empty.").Default("").StringVar(&o.AnnotationKMSKeyID)
+	parser.Flag("require-encrypted-annotations", "Only resolve role annotations encrypted with annotation-kms-key-id.").Default("false").BoolVar(&o.RequireEncryptedAnnotations)
+	parser.Flag("credentials-per-namespace", "Maximum number of distinct roles whose credentials are cached for each namespace, evicting the least recently requested. Unlimited if 0.").Default("0").IntVar(&o.CredentialsPerNamespace)
 	parser.Flag("role-base-arn-autodetect", "Use EC2 metadata service to detect ARN prefix.").BoolVar(&o.AutoDetectBaseARN)
 	parser.Flag("disable-strict-namespace-regexp", "Disable default strict namespace regexp when matching roles.").BoolVar(&o.DisableStrictNamespaceRegexp)
+	parser.Flag("cluster-role-permissions", "Also permit roles with ClusterRolePermission resources selecting namespaces by label. Requires the ClusterRolePermission CRD.").Default("false").BoolVar(&o.ClusterRolePermissions)
+	parser.Flag("namespace-regexp-delimiter", "Character separating multiple regexps in the namespace permitted annotation.").Default("|").StringVar(&o.NamespaceRegexpDelimiter)
+	parser.Flag("namespace-permitted-wildcard", "Permit pods to assume any role when their namespace's permitted annotation is exactly *. Anyone able to annotate a namespace can then grant its pods every role the server can assume.").Default("false").BoolVar(&o.PermitNamespaceWildcard)
 	parser.Flag("session", "Session name used when creating STS Tokens.").Default("kiam").StringVar(&o.SessionName)
 	parser.Flag("session-duration", "Requested session duration for STS Tokens.").Default("15m").DurationVar(&o.SessionDuration)
 	parser.Flag("session-refresh", "How soon STS Tokens should be refreshed before their expiration.").Default("5m").DurationVar(&o.SessionRefresh)
+	parser.Flag("session-refresh-jitter", "Longest random time STS Tokens are refreshed earlier than session-refresh, spreading out refreshes of tokens issued together.").Default("0s").DurationVar(&o.SessionRefreshJitter)
+	parser.Flag("annotate-assumed-role", "Annotate pods with the ARN of the role session assumed for them (iam.amazonaws.com/assumed-role-arn). Requires permission to patch pods.").Default("false").BoolVar(&o.AnnotateAssumedRole)
+	parser.Flag("prewarm-threshold", "Refresh cached credentials in the background once this fraction of the time they are cached for (session-duration less session-refresh) remains before they are evicted, e.g. 0.2 refreshes default credentials 8m after they are issued. Disabled if 0.").Default("0").Float64Var(&o.PrewarmThreshold)
+	parser.Flag("prewarm-interval", "How often cached credentials are checked for prewarming.").Default("30s").DurationVar(&o.PrewarmInterval)
 	parser.Flag("assume-role-arn", "IAM Role to assume before processing requests").Default("").StringVar(&o.AssumeRoleArn)
+	parser.Flag("web-identity-token-file", "Web identity token file (e.g. from IAM roles for service accounts) used to issue the server's own credentials by assuming web-identity-role-arn. Disabled if empty.").Default("").Envar("AWS_WEB_IDENTITY_TOKEN_FILE").StringVar(&o.WebIdentityTokenFile)
+	parser.Flag("web-identity-role-arn", "IAM Role assumed with the web identity token.").Default("").Envar("AWS_ROLE_ARN").StringVar(&o.WebIdentityRoleARN)
 	parser.Flag("region", "AWS Region to use for regional STS calls (e.g. us-west-2). Defaults to the global endpoint.").Default("").StringVar(&o.Region)
+	parser.Flag("sts-regional-endpoint", "STS endpoint URL for a region, as region=url. Credentials are requested from the endpoint of --region, retrying failed calls with the global endpoint. Can be repeated.").StringMapVar(&o.STSRegionalEndpoints)
+	parser.Flag("sts-retry-base-delay", "Delay before retrying assume role calls failing with transient errors, such as throttling, which backs off exponentially with jitter.").Default("100ms").DurationVar(&o.STSRetryBaseDelay)
+	parser.Flag("sts-retry-max-delay", "Maximum delay between retries of assume role calls, retries stop at the request deadline. Disabled if 0.").Default("5s").DurationVar(&o.STSRetryMaxDelay)
+	parser.Flag("assume-role-rate-limit", "Maximum assume role requests per second for each Pod. 0 disables rate limiting.").Default("0").Float64Var(&o.AssumeRoleRateLimit)
+	parser.Flag("assume-role-rate-burst", "Maximum burst of assume role requests for each Pod when rate limited.").Default("10").IntVar(&o.AssumeRoleRateBurst)
+	parser.Flag("assume-role-rate-adaptive", "Halve the assume role rate limit when STS throttles requests, gradually increasing it back to the maximum set by --assume-role-rate-limit.").Default("false").BoolVar(&o.AssumeRoleRateAdaptive)
+	parser.Flag("role-change-limit", "Maximum number of times a Pod can change its role annotation within --role-change-window before its requests are forbidden. 0 disables the limit.").Default("0").IntVar(&o.RoleChangeLimit)
+	parser.Flag("role-change-window", "Sliding window in which role annotation changes are counted for --role-change-limit.").Default("10m").DurationVar(&o.RoleChangeWindow)
+	parser.Flag("role-history-depth", "Number of role annotations remembered for each Pod, must be greater than --role-change-limit.").Default("10").IntVar(&o.RoleHistoryDepth)
+	parser.Flag("per-pod-sessions", "Assume roles in a separate session for each pod, named by the pod UID, rather than sharing credentials between pods with the same role. Credentials are cached per pod, increasing the number of calls to STS.").Default("false").BoolVar(&o.PerPodSessions)
+	parser.Flag("enable-grpc-reflection", "Register gRPC server reflection, letting tools like grpcurl discover the server's methods. Exposes the service schema to any client that can connect.").Default("false").BoolVar(&o.EnableGRPCReflection)
+	parser.Flag("policy-webhook-url", "URL of a webhook that must also permit assume role requests. Disabled if empty.").Default("").StringVar(&o.PolicyWebhook.URL)
+	parser.Flag("policy-webhook-timeout", "Timeout for each request to the policy webhook.").Default("1s").DurationVar(&o.PolicyWebhook.Timeout)
+	parser.Flag("policy-webhook-retry-interval", "Initial interval between retries of failed policy webhook requests.").Default("50ms").DurationVar(&o.PolicyWebhook.RetryInterval)
+	parser.Flag("policy-webhook-max-retry-duration", "Maximum time spent retrying failed policy webhook requests.").Default("2s").DurationVar(&o.PolicyWebhook.MaxRetryDuration)
+	parser.Flag("policy-webhook-cert", "Client certificate path presented to the policy webhook").Default("").StringVar(&o.PolicyWebhook.ClientCert)
+	parser.Flag("policy-webhook-key", "Client key path presented to the policy webhook").Default("").StringVar(&o.PolicyWebhook.ClientKey)
+	parser.Flag("policy-webhook-ca", "CA certificate path used to verify the policy webhook").Default("").StringVar(&o.PolicyWebhook.CA)
+	parser.Flag("role-chain-max-depth", "Maximum number of roles, including the requested role, in a pod's role chain. Intermediate roles must be permitted by the namespace. Disabled if 0.").Default("0").IntVar(&o.MaxRoleChainDepth)
+	parser.Flag("role-annotation-prefix", "Forbid pods declaring roles with annotations (keys ending /role) outside of this prefix. e.g. iam.amazonaws.com/. Disabled if empty.").Default("").StringVar(&o.RoleAnnotationPrefix)
+	parser.Flag("role-inheritance-depth", "Pods without a role annotation inherit the role annotated on their closest owner (e.g. Deployment or CronJob), walking at most this many owners. Disabled if 0.").Default("0").IntVar(&o.RoleInheritanceDepth)
+	parser.Flag("oidc-jwks-uri", "URI of the JSON Web Key Set used to verify the projected service account tokens pods present in the X-Kiam-Service-Account-Token header. Disabled if empty.").Default("").StringVar(&o.OIDC.JWKSURI)
+	parser.Flag("oidc-issuer", "Expected issuer of service account tokens. Not checked if empty.").Default("").StringVar(&o.OIDC.Issuer)
+	parser.Flag("oidc-audience", "Expected audience of service account tokens. Not checked if empty.").Default("sts.amazonaws.com").StringVar(&o.OIDC.Audience)
+	parser.Flag("oidc-jwks-timeout", "Timeout fetching the JSON Web Key Set.").Default("5s").DurationVar(&o.OIDC.Timeout)
+	parser.Flag("oidc-allow-missing-token", "Allow pods that don't present a service account token. Tokens that are presented are still verified.").Default("false").BoolVar(&o.OIDC.AllowMissingToken)
+	parser.Flag("namespace-policy-breaker-failures", "Consecutive namespace policy errors (such as failing namespace lookups) before the circuit breaker opens. Disabled if 0.").Default("0").Uint32Var(&o.NamespacePolicyBreaker.ConsecutiveFailures)
+	parser.Flag("namespace-policy-breaker-timeout", "Time the namespace policy circuit breaker stays open before retrying.").Default("30s").DurationVar(&o.NamespacePolicyBreaker.OpenTimeout)
+	parser.Flag("namespace-policy-breaker-fail-open", "Allow requests while the namespace policy circuit breaker is open, rather than forbidding them.").Default("false").BoolVar(&o.NamespacePolicyBreaker.FailOpen)
+	parser.Flag("allow-list-configmap", "ConfigMap (namespace/name) listing the namespace/role pairs permitted to be assumed. Disabled if empty.").Default("").StringVar(&o.AllowListConfigMap)
+	parser.Flag("service-account-configmap", "ConfigMap (namespace/name) mapping each namespace's service accounts to the role regexps they may assume. Disabled if empty.").Default("").StringVar(&o.ServiceAccountConfigMap)
+	parser.Flag("allowed-account", "AWS account ID in which roles may be assumed. Can be repeated. Disabled if not set.").StringsVar(&o.AllowedAccounts)
+	parser.Flag("allowed-partition", "AWS partition (e.g. aws-us-gov) in which roles may be assumed. Disabled if empty.").Default("").StringVar(&o.AllowedPartition)
+	parser.Flag("allowed-accounts-configmap", "ConfigMap (namespace/name) listing the AWS account IDs in which roles may be assumed, reloaded when changed. Replaces allowed-account when set. Disabled if empty.").Default("").StringVar(&o.AllowedAccountsConfigMap)
+	parser.Flag("arn-aliases-configmap", "ConfigMap (namespace/name) mapping short role aliases to role ARNs, usable in pod and namespace annotations instead of full ARNs. Disabled if empty.").Default("").StringVar(&o.ARNAliasesConfigMap)
+	parser.Flag("node-selector", "Label selector (e.g. node-group=secure) of the nodes pods must run on to assume the node-selector-role roles.").Default("").StringVar(&o.NodeSelector)
+	parser.Flag("node-selector-role", "Role ARN pattern (supporting * and ? wildcards) that can only be assumed by pods on nodes matching node-selector. Can be repeated. Disabled if not set.").StringsVar(&o.NodeSelectorRoles)
+	parser.Flag("non-root-role", "Role ARN pattern (supporting * and ? wildcards) that can't be assumed by pods with containers running as root (UID 0). Can be repeated. Disabled if not set.").StringsVar(&o.NonRootRoles)
+	parser.Flag("revoke-on-annotation-change", "Remove cached credentials as soon as their pod's IAM annotations change or the pod is deleted.").Default("false").BoolVar(&o.RevokeOnAnnotationChange)
+	parser.Flag("min-credential-ttl", "Forbid requests while the cached credentials for the role expire within this duration, so callers retry once fresh credentials are fetched. Disabled if 0.").Default("0").DurationVar(&o.MinCredentialTTL)
+	parser.Flag("deny-completed-pods", "Forbid pods that have succeeded or failed, or can no longer be found, from assuming roles.").Default("false").BoolVar(&o.DenyCompletedPods)
+	parser.Flag("termination-grace-period", "Forbid terminating pods from assuming roles once they have been terminating for longer than the grace period. Disabled if 0.").Default("0s").DurationVar(&o.TerminationGracePeriod)
+	parser.Flag("deny-list-file", "File listing role ARN patterns (supporting * and ? wildcards) that can never be assumed. Disabled if empty.").Default("").StringVar(&o.DenyListFile)
+	parser.Flag("policy-file", "YAML file listing the policies checked before issuing credentials, replacing the policies configured by other flags. The file is validated against server.PolicyFileSchema. Disabled if empty.").Default("").StringVar(&o.PolicyFile)
+	parser.Flag("deny-list-watch", "Reload the deny list file when it changes.").Default("false").BoolVar(&o.DenyListWatch)
+	parser.Flag("policy-timeout", "Maximum time each policy may take to decide, after which the request is forbidden. Disabled if 0.").Default("0").DurationVar(&o.PolicyTimeout)
+	parser.Flag("policy-timeout-fail-open", "Return an error when a policy times out, rather than forbidding the request.").Default("false").BoolVar(&o.PolicyTimeoutFailOpen)
+	parser.Flag("credential-stream-listen-addr", "Address to serve Server-Sent Events at /credentials/stream, pushing a pod's credentials to agents when they are issued or refreshed. Subscriptions are checked against the assume role policy like credential requests. Clients must present a certificate signed by the server CA. Disabled if empty.").Default("").StringVar(&o.CredentialStreamAddress)
+	parser.Flag("policy-dry-run", "Name of a policy (e.g. ExternalWebhookAssumeRolePolicy) that only logs the requests it would deny, without blocking them. Can be repeated.").StringsVar(&o.DryRunPolicies)
+	parser.Flag("policy-health-listen-addr", "HTTP listen address serving the health of policies at /healthz, of STS, the Kubernetes API and policies at /healthz/subsystems, and policy configuration at /debug/policies. e.g. localhost:9621. Disabled if empty.").Default("").StringVar(&o.PolicyHealthAddress)
+	parser.Flag("decision-log-size", "Number of recent policy decisions kept in memory and served at /debug/decisions by the policy health server, filtered with the namespace and pod query parameters. Disabled if 0.").Default("0").IntVar(&o.DecisionLogSize)
+	parser.Flag("policy-audit-log", "File to append a JSON record of every policy decision to, one per line, or - for stdout. Disabled if empty.").Default("").StringVar(&o.PolicyAuditLog)
+	parser.Flag("namespace-prewarm-timeout", "Snapshot all namespaces before serving requests, waiting at most this long. Snapshots are used by namespace policies when finding a namespace fails. Disabled if 0.").Default("0").DurationVar(&o.NamespacePrewarmTimeout)
+	parser.Flag("namespace-access-review-user", "Get namespaces from the API server, when a SubjectAccessReview permits this user (e.g. system:serviceaccount:kube-system:kiam-server) to get them, rather than from the namespace cache. Requires permission to create subjectaccessreviews. Disabled if empty.").Default("").StringVar(&o.NamespaceAccessReviewUser)
+	parser.Flag("preload-role", "Role to fetch credentials for before serving requests, so the first pods using it don't wait for STS. Can be repeated.").StringsVar(&o.PreloadRoles)
 	parser.Flag("grpc-keepalive-time-duration", "gRPC keepalive time").Default("10s").DurationVar(&o.KeepaliveParams.Time)
 	parser.Flag("grpc-keepalive-timeout-duration", "gRPC keepalive timeout").Default("2s").DurationVar(&o.KeepaliveParams.Timeout)
 	parser.Flag("grpc-max-connection-idle-duration", "gRPC max connection idle").Default("15m").DurationVar(&o.KeepaliveParams.MaxConnectionIdle)
@@ -69,8 +141,8 @@ func (o *serverOptions) bind(parser parser) {
 func (cmd *serverCommand) Run() {
 	cmd.configureLogger()
 
-	if !cmd.AutoDetectBaseARN && cmd.RoleBaseARN == "" {
-		log.Fatal("role-base-arn not specified and not auto-detected. please specify or use --role-base-arn-autodetect")
+	if !cmd.AutoDetectBaseARN && cmd.RoleBaseARN == "" && len(cmd.RoleAccountAliases) == 0 {
+		log.Fatal("role-base-arn not specified and not auto-detected. please specify, use --role-base-arn-autodetect or --role-account-alias")
 	}
 
 	if cmd.SessionDuration < sts.AWSMinSessionDuration {
@@ -88,7 +160,7 @@ func (cmd *serverCommand) Run() {
 
 	cmd.Config.TLS = serv.TLSConfig{ServerCert: cmd.certificatePath, ServerKey: cmd.keyPath, CA: cmd.caPath}
 
-	serverBuilder := serv.NewKiamServerBuilder(&cmd.Config)
+	serverBuilder := serv.NewKiamServerBuilder(&cmd.Config).WithLogger(cmd.logger())
 	_, err := serverBuilder.WithAWSSTSGateway()
 	if err != nil {
 		log.Fatal("error using AWS STS Gateway: ", err.Error())
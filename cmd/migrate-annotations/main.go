@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/uswitch/k8sc/official"
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	"github.com/uswitch/kiam/pkg/server"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type options struct {
+	jsonLog           bool
+	logLevel          string
+	kubeConfig        string
+	roleBaseARN       string
+	autoDetectBaseARN bool
+	delimiter         string
+	dryRun            bool
+}
+
+func main() {
+	opts := &options{}
+
+	kingpin.Flag("json-log", "Output log in JSON").BoolVar(&opts.jsonLog)
+	kingpin.Flag("level", "Log level: debug, info, warn, error.").Default("info").EnumVar(&opts.logLevel, "debug", "info", "warn", "error")
+	kingpin.Flag("kubeconfig", "Path to .kube/config (or empty for in-cluster)").Default("").StringVar(&opts.kubeConfig)
+	kingpin.Flag("role-base-arn", "Base ARN for roles. e.g. arn:aws:iam::123456789:role/").StringVar(&opts.roleBaseARN)
+	kingpin.Flag("role-base-arn-autodetect", "Use EC2 metadata service to detect ARN prefix.").BoolVar(&opts.autoDetectBaseARN)
+	kingpin.Flag("namespace-regexp-delimiter", "Character separating multiple regexps in the namespace permitted annotation.").Default("|").StringVar(&opts.delimiter)
+	kingpin.Flag("dry-run", "Log the annotations that would be rewritten without patching namespaces.").BoolVar(&opts.dryRun)
+	kingpin.Parse()
+
+	if opts.jsonLog {
+		log.SetFormatter(&log.JSONFormatter{})
+	}
+	level, _ := log.ParseLevel(opts.logLevel)
+	log.SetLevel(level)
+
+	delimiter, size := utf8.DecodeRuneInString(opts.delimiter)
+	if size == 0 || size != len(opts.delimiter) {
+		log.Fatalf("namespace regexp delimiter must be a single character, was '%s'", opts.delimiter)
+	}
+
+	if opts.autoDetectBaseARN {
+		prefix, err := sts.DetectARNPrefix()
+		if err != nil {
+			log.Fatalf("error detecting arn prefix: %s", err)
+		}
+		opts.roleBaseARN = prefix
+	}
+	if opts.roleBaseARN == "" {
+		log.Fatal("role-base-arn not specified and not auto-detected. please specify or use --role-base-arn-autodetect")
+	}
+
+	client, err := official.NewClient(opts.kubeConfig)
+	if err != nil {
+		log.Fatalf("error creating kubernetes client: %s", err)
+	}
+
+	migration := server.NewNamespaceAnnotationMigration(sts.DefaultResolver(opts.roleBaseARN), delimiter)
+
+	namespaces, err := client.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		log.Fatalf("error listing namespaces: %s", err)
+	}
+
+	failed := 0
+	for _, namespace := range namespaces.Items {
+		logger := log.WithField("namespace", namespace.GetName())
+
+		annotation := namespace.GetAnnotations()[k8s.AnnotationPermittedKey]
+		if annotation == "" {
+			continue
+		}
+
+		migrated, err := migration.Migrate(annotation)
+		if err != nil {
+			logger.Errorf("error migrating annotation '%s': %s", annotation, err)
+			failed++
+			continue
+		}
+		if migrated == annotation {
+			logger.Debugf("annotation '%s' already matches arns", annotation)
+			continue
+		}
+
+		logger = logger.WithField("annotation.current", annotation).WithField("annotation.migrated", migrated)
+		if opts.dryRun {
+			logger.Infof("would rewrite annotation (dry run)")
+			continue
+		}
+
+		patch, _ := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]string{k8s.AnnotationPermittedKey: migrated},
+			},
+		})
+		if _, err := client.CoreV1().Namespaces().Patch(namespace.GetName(), types.StrategicMergePatchType, patch); err != nil {
+			logger.Errorf("error patching namespace: %s", err)
+			failed++
+			continue
+		}
+		logger.Infof("rewrote annotation")
+	}
+
+	if failed > 0 {
+		log.Fatalf("failed to migrate %d namespaces", failed)
+	}
+}
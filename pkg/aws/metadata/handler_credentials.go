@@ -49,6 +49,7 @@ func (c *credentialsHandler) Handle(ctx context.Context, w http.ResponseWriter,
 	}
 
 	requestedRole := mux.Vars(req)["role"]
+	ctx = server.WithServiceAccountToken(ctx, req.Header.Get(server.ServiceAccountTokenHeader))
 	credentials, err := c.fetchCredentials(ctx, ip, requestedRole)
 	if err != nil {
 		credentialFetchError.WithLabelValues("credentials").Inc()
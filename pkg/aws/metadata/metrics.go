@@ -86,6 +86,15 @@ var (
 			Help:      "Number of access requests to the proxy handler that were blocked by the regexp",
 		},
 	)
+
+	imdsv1Rejected = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "kiam",
+			Subsystem: "metadata",
+			Name:      "imdsv1_requests_rejected_total",
+			Help:      "Number of credentials requests rejected for not including an IMDSv2 token",
+		},
+	)
 )
 
 func init() {
@@ -97,4 +106,5 @@ func init() {
 	prometheus.MustRegister(success)
 	prometheus.MustRegister(responses)
 	prometheus.MustRegister(proxyDenies)
+	prometheus.MustRegister(imdsv1Rejected)
 }
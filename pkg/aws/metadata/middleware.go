@@ -0,0 +1,155 @@
+package metadata
+
+import (
+	"compress/gzip"
+	"net/http"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// MetadataServerMiddleware wraps the handler serving metadata requests
+type MetadataServerMiddleware func(http.Handler) http.Handler
+
+// metadataTokenHeader is the header IMDSv2 clients send with the session token
+// they've requested from the metadata API
+const metadataTokenHeader = "X-aws-ec2-metadata-token"
+
+var securityCredentialsPath = regexp.MustCompile("^/[^/]+/meta-data/iam/security-credentials(/|$)")
+
+// RequireIMDSv2 rejects IMDSv1 requests for security credentials, those
+// without a session token, with 401 Unauthorized. IMDSv1 requests are simple
+// GETs and can be made by exploiting SSRF vulnerabilities, the token must be
+// requested with a PUT (which the metadata API serves, via the proxy) first.
+func RequireIMDSv2(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if securityCredentialsPath.MatchString(req.URL.Path) && req.Header.Get(metadataTokenHeader) == "" {
+			log.WithFields(requestFields(req)).Warn("rejected request without metadata token")
+			imdsv1Rejected.Inc()
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// Gzip compresses responses with gzip for clients that accept it. Responses
+// smaller than minSize bytes, and responses that are already encoded, are
+// written uncompressed.
+func Gzip(minSize int) MetadataServerMiddleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(req) || req.Method == http.MethodHead {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize, status: http.StatusOK}
+			defer gw.Close()
+			next.ServeHTTP(gw, req)
+		})
+	}
+}
+
+func acceptsGzip(req *http.Request) bool {
+	for _, value := range req.Header.Values("Accept-Encoding") {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.TrimSpace(encoding)
+			if i := strings.Index(encoding, ";"); i >= 0 {
+				if strings.TrimSpace(encoding[i+1:]) == "q=0" {
+					continue
+				}
+				encoding = strings.TrimSpace(encoding[:i])
+			}
+			if encoding == "gzip" || encoding == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter buffers the response until minSize bytes have been
+// written, deciding whether to compress it. Close must be called to write
+// buffered responses.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize int
+	status  int
+
+	started bool
+	buf     []byte
+	gz      *gzip.Writer
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if !w.started {
+		w.status = status
+	}
+}
+
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if w.started {
+		if w.gz != nil {
+			return w.gz.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.minSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// start writes the header and buffered response, compressing it when
+// compress is true and the response can be compressed
+func (w *gzipResponseWriter) start(compress bool) error {
+	w.started = true
+
+	header := w.Header()
+	if compress && header.Get("Content-Encoding") == "" && w.status != http.StatusNoContent && w.status != http.StatusNotModified {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if w.gz != nil {
+		_, err := w.gz.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// Flush writes the response so far, responses that haven't reached minSize
+// are written uncompressed.
+func (w *gzipResponseWriter) Flush() {
+	if !w.started {
+		w.start(false)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *gzipResponseWriter) Close() error {
+	if !w.started {
+		return w.start(false)
+	}
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package metadata
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireIMDSv2(t *testing.T) {
+	var tests = []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		expected int
+	}{
+		{"CredentialsWithToken", http.MethodGet, "/latest/meta-data/iam/security-credentials/role", "token", http.StatusOK},
+		{"CredentialsWithoutToken", http.MethodGet, "/latest/meta-data/iam/security-credentials/role", "", http.StatusUnauthorized},
+		{"RoleNameWithToken", http.MethodGet, "/latest/meta-data/iam/security-credentials/", "token", http.StatusOK},
+		{"RoleNameWithoutToken", http.MethodGet, "/latest/meta-data/iam/security-credentials/", "", http.StatusUnauthorized},
+		{"RoleNameWithoutSlashWithoutToken", http.MethodGet, "/latest/meta-data/iam/security-credentials", "", http.StatusUnauthorized},
+		{"VersionedWithoutToken", http.MethodGet, "/2016-09-02/meta-data/iam/security-credentials/role", "", http.StatusUnauthorized},
+		{"TokenRequest", http.MethodPut, "/latest/api/token", "", http.StatusOK},
+		{"OtherMetadataWithoutToken", http.MethodGet, "/latest/meta-data/instance-id", "", http.StatusOK},
+		{"SimilarPathWithoutToken", http.MethodGet, "/latest/meta-data/iam/security-credentials-other", "", http.StatusOK},
+		{"Ping", http.MethodGet, "/ping", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			r, _ := http.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				r.Header.Set("X-aws-ec2-metadata-token", tt.token)
+			}
+			rr := httptest.NewRecorder()
+			RequireIMDSv2(next).ServeHTTP(rr, r)
+
+			if rr.Code != tt.expected {
+				t.Errorf("expected status %d, was %d", tt.expected, rr.Code)
+			}
+		})
+	}
+}
+
+func TestGzip(t *testing.T) {
+	large := strings.Repeat(`{"Code":"Success"}`, 100)
+
+	var tests = []struct {
+		name           string
+		acceptEncoding string
+		body           string
+		encoding       string
+		compressed     bool
+	}{
+		{"Large", "gzip", large, "", true},
+		{"LargeWithQuality", "deflate, gzip;q=0.8", large, "", true},
+		{"Small", "gzip", `{"Code":"Success"}`, "", false},
+		{"NotAccepted", "", large, "", false},
+		{"Refused", "gzip;q=0", large, "", false},
+		{"AlreadyEncoded", "gzip", large, "br", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				// write in parts, crossing the minimum size
+				w.Write([]byte(tt.body[:len(tt.body)/2]))
+				w.Write([]byte(tt.body[len(tt.body)/2:]))
+			})
+
+			r, _ := http.NewRequest(http.MethodGet, "/latest/meta-data/iam/security-credentials/role", nil)
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rr := httptest.NewRecorder()
+			Gzip(1024)(next).ServeHTTP(rr, r)
+
+			if rr.Code != http.StatusCreated {
+				t.Errorf("expected status %d, was %d", http.StatusCreated, rr.Code)
+			}
+
+			body := rr.Body.String()
+			if tt.compressed {
+				if encoding := rr.Header().Get("Content-Encoding"); encoding != "gzip" {
+					t.Fatal("expected gzip encoding, was", encoding)
+				}
+				reader, err := gzip.NewReader(rr.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				decompressed, _ := ioutil.ReadAll(reader)
+				body = string(decompressed)
+			} else if encoding := rr.Header().Get("Content-Encoding"); encoding != tt.encoding {
+				t.Error("expected response not to be compressed, encoding was", encoding)
+			}
+
+			if body != tt.body {
+				t.Error("unexpected body", body)
+			}
+		})
+	}
+}
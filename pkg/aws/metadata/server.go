@@ -38,6 +38,11 @@ type ServerOptions struct {
 	MetadataEndpoint string
 	AllowIPQuery     bool
 	AllowRouteRegexp *regexp.Regexp
+	RequireIMDSv2    bool
+	// Gzip compresses responses of at least GzipMinSize bytes for clients
+	// accepting gzip
+	Gzip        bool
+	GzipMinSize int
 }
 
 func DefaultOptions() *ServerOptions {
@@ -46,6 +51,7 @@ func DefaultOptions() *ServerOptions {
 		ListenPort:       3100,
 		AllowIPQuery:     false,
 		AllowRouteRegexp: regexp.MustCompile("^$"),
+		GzipMinSize:      1024,
 	}
 }
 
@@ -78,8 +84,16 @@ func buildHTTPServer(config *ServerOptions, client server.Client) (*http.Server,
 	p := newProxyHandler(httputil.NewSingleHostReverseProxy(metadataURL), config.AllowRouteRegexp)
 	p.Install(router)
 
+	var handler http.Handler = router
+	if config.RequireIMDSv2 {
+		handler = RequireIMDSv2(handler)
+	}
+	if config.Gzip {
+		handler = Gzip(config.GzipMinSize)(handler)
+	}
+
 	listen := fmt.Sprintf(":%d", config.ListenPort)
-	return &http.Server{Addr: listen, Handler: loggingHandler(router)}, nil
+	return &http.Server{Addr: listen, Handler: loggingHandler(handler)}, nil
 }
 
 func buildClientIP(config *ServerOptions) clientIPFunc {
@@ -0,0 +1,96 @@
+package sts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ARN is an Amazon Resource Name, e.g. arn:aws:iam::123456789012:role/name
+type ARN struct {
+	Partition string
+	Service   string
+	Region    string
+	AccountID string
+	Resource  string
+}
+
+// ParseARN parses s into its components. The partition, service and resource
+// are required, the resource may contain colons.
+func ParseARN(s string) (ARN, error) {
+	parts := strings.SplitN(s, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" {
+		return ARN{}, fmt.Errorf("invalid arn, expected arn:partition:service:region:account-id:resource, was: %s", s)
+	}
+
+	arn := ARN{
+		Partition: parts[1],
+		Service:   parts[2],
+		Region:    parts[3],
+		AccountID: parts[4],
+		Resource:  parts[5],
+	}
+	if arn.Partition == "" || arn.Service == "" || arn.Resource == "" {
+		return ARN{}, fmt.Errorf("invalid arn, partition, service and resource are required, was: %s", s)
+	}
+
+	return arn, nil
+}
+
+func (a ARN) String() string {
+	return fmt.Sprintf("arn:%s:%s:%s:%s:%s", a.Partition, a.Service, a.Region, a.AccountID, a.Resource)
+}
+
+// MustParseARN parses s, panicking if it isn't a valid ARN. For ARNs known to
+// be valid, such as constants in tests.
+func MustParseARN(s string) ARN {
+	arn, err := ParseARN(s)
+	if err != nil {
+		panic(err)
+	}
+	return arn
+}
+
+// MarshalText encodes the ARN as its string form, so that it's logged and
+// encoded as JSON as a string rather than by its components.
+func (a ARN) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// Equals returns whether the ARNs identify the same resource
+func (a ARN) Equals(other ARN) bool {
+	return a == other
+}
+
+// IsWildcardMatch returns whether the ARN matches pattern, an ARN where *
+// matches any sequence of characters and ? any single character.
+func (a ARN) IsWildcardMatch(pattern string) bool {
+	return wildcardMatch(pattern, a.String())
+}
+
+// wildcardMatch matches s against pattern, backtracking to the most recent *
+// when characters don't match.
+func wildcardMatch(pattern, s string) bool {
+	p, i := 0, 0
+	star, match := -1, 0
+
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, match = p, i
+			p++
+		case star >= 0:
+			match++
+			p, i = star+1, match
+		default:
+			return false
+		}
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
@@ -22,9 +22,11 @@ type Resolver struct {
 	prefix string
 }
 
+// ResolvedRole is a role and its parsed ARN. Use ARN.String() for logging and
+// on the wire.
 type ResolvedRole struct {
 	Name string
-	ARN  string
+	ARN  ARN
 }
 
 // DefaultResolver will add the prefix to any roles which
@@ -40,22 +42,31 @@ func (r *Resolver) Resolve(role string) (*ResolvedRole, error) {
 	}
 
 	if strings.HasPrefix(role, "arn:") {
-		return &ResolvedRole{ARN: role, Name: roleFromArn(role)}, nil
+		return resolveARN(role)
 	}
 
 	if strings.HasPrefix(role, "/") {
 		role = strings.TrimPrefix(role, "/")
 	}
 
-	return &ResolvedRole{ARN: fmt.Sprintf("%s%s", r.prefix, role), Name: role}, nil
+	arn, err := ParseARN(r.prefix + role)
+	if err != nil {
+		return nil, fmt.Errorf("role %s doesn't resolve to a valid arn with base arn '%s': %v", role, r.prefix, err)
+	}
+	return &ResolvedRole{ARN: arn, Name: role}, nil
 }
 
+// resolveARN validates an absolute role arn, e.g.
 // arn:aws:iam::account-id:role/role-name-with-path
-func roleFromArn(arn string) string {
-	splits := strings.SplitAfterN(arn, ":", 6)
-	return strings.TrimPrefix(splits[5], "role/")
+func resolveARN(role string) (*ResolvedRole, error) {
+	arn, err := ParseARN(role)
+	if err != nil {
+		return nil, err
+	}
+	return &ResolvedRole{ARN: arn, Name: strings.TrimPrefix(arn.Resource, "role/")}, nil
 }
 
+// Equals returns whether the roles have the same ARN
 func (i *ResolvedRole) Equals(other *ResolvedRole) bool {
-	return *i == *other
+	return i.ARN.Equals(other.ARN)
 }
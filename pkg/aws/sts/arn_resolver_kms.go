@@ -0,0 +1,97 @@
+package sts
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// KMSCiphertextPrefix prefixes role annotations containing the base64
+// encoded ciphertext of the role, encrypted with a KMS key.
+const KMSCiphertextPrefix = "kms:v1:"
+
+// DefaultKMSCacheSize is the number of decrypted roles cached by
+// KMSAnnotationDecryptingResolver.
+const DefaultKMSCacheSize = 1024
+
+// KMSAnnotationDecryptingResolver decrypts roles encrypted with a KMS key
+// before resolving them with another resolver, so that annotations can't be
+// changed to name another role without access to the key. Encrypted roles are
+// prefixed with KMSCiphertextPrefix. When encryption is required roles that
+// aren't encrypted aren't resolved. Decrypted roles are cached by the hash of
+// their ciphertext to avoid repeated KMS calls.
+type KMSAnnotationDecryptingResolver struct {
+	resolver ARNResolver
+	kms      kmsiface.KMSAPI
+	keyID    string
+	required bool
+	cache    *lru.Cache
+}
+
+// NewKMSClient creates a KMS client for region, the default region if empty.
+func NewKMSClient(region string) (kmsiface.KMSAPI, error) {
+	config := aws.NewConfig()
+	if region != "" {
+		config.WithRegion(region)
+	}
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, err
+	}
+	return kms.New(sess), nil
+}
+
+// NewKMSAnnotationDecryptingResolver creates the resolver decrypting roles
+// with the KMS key keyID.
+func NewKMSAnnotationDecryptingResolver(resolver ARNResolver, client kmsiface.KMSAPI, keyID string, required bool) *KMSAnnotationDecryptingResolver {
+	cache, _ := lru.New(DefaultKMSCacheSize)
+	return &KMSAnnotationDecryptingResolver{resolver: resolver, kms: client, keyID: keyID, required: required, cache: cache}
+}
+
+// Resolve decrypts role, when encrypted, and resolves it with the wrapped
+// resolver.
+func (r *KMSAnnotationDecryptingResolver) Resolve(role string) (*ResolvedRole, error) {
+	if !strings.HasPrefix(role, KMSCiphertextPrefix) {
+		if r.required && role != "" {
+			return nil, fmt.Errorf("role must be encrypted with kms key %s", r.keyID)
+		}
+		return r.resolver.Resolve(role)
+	}
+
+	decrypted, err := r.decrypt(strings.TrimPrefix(role, KMSCiphertextPrefix))
+	if err != nil {
+		return nil, err
+	}
+	return r.resolver.Resolve(decrypted)
+}
+
+func (r *KMSAnnotationDecryptingResolver) decrypt(encoded string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted role: %w", err)
+	}
+
+	key := sha256.Sum256(ciphertext)
+	if plaintext, ok := r.cache.Get(key); ok {
+		return plaintext.(string), nil
+	}
+
+	resp, err := r.kms.Decrypt(&kms.DecryptInput{
+		CiphertextBlob: ciphertext,
+		KeyId:          aws.String(r.keyID),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error decrypting role: %w", err)
+	}
+
+	plaintext := string(resp.Plaintext)
+	r.cache.Add(key, plaintext)
+	return plaintext, nil
+}
@@ -0,0 +1,80 @@
+package sts
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// stubKMS decrypts ciphertexts by looking them up
+type stubKMS struct {
+	kmsiface.KMSAPI
+	plaintexts map[string]string
+	calls      int
+	keyID      string
+}
+
+func (s *stubKMS) Decrypt(in *kms.DecryptInput) (*kms.DecryptOutput, error) {
+	s.calls++
+	s.keyID = aws.StringValue(in.KeyId)
+	plaintext, ok := s.plaintexts[string(in.CiphertextBlob)]
+	if !ok {
+		return nil, errors.New("InvalidCiphertextException")
+	}
+	return &kms.DecryptOutput{Plaintext: []byte(plaintext)}, nil
+}
+
+func encrypted(ciphertext string) string {
+	return KMSCiphertextPrefix + base64.StdEncoding.EncodeToString([]byte(ciphertext))
+}
+
+func TestKMSAnnotationDecryptingResolver(t *testing.T) {
+	client := &stubKMS{plaintexts: map[string]string{"ciphertext": "myrole"}}
+	resolver := NewKMSAnnotationDecryptingResolver(DefaultResolver("arn:aws:iam::123456789012:role/"), client, "alias/kiam", false)
+
+	for i := 0; i < 2; i++ {
+		resolved, err := resolver.Resolve(encrypted("ciphertext"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resolved.ARN.String() != "arn:aws:iam::123456789012:role/myrole" {
+			t.Error("unexpected arn, was:", resolved.ARN)
+		}
+	}
+	if client.calls != 1 {
+		t.Error("expected decrypted role to be cached, kms calls were", client.calls)
+	}
+	if client.keyID != "alias/kiam" {
+		t.Error("unexpected key id", client.keyID)
+	}
+
+	resolved, err := resolver.Resolve("plainrole")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.ARN.String() != "arn:aws:iam::123456789012:role/plainrole" {
+		t.Error("unexpected arn, was:", resolved.ARN)
+	}
+
+	for _, role := range []string{encrypted("tampered"), KMSCiphertextPrefix + "not base64!"} {
+		if _, err := resolver.Resolve(role); err == nil {
+			t.Errorf("expected error resolving %s", role)
+		}
+	}
+}
+
+func TestKMSAnnotationDecryptingResolverRequiresEncryption(t *testing.T) {
+	client := &stubKMS{plaintexts: map[string]string{"ciphertext": "myrole"}}
+	resolver := NewKMSAnnotationDecryptingResolver(DefaultResolver("arn:aws:iam::123456789012:role/"), client, "alias/kiam", true)
+
+	if _, err := resolver.Resolve("plainrole"); err == nil {
+		t.Error("expected error resolving unencrypted role")
+	}
+	if _, err := resolver.Resolve(encrypted("ciphertext")); err != nil {
+		t.Error("unexpected error", err)
+	}
+}
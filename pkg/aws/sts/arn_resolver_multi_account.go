@@ -0,0 +1,45 @@
+package sts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MultiAccountARNResolver resolves roles across several AWS accounts. Roles are
+// named with an account alias prefix, alias/role-name, and resolved into the ARN
+// of role-name in the aliased account.
+type MultiAccountARNResolver struct {
+	accounts  map[string]string
+	partition string
+}
+
+// NewMultiAccountARNResolver creates a resolver for the accounts, a map of alias to
+// account ID, in the partition (e.g. aws, aws-cn).
+func NewMultiAccountARNResolver(accounts map[string]string, partition string) ARNResolver {
+	return &MultiAccountARNResolver{accounts: accounts, partition: partition}
+}
+
+// Resolve converts from an alias/role-name string into the absolute role arn.
+// Absolute role arns are returned unchanged.
+func (r *MultiAccountARNResolver) Resolve(role string) (*ResolvedRole, error) {
+	if role == "" {
+		return nil, fmt.Errorf("role can't be empty")
+	}
+
+	if strings.HasPrefix(role, "arn:") {
+		return resolveARN(role)
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(role, "/"), "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("role should be alias/role-name, was: %s", role)
+	}
+
+	accountID, ok := r.accounts[parts[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown account alias: %s", parts[0])
+	}
+
+	arn := ARN{Partition: r.partition, Service: "iam", AccountID: accountID, Resource: "role/" + parts[1]}
+	return &ResolvedRole{ARN: arn, Name: parts[1]}, nil
+}
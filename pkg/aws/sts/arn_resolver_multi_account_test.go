@@ -0,0 +1,53 @@
+package sts
+
+import (
+	"testing"
+)
+
+func TestMultiAccountResolver(t *testing.T) {
+	resolver := NewMultiAccountARNResolver(map[string]string{"prod": "123456789012", "dev": "210987654321"}, "aws")
+
+	var tests = []struct {
+		role         string
+		expectedARN  string
+		expectedName string
+	}{
+		{"prod/myrole", "arn:aws:iam::123456789012:role/myrole", "myrole"},
+		{"/dev/myrole", "arn:aws:iam::210987654321:role/myrole", "myrole"},
+		{"prod/path/myrole", "arn:aws:iam::123456789012:role/path/myrole", "path/myrole"},
+		{"arn:aws:iam::555555555555:role/myrole", "arn:aws:iam::555555555555:role/myrole", "myrole"},
+	}
+
+	for _, tt := range tests {
+		resolved, err := resolver.Resolve(tt.role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resolved.ARN.String() != tt.expectedARN {
+			t.Error("unexpected arn, was:", resolved.ARN)
+		}
+		if resolved.Name != tt.expectedName {
+			t.Error("unexpected name, was:", resolved.Name)
+		}
+	}
+}
+
+func TestMultiAccountResolverEquality(t *testing.T) {
+	resolver := NewMultiAccountARNResolver(map[string]string{"prod": "123456789012"}, "aws")
+	aliased, _ := resolver.Resolve("prod/myrole")
+	absolute, _ := resolver.Resolve("arn:aws:iam::123456789012:role/myrole")
+
+	if !aliased.Equals(absolute) {
+		t.Error("expected aliased and absolute roles to be equal")
+	}
+}
+
+func TestMultiAccountResolverErrors(t *testing.T) {
+	resolver := NewMultiAccountARNResolver(map[string]string{"prod": "123456789012"}, "aws-cn")
+
+	for _, role := range []string{"", "myrole", "prod/", "staging/myrole"} {
+		if _, err := resolver.Resolve(role); err == nil {
+			t.Errorf("expected error resolving '%s'", role)
+		}
+	}
+}
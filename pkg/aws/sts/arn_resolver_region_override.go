@@ -0,0 +1,29 @@
+package sts
+
+// RegionOverrideARNResolver replaces the region of the ARNs resolved by another
+// resolver with a canonical region, correcting annotations naming the wrong
+// region. The other components of the ARN are unchanged. An empty region
+// strips the region, as in IAM role ARNs.
+type RegionOverrideARNResolver struct {
+	resolver ARNResolver
+	region   string
+}
+
+// NewRegionOverrideARNResolver wraps resolver, overriding the region of
+// resolved ARNs with region.
+func NewRegionOverrideARNResolver(resolver ARNResolver, region string) *RegionOverrideARNResolver {
+	return &RegionOverrideARNResolver{resolver: resolver, region: region}
+}
+
+// Resolve resolves role with the wrapped resolver and overrides its region.
+// Resolved roles that aren't valid ARNs are returned unchanged.
+func (r *RegionOverrideARNResolver) Resolve(role string) (*ResolvedRole, error) {
+	resolved, err := r.resolver.Resolve(role)
+	if err != nil {
+		return nil, err
+	}
+
+	arn := resolved.ARN
+	arn.Region = r.region
+	return &ResolvedRole{ARN: arn, Name: resolved.Name}, nil
+}
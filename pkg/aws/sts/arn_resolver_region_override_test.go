@@ -0,0 +1,57 @@
+package sts
+
+import (
+	"testing"
+)
+
+func TestRegionOverrideResolver(t *testing.T) {
+	var tests = []struct {
+		name         string
+		region       string
+		role         string
+		expectedARN  string
+		expectedName string
+	}{
+		{"WithoutRegion", "eu-west-1", "myrole", "arn:aws:iam:eu-west-1:123456789012:role/myrole", "myrole"},
+		{"WrongRegion", "eu-west-1", "arn:aws:iam:us-east-1:123456789012:role/path/myrole", "arn:aws:iam:eu-west-1:123456789012:role/path/myrole", "path/myrole"},
+		{"CanonicalRegion", "eu-west-1", "arn:aws:iam:eu-west-1:123456789012:role/myrole", "arn:aws:iam:eu-west-1:123456789012:role/myrole", "myrole"},
+		{"StripRegion", "", "arn:aws:iam:us-east-1:123456789012:role/my:role", "arn:aws:iam::123456789012:role/my:role", "my:role"},
+		{"StripWithoutRegion", "", "myrole", "arn:aws:iam::123456789012:role/myrole", "myrole"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := NewRegionOverrideARNResolver(DefaultResolver("arn:aws:iam::123456789012:role/"), tt.region)
+			resolved, err := resolver.Resolve(tt.role)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resolved.ARN.String() != tt.expectedARN {
+				t.Error("unexpected arn, was:", resolved.ARN)
+			}
+			if resolved.Name != tt.expectedName {
+				t.Error("unexpected name, was:", resolved.Name)
+			}
+
+			// resolving the overridden arn again leaves it unchanged
+			again, err := resolver.Resolve(resolved.ARN.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !again.ARN.Equals(resolved.ARN) {
+				t.Error("expected round trip to be unchanged, was:", again.ARN)
+			}
+		})
+	}
+}
+
+func TestRegionOverrideResolverInvalidARN(t *testing.T) {
+	resolver := NewRegionOverrideARNResolver(DefaultResolver(""), "eu-west-1")
+	if _, err := resolver.Resolve("myrole"); err == nil {
+		t.Error("expected error resolving role without a base arn")
+	}
+
+	if _, err := resolver.Resolve(""); err == nil {
+		t.Error("expected error resolving empty role")
+	}
+}
@@ -0,0 +1,127 @@
+package sts
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// DefaultSSMCacheTTL is how long roles resolved from SSM are cached by
+// default.
+const DefaultSSMCacheTTL = 5 * time.Minute
+
+// SSMResolverOption configures the resolver created by SSMARNResolver
+type SSMResolverOption func(*ssmARNResolver)
+
+// WithSSMCacheTTL sets how long resolved roles are cached, roles aren't
+// cached if ttl is 0.
+func WithSSMCacheTTL(ttl time.Duration) SSMResolverOption {
+	return func(r *ssmARNResolver) {
+		r.ttl = ttl
+	}
+}
+
+type ssmARNResolver struct {
+	client ssmiface.SSMAPI
+	prefix string
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]ssmCachedRole
+}
+
+type ssmCachedRole struct {
+	role    *ResolvedRole
+	expires time.Time
+}
+
+// NewSSMClient creates an SSM client for region, the default region if empty.
+func NewSSMClient(region string) (ssmiface.SSMAPI, error) {
+	config := aws.NewConfig()
+	if region != "" {
+		config.WithRegion(region)
+	}
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, err
+	}
+	return ssm.New(sess), nil
+}
+
+// SSMARNResolver resolves role names to the ARNs stored in SSM Parameter
+// Store, reading the parameter {paramPrefix}/{name}. Roles that are already
+// ARNs are resolved without reading SSM. Resolved roles are cached for
+// DefaultSSMCacheTTL unless configured with WithSSMCacheTTL; errors aren't
+// cached.
+func SSMARNResolver(client ssmiface.SSMAPI, paramPrefix string, opts ...SSMResolverOption) ARNResolver {
+	r := &ssmARNResolver{
+		client: client,
+		prefix: strings.TrimSuffix(paramPrefix, "/"),
+		ttl:    DefaultSSMCacheTTL,
+		now:    time.Now,
+		cache:  map[string]ssmCachedRole{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *ssmARNResolver) Resolve(role string) (*ResolvedRole, error) {
+	if role == "" {
+		return nil, fmt.Errorf("role can't be empty")
+	}
+
+	if strings.HasPrefix(role, "arn:") {
+		return resolveARN(role)
+	}
+
+	name := strings.TrimPrefix(role, "/")
+	if cached, ok := r.cached(name); ok {
+		return cached, nil
+	}
+
+	parameter := fmt.Sprintf("%s/%s", r.prefix, name)
+	resp, err := r.client.GetParameter(&ssm.GetParameterInput{Name: aws.String(parameter)})
+	if err != nil {
+		return nil, fmt.Errorf("error reading role parameter %s: %w", parameter, err)
+	}
+	if resp.Parameter == nil || aws.StringValue(resp.Parameter.Value) == "" {
+		return nil, fmt.Errorf("role parameter %s is empty", parameter)
+	}
+
+	resolved, err := resolveARN(aws.StringValue(resp.Parameter.Value))
+	if err != nil {
+		return nil, fmt.Errorf("role parameter %s is invalid: %w", parameter, err)
+	}
+
+	if r.ttl > 0 {
+		r.mu.Lock()
+		r.cache[name] = ssmCachedRole{role: resolved, expires: r.now().Add(r.ttl)}
+		r.mu.Unlock()
+	}
+
+	return resolved, nil
+}
+
+func (r *ssmARNResolver) cached(name string) (*ResolvedRole, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cached, ok := r.cache[name]
+	if !ok {
+		return nil, false
+	}
+	if !r.now().Before(cached.expires) {
+		delete(r.cache, name)
+		return nil, false
+	}
+	return cached.role, true
+}
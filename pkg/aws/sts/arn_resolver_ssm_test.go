@@ -0,0 +1,109 @@
+package sts
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// stubSSM returns parameters by looking them up
+type stubSSM struct {
+	ssmiface.SSMAPI
+	parameters map[string]string
+	calls      int
+}
+
+func (s *stubSSM) GetParameter(in *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
+	s.calls++
+	value, ok := s.parameters[aws.StringValue(in.Name)]
+	if !ok {
+		return nil, errors.New("ParameterNotFound")
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Name: in.Name, Value: aws.String(value)}}, nil
+}
+
+func TestSSMARNResolver(t *testing.T) {
+	client := &stubSSM{parameters: map[string]string{"/kiam/roles/reader": "arn:aws:iam::123456789012:role/team/reader"}}
+	resolver := SSMARNResolver(client, "/kiam/roles/")
+
+	for _, role := range []string{"reader", "/reader"} {
+		resolved, err := resolver.Resolve(role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resolved.ARN.String() != "arn:aws:iam::123456789012:role/team/reader" {
+			t.Error("unexpected arn, was:", resolved.ARN)
+		}
+		if resolved.Name != "team/reader" {
+			t.Error("unexpected name, was:", resolved.Name)
+		}
+	}
+	if client.calls != 1 {
+		t.Error("expected resolved role to be cached, ssm calls were", client.calls)
+	}
+
+	resolved, err := resolver.Resolve("arn:aws:iam::123456789012:role/writer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.ARN.String() != "arn:aws:iam::123456789012:role/writer" || client.calls != 1 {
+		t.Error("expected arn to be resolved without ssm, was:", resolved.ARN)
+	}
+}
+
+func TestSSMARNResolverExpiresCache(t *testing.T) {
+	client := &stubSSM{parameters: map[string]string{"/kiam/reader": "arn:aws:iam::123456789012:role/reader"}}
+	resolver := SSMARNResolver(client, "/kiam", WithSSMCacheTTL(time.Minute)).(*ssmARNResolver)
+	now := time.Now()
+	resolver.now = func() time.Time { return now }
+
+	resolver.Resolve("reader")
+	resolver.Resolve("reader")
+	if client.calls != 1 {
+		t.Fatal("expected cached role, ssm calls were", client.calls)
+	}
+
+	now = now.Add(time.Minute)
+	resolver.Resolve("reader")
+	if client.calls != 2 {
+		t.Error("expected expired role to be read again, ssm calls were", client.calls)
+	}
+}
+
+func TestSSMARNResolverErrors(t *testing.T) {
+	client := &stubSSM{parameters: map[string]string{
+		"/kiam/empty":   "",
+		"/kiam/invalid": "not-an-arn",
+	}}
+	resolver := SSMARNResolver(client, "/kiam")
+
+	var tests = []struct {
+		role  string
+		error string
+	}{
+		{"", "role can't be empty"},
+		{"missing", "error reading role parameter /kiam/missing: ParameterNotFound"},
+		{"empty", "role parameter /kiam/empty is empty"},
+		{"invalid", "role parameter /kiam/invalid is invalid"},
+	}
+
+	for _, tt := range tests {
+		resolved, err := resolver.Resolve(tt.role)
+		if err == nil || !strings.Contains(err.Error(), tt.error) {
+			t.Errorf("%s: expected error containing '%s', was: %v", tt.role, tt.error, err)
+		}
+		if resolved != nil {
+			t.Errorf("%s: expected no role, was: %v", tt.role, resolved)
+		}
+	}
+
+	client.parameters["/kiam/missing"] = "arn:aws:iam::123456789012:role/missing"
+	if _, err := resolver.Resolve("missing"); err != nil {
+		t.Error("expected errors not to be cached, was:", err)
+	}
+}
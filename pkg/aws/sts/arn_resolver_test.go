@@ -36,7 +36,7 @@ func TestAddsPrefix(t *testing.T) {
 	resolver := DefaultResolver("arn:aws:iam::account-id:role/")
 	resolvedRole, _ := resolver.Resolve("myrole")
 
-	if resolvedRole.ARN != "arn:aws:iam::account-id:role/myrole" {
+	if resolvedRole.ARN.String() != "arn:aws:iam::account-id:role/myrole" {
 		t.Error("unexpected role, was:", resolvedRole.ARN)
 	}
 }
@@ -54,7 +54,7 @@ func TestAddsPrefixWithRoleBeginningWithSlash(t *testing.T) {
 	resolver := DefaultResolver("arn:aws:iam::account-id:role/")
 	resolvedRole, _ := resolver.Resolve("/myrole")
 
-	if resolvedRole.ARN != "arn:aws:iam::account-id:role/myrole" {
+	if resolvedRole.ARN.String() != "arn:aws:iam::account-id:role/myrole" {
 		t.Error("unexpected role, was:", resolvedRole.ARN)
 	}
 
@@ -66,7 +66,7 @@ func TestAddsPrefixWithRoleBeginningWithPathWithoutSlash(t *testing.T) {
 	resolver := DefaultResolver("arn:aws:iam::account-id:role/")
 	resolvedRole, _ := resolver.Resolve("kiam/myrole")
 
-	if resolvedRole.ARN != "arn:aws:iam::account-id:role/kiam/myrole" {
+	if resolvedRole.ARN.String() != "arn:aws:iam::account-id:role/kiam/myrole" {
 		t.Error("unexpected role, was:", resolvedRole.ARN)
 	}
 
@@ -78,7 +78,7 @@ func TestAddsPrefixWithRoleBeginningWithSlashPath(t *testing.T) {
 	resolver := DefaultResolver("arn:aws:iam::account-id:role/")
 	resolvedRole, _ := resolver.Resolve("/kiam/myrole")
 
-	if resolvedRole.ARN != "arn:aws:iam::account-id:role/kiam/myrole" {
+	if resolvedRole.ARN.String() != "arn:aws:iam::account-id:role/kiam/myrole" {
 		t.Error("unexpected role, was:", resolvedRole.ARN)
 	}
 }
@@ -87,7 +87,7 @@ func TestUsesAbsoluteARN(t *testing.T) {
 	resolver := DefaultResolver("arn:aws:iam::account-id:role/")
 	resolvedRole, _ := resolver.Resolve("arn:aws:iam::some-other-account:role/path-prefix/another-role")
 
-	if resolvedRole.ARN != "arn:aws:iam::some-other-account:role/path-prefix/another-role" {
+	if resolvedRole.ARN.String() != "arn:aws:iam::some-other-account:role/path-prefix/another-role" {
 		t.Error("unexpected role, was:", resolvedRole.ARN)
 	}
 
@@ -107,3 +107,27 @@ func TestExtractsBaseFromInstanceArn(t *testing.T) {
 		t.Error("unexpected prefix, was: ", prefix)
 	}
 }
+
+func TestReturnsErrorForInvalidARN(t *testing.T) {
+	resolver := DefaultResolver("arn:aws:iam::account-id:role/")
+	_, err := resolver.Resolve("arn:aws:iam")
+
+	if err == nil {
+		t.Error("should've returned an error for invalid arn")
+	}
+}
+
+func TestResolvedRoleEqualityComparesARNs(t *testing.T) {
+	resolver := DefaultResolver("arn:aws:iam::123456789012:role/")
+	i1, _ := resolver.Resolve("foo")
+	i2, _ := resolver.Resolve("arn:aws:iam::123456789012:role/foo")
+	i3, _ := DefaultResolver("arn:aws-cn:iam::123456789012:role/").Resolve("foo")
+
+	if !i1.Equals(i2) {
+		t.Error("expected role name and arn to be equal")
+	}
+
+	if i1.Equals(i3) {
+		t.Error("unexpected equality across partitions")
+	}
+}
@@ -0,0 +1,65 @@
+package sts
+
+import (
+	"testing"
+)
+
+func TestParseARN(t *testing.T) {
+	var tests = []struct {
+		name     string
+		arn      string
+		expected ARN
+		err      bool
+	}{
+		{"Role", "arn:aws:iam::123456789012:role/name", ARN{Partition: "aws", Service: "iam", AccountID: "123456789012", Resource: "role/name"}, false},
+		{"RoleWithPath", "arn:aws-cn:iam::123456789012:role/path/name", ARN{Partition: "aws-cn", Service: "iam", AccountID: "123456789012", Resource: "role/path/name"}, false},
+		{"RegionalResourceWithColons", "arn:aws:logs:eu-west-1:123456789012:log-group:name:*", ARN{Partition: "aws", Service: "logs", Region: "eu-west-1", AccountID: "123456789012", Resource: "log-group:name:*"}, false},
+		{"NotAnARN", "role/name", ARN{}, true},
+		{"TooFewComponents", "arn:aws:iam::123456789012", ARN{}, true},
+		{"WrongPrefix", "urn:aws:iam::123456789012:role/name", ARN{}, true},
+		{"MissingService", "arn:aws:::123456789012:role/name", ARN{}, true},
+		{"MissingResource", "arn:aws:iam::123456789012:", ARN{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			arn, err := ParseARN(tt.arn)
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error to be %t, was %v", tt.err, err)
+			}
+			if !arn.Equals(tt.expected) {
+				t.Errorf("expected %+v, was %+v", tt.expected, arn)
+			}
+			if err == nil && arn.String() != tt.arn {
+				t.Errorf("expected string %s, was %s", tt.arn, arn.String())
+			}
+		})
+	}
+}
+
+func TestARNIsWildcardMatch(t *testing.T) {
+	arn, _ := ParseARN("arn:aws:iam::123456789012:role/admin-role")
+
+	var tests = []struct {
+		pattern  string
+		expected bool
+	}{
+		{"arn:aws:iam::123456789012:role/admin-role", true},
+		{"arn:aws:iam::123456789012:role/admin*", true},
+		{"arn:aws:iam::*:role/admin-role", true},
+		{"arn:aws:iam::*:role/*", true},
+		{"*", true},
+		{"arn:aws:iam::123456789012:role/admin-rol?", true},
+		{"arn:aws:iam::123456789012:role/*-role", true},
+		{"arn:aws:iam::123456789012:role/admin", false},
+		{"arn:aws:iam::210987654321:role/*", false},
+		{"arn:aws:iam::123456789012:role/admin-role?", false},
+		{"arn:aws:iam::123456789012:role/*-user", false},
+	}
+
+	for _, tt := range tests {
+		if arn.IsWildcardMatch(tt.pattern) != tt.expected {
+			t.Errorf("expected %s match to be %t", tt.pattern, tt.expected)
+		}
+	}
+}
@@ -16,7 +16,11 @@ package sts
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/patrickmn/go-cache"
@@ -25,6 +29,9 @@ import (
 )
 
 type credentialsCache struct {
+	// counters are first so they're 64-bit aligned for atomic access
+	counters cacheCounters
+
 	arnResolver     ARNResolver
 	baseARN         string
 	cache           *cache.Cache
@@ -33,22 +40,74 @@ type credentialsCache struct {
 	sessionDuration time.Duration
 	cacheTTL        time.Duration
 	gateway         STSGateway
+	clock           func() time.Time
+	// jitter is the longest the cache ttl is randomly shortened by, spreading
+	// out refreshes of credentials issued together
+	jitter time.Duration
+	random func() float64
+	// backend stores issued credentials, allowing them to be shared with
+	// other servers
+	backend CredentialStoreBackend
+	// listeners are notified of credentials issued by the gateway
+	listeners []CredentialsListener
+
+	// mu ensures only one request issues credentials for an identity that
+	// isn't cached, and protects refreshing
+	mu         sync.Mutex
+	refreshing map[string]bool
+
+	// silenced holds the keys being evicted without announcing them as
+	// expiring
+	silencedMu sync.Mutex
+	silenced   map[string]bool
 }
 
 type CachedCredentials struct {
 	Identity    *RoleIdentity
 	Credentials *Credentials
+	// AssumedRoleUser is the session the credentials were issued for, when
+	// reported by the STSGateway
+	AssumedRoleUser *AssumedRoleUser
 }
 
 const (
 	DefaultPurgeInterval = 1 * time.Minute
+
+	// minCacheTTL is the shortest credentials are cached for when jitter is
+	// applied
+	minCacheTTL = 1 * time.Second
 )
 
+// CacheOption configures the credentials cache created by DefaultCache
+type CacheOption func(*credentialsCache)
+
+// WithRefreshJitter randomly shortens how long credentials are cached by up to
+// jitter, so that credentials issued together (e.g. when pods start after a
+// cluster restart) aren't refreshed together.
+func WithRefreshJitter(jitter time.Duration) CacheOption {
+	return func(c *credentialsCache) {
+		c.jitter = jitter
+	}
+}
+
+// CredentialsListener is notified when credentials are issued, either
+// initially or when they're refreshed
+type CredentialsListener func(*CachedCredentials)
+
+// WithCredentialsListener notifies listener of credentials issued by the cache.
+// Listeners are called synchronously and must not block.
+func WithCredentialsListener(listener CredentialsListener) CacheOption {
+	return func(c *credentialsCache) {
+		c.listeners = append(c.listeners, listener)
+	}
+}
+
 func DefaultCache(
 	gateway STSGateway,
 	sessionName string,
 	sessionDuration time.Duration,
 	sessionRefresh time.Duration,
+	options ...CacheOption,
 ) *credentialsCache {
 	c := &credentialsCache{
 		expiring:        make(chan *CachedCredentials, 1),
@@ -56,6 +115,14 @@ func DefaultCache(
 		sessionDuration: sessionDuration,
 		cacheTTL:        sessionDuration - sessionRefresh,
 		gateway:         gateway,
+		clock:           time.Now,
+		refreshing:      map[string]bool{},
+		silenced:        map[string]bool{},
+		backend:         NewMemoryCredentialStore(),
+		random:          rand.Float64,
+	}
+	for _, option := range options {
+		option(c)
 	}
 	c.cache = cache.New(c.cacheTTL, DefaultPurgeInterval)
 	c.cache.OnEvicted(c.evicted)
@@ -65,6 +132,16 @@ func DefaultCache(
 
 func (c *credentialsCache) evicted(key string, item interface{}) {
 	cacheSize.Dec()
+	cacheEvictions.Inc()
+	atomic.AddUint64(&c.counters.evictions, 1)
+
+	c.silencedMu.Lock()
+	silenced := c.silenced[key]
+	delete(c.silenced, key)
+	c.silencedMu.Unlock()
+	if silenced {
+		return
+	}
 
 	f := item.(*future.Future)
 	obj, err := f.Get(context.Background())
@@ -84,6 +161,14 @@ func (c *credentialsCache) evicted(key string, item interface{}) {
 	}
 }
 
+// SetBackend sets the backend issued credentials are stored in. Credentials
+// found in the backend are used, rather than issued, while they have longer
+// than the refresh period before they expire. It must be set before
+// credentials are requested.
+func (c *credentialsCache) SetBackend(backend CredentialStoreBackend) {
+	c.backend = backend
+}
+
 func (c *credentialsCache) Expiring() chan *CachedCredentials {
 	return c.expiring
 }
@@ -92,7 +177,27 @@ func (c *credentialsCache) Expiring() chan *CachedCredentials {
 // must have their ARN set.
 func (c *credentialsCache) CredentialsForRole(ctx context.Context, identity *RoleIdentity) (*Credentials, error) {
 	logger := log.WithFields(identity.LogFields())
+
 	item, found := c.cache.Get(identity.String())
+	if !found {
+		// the backend may be remote, so it's checked without holding the lock.
+		// The cache is checked again in case another request set it meanwhile.
+		stored, remaining, storedFound := c.stored(identity)
+
+		c.mu.Lock()
+		item, found = c.cache.Get(identity.String())
+		if !found {
+			ttl := c.ttl()
+			if storedFound {
+				item, ttl, found = future.New(func() (interface{}, error) { return stored, nil }), remaining, true
+			} else {
+				item = future.New(c.issue(ctx, identity))
+			}
+			c.cache.Set(identity.String(), item, ttl)
+			cacheSize.Inc()
+		}
+		c.mu.Unlock()
+	}
 
 	if found {
 		future, _ := item.(*future.Future)
@@ -105,24 +210,188 @@ func (c *credentialsCache) CredentialsForRole(ctx context.Context, identity *Rol
 		}
 
 		cacheHit.Inc()
+		atomic.AddUint64(&c.counters.hits, 1)
 
 		cachedCreds := val.(*CachedCredentials)
 		return cachedCreds.Credentials, nil
 	}
 
 	cacheMiss.Inc()
+	atomic.AddUint64(&c.counters.misses, 1)
+
+	val, err := item.(*future.Future).Get(ctx)
+	if err != nil {
+		c.cache.Delete(identity.String())
+		return nil, err
+	}
+
+	cachedCreds := val.(*CachedCredentials)
+	return cachedCreds.Credentials, nil
+}
+
+// ttl returns how long issued credentials are cached for before they're
+// refreshed, shortened by a random jitter
+func (c *credentialsCache) ttl() time.Duration {
+	if c.jitter <= 0 {
+		return c.cacheTTL
+	}
+
+	ttl := c.cacheTTL - time.Duration(float64(c.jitter)*c.random())
+	if ttl < minCacheTTL {
+		return minCacheTTL
+	}
+	return ttl
+}
 
-	issue := func() (interface{}, error) {
+// stored returns the credentials for identity held by the backend, along with
+// how long they can be cached before they should be refreshed
+func (c *credentialsCache) stored(identity *RoleIdentity) (*CachedCredentials, time.Duration, bool) {
+	credentials, found := c.backend.Get(identity.String())
+	if !found {
+		return nil, 0, false
+	}
+
+	expiration, err := time.Parse(timeLayout, credentials.Expiration)
+	if err != nil {
+		return nil, 0, false
+	}
+	remaining := expiration.Sub(c.clock()) - (c.sessionDuration - c.cacheTTL)
+	if remaining <= 0 {
+		return nil, 0, false
+	}
+
+	return &CachedCredentials{Identity: identity, Credentials: credentials}, remaining, true
+}
+
+// Expiration returns the expiration of the credentials cached for identity
+func (c *credentialsCache) Expiration(identity *RoleIdentity) (time.Time, bool) {
+	cached, found := c.cached(identity)
+	if !found {
+		return time.Time{}, false
+	}
+
+	expiration, err := time.Parse(timeLayout, cached.Credentials.Expiration)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return expiration, true
+}
+
+// AssumedRoleUser returns the session the credentials cached for identity were
+// issued for, and false when none are cached or the session wasn't reported
+func (c *credentialsCache) AssumedRoleUser(identity *RoleIdentity) (*AssumedRoleUser, bool) {
+	cached, found := c.cached(identity)
+	if !found || cached.AssumedRoleUser == nil {
+		return nil, false
+	}
+	return cached.AssumedRoleUser, true
+}
+
+// cached returns the credentials cached for identity, when they've been issued
+func (c *credentialsCache) cached(identity *RoleIdentity) (*CachedCredentials, bool) {
+	item, found := c.cache.Get(identity.String())
+	if !found {
+		return nil, false
+	}
+
+	f := item.(*future.Future)
+	select {
+	case <-f.Done():
+	default:
+		return nil, false
+	}
+
+	val, err := f.Get(context.Background())
+	if err != nil {
+		return nil, false
+	}
+	return val.(*CachedCredentials), true
+}
+
+// CachedIdentities returns the identities with issued credentials cached for
+// the role ARN, such as those for different session names
+func (c *credentialsCache) CachedIdentities(roleARN ARN) []*RoleIdentity {
+	identities := []*RoleIdentity{}
+	for key, item := range c.cache.Items() {
+		if !strings.HasPrefix(key, roleARN.String()+"|") {
+			continue
+		}
+
+		f := item.Object.(*future.Future)
+		select {
+		case <-f.Done():
+		default:
+			continue
+		}
+		val, err := f.Get(context.Background())
+		if err != nil {
+			continue
+		}
+		identities = append(identities, val.(*CachedCredentials).Identity)
+	}
+	return identities
+}
+
+// Revoke removes the credentials cached for identity, so that new credentials
+// are issued when next requested. Revoked credentials are announced as
+// expiring, allowing them to be refreshed for any pods still using them.
+func (c *credentialsCache) Revoke(identity *RoleIdentity) bool {
+	c.backend.Delete(identity.String())
+	if _, found := c.cache.Get(identity.String()); !found {
+		return false
+	}
+	c.cache.Delete(identity.String())
+	return true
+}
+
+// Evict removes the credentials cached for identity without announcing them as
+// expiring, so they aren't refreshed until next requested.
+func (c *credentialsCache) Evict(identity *RoleIdentity) bool {
+	key := identity.String()
+	c.backend.Delete(key)
+	if _, found := c.cache.Get(key); !found {
+		return false
+	}
+
+	c.silencedMu.Lock()
+	c.silenced[key] = true
+	c.silencedMu.Unlock()
+
+	// evicted is called synchronously by Delete, clear the key in case it
+	// expired in the meantime
+	c.cache.Delete(key)
+	c.silencedMu.Lock()
+	delete(c.silenced, key)
+	c.silencedMu.Unlock()
+	return true
+}
+
+// issue returns a function requesting credentials for identity from the STSGateway
+func (c *credentialsCache) issue(ctx context.Context, identity *RoleIdentity) future.FutureFn {
+	logger := log.WithFields(identity.LogFields())
+
+	return func() (interface{}, error) {
 		sessionName := c.getSessionName(identity)
 
 		stsIssueRequest := &STSIssueRequest{
-			RoleARN:         identity.Role.ARN,
+			RoleARN:         identity.Role.ARN.String(),
 			SessionName:     sessionName,
 			ExternalID:      identity.ExternalID,
 			SessionDuration: c.sessionDuration,
+			SessionTags:     identity.SessionTags,
 		}
 
-		credentials, err := c.gateway.Issue(ctx, stsIssueRequest)
+		var (
+			credentials *Credentials
+			user        *AssumedRoleUser
+			err         error
+		)
+		// external ids and tags can't be passed when reporting the assumed role
+		if reporter, ok := c.gateway.(AssumedRoleReporter); ok && identity.ExternalID == "" && len(identity.SessionTags) == 0 {
+			credentials, user, err = reporter.AssumeRoleWithReport(ctx, stsIssueRequest.RoleARN, stsIssueRequest.SessionName, stsIssueRequest.SessionDuration)
+		} else {
+			credentials, err = c.gateway.Issue(ctx, stsIssueRequest)
+		}
 		if err != nil {
 			errorIssuing.Inc()
 			logger.Errorf("error requesting credentials: %s", err.Error())
@@ -130,25 +399,23 @@ func (c *credentialsCache) CredentialsForRole(ctx context.Context, identity *Rol
 		}
 
 		cachedCreds := &CachedCredentials{
-			Identity:    identity,
-			Credentials: credentials,
+			Identity:        identity,
+			Credentials:     credentials,
+			AssumedRoleUser: user,
 		}
 
-		log.WithFields(CredentialsFields(identity, credentials)).Infof("requested new credentials")
-		return cachedCreds, err
-	}
-	f := future.New(issue)
-	c.cache.Set(identity.String(), f, c.cacheTTL)
-	cacheSize.Inc()
+		fields := CredentialsFields(identity, credentials)
+		if user != nil {
+			fields["credentials.assumed-role.arn"] = user.ARN
+		}
+		log.WithFields(fields).Infof("requested new credentials")
 
-	val, err := f.Get(ctx)
-	if err != nil {
-		c.cache.Delete(identity.String())
-		return nil, err
+		c.backend.Set(identity.String(), credentials, c.cacheTTL)
+		for _, listener := range c.listeners {
+			listener(cachedCreds)
+		}
+		return cachedCreds, err
 	}
-
-	cachedCreds := val.(*CachedCredentials)
-	return cachedCreds.Credentials, nil
 }
 
 func (c *credentialsCache) getSessionName(identity *RoleIdentity) string {
@@ -0,0 +1,121 @@
+package sts
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/uswitch/kiam/pkg/future"
+)
+
+// CredentialsPrewarmer refreshes cached credentials in the background once
+// the time left before they're evicted drops below a fraction of the cache
+// TTL, the session duration less the session refresh. This
+// spreads STS requests out, rather than many pods requesting credentials when
+// they expire. The cached credentials continue to be used until the refreshed
+// credentials are issued, and only one refresh is made for each identity at a
+// time.
+type CredentialsPrewarmer struct {
+	cache     *credentialsCache
+	threshold float64
+	interval  time.Duration
+}
+
+// Prewarmer creates a prewarmer refreshing the cache's credentials once less
+// than threshold (between 0 and 1) of the cache TTL remains before they're
+// evicted. The threshold is measured against the cache TTL, rather than the
+// session duration, as credentials are evicted once the session refresh
+// remains. Cached credentials are checked every interval.
+func (c *credentialsCache) Prewarmer(threshold float64, interval time.Duration) (*CredentialsPrewarmer, error) {
+	if threshold <= 0 || threshold >= 1 {
+		return nil, fmt.Errorf("prewarm threshold must be between 0 and 1, was %v", threshold)
+	}
+	return &CredentialsPrewarmer{cache: c, threshold: threshold, interval: interval}, nil
+}
+
+// Run starts checking cached credentials until ctx is cancelled
+func (p *CredentialsPrewarmer) Run(ctx context.Context) error {
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.prewarm(ctx)
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *CredentialsPrewarmer) prewarm(ctx context.Context) {
+	c := p.cache
+	threshold := time.Duration(p.threshold * float64(c.cacheTTL))
+	now := c.clock()
+
+	for key, item := range c.cache.Items() {
+		if item.Expiration == 0 {
+			continue
+		}
+
+		f := item.Object.(*future.Future)
+		select {
+		case <-f.Done():
+		default:
+			// credentials are still being issued
+			continue
+		}
+
+		val, err := f.Get(ctx)
+		if err != nil {
+			continue
+		}
+		cachedCreds := val.(*CachedCredentials)
+
+		if time.Unix(0, item.Expiration).Sub(now) < threshold {
+			p.refresh(ctx, key, cachedCreds.Identity)
+		}
+	}
+}
+
+// refresh issues new credentials for identity, replacing the cached
+// credentials once issued. Returns immediately if the identity is already
+// being refreshed.
+func (p *CredentialsPrewarmer) refresh(ctx context.Context, key string, identity *RoleIdentity) {
+	c := p.cache
+
+	c.mu.Lock()
+	if c.refreshing[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshing[key] = true
+	c.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.refreshing, key)
+			c.mu.Unlock()
+		}()
+
+		f := future.New(c.issue(ctx, identity))
+		if _, err := f.Get(ctx); err != nil {
+			// the cached credentials are kept until they expire
+			return
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if err := c.cache.Replace(key, f, c.ttl()); err != nil {
+			// the credentials were evicted while being refreshed
+			return
+		}
+		cachePrewarm.Inc()
+		log.WithFields(identity.LogFields()).Infof("prewarmed credentials")
+	}()
+}
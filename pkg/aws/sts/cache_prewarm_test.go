@@ -0,0 +1,123 @@
+package sts
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type countingGateway struct {
+	issueCount int32
+	expiry     time.Duration
+	delay      time.Duration
+}
+
+func (g *countingGateway) Issue(ctx context.Context, request *STSIssueRequest) (*Credentials, error) {
+	count := atomic.AddInt32(&g.issueCount, 1)
+	time.Sleep(g.delay)
+	return NewCredentials("access", "secret", fmt.Sprintf("token-%d", count), time.Now().Add(g.expiry)), nil
+}
+
+func (g *countingGateway) issued() int32 {
+	return atomic.LoadInt32(&g.issueCount)
+}
+
+// restoreCacheSize returns a function resetting the cache size gauge, which
+// other tests expect to only count their own credentials
+func restoreCacheSize() func() {
+	size := testutil.ToFloat64(cacheSize)
+	return func() { cacheSize.Set(size) }
+}
+
+func TestConcurrentRequestsShareIssuedCredentials(t *testing.T) {
+	defer restoreCacheSize()()
+
+	gateway := &countingGateway{expiry: 15 * time.Minute, delay: 50 * time.Millisecond}
+	cache := DefaultCache(gateway, "session", 15*time.Minute, 5*time.Minute)
+	identity := &RoleIdentity{Role: ResolvedRole{Name: "role", ARN: MustParseARN("arn:aws:iam::123456789012:role/role")}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.CredentialsForRole(context.Background(), identity); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if gateway.issued() != 1 {
+		t.Error("expected a single request for credentials, was", gateway.issued())
+	}
+}
+
+func TestPrewarmRefreshesExpiringCredentials(t *testing.T) {
+	// credentials are issued for the default 15m session and cached for 10m,
+	// until 5m of the session remains. A threshold of 0.2 refreshes them
+	// once less than 2m remains before they're evicted.
+	var tests = []struct {
+		name     string
+		elapsed  time.Duration
+		expected int32
+	}{
+		{"Expiring", 9 * time.Minute, 2},
+		{"NotExpiring", 7 * time.Minute, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer restoreCacheSize()()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			gateway := &countingGateway{expiry: 15 * time.Minute}
+			cache := DefaultCache(gateway, "session", 15*time.Minute, 5*time.Minute)
+			identity := &RoleIdentity{Role: ResolvedRole{Name: "role", ARN: MustParseARN("arn:aws:iam::123456789012:role/role")}}
+			original, _ := cache.CredentialsForRole(ctx, identity)
+
+			prewarmer, err := cache.Prewarmer(0.2, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cache.clock = func() time.Time { return time.Now().Add(tt.elapsed) }
+			// repeated checks are de-duplicated while refreshing
+			prewarmer.prewarm(ctx)
+			prewarmer.prewarm(ctx)
+
+			deadline := time.Now().Add(time.Second)
+			for gateway.issued() < tt.expected && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			time.Sleep(50 * time.Millisecond)
+
+			if gateway.issued() != tt.expected {
+				t.Fatalf("expected %d requests for credentials, was %d", tt.expected, gateway.issued())
+			}
+
+			creds, _ := cache.CredentialsForRole(ctx, identity)
+			if tt.expected > 1 && creds.Token == original.Token {
+				t.Error("expected refreshed credentials to be cached")
+			}
+			if gateway.issued() != tt.expected {
+				t.Error("expected cached credentials to be returned, issued", gateway.issued())
+			}
+		})
+	}
+}
+
+func TestPrewarmerValidatesThreshold(t *testing.T) {
+	cache := DefaultCache(&countingGateway{}, "session", 15*time.Minute, 5*time.Minute)
+	for _, threshold := range []float64{0, 1, 1.5} {
+		if _, err := cache.Prewarmer(threshold, time.Minute); err == nil {
+			t.Errorf("expected error for threshold %v", threshold)
+		}
+	}
+}
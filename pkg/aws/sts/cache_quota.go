@@ -0,0 +1,130 @@
+package sts
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type namespaceKey struct{}
+
+// WithNamespace returns a context identifying the namespace of the pod
+// requesting credentials.
+func WithNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, namespaceKey{}, namespace)
+}
+
+// NamespaceFromContext returns the namespace added with WithNamespace.
+func NamespaceFromContext(ctx context.Context) (string, bool) {
+	namespace, ok := ctx.Value(namespaceKey{}).(string)
+	return namespace, ok
+}
+
+// QuotaCredentialsCache is a credentials cache the quota can evict from.
+type QuotaCredentialsCache interface {
+	CredentialsProvider
+	CredentialsExpiration
+	CredentialsEvicter
+}
+
+// PerNamespaceQuotaCache limits the number of distinct role identities cached
+// for each namespace, so that one namespace can't consume a disproportionate
+// share of the cache. When a namespace exceeds the limit its least recently
+// requested identity is evicted. The namespace is read from the request
+// context (see WithNamespace); requests without a namespace aren't limited.
+type PerNamespaceQuotaCache struct {
+	cache QuotaCredentialsCache
+	limit int
+
+	mu         sync.Mutex
+	namespaces map[string]*namespaceQuota
+}
+
+type namespaceQuota struct {
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// NewPerNamespaceQuotaCache wraps cache, limiting each namespace to limit
+// cached identities.
+func NewPerNamespaceQuotaCache(cache QuotaCredentialsCache, limit int) *PerNamespaceQuotaCache {
+	return &PerNamespaceQuotaCache{cache: cache, limit: limit, namespaces: map[string]*namespaceQuota{}}
+}
+
+func (c *PerNamespaceQuotaCache) CredentialsForRole(ctx context.Context, identity *RoleIdentity) (*Credentials, error) {
+	if namespace, ok := NamespaceFromContext(ctx); ok {
+		c.track(namespace, identity)
+	}
+	return c.cache.CredentialsForRole(ctx, identity)
+}
+
+// Usage returns the number of identities tracked for namespace.
+func (c *PerNamespaceQuotaCache) Usage(namespace string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	quota, ok := c.namespaces[namespace]
+	if !ok {
+		return 0
+	}
+	return quota.order.Len()
+}
+
+// track marks identity as the namespace's most recently requested, evicting
+// identities over the limit.
+func (c *PerNamespaceQuotaCache) track(namespace string, identity *RoleIdentity) {
+	key := identity.String()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	quota, ok := c.namespaces[namespace]
+	if !ok {
+		quota = &namespaceQuota{order: list.New(), entries: map[string]*list.Element{}}
+		c.namespaces[namespace] = quota
+	}
+
+	if element, ok := quota.entries[key]; ok {
+		quota.order.MoveToFront(element)
+		return
+	}
+
+	quota.entries[key] = quota.order.PushFront(identity)
+	if quota.order.Len() > c.limit {
+		c.prune(quota, key)
+	}
+	for quota.order.Len() > c.limit {
+		c.remove(quota, quota.order.Back())
+	}
+
+	namespaceCachedRoles.WithLabelValues(namespace).Set(float64(quota.order.Len()))
+}
+
+// prune forgets identities, other than current, whose credentials are no
+// longer cached
+func (c *PerNamespaceQuotaCache) prune(quota *namespaceQuota, current string) {
+	for element := quota.order.Back(); element != nil; {
+		previous := element.Prev()
+		identity := element.Value.(*RoleIdentity)
+		if identity.String() != current {
+			if _, cached := c.cache.Expiration(identity); !cached {
+				quota.order.Remove(element)
+				delete(quota.entries, identity.String())
+			}
+		}
+		element = previous
+	}
+}
+
+func (c *PerNamespaceQuotaCache) remove(quota *namespaceQuota, element *list.Element) {
+	identity := element.Value.(*RoleIdentity)
+	quota.order.Remove(element)
+	delete(quota.entries, identity.String())
+
+	if c.cache.Evict(identity) {
+		namespaceQuotaEvictions.Inc()
+		log.WithFields(identity.LogFields()).Infof("evicted credentials over namespace quota")
+	}
+}
@@ -0,0 +1,97 @@
+package sts
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPerNamespaceQuotaCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	defer restoreCacheSize()()
+
+	gateway := &stubGateway{c: NewCredentials("A1", "S1", "T1", time.Now().Add(time.Hour))}
+	cache := DefaultCache(gateway, "session", 15*time.Minute, 5*time.Minute)
+	quota := NewPerNamespaceQuotaCache(cache, 2)
+
+	identity := func(role string) *RoleIdentity {
+		return &RoleIdentity{Role: ResolvedRole{Name: role, ARN: MustParseARN(fmt.Sprintf("arn:aws:iam::123456789012:role/%s", role))}}
+	}
+	red := WithNamespace(context.Background(), "red")
+	blue := WithNamespace(context.Background(), "blue")
+
+	quota.CredentialsForRole(red, identity("one"))
+	quota.CredentialsForRole(red, identity("two"))
+	quota.CredentialsForRole(blue, identity("three"))
+	// one becomes the most recently used
+	quota.CredentialsForRole(red, identity("one"))
+	quota.CredentialsForRole(red, identity("four"))
+
+	if _, cached := cache.Expiration(identity("two")); cached {
+		t.Error("expected least recently used role to be evicted")
+	}
+	for _, role := range []string{"one", "three", "four"} {
+		if _, cached := cache.Expiration(identity(role)); !cached {
+			t.Errorf("expected %s to remain cached", role)
+		}
+	}
+
+	if quota.Usage("red") != 2 || quota.Usage("blue") != 1 {
+		t.Error("unexpected usage", quota.Usage("red"), quota.Usage("blue"))
+	}
+	if testutil.ToFloat64(namespaceCachedRoles.WithLabelValues("red")) != 2 {
+		t.Error("unexpected gauge", testutil.ToFloat64(namespaceCachedRoles.WithLabelValues("red")))
+	}
+
+	select {
+	case expiring := <-cache.Expiring():
+		t.Error("expected eviction not to be announced, was", expiring.Identity)
+	default:
+	}
+}
+
+func TestPerNamespaceQuotaCacheForgetsExpiredRoles(t *testing.T) {
+	defer restoreCacheSize()()
+
+	gateway := &stubGateway{c: NewCredentials("A1", "S1", "T1", time.Now().Add(time.Hour))}
+	cache := DefaultCache(gateway, "session", 15*time.Minute, 5*time.Minute)
+	quota := NewPerNamespaceQuotaCache(cache, 2)
+
+	red := WithNamespace(context.Background(), "red")
+	one := &RoleIdentity{Role: ResolvedRole{Name: "one", ARN: MustParseARN("arn:aws:iam::123456789012:role/one")}}
+	two := &RoleIdentity{Role: ResolvedRole{Name: "two", ARN: MustParseARN("arn:aws:iam::123456789012:role/two")}}
+	three := &RoleIdentity{Role: ResolvedRole{Name: "three", ARN: MustParseARN("arn:aws:iam::123456789012:role/three")}}
+
+	quota.CredentialsForRole(red, one)
+	quota.CredentialsForRole(red, two)
+	cache.Revoke(one)
+	quota.CredentialsForRole(red, three)
+
+	if _, cached := cache.Expiration(two); !cached {
+		t.Error("expected two to remain cached")
+	}
+	if quota.Usage("red") != 2 {
+		t.Error("unexpected usage", quota.Usage("red"))
+	}
+}
+
+func TestPerNamespaceQuotaCacheIgnoresRequestsWithoutNamespace(t *testing.T) {
+	defer restoreCacheSize()()
+
+	gateway := &stubGateway{c: NewCredentials("A1", "S1", "T1", time.Now().Add(time.Hour))}
+	cache := DefaultCache(gateway, "session", 15*time.Minute, 5*time.Minute)
+	quota := NewPerNamespaceQuotaCache(cache, 1)
+
+	for _, role := range []string{"one", "two"} {
+		quota.CredentialsForRole(context.Background(), &RoleIdentity{Role: ResolvedRole{Name: role, ARN: MustParseARN("arn:aws:iam::123456789012:role/" + role)}})
+	}
+
+	if gateway.issueCount != 2 {
+		t.Error("expected credentials to be issued, was", gateway.issueCount)
+	}
+	if _, cached := cache.Expiration(&RoleIdentity{Role: ResolvedRole{Name: "one", ARN: MustParseARN("arn:aws:iam::123456789012:role/one")}}); !cached {
+		t.Error("expected requests without namespace not to be limited")
+	}
+}
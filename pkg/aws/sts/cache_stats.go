@@ -0,0 +1,46 @@
+package sts
+
+import "sync/atomic"
+
+// CacheStats is a snapshot of a credentials cache's counters, used to tune
+// cache sizes and TTLs
+type CacheStats struct {
+	// Hits is the number of requests answered with cached credentials
+	Hits uint64 `json:"hits"`
+	// Misses is the number of requests that issued credentials
+	Misses uint64 `json:"misses"`
+	// Evictions is the number of credentials removed from the cache, because
+	// they expired, failed, or were revoked or evicted
+	Evictions uint64 `json:"evictions"`
+	// CurrentSize is the number of unexpired credentials in the cache's
+	// credential store, which are shared with other servers when the store
+	// is shared
+	CurrentSize uint64 `json:"currentSize"`
+}
+
+// CacheStatsReporter reports the counters of a credentials cache
+type CacheStatsReporter interface {
+	Stats() CacheStats
+}
+
+// cacheCounters are updated atomically by the credentials cache
+type cacheCounters struct {
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// Stats returns a snapshot of the cache's counters. The size is counted from
+// the credential store, which unlike the cache excludes credentials that
+// expired and haven't been purged yet.
+func (c *credentialsCache) Stats() CacheStats {
+	var size uint64
+	c.backend.ForEach(func(string, *Credentials) { size++ })
+
+	return CacheStats{
+		Hits:        atomic.LoadUint64(&c.counters.hits),
+		Misses:      atomic.LoadUint64(&c.counters.misses),
+		Evictions:   atomic.LoadUint64(&c.counters.evictions),
+		CurrentSize: size,
+	}
+}
@@ -0,0 +1,30 @@
+package sts
+
+import (
+	"context"
+)
+
+// CachingSTSClient gets credentials for roles by name, for callers that aren't
+// acting for a pod, such as tools and tests. It's a thin facade over a cache
+// created with DefaultCache: credentials are keyed by the role's ARN and the
+// cache's session name, shared with the cache's other users, and issued again
+// once they're due for refresh.
+type CachingSTSClient struct {
+	cache    CredentialsProvider
+	resolver ARNResolver
+}
+
+// NewCachingSTSClient creates a client getting credentials from cache, for
+// roles resolved with resolver.
+func NewCachingSTSClient(cache CredentialsProvider, resolver ARNResolver) *CachingSTSClient {
+	return &CachingSTSClient{cache: cache, resolver: resolver}
+}
+
+// GetCredentials returns credentials for role, a role name or ARN
+func (c *CachingSTSClient) GetCredentials(ctx context.Context, role string) (*Credentials, error) {
+	identity, err := NewRoleIdentity(c.resolver, role, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return c.cache.CredentialsForRole(ctx, identity)
+}
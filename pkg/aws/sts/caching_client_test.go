@@ -0,0 +1,50 @@
+package sts
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCachingSTSClientGetsCachedCredentials(t *testing.T) {
+	defer restoreCacheSize()()
+
+	gateway := &stubGateway{c: NewCredentials("A", "S", "T", time.Now().Add(15*time.Minute))}
+	cache := DefaultCache(gateway, "session", 15*time.Minute, 5*time.Minute)
+	client := NewCachingSTSClient(cache, DefaultResolver("arn:aws:iam::123456789012:role/"))
+
+	for i := 0; i < 2; i++ {
+		creds, err := client.GetCredentials(context.Background(), "reader")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if creds.AccessKeyId != "A" {
+			t.Error("unexpected credentials, was", creds.AccessKeyId)
+		}
+	}
+
+	if gateway.issueCount != 1 {
+		t.Error("expected credentials to be issued once, was", gateway.issueCount)
+	}
+	if gateway.requestedRole != "arn:aws:iam::123456789012:role/reader" || gateway.requestedSessionName != "kiam-session" {
+		t.Error("unexpected request", gateway.requestedRole, gateway.requestedSessionName)
+	}
+}
+
+func TestCachingSTSClientSharesCacheWithPods(t *testing.T) {
+	defer restoreCacheSize()()
+
+	gateway := &stubGateway{c: NewCredentials("A", "S", "T", time.Now().Add(15*time.Minute))}
+	cache := DefaultCache(gateway, "session", 15*time.Minute, 5*time.Minute)
+	resolver := DefaultResolver("arn:aws:iam::123456789012:role/")
+
+	identity, _ := NewRoleIdentity(resolver, "reader", "", "")
+	cache.CredentialsForRole(context.Background(), identity)
+
+	if _, err := NewCachingSTSClient(cache, resolver).GetCredentials(context.Background(), "arn:aws:iam::123456789012:role/reader"); err != nil {
+		t.Fatal(err)
+	}
+	if gateway.issueCount != 1 {
+		t.Error("expected credentials cached for pods to be used, issued", gateway.issueCount)
+	}
+}
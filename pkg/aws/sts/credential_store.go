@@ -0,0 +1,65 @@
+package sts
+
+import (
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+// CredentialStoreBackend stores issued credentials so they can be shared,
+// e.g. between kiam servers running in HA, avoiding stampedes of requests to
+// STS when a server restarts. Credentials are stored by their identity.
+type CredentialStoreBackend interface {
+	Get(key string) (*Credentials, bool)
+	Set(key string, c *Credentials, ttl time.Duration)
+	Delete(key string)
+	// ForEach calls fn with the key and credentials of each stored entry,
+	// e.g. to inspect the store in tests and admin tools. The store is locked
+	// for reading while iterating, fn must not modify it.
+	ForEach(fn func(key string, c *Credentials))
+}
+
+// MemoryCredentialStore stores credentials in memory, used when no shared
+// backend is configured.
+type MemoryCredentialStore struct {
+	mu    sync.RWMutex
+	cache *cache.Cache
+}
+
+func NewMemoryCredentialStore() *MemoryCredentialStore {
+	return &MemoryCredentialStore{cache: cache.New(cache.NoExpiration, DefaultPurgeInterval)}
+}
+
+func (s *MemoryCredentialStore) Get(key string) (*Credentials, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, found := s.cache.Get(key)
+	if !found {
+		return nil, false
+	}
+	return item.(*Credentials), true
+}
+
+func (s *MemoryCredentialStore) Set(key string, c *Credentials, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache.Set(key, c, ttl)
+}
+
+func (s *MemoryCredentialStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache.Delete(key)
+}
+
+// ForEach calls fn with each unexpired entry
+func (s *MemoryCredentialStore) ForEach(fn func(key string, c *Credentials)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for key, item := range s.cache.Items() {
+		fn(key, item.Object.(*Credentials))
+	}
+}
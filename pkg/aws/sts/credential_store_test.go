@@ -0,0 +1,136 @@
+package sts
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSharedBackendAvoidsIssuingCredentials(t *testing.T) {
+	defer restoreCacheSize()()
+
+	backend := NewMemoryCredentialStore()
+	identity := &RoleIdentity{Role: ResolvedRole{Name: "role", ARN: MustParseARN("arn:aws:iam::123456789012:role/role")}}
+
+	first := &stubGateway{c: NewCredentials("A", "S", "T", time.Now().Add(15*time.Minute))}
+	cache := DefaultCache(first, "session", 15*time.Minute, 5*time.Minute)
+	cache.SetBackend(backend)
+	cache.CredentialsForRole(context.Background(), identity)
+
+	second := &stubGateway{c: NewCredentials("B", "S", "T", time.Now().Add(15*time.Minute))}
+	other := DefaultCache(second, "session", 15*time.Minute, 5*time.Minute)
+	other.SetBackend(backend)
+
+	creds, err := other.CredentialsForRole(context.Background(), identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyId != "A" || second.issueCount != 0 {
+		t.Error("expected stored credentials, was", creds.AccessKeyId)
+	}
+}
+
+func TestStoredCredentialsDueForRefreshAreIssued(t *testing.T) {
+	defer restoreCacheSize()()
+
+	backend := NewMemoryCredentialStore()
+	identity := &RoleIdentity{Role: ResolvedRole{Name: "role", ARN: MustParseARN("arn:aws:iam::123456789012:role/role")}}
+	backend.Set(identity.String(), NewCredentials("A", "S", "T", time.Now().Add(4*time.Minute)), time.Minute)
+
+	gateway := &stubGateway{c: NewCredentials("B", "S", "T", time.Now().Add(15*time.Minute))}
+	cache := DefaultCache(gateway, "session", 15*time.Minute, 5*time.Minute)
+	cache.SetBackend(backend)
+
+	creds, _ := cache.CredentialsForRole(context.Background(), identity)
+	if creds.AccessKeyId != "B" || gateway.issueCount != 1 {
+		t.Error("expected credentials to be issued, was", creds.AccessKeyId)
+	}
+	if stored, _ := backend.Get(identity.String()); stored.AccessKeyId != "B" {
+		t.Error("expected issued credentials to be stored, was", stored.AccessKeyId)
+	}
+}
+
+// slowCredentialStore blocks getting key until released, as a remote
+// backend might
+type slowCredentialStore struct {
+	*MemoryCredentialStore
+	key      string
+	getting  chan struct{}
+	released chan struct{}
+}
+
+func (s *slowCredentialStore) Get(key string) (*Credentials, bool) {
+	if key == s.key {
+		close(s.getting)
+		<-s.released
+	}
+	return s.MemoryCredentialStore.Get(key)
+}
+
+func TestSlowBackendDoesNotBlockCachedCredentials(t *testing.T) {
+	defer restoreCacheSize()()
+
+	cached := &RoleIdentity{Role: ResolvedRole{Name: "cached", ARN: MustParseARN("arn:aws:iam::123456789012:role/cached")}}
+	slow := &RoleIdentity{Role: ResolvedRole{Name: "slow", ARN: MustParseARN("arn:aws:iam::123456789012:role/slow")}}
+	backend := &slowCredentialStore{MemoryCredentialStore: NewMemoryCredentialStore(), key: slow.String(), getting: make(chan struct{}), released: make(chan struct{})}
+
+	cache := DefaultCache(&countingGateway{expiry: 15 * time.Minute}, "session", 15*time.Minute, 5*time.Minute)
+	cache.SetBackend(backend)
+	if _, err := cache.CredentialsForRole(context.Background(), cached); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cache.CredentialsForRole(context.Background(), slow)
+	}()
+	<-backend.getting
+
+	returned := make(chan struct{})
+	go func() {
+		defer close(returned)
+		cache.CredentialsForRole(context.Background(), cached)
+	}()
+
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Error("expected cached credentials while the backend was slow")
+	}
+	close(backend.released)
+	<-done
+}
+
+func TestRevokeDeletesStoredCredentials(t *testing.T) {
+	defer restoreCacheSize()()
+
+	backend := NewMemoryCredentialStore()
+	identity := &RoleIdentity{Role: ResolvedRole{Name: "role", ARN: MustParseARN("arn:aws:iam::123456789012:role/role")}}
+	gateway := &stubGateway{c: NewCredentials("A", "S", "T", time.Now().Add(15*time.Minute))}
+	cache := DefaultCache(gateway, "session", 15*time.Minute, 5*time.Minute)
+	cache.SetBackend(backend)
+
+	cache.CredentialsForRole(context.Background(), identity)
+	cache.Revoke(identity)
+
+	if _, found := backend.Get(identity.String()); found {
+		t.Error("expected revoked credentials to be deleted")
+	}
+}
+
+func TestMemoryCredentialStoreForEach(t *testing.T) {
+	store := NewMemoryCredentialStore()
+	store.Set("reader", NewCredentials("A", "S", "T", time.Now()), time.Minute)
+	store.Set("writer", NewCredentials("B", "S", "T", time.Now()), time.Minute)
+	store.Set("expired", NewCredentials("C", "S", "T", time.Now()), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	found := map[string]string{}
+	store.ForEach(func(key string, c *Credentials) {
+		found[key] = c.AccessKeyId
+	})
+	if len(found) != 2 || found["reader"] != "A" || found["writer"] != "B" {
+		t.Error("unexpected credentials", found)
+	}
+}
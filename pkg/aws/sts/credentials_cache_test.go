@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/uswitch/kiam/pkg/future"
 )
 
 type stubGateway struct {
@@ -43,7 +44,7 @@ func TestRequestsCredentialsFromGatewayWithEmptyCache(t *testing.T) {
 	cache := DefaultCache(stubGateway, "session", 15*time.Minute, 5*time.Minute)
 	ctx := context.Background()
 
-	credentialsIdentity := &RoleIdentity{Role: ResolvedRole{Name: "role", ARN: "arn:account:role"}}
+	credentialsIdentity := &RoleIdentity{Role: ResolvedRole{Name: "role", ARN: MustParseARN("arn:aws:iam::123456789012:role/role")}}
 	creds, _ := cache.CredentialsForRole(ctx, credentialsIdentity)
 	if creds.Code != "foo" {
 		t.Error("didnt return expected credentials code, was", creds.Code)
@@ -57,7 +58,7 @@ func TestRequestsCredentialsFromGatewayWithEmptyCache(t *testing.T) {
 		t.Error("expected creds to be cached")
 	}
 
-	if stubGateway.requestedRole != "arn:account:role" {
+	if stubGateway.requestedRole != "arn:aws:iam::123456789012:role/role" {
 		t.Error("unexpected role, was:", stubGateway.requestedRole)
 	}
 }
@@ -80,7 +81,7 @@ func TestRequestsCredentialsWithSessionName(t *testing.T) {
 			ctx := context.Background()
 
 			credentialsIdentity := &RoleIdentity{
-				Role:        ResolvedRole{Name: "role", ARN: "arn:account:role"},
+				Role:        ResolvedRole{Name: "role", ARN: MustParseARN("arn:aws:iam::123456789012:role/role")},
 				SessionName: tt.sessionName,
 			}
 
@@ -99,7 +100,7 @@ func TestRequestsCredentialsWithExternalID(t *testing.T) {
 	ctx := context.Background()
 
 	credentialsIdentity := &RoleIdentity{
-		Role:       ResolvedRole{Name: "role", ARN: "arn:account:role"},
+		Role:       ResolvedRole{Name: "role", ARN: MustParseARN("arn:aws:iam::123456789012:role/role")},
 		ExternalID: "123456",
 	}
 
@@ -109,3 +110,201 @@ func TestRequestsCredentialsWithExternalID(t *testing.T) {
 		t.Error("unexpected external-id, was:", stubGateway.requestedExternalID)
 	}
 }
+
+func TestCachedCredentialsExpiration(t *testing.T) {
+	expiry := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	stubGateway := &stubGateway{c: NewCredentials("access", "secret", "token", expiry)}
+	cache := DefaultCache(stubGateway, "session", 15*time.Minute, 5*time.Minute)
+	credentialsIdentity := &RoleIdentity{Role: ResolvedRole{Name: "role", ARN: MustParseARN("arn:aws:iam::123456789012:role/role")}}
+
+	if _, ok := cache.Expiration(credentialsIdentity); ok {
+		t.Error("expected no expiration before credentials are cached")
+	}
+
+	cache.CredentialsForRole(context.Background(), credentialsIdentity)
+
+	expiration, ok := cache.Expiration(credentialsIdentity)
+	if !ok {
+		t.Fatal("expected expiration of cached credentials")
+	}
+	if !expiration.Equal(expiry) {
+		t.Error("unexpected expiration, was", expiration)
+	}
+}
+
+type reportingGateway struct {
+	stubGateway
+	reported int
+}
+
+func (g *reportingGateway) AssumeRoleWithReport(ctx context.Context, role, sessionName string, duration time.Duration) (*Credentials, *AssumedRoleUser, error) {
+	g.reported = g.reported + 1
+	return g.c, &AssumedRoleUser{ARN: "arn:aws:sts::123456789012:assumed-role/role/" + sessionName}, nil
+}
+
+func TestCachesReportedAssumedRoleUser(t *testing.T) {
+	var tests = []struct {
+		name       string
+		externalID string
+		expected   string
+	}{
+		{"Reported", "", "arn:aws:sts::123456789012:assumed-role/role/kiam-session"},
+		{"ExternalIDNotReported", "123456", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway := &reportingGateway{stubGateway: stubGateway{c: &Credentials{Code: "foo"}}}
+			cache := DefaultCache(gateway, "session", 15*time.Minute, 5*time.Minute)
+			ctx := context.Background()
+
+			credentialsIdentity := &RoleIdentity{Role: ResolvedRole{Name: "role", ARN: MustParseARN("arn:aws:iam::123456789012:role/role")}, ExternalID: tt.externalID}
+			if _, err := cache.CredentialsForRole(ctx, credentialsIdentity); err != nil {
+				t.Fatal(err)
+			}
+
+			item, _ := cache.cache.Get(credentialsIdentity.String())
+			val, _ := item.(*future.Future).Get(ctx)
+			user := val.(*CachedCredentials).AssumedRoleUser
+
+			if tt.expected == "" {
+				if user != nil || gateway.issueCount != 1 {
+					t.Error("expected credentials to be issued without report, was", user)
+				}
+				return
+			}
+			if user == nil || user.ARN != tt.expected {
+				t.Errorf("expected assumed role %s, was %+v", tt.expected, user)
+			}
+		})
+	}
+}
+
+func TestRevokeRemovesCachedCredentials(t *testing.T) {
+	defer restoreCacheSize()()
+
+	stubGateway := &stubGateway{c: &Credentials{Code: "foo"}}
+	cache := DefaultCache(stubGateway, "session", 15*time.Minute, 5*time.Minute)
+	ctx := context.Background()
+	credentialsIdentity := &RoleIdentity{Role: ResolvedRole{Name: "role", ARN: MustParseARN("arn:aws:iam::123456789012:role/role")}}
+
+	if cache.Revoke(credentialsIdentity) {
+		t.Error("expected nothing to revoke before credentials are cached")
+	}
+
+	cache.CredentialsForRole(ctx, credentialsIdentity)
+	if !cache.Revoke(credentialsIdentity) {
+		t.Error("expected cached credentials to be revoked")
+	}
+
+	expiring := <-cache.Expiring()
+	if expiring.Identity != credentialsIdentity {
+		t.Error("expected revoked credentials to be announced, was", expiring.Identity)
+	}
+
+	cache.CredentialsForRole(ctx, credentialsIdentity)
+	if stubGateway.issueCount != 2 {
+		t.Error("expected credentials to be issued again, was", stubGateway.issueCount)
+	}
+}
+
+func TestCacheStatsCountHitsMissesAndEvictions(t *testing.T) {
+	defer restoreCacheSize()()
+
+	stubGateway := &stubGateway{c: &Credentials{Code: "foo"}}
+	cache := DefaultCache(stubGateway, "session", 15*time.Minute, 5*time.Minute)
+	ctx := context.Background()
+	credentialsIdentity := &RoleIdentity{Role: ResolvedRole{Name: "role", ARN: MustParseARN("arn:aws:iam::123456789012:role/role")}}
+
+	cache.CredentialsForRole(ctx, credentialsIdentity)
+	cache.CredentialsForRole(ctx, credentialsIdentity)
+	cache.CredentialsForRole(ctx, credentialsIdentity)
+	if stats := cache.Stats(); stats != (CacheStats{Hits: 2, Misses: 1, CurrentSize: 1}) {
+		t.Error("unexpected stats", stats)
+	}
+
+	cache.Evict(credentialsIdentity)
+	if stats := cache.Stats(); stats != (CacheStats{Hits: 2, Misses: 1, Evictions: 1}) {
+		t.Error("unexpected stats after eviction", stats)
+	}
+}
+
+func TestCachedIdentitiesForRole(t *testing.T) {
+	defer restoreCacheSize()()
+
+	stubGateway := &stubGateway{c: &Credentials{Code: "foo"}}
+	cache := DefaultCache(stubGateway, "session", 15*time.Minute, 5*time.Minute)
+	ctx := context.Background()
+
+	cache.CredentialsForRole(ctx, &RoleIdentity{Role: ResolvedRole{Name: "role", ARN: MustParseARN("arn:aws:iam::123456789012:role/role")}, SessionName: "red"})
+	cache.CredentialsForRole(ctx, &RoleIdentity{Role: ResolvedRole{Name: "role", ARN: MustParseARN("arn:aws:iam::123456789012:role/role")}, SessionName: "blue"})
+	cache.CredentialsForRole(ctx, &RoleIdentity{Role: ResolvedRole{Name: "role-admin", ARN: MustParseARN("arn:aws:iam::123456789012:role/role-admin")}})
+
+	identities := cache.CachedIdentities(MustParseARN("arn:aws:iam::123456789012:role/role"))
+	if len(identities) != 2 {
+		t.Fatal("expected identities for both sessions, was", identities)
+	}
+	for _, identity := range identities {
+		if identity.Role.ARN.String() != "arn:aws:iam::123456789012:role/role" {
+			t.Error("unexpected identity", identity)
+		}
+	}
+
+	if identities := cache.CachedIdentities(MustParseARN("arn:aws:iam::123456789012:role/other")); len(identities) != 0 {
+		t.Error("expected no identities for uncached role, was", identities)
+	}
+}
+
+func TestRefreshJitterShortensCacheTTL(t *testing.T) {
+	var tests = []struct {
+		name     string
+		jitter   time.Duration
+		random   float64
+		expected time.Duration
+	}{
+		{"NoJitter", 0, 0.5, 10 * time.Minute},
+		{"Jitter", 2 * time.Minute, 0.5, 9 * time.Minute},
+		{"MaximumJitter", 2 * time.Minute, 1, 8 * time.Minute},
+		{"JitterLongerThanTTL", 20 * time.Minute, 1, minCacheTTL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer restoreCacheSize()()
+
+			stubGateway := &stubGateway{c: &Credentials{Code: "foo"}}
+			cache := DefaultCache(stubGateway, "session", 15*time.Minute, 5*time.Minute, WithRefreshJitter(tt.jitter))
+			cache.random = func() float64 { return tt.random }
+
+			identity := &RoleIdentity{Role: ResolvedRole{Name: "role", ARN: MustParseARN("arn:aws:iam::123456789012:role/role")}}
+			before := time.Now()
+			cache.CredentialsForRole(context.Background(), identity)
+
+			expiration := time.Unix(0, cache.cache.Items()[identity.String()].Expiration)
+			if ttl := expiration.Sub(before); ttl < tt.expected || ttl > tt.expected+time.Second {
+				t.Errorf("expected ttl %s, was %s", tt.expected, ttl)
+			}
+		})
+	}
+}
+
+func TestCredentialsListenersNotifiedOfIssuedCredentials(t *testing.T) {
+	defer restoreCacheSize()()
+
+	var notified []*CachedCredentials
+	stubGateway := &stubGateway{c: &Credentials{Code: "foo"}}
+	cache := DefaultCache(stubGateway, "session", 15*time.Minute, 5*time.Minute, WithCredentialsListener(func(creds *CachedCredentials) {
+		notified = append(notified, creds)
+	}))
+
+	identity := &RoleIdentity{Role: ResolvedRole{Name: "role", ARN: MustParseARN("arn:aws:iam::123456789012:role/role")}}
+	cache.CredentialsForRole(context.Background(), identity)
+	cache.CredentialsForRole(context.Background(), identity)
+
+	if len(notified) != 1 {
+		t.Fatal("expected listener notified once, was", len(notified))
+	}
+	if notified[0].Identity != identity || notified[0].Credentials.Code != "foo" {
+		t.Error("unexpected notified credentials", notified[0])
+	}
+}
@@ -0,0 +1,12 @@
+// Package sts issues and caches credentials for the roles pods assume.
+//
+// An STSGateway calls STS, and may be wrapped to retry, deduplicate or
+// observe calls. The cache created by DefaultCache is the only place issued
+// credentials are stored: it implements CredentialsProvider, keyed by the
+// RoleIdentity (role ARN, session name, external id and session tags), and
+// announces credentials on Expiring before they expire. The
+// prefetch.CredentialManager receives those announcements and refreshes the
+// credentials of roles that running pods still use, so requests are normally
+// served from the cache without calling STS. CachingSTSClient gets credentials
+// from the same cache by role name, for callers that aren't acting for a pod.
+package sts
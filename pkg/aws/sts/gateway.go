@@ -15,6 +15,7 @@ package sts
 
 import (
 	"context"
+	"sort"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -28,12 +29,27 @@ type STSIssueRequest struct {
 	SessionName     string
 	ExternalID      string
 	SessionDuration time.Duration
+	SessionTags     map[string]string
 }
 
 type STSGateway interface {
 	Issue(ctx context.Context, request *STSIssueRequest) (*Credentials, error)
 }
 
+// AssumedRoleUser identifies the session created when assuming a role
+type AssumedRoleUser struct {
+	// ARN of the assumed role session, e.g.
+	// arn:aws:sts::123456789012:assumed-role/role/session-name
+	ARN           string
+	AssumedRoleID string
+}
+
+// AssumedRoleReporter is implemented by STSGateways that can report the
+// session created when assuming a role, for auditing.
+type AssumedRoleReporter interface {
+	AssumeRoleWithReport(ctx context.Context, role, sessionName string, duration time.Duration) (*Credentials, *AssumedRoleUser, error)
+}
+
 type DefaultSTSGateway struct {
 	session *session.Session
 }
@@ -43,12 +59,36 @@ func DefaultGateway(config *aws.Config) (*DefaultSTSGateway, error) {
 }
 
 func (g *DefaultSTSGateway) Issue(ctx context.Context, request *STSIssueRequest) (*Credentials, error) {
+	credentials, _, err := g.assumeRole(ctx, request)
+	return credentials, err
+}
+
+// AssumeRoleWithReport assumes the role, returning the assumed role session
+// along with its credentials.
+func (g *DefaultSTSGateway) AssumeRoleWithReport(ctx context.Context, role, sessionName string, duration time.Duration) (*Credentials, *AssumedRoleUser, error) {
+	return g.assumeRole(ctx, &STSIssueRequest{RoleARN: role, SessionName: sessionName, SessionDuration: duration})
+}
+
+// CheckConnectivity requests the caller identity, checking STS can be reached
+// and the gateway's credentials are valid
+func (g *DefaultSTSGateway) CheckConnectivity(ctx context.Context) error {
+	_, err := sts.New(g.session).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	return err
+}
+
+func (g *DefaultSTSGateway) assumeRole(ctx context.Context, request *STSIssueRequest) (*Credentials, *AssumedRoleUser, error) {
 	timer := prometheus.NewTimer(assumeRole)
 	defer timer.ObserveDuration()
 
 	assumeRoleExecuting.Inc()
 	defer assumeRoleExecuting.Dec()
 
+	start := time.Now()
+	result := "success"
+	defer func() {
+		assumeRoleByRole.WithLabelValues(request.RoleARN, result).Observe(time.Since(start).Seconds())
+	}()
+
 	svc := sts.New(g.session)
 	in := &sts.AssumeRoleInput{
 		DurationSeconds: aws.Int64(int64(request.SessionDuration.Seconds())),
@@ -60,10 +100,27 @@ func (g *DefaultSTSGateway) Issue(ctx context.Context, request *STSIssueRequest)
 		in.ExternalId = aws.String(request.ExternalID)
 	}
 
+	keys := make([]string, 0, len(request.SessionTags))
+	for key := range request.SessionTags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		in.Tags = append(in.Tags, &sts.Tag{Key: aws.String(key), Value: aws.String(request.SessionTags[key])})
+	}
+
 	resp, err := svc.AssumeRoleWithContext(ctx, in)
 	if err != nil {
-		return nil, err
+		result = "error"
+		return nil, nil, err
+	}
+
+	credentials := NewCredentials(*resp.Credentials.AccessKeyId, *resp.Credentials.SecretAccessKey, *resp.Credentials.SessionToken, *resp.Credentials.Expiration)
+
+	var user *AssumedRoleUser
+	if resp.AssumedRoleUser != nil {
+		user = &AssumedRoleUser{ARN: aws.StringValue(resp.AssumedRoleUser.Arn), AssumedRoleID: aws.StringValue(resp.AssumedRoleUser.AssumedRoleId)}
 	}
 
-	return NewCredentials(*resp.Credentials.AccessKeyId, *resp.Credentials.SecretAccessKey, *resp.Credentials.SessionToken, *resp.Credentials.Expiration), nil
+	return credentials, user, nil
 }
@@ -0,0 +1,90 @@
+package sts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DeduplicatingSTSGateway coalesces concurrent requests for the same role,
+// session name, duration, external id and session tags into a single call to
+// the wrapped gateway, returning its result to all waiting requests. The call
+// uses the context of the first request; waiting requests stop waiting when
+// their own contexts are cancelled.
+type DeduplicatingSTSGateway struct {
+	gateway STSGateway
+
+	mu    sync.Mutex
+	calls map[string]*inflightCall
+}
+
+// errCallPanicked is returned to waiting requests when the call they're
+// waiting for panics
+var errCallPanicked = errors.New("deduplicated sts call panicked")
+
+type inflightCall struct {
+	done        chan struct{}
+	credentials *Credentials
+	user        *AssumedRoleUser
+	err         error
+}
+
+func NewDeduplicatingSTSGateway(gateway STSGateway) *DeduplicatingSTSGateway {
+	return &DeduplicatingSTSGateway{gateway: gateway, calls: map[string]*inflightCall{}}
+}
+
+func (g *DeduplicatingSTSGateway) Issue(ctx context.Context, request *STSIssueRequest) (*Credentials, error) {
+	key := fmt.Sprintf("issue\x00%s\x00%s\x00%s\x00%s\x00%s", request.RoleARN, request.SessionName, request.SessionDuration, request.ExternalID, formatTags(request.SessionTags))
+	credentials, _, err := g.do(ctx, key, func() (*Credentials, *AssumedRoleUser, error) {
+		credentials, err := g.gateway.Issue(ctx, request)
+		return credentials, nil, err
+	})
+	return credentials, err
+}
+
+// AssumeRoleWithReport assumes the role, reporting the assumed role session
+// when the wrapped gateway implements AssumedRoleReporter.
+func (g *DeduplicatingSTSGateway) AssumeRoleWithReport(ctx context.Context, role, sessionName string, duration time.Duration) (*Credentials, *AssumedRoleUser, error) {
+	key := fmt.Sprintf("report\x00%s\x00%s\x00%s", role, sessionName, duration)
+	return g.do(ctx, key, func() (*Credentials, *AssumedRoleUser, error) {
+		if reporter, ok := g.gateway.(AssumedRoleReporter); ok {
+			return reporter.AssumeRoleWithReport(ctx, role, sessionName, duration)
+		}
+		credentials, err := g.gateway.Issue(ctx, &STSIssueRequest{RoleARN: role, SessionName: sessionName, SessionDuration: duration})
+		return credentials, nil, err
+	})
+}
+
+func (g *DeduplicatingSTSGateway) do(ctx context.Context, key string, fn func() (*Credentials, *AssumedRoleUser, error)) (*Credentials, *AssumedRoleUser, error) {
+	g.mu.Lock()
+	call, inflight := g.calls[key]
+	if !inflight {
+		call = &inflightCall{done: make(chan struct{})}
+		g.calls[key] = call
+	}
+	g.mu.Unlock()
+
+	if inflight {
+		assumeRoleDeduplicated.Inc()
+		select {
+		case <-call.done:
+			return call.credentials, call.user, call.err
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+
+	// waiters are released, with an error, even if fn panics
+	call.err = errCallPanicked
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.credentials, call.user, call.err = fn()
+	return call.credentials, call.user, call.err
+}
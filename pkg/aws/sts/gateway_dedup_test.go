@@ -0,0 +1,147 @@
+package sts
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDeduplicatingGatewayCoalescesConcurrentRequests(t *testing.T) {
+	gateway := &countingGateway{expiry: 15 * time.Minute, delay: 50 * time.Millisecond}
+	dedup := NewDeduplicatingSTSGateway(gateway)
+	deduplicated := testutil.ToFloat64(assumeRoleDeduplicated)
+
+	request := &STSIssueRequest{RoleARN: "arn:aws:iam::123456789012:role/foo", SessionName: "kiam-session", SessionDuration: 15 * time.Minute}
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 10)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			credentials, err := dedup.Issue(context.Background(), request)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			tokens[i] = credentials.Token
+		}(i)
+	}
+	wg.Wait()
+
+	if gateway.issued() != 1 {
+		t.Error("expected a single request to sts, was", gateway.issued())
+	}
+	for _, token := range tokens {
+		if token != tokens[0] {
+			t.Error("expected requests to share credentials, were", tokens)
+			break
+		}
+	}
+	if count := testutil.ToFloat64(assumeRoleDeduplicated) - deduplicated; count != 9 {
+		t.Error("expected 9 deduplicated requests, was", count)
+	}
+}
+
+func TestDeduplicatingGatewayKeysOnRequest(t *testing.T) {
+	gateway := &countingGateway{expiry: 15 * time.Minute, delay: 20 * time.Millisecond}
+	dedup := NewDeduplicatingSTSGateway(gateway)
+
+	requests := []*STSIssueRequest{
+		{RoleARN: "arn:aws:iam::123456789012:role/foo", SessionName: "kiam-a", SessionDuration: 15 * time.Minute},
+		{RoleARN: "arn:aws:iam::123456789012:role/bar", SessionName: "kiam-a", SessionDuration: 15 * time.Minute},
+		{RoleARN: "arn:aws:iam::123456789012:role/foo", SessionName: "kiam-b", SessionDuration: 15 * time.Minute},
+		{RoleARN: "arn:aws:iam::123456789012:role/foo", SessionName: "kiam-a", SessionDuration: 30 * time.Minute},
+		{RoleARN: "arn:aws:iam::123456789012:role/foo", SessionName: "kiam-a", SessionDuration: 15 * time.Minute, ExternalID: "external"},
+	}
+
+	var wg sync.WaitGroup
+	for _, request := range requests {
+		wg.Add(1)
+		go func(request *STSIssueRequest) {
+			defer wg.Done()
+			dedup.Issue(context.Background(), request)
+		}(request)
+	}
+	wg.Wait()
+
+	if gateway.issued() != int32(len(requests)) {
+		t.Errorf("expected %d requests to sts, was %d", len(requests), gateway.issued())
+	}
+}
+
+func TestDeduplicatingGatewayWaiterCancelled(t *testing.T) {
+	gateway := &countingGateway{expiry: 15 * time.Minute, delay: 200 * time.Millisecond}
+	dedup := NewDeduplicatingSTSGateway(gateway)
+	request := &STSIssueRequest{RoleARN: "arn:aws:iam::123456789012:role/foo", SessionName: "kiam-session", SessionDuration: 15 * time.Minute}
+
+	go dedup.Issue(context.Background(), request)
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := dedup.Issue(ctx, request); err != context.DeadlineExceeded {
+		t.Error("expected waiter to stop waiting, was", err)
+	}
+}
+
+func TestDeduplicatingGatewayReportsWithoutReporter(t *testing.T) {
+	gateway := &countingGateway{expiry: 15 * time.Minute}
+	dedup := NewDeduplicatingSTSGateway(gateway)
+
+	credentials, user, err := dedup.AssumeRoleWithReport(context.Background(), "arn:aws:iam::123456789012:role/foo", "kiam-session", 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials == nil || user != nil {
+		t.Error("expected issued credentials without assumed role user")
+	}
+}
+
+// panickingGateway panics once released
+type panickingGateway struct {
+	release chan struct{}
+}
+
+func (g *panickingGateway) Issue(ctx context.Context, request *STSIssueRequest) (*Credentials, error) {
+	<-g.release
+	panic("issue failed")
+}
+
+func TestDeduplicatingGatewayReleasesWaitersOnPanic(t *testing.T) {
+	gateway := &panickingGateway{release: make(chan struct{})}
+	dedup := NewDeduplicatingSTSGateway(gateway)
+	request := &STSIssueRequest{RoleARN: "arn:aws:iam::123456789012:role/foo", SessionName: "kiam-session", SessionDuration: 15 * time.Minute}
+
+	go func() {
+		defer func() { recover() }()
+		dedup.Issue(context.Background(), request)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	errs := make(chan error)
+	go func() {
+		_, err := dedup.Issue(context.Background(), request)
+		errs <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(gateway.release)
+
+	select {
+	case err := <-errs:
+		if err != errCallPanicked {
+			t.Error("expected waiter to be told the call panicked, was", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected waiter to be released")
+	}
+
+	dedup.mu.Lock()
+	defer dedup.mu.Unlock()
+	if len(dedup.calls) != 0 {
+		t.Error("expected panicked call to be removed")
+	}
+}
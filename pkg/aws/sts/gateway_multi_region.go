@@ -0,0 +1,107 @@
+package sts
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
+	log "github.com/sirupsen/logrus"
+)
+
+// MultiRegionSTSClient assumes roles with the STS endpoint of its region,
+// avoiding the latency of the global endpoint in us-east-1. Failed regional
+// calls are retried once against the global endpoint.
+type MultiRegionSTSClient struct {
+	region   string
+	regional regionGateway
+	global   regionGateway
+}
+
+// regionGateway is implemented by DefaultSTSGateway
+type regionGateway interface {
+	STSGateway
+	AssumedRoleReporter
+	ConnectivityChecker
+}
+
+// NewMultiRegionSTSClient creates a client for region, using its endpoint URL
+// from endpoints. Calls use the global endpoint when region isn't in
+// endpoints. Configs, such as credentials, are applied to both endpoints'
+// sessions.
+func NewMultiRegionSTSClient(region string, endpoints map[string]string, configs ...*aws.Config) *MultiRegionSTSClient {
+	client := &MultiRegionSTSClient{
+		region: region,
+		global: newGatewayWithConfigs(configs, globalEndpointConfig(region)),
+	}
+	if endpoint, ok := endpoints[region]; ok && endpoint != "" {
+		client.regional = newGatewayWithConfigs(configs, aws.NewConfig().WithRegion(region).WithEndpoint(endpoint))
+	}
+	return client
+}
+
+// globalEndpointConfig resolves STS to the global endpoint of region's
+// partition
+func globalEndpointConfig(region string) *aws.Config {
+	if region == "" {
+		region = endpoints.UsEast1RegionID
+	}
+	return aws.NewConfig().
+		WithRegion(region).
+		WithEndpoint("").
+		WithEndpointResolver(endpoints.DefaultResolver()).
+		WithSTSRegionalEndpoint(endpoints.LegacySTSEndpoint)
+}
+
+func newGatewayWithConfigs(configs []*aws.Config, endpoint *aws.Config) *DefaultSTSGateway {
+	all := append(append([]*aws.Config{}, configs...), endpoint)
+	return &DefaultSTSGateway{session: session.Must(session.NewSession(all...))}
+}
+
+func (c *MultiRegionSTSClient) Issue(ctx context.Context, request *STSIssueRequest) (*Credentials, error) {
+	if c.regional == nil {
+		return c.global.Issue(ctx, request)
+	}
+
+	credentials, err := c.regional.Issue(ctx, request)
+	if !c.retryGlobal(ctx, request.RoleARN, err) {
+		return credentials, err
+	}
+	return c.global.Issue(ctx, request)
+}
+
+// AssumeRoleWithReport assumes the role, returning the assumed role session
+// along with its credentials.
+func (c *MultiRegionSTSClient) AssumeRoleWithReport(ctx context.Context, role, sessionName string, duration time.Duration) (*Credentials, *AssumedRoleUser, error) {
+	if c.regional == nil {
+		return c.global.AssumeRoleWithReport(ctx, role, sessionName, duration)
+	}
+
+	credentials, user, err := c.regional.AssumeRoleWithReport(ctx, role, sessionName, duration)
+	if !c.retryGlobal(ctx, role, err) {
+		return credentials, user, err
+	}
+	return c.global.AssumeRoleWithReport(ctx, role, sessionName, duration)
+}
+
+// CheckConnectivity checks the regional endpoint can be reached, or the global
+// endpoint when there's no regional endpoint
+func (c *MultiRegionSTSClient) CheckConnectivity(ctx context.Context) error {
+	if c.regional == nil {
+		return c.global.CheckConnectivity(ctx)
+	}
+	return c.regional.CheckConnectivity(ctx)
+}
+
+// retryGlobal returns whether a regional call that returned err should be
+// retried against the global endpoint. Calls cancelled by ctx aren't retried.
+func (c *MultiRegionSTSClient) retryGlobal(ctx context.Context, role string, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	assumeRoleGlobalFallback.Inc()
+	log.WithFields(log.Fields{"sts.region": c.region, "credentials.role": role}).Warnf("error assuming role with regional sts endpoint, retrying with global endpoint: %s", err.Error())
+	return true
+}
@@ -0,0 +1,141 @@
+package sts
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+)
+
+type stubRegionGateway struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (g *stubRegionGateway) Issue(ctx context.Context, request *STSIssueRequest) (*Credentials, error) {
+	g.calls++
+	if g.err != nil {
+		return nil, g.err
+	}
+	return &Credentials{Code: g.name}, nil
+}
+
+func (g *stubRegionGateway) AssumeRoleWithReport(ctx context.Context, role, sessionName string, duration time.Duration) (*Credentials, *AssumedRoleUser, error) {
+	credentials, err := g.Issue(ctx, &STSIssueRequest{RoleARN: role})
+	return credentials, &AssumedRoleUser{ARN: g.name}, err
+}
+
+func (g *stubRegionGateway) CheckConnectivity(ctx context.Context) error {
+	return g.err
+}
+
+func TestMultiRegionClientRetriesWithGlobalEndpoint(t *testing.T) {
+	var tests = []struct {
+		name        string
+		regional    *stubRegionGateway
+		expected    string
+		globalCalls int
+	}{
+		{"Regional", &stubRegionGateway{name: "regional"}, "regional", 0},
+		{"RegionalFailed", &stubRegionGateway{name: "regional", err: fmt.Errorf("unavailable")}, "global", 1},
+		{"NoRegionalEndpoint", nil, "global", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			global := &stubRegionGateway{name: "global"}
+			client := &MultiRegionSTSClient{region: "eu-west-1", global: global}
+			if tt.regional != nil {
+				client.regional = tt.regional
+			}
+
+			credentials, err := client.Issue(context.Background(), &STSIssueRequest{RoleARN: "arn:aws:iam::123456789012:role/foo"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if credentials.Code != tt.expected {
+				t.Errorf("expected credentials from %s endpoint, were from %s", tt.expected, credentials.Code)
+			}
+			if global.calls != tt.globalCalls {
+				t.Errorf("expected %d calls to global endpoint, was %d", tt.globalCalls, global.calls)
+			}
+		})
+	}
+}
+
+func TestMultiRegionClientRetriesOnce(t *testing.T) {
+	regional := &stubRegionGateway{err: fmt.Errorf("unavailable")}
+	global := &stubRegionGateway{err: fmt.Errorf("unavailable")}
+	client := &MultiRegionSTSClient{region: "eu-west-1", regional: regional, global: global}
+
+	if _, _, err := client.AssumeRoleWithReport(context.Background(), "arn:aws:iam::123456789012:role/foo", "session", 15*time.Minute); err == nil {
+		t.Error("expected error when both endpoints fail")
+	}
+	if regional.calls != 1 || global.calls != 1 {
+		t.Errorf("expected a call to each endpoint, regional was %d and global %d", regional.calls, global.calls)
+	}
+}
+
+func TestMultiRegionClientDoesntRetryCancelledCalls(t *testing.T) {
+	regional := &stubRegionGateway{err: context.Canceled}
+	global := &stubRegionGateway{}
+	client := &MultiRegionSTSClient{region: "eu-west-1", regional: regional, global: global}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client.Issue(ctx, &STSIssueRequest{RoleARN: "arn:aws:iam::123456789012:role/foo"})
+	if global.calls != 0 {
+		t.Error("expected cancelled call not to be retried")
+	}
+}
+
+const assumeRoleResponse = `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>ASIAREGIONAL</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>2030-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleResult>
+</AssumeRoleResponse>`
+
+func TestMultiRegionClientUsesRegionalEndpoint(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprint(w, assumeRoleResponse)
+	}))
+	defer server.Close()
+
+	config := aws.NewConfig().WithCredentials(credentials.NewStaticCredentials("id", "secret", ""))
+	client := NewMultiRegionSTSClient("eu-west-1", map[string]string{"eu-west-1": server.URL}, config)
+
+	credentials, err := client.Issue(context.Background(), &STSIssueRequest{RoleARN: "arn:aws:iam::123456789012:role/foo", SessionName: "session", SessionDuration: 15 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials.AccessKeyId != "ASIAREGIONAL" || requests != 1 {
+		t.Errorf("expected credentials from regional endpoint, were %s after %d requests", credentials.AccessKeyId, requests)
+	}
+}
+
+func TestMultiRegionClientWithoutRegionalEndpointUsesGlobal(t *testing.T) {
+	client := NewMultiRegionSTSClient("eu-west-1", map[string]string{"eu-central-1": "https://sts.eu-central-1.amazonaws.com"})
+	if client.regional != nil {
+		t.Error("expected no regional endpoint")
+	}
+
+	config := client.global.(*DefaultSTSGateway).session.Config
+	if aws.StringValue(config.Endpoint) != "" || config.STSRegionalEndpoint != endpoints.LegacySTSEndpoint {
+		t.Error("expected global endpoint, was", aws.StringValue(config.Endpoint), config.STSRegionalEndpoint)
+	}
+}
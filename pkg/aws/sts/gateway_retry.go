@@ -0,0 +1,124 @@
+package sts
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+const (
+	// DefaultRetryBaseDelay is the delay before the first retry, before jitter
+	DefaultRetryBaseDelay = 100 * time.Millisecond
+	// DefaultRetryMaxDelay truncates the exponential backoff between retries
+	DefaultRetryMaxDelay = 5 * time.Second
+)
+
+// RetryingSTSGateway retries calls to the wrapped gateway that fail with
+// transient errors, such as Throttling or ServiceUnavailable. Retries back
+// off exponentially, truncated at the maximum delay, with full jitter. Calls
+// are retried until they succeed, fail with another error, or the next retry
+// wouldn't start before the context's deadline.
+//
+// AssumeRole doesn't accept an idempotency token. It's safe to retry because
+// assuming a role creates no resources: a retry that follows a call which
+// succeeded without returning issues another set of temporary credentials for
+// the same session, which expire like the first.
+type RetryingSTSGateway struct {
+	gateway   STSGateway
+	baseDelay time.Duration
+	maxDelay  time.Duration
+	sleep     func(ctx context.Context, d time.Duration) error
+}
+
+// NewRetryingSTSGateway creates the gateway retrying gateway's calls, backing
+// off from baseDelay up to maxDelay
+func NewRetryingSTSGateway(gateway STSGateway, baseDelay, maxDelay time.Duration) *RetryingSTSGateway {
+	return &RetryingSTSGateway{gateway: gateway, baseDelay: baseDelay, maxDelay: maxDelay, sleep: sleepContext}
+}
+
+func (g *RetryingSTSGateway) Issue(ctx context.Context, request *STSIssueRequest) (*Credentials, error) {
+	credentials, _, err := g.retry(ctx, func() (*Credentials, *AssumedRoleUser, error) {
+		credentials, err := g.gateway.Issue(ctx, request)
+		return credentials, nil, err
+	})
+	return credentials, err
+}
+
+// AssumeRoleWithReport assumes the role, reporting the assumed role session
+// when the wrapped gateway implements AssumedRoleReporter.
+func (g *RetryingSTSGateway) AssumeRoleWithReport(ctx context.Context, role, sessionName string, duration time.Duration) (*Credentials, *AssumedRoleUser, error) {
+	return g.retry(ctx, func() (*Credentials, *AssumedRoleUser, error) {
+		if reporter, ok := g.gateway.(AssumedRoleReporter); ok {
+			return reporter.AssumeRoleWithReport(ctx, role, sessionName, duration)
+		}
+		credentials, err := g.gateway.Issue(ctx, &STSIssueRequest{RoleARN: role, SessionName: sessionName, SessionDuration: duration})
+		return credentials, nil, err
+	})
+}
+
+func (g *RetryingSTSGateway) retry(ctx context.Context, fn func() (*Credentials, *AssumedRoleUser, error)) (*Credentials, *AssumedRoleUser, error) {
+	for attempt := 0; ; attempt++ {
+		credentials, user, err := fn()
+		if err == nil || !isTransientSTSError(err) {
+			return credentials, user, err
+		}
+
+		delay := g.delay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return nil, nil, err
+		}
+
+		assumeRoleRetries.Inc()
+		if sleepErr := g.sleep(ctx, delay); sleepErr != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// delay returns a random delay up to the exponential backoff for the attempt
+func (g *RetryingSTSGateway) delay(attempt int) time.Duration {
+	backoff := g.maxDelay
+	if attempt < 32 {
+		if d := g.baseDelay << uint(attempt); d > 0 && d < g.maxDelay {
+			backoff = d
+		}
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+// isTransientSTSError returns whether the call failed because STS was
+// throttling requests or temporarily unavailable
+func isTransientSTSError(err error) bool {
+	if IsThrottlingError(err) {
+		return true
+	}
+
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+
+	switch aerr.Code() {
+	case "ServiceUnavailable", "InternalFailure":
+		return true
+	}
+
+	if failure, ok := err.(awserr.RequestFailure); ok {
+		return failure.StatusCode() >= 500
+	}
+	return false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
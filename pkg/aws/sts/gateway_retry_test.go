@@ -0,0 +1,111 @@
+package sts
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// failingGateway fails calls with errs, in order, before succeeding
+type failingGateway struct {
+	errs  []error
+	calls int
+}
+
+func (g *failingGateway) Issue(ctx context.Context, request *STSIssueRequest) (*Credentials, error) {
+	g.calls++
+	if len(g.errs) > 0 {
+		err := g.errs[0]
+		g.errs = g.errs[1:]
+		return nil, err
+	}
+	return NewCredentials("A1234", "secret", "token", time.Now().Add(15*time.Minute)), nil
+}
+
+func TestRetryingGateway(t *testing.T) {
+	throttled := awserr.New("Throttling", "Rate exceeded", nil)
+	unavailable := awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "unavailable", nil), 503, "request-id")
+	serverError := awserr.NewRequestFailure(awserr.New("Unknown", "bad gateway", nil), 502, "request-id")
+	denied := awserr.NewRequestFailure(awserr.New("AccessDenied", "not authorized", nil), 403, "request-id")
+	reset := errors.New("connection reset")
+
+	var tests = []struct {
+		name          string
+		errs          []error
+		expectedCalls int
+		expectedError error
+	}{
+		{"Success", nil, 1, nil},
+		{"RetriesThrottling", []error{throttled, throttled}, 3, nil},
+		{"RetriesUnavailable", []error{unavailable}, 2, nil},
+		{"RetriesServerErrors", []error{serverError}, 2, nil},
+		{"AccessDenied", []error{denied}, 1, denied},
+		{"OtherErrors", []error{reset}, 1, reset},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway := &failingGateway{errs: tt.errs}
+			retrying := NewRetryingSTSGateway(gateway, time.Millisecond, 10*time.Millisecond)
+
+			credentials, err := retrying.Issue(context.Background(), &STSIssueRequest{RoleARN: "arn:aws:iam::123456789012:role/foo"})
+			if err != tt.expectedError {
+				t.Errorf("expected error %v, was %v", tt.expectedError, err)
+			}
+			if err == nil && credentials.AccessKeyId != "A1234" {
+				t.Error("unexpected credentials", credentials)
+			}
+			if gateway.calls != tt.expectedCalls {
+				t.Errorf("expected %d calls, was %d", tt.expectedCalls, gateway.calls)
+			}
+		})
+	}
+}
+
+func TestRetryingGatewayBoundedByDeadline(t *testing.T) {
+	errs := make([]error, 1000)
+	for i := range errs {
+		errs[i] = awserr.New("Throttling", "Rate exceeded", nil)
+	}
+	gateway := &failingGateway{errs: errs}
+	retrying := NewRetryingSTSGateway(gateway, time.Millisecond, 10*time.Millisecond)
+	retries := testutil.ToFloat64(assumeRoleRetries)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := retrying.Issue(ctx, &STSIssueRequest{RoleARN: "arn:aws:iam::123456789012:role/foo"})
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != "Throttling" {
+		t.Error("expected throttling error, was", err)
+	}
+	// the last call is made before the deadline but may return after it, it
+	// mustn't be followed by a sleep past the deadline
+	if elapsed := time.Since(start); elapsed > 75*time.Millisecond {
+		t.Error("expected retries to stop before the deadline, took", elapsed)
+	}
+	if gateway.calls < 2 {
+		t.Error("expected retries, calls were", gateway.calls)
+	}
+	if testutil.ToFloat64(assumeRoleRetries)-retries != float64(gateway.calls-1) {
+		t.Error("expected retries to be counted")
+	}
+}
+
+func TestRetryingGatewayDelayTruncated(t *testing.T) {
+	retrying := NewRetryingSTSGateway(&failingGateway{}, 100*time.Millisecond, time.Second)
+
+	for attempt := 0; attempt < 100; attempt++ {
+		delay := retrying.delay(attempt)
+		if delay < 0 || delay > time.Second {
+			t.Fatalf("attempt %d: delay %s outside bounds", attempt, delay)
+		}
+		if attempt == 0 && delay > 100*time.Millisecond {
+			t.Errorf("first delay %s exceeds base delay", delay)
+		}
+	}
+}
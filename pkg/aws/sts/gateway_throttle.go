@@ -0,0 +1,60 @@
+package sts
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// ThrottleObservingSTSGateway notifies an observer of each call to the
+// wrapped gateway that STS throttled, allowing request rates to be adapted.
+type ThrottleObservingSTSGateway struct {
+	gateway STSGateway
+	observe func()
+}
+
+// NewThrottleObservingSTSGateway creates the gateway calling observe when
+// gateway's calls are throttled. observe is called synchronously and must not
+// block.
+func NewThrottleObservingSTSGateway(gateway STSGateway, observe func()) *ThrottleObservingSTSGateway {
+	return &ThrottleObservingSTSGateway{gateway: gateway, observe: observe}
+}
+
+func (g *ThrottleObservingSTSGateway) Issue(ctx context.Context, request *STSIssueRequest) (*Credentials, error) {
+	credentials, err := g.gateway.Issue(ctx, request)
+	g.check(err)
+	return credentials, err
+}
+
+// AssumeRoleWithReport assumes the role, reporting the assumed role session
+// when the wrapped gateway implements AssumedRoleReporter.
+func (g *ThrottleObservingSTSGateway) AssumeRoleWithReport(ctx context.Context, role, sessionName string, duration time.Duration) (*Credentials, *AssumedRoleUser, error) {
+	if reporter, ok := g.gateway.(AssumedRoleReporter); ok {
+		credentials, user, err := reporter.AssumeRoleWithReport(ctx, role, sessionName, duration)
+		g.check(err)
+		return credentials, user, err
+	}
+	credentials, err := g.Issue(ctx, &STSIssueRequest{RoleARN: role, SessionName: sessionName, SessionDuration: duration})
+	return credentials, nil, err
+}
+
+func (g *ThrottleObservingSTSGateway) check(err error) {
+	if IsThrottlingError(err) {
+		g.observe()
+	}
+}
+
+// IsThrottlingError returns whether the call failed because STS throttled it
+func IsThrottlingError(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+
+	switch aerr.Code() {
+	case "Throttling", "ThrottlingException", "RequestLimitExceeded":
+		return true
+	}
+	return false
+}
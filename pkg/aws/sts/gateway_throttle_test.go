@@ -0,0 +1,43 @@
+package sts
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func TestThrottleObservingGateway(t *testing.T) {
+	var tests = []struct {
+		name      string
+		err       error
+		throttled bool
+	}{
+		{"Success", nil, false},
+		{"Throttling", awserr.New("Throttling", "Rate exceeded", nil), true},
+		{"ThrottlingException", awserr.New("ThrottlingException", "Rate exceeded", nil), true},
+		{"RequestLimitExceeded", awserr.New("RequestLimitExceeded", "Request limit exceeded", nil), true},
+		{"ServiceUnavailable", awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "unavailable", nil), 503, "request-id"), false},
+		{"OtherErrors", errors.New("connection reset"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs []error
+			if tt.err != nil {
+				errs = []error{tt.err}
+			}
+			observed := 0
+			gateway := NewThrottleObservingSTSGateway(&failingGateway{errs: errs}, func() { observed++ })
+
+			_, err := gateway.Issue(context.Background(), &STSIssueRequest{RoleARN: "arn:aws:iam::123456789012:role/foo"})
+			if err != tt.err {
+				t.Errorf("expected error %v, was %v", tt.err, err)
+			}
+			if (observed == 1) != tt.throttled {
+				t.Errorf("expected throttled to be %t, observed %d", tt.throttled, observed)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package sts
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// webIdentityExpiryWindow is how long before expiring the server's web
+// identity credentials are refreshed
+const webIdentityExpiryWindow = 5 * time.Minute
+
+// NewWebIdentitySTSClient creates an STSGateway whose own credentials are
+// issued by assuming roleARN with the web identity token in tokenFilePath (as
+// used by IAM roles for service accounts on EKS). The token file is read each
+// time the credentials are refreshed, shortly before they expire.
+func NewWebIdentitySTSClient(tokenFilePath, roleARN, sessionName string) (*DefaultSTSGateway, error) {
+	cfg, err := NewServerConfigBuilder().WithCredentialsFromWebIdentity(tokenFilePath, roleARN, sessionName)
+	if err != nil {
+		return nil, err
+	}
+	return DefaultGateway(cfg.Config())
+}
+
+// WithCredentialsFromWebIdentity configures the *aws.Config with credentials
+// issued by assuming roleARN with the web identity token in tokenFilePath.
+func (c *configBuilder) WithCredentialsFromWebIdentity(tokenFilePath, roleARN, sessionName string) (*configBuilder, error) {
+	if roleARN == "" {
+		return nil, fmt.Errorf("web identity role arn can't be empty")
+	}
+	if _, err := os.Stat(tokenFilePath); err != nil {
+		return nil, fmt.Errorf("error reading web identity token: %w", err)
+	}
+
+	sess, err := session.NewSession(c.config.Copy())
+	if err != nil {
+		return nil, err
+	}
+
+	provider := stscreds.NewWebIdentityRoleProvider(sts.New(sess), roleARN, sessionName, tokenFilePath)
+	provider.ExpiryWindow = webIdentityExpiryWindow
+	c.config.WithCredentials(credentials.NewCredentials(provider))
+
+	return c, nil
+}
@@ -0,0 +1,65 @@
+package sts
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const assumeRoleWithWebIdentityResponse = `<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>ASIAWEBIDENTITY</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>%s</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`
+
+func TestWithCredentialsFromWebIdentity(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("web-identity-token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var requested http.Header
+	var form map[string][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		requested = r.Header
+		form = r.PostForm
+		fmt.Fprintf(w, assumeRoleWithWebIdentityResponse, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	defer server.Close()
+
+	b := NewServerConfigBuilder()
+	b.Config().WithEndpoint(server.URL).WithRegion("us-east-1")
+	if _, err := b.WithCredentialsFromWebIdentity(tokenFile, "arn:aws:iam::123456789012:role/kiam-server", "kiam"); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := b.Config().Credentials.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.AccessKeyID != "ASIAWEBIDENTITY" {
+		t.Error("unexpected access key", v.AccessKeyID)
+	}
+	if form["WebIdentityToken"][0] != "web-identity-token" || form["RoleArn"][0] != "arn:aws:iam::123456789012:role/kiam-server" {
+		t.Error("unexpected request", form)
+	}
+	if requested.Get("Authorization") != "" {
+		t.Error("expected anonymous request, was signed", requested.Get("Authorization"))
+	}
+}
+
+func TestWithCredentialsFromWebIdentityMissingToken(t *testing.T) {
+	if _, err := NewWebIdentitySTSClient(filepath.Join(t.TempDir(), "missing"), "arn:aws:iam::123456789012:role/kiam-server", "kiam"); err == nil {
+		t.Error("expected error for missing token file")
+	}
+}
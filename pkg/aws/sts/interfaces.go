@@ -15,6 +15,7 @@ package sts
 
 import (
 	"context"
+	"time"
 )
 
 type CredentialsProvider interface {
@@ -26,7 +27,50 @@ type CredentialsCache interface {
 	Expiring() chan *CachedCredentials
 }
 
+// CredentialsExpiration reports when cached credentials expire
+type CredentialsExpiration interface {
+	// Expiration returns the expiration of the credentials cached for
+	// identity, and false when none are cached (or still being issued)
+	Expiration(identity *RoleIdentity) (time.Time, bool)
+}
+
+// AssumedRoleLookup reports the sessions cached credentials were issued for
+type AssumedRoleLookup interface {
+	// AssumedRoleUser returns the session the credentials cached for identity
+	// were issued for, and false when none are cached or the session wasn't
+	// reported by the STSGateway
+	AssumedRoleUser(identity *RoleIdentity) (*AssumedRoleUser, bool)
+}
+
+// CredentialsRevoker removes cached credentials
+type CredentialsRevoker interface {
+	// Revoke removes the credentials cached for identity, returning false
+	// when none were cached
+	Revoke(identity *RoleIdentity) bool
+}
+
+// CredentialsEvicter removes cached credentials without announcing them as
+// expiring
+type CredentialsEvicter interface {
+	// Evict removes the credentials cached for identity, returning false
+	// when none were cached
+	Evict(identity *RoleIdentity) bool
+}
+
+// CachedIdentityLister lists the identities credentials are cached for
+type CachedIdentityLister interface {
+	// CachedIdentities returns the identities with credentials cached for
+	// the role ARN
+	CachedIdentities(roleARN ARN) []*RoleIdentity
+}
+
 // ARNResolver encapsulates resolution of roles into ARNs.
 type ARNResolver interface {
 	Resolve(role string) (*ResolvedRole, error)
 }
+
+// ConnectivityChecker is implemented by STSGateways that can check whether STS
+// is reachable with their credentials
+type ConnectivityChecker interface {
+	CheckConnectivity(ctx context.Context) error
+}
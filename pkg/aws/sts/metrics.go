@@ -29,6 +29,43 @@ var (
 		},
 	)
 
+	cacheEvictions = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "kiam",
+			Subsystem: "sts",
+			Name:      "cache_evictions_total",
+			Help:      "Number of credentials removed from the metadata cache",
+		},
+	)
+
+	cachePrewarm = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "kiam",
+			Subsystem: "sts",
+			Name:      "cache_prewarm_total",
+			Help:      "Number of cached credentials refreshed before expiring",
+		},
+	)
+
+	namespaceCachedRoles = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "kiam",
+			Subsystem: "sts",
+			Name:      "namespace_cached_roles",
+			Help:      "Number of distinct role credentials cached for each namespace",
+		},
+		[]string{"namespace"},
+	)
+
+	namespaceQuotaEvictions = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "kiam",
+			Subsystem: "sts",
+			Name:      "namespace_quota_evictions_total",
+			Help:      "Number of cached credentials evicted for exceeding their namespace's quota",
+		},
+	)
+
 	errorIssuing = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Namespace: "kiam",
@@ -50,6 +87,48 @@ var (
 		},
 	)
 
+	// assumeRoleByRole is exported as kiam_sts_assumerole_role_timing_seconds
+	// with role (the role ARN) and result (success or error) labels.
+	assumeRoleByRole = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "kiam",
+			Subsystem: "sts",
+			Name:      "assumerole_role_timing_seconds",
+			Help:      "Bucketed histogram of assumeRole timings by role and result",
+
+			// 1ms to 5min
+			Buckets: prometheus.ExponentialBuckets(.001, 2, 13),
+		},
+		[]string{"role", "result"},
+	)
+
+	assumeRoleDeduplicated = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "kiam",
+			Subsystem: "sts",
+			Name:      "assumerole_deduplicated_total",
+			Help:      "Number of assume role requests sharing the result of an identical call in flight",
+		},
+	)
+
+	assumeRoleGlobalFallback = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "kiam",
+			Subsystem: "sts",
+			Name:      "assumerole_global_fallback_total",
+			Help:      "Number of failed regional assume role calls retried against the global endpoint",
+		},
+	)
+
+	assumeRoleRetries = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "kiam",
+			Subsystem: "sts",
+			Name:      "assumerole_retries_total",
+			Help:      "Number of assume role calls retried after transient errors",
+		},
+	)
+
 	assumeRoleExecuting = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Namespace: "kiam",
@@ -64,7 +143,30 @@ func init() {
 	prometheus.MustRegister(cacheHit)
 	prometheus.MustRegister(cacheMiss)
 	prometheus.MustRegister(cacheSize)
+	prometheus.MustRegister(cacheEvictions)
+	prometheus.MustRegister(cachePrewarm)
+	prometheus.MustRegister(namespaceCachedRoles)
+	prometheus.MustRegister(namespaceQuotaEvictions)
 	prometheus.MustRegister(errorIssuing)
 	prometheus.MustRegister(assumeRole)
 	prometheus.MustRegister(assumeRoleExecuting)
+	prometheus.MustRegister(assumeRoleDeduplicated)
+	prometheus.MustRegister(assumeRoleGlobalFallback)
+	prometheus.MustRegister(assumeRoleRetries)
+}
+
+// RegisterSTSMetrics registers the metrics of AWS STS calls labelled by role
+// with reg. The metrics are:
+//
+//	kiam_sts_assumerole_role_timing_seconds{role, result}
+//	    histogram of AssumeRole call latency, role is the role's ARN and
+//	    result is success or error.
+//
+// Registering more than once with the same registerer has no effect.
+func RegisterSTSMetrics(reg prometheus.Registerer) error {
+	err := reg.Register(assumeRoleByRole)
+	if _, ok := err.(prometheus.AlreadyRegisteredError); ok {
+		return nil
+	}
+	return err
 }
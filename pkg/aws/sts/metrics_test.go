@@ -0,0 +1,26 @@
+package sts
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRegisterSTSMetricsTwice(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if err := RegisterSTSMetrics(reg); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterSTSMetrics(reg); err != nil {
+		t.Error("expected registering again to succeed, was", err)
+	}
+
+	assumeRoleByRole.WithLabelValues("arn:aws:iam::123456789012:role/red_role", "success").Observe(0.1)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(families) != 1 || families[0].GetName() != "kiam_sts_assumerole_role_timing_seconds" {
+		t.Error("unexpected metrics", families)
+	}
+}
@@ -0,0 +1,196 @@
+// Package mock provides an in-memory STS gateway for tests that can't call
+// AWS.
+package mock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+)
+
+// ErrRoleNotConfigured is returned when assuming a role that has neither
+// credentials nor an error configured and credentials aren't generated.
+var ErrRoleNotConfigured = errors.New("mock: no credentials configured for role")
+
+// TestingT is the subset of testing.TB used by the assertions.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// MockCredentialProvider implements sts.STSGateway and
+// sts.AssumedRoleReporter. Credentials or errors are configured per role,
+// either by ARN or by role name, and every request is recorded.
+type MockCredentialProvider struct {
+	// Now returns the current time used for generated credentials
+	Now func() time.Time
+
+	mu          sync.Mutex
+	ttl         time.Duration
+	credentials map[string]*sts.Credentials
+	errors      map[string]error
+	calls       []sts.STSIssueRequest
+	generated   int
+}
+
+var (
+	_ sts.STSGateway          = &MockCredentialProvider{}
+	_ sts.AssumedRoleReporter = &MockCredentialProvider{}
+)
+
+// NewMock creates a provider returning ErrRoleNotConfigured for roles without
+// configured credentials.
+func NewMock() *MockCredentialProvider {
+	return &MockCredentialProvider{
+		Now:         time.Now,
+		credentials: map[string]*sts.Credentials{},
+		errors:      map[string]error{},
+	}
+}
+
+// NewMockWithExpiry creates a provider generating unique credentials, expiring
+// after ttl, for roles without configured credentials.
+func NewMockWithExpiry(ttl time.Duration) *MockCredentialProvider {
+	m := NewMock()
+	m.ttl = ttl
+	return m
+}
+
+// SetCredentials configures the credentials returned for role, an ARN or role
+// name.
+func (m *MockCredentialProvider) SetCredentials(role string, credentials *sts.Credentials) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.errors, role)
+	m.credentials[role] = credentials
+}
+
+// SetError configures the error returned for role, an ARN or role name.
+func (m *MockCredentialProvider) SetError(role string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.credentials, role)
+	m.errors[role] = err
+}
+
+func (m *MockCredentialProvider) Issue(ctx context.Context, request *sts.STSIssueRequest) (*sts.Credentials, error) {
+	credentials, _, err := m.issue(ctx, *request)
+	return credentials, err
+}
+
+func (m *MockCredentialProvider) AssumeRoleWithReport(ctx context.Context, role, sessionName string, duration time.Duration) (*sts.Credentials, *sts.AssumedRoleUser, error) {
+	return m.issue(ctx, sts.STSIssueRequest{RoleARN: role, SessionName: sessionName, SessionDuration: duration})
+}
+
+func (m *MockCredentialProvider) issue(ctx context.Context, request sts.STSIssueRequest) (*sts.Credentials, *sts.AssumedRoleUser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls = append(m.calls, request)
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	for _, key := range []string{request.RoleARN, roleName(request.RoleARN)} {
+		if err, ok := m.errors[key]; ok {
+			return nil, nil, err
+		}
+		if credentials, ok := m.credentials[key]; ok {
+			return credentials, assumedRoleUser(request), nil
+		}
+	}
+
+	if m.ttl <= 0 {
+		return nil, nil, fmt.Errorf("%w: %s", ErrRoleNotConfigured, request.RoleARN)
+	}
+
+	m.generated++
+	credentials := sts.NewCredentials(
+		fmt.Sprintf("ASIAMOCK%012d", m.generated),
+		fmt.Sprintf("mock-secret-%d", m.generated),
+		fmt.Sprintf("mock-token-%d", m.generated),
+		m.Now().Add(m.ttl),
+	)
+	return credentials, assumedRoleUser(request), nil
+}
+
+// Calls returns the requests made, in order.
+func (m *MockCredentialProvider) Calls() []sts.STSIssueRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	calls := make([]sts.STSIssueRequest, len(m.calls))
+	copy(calls, m.calls)
+	return calls
+}
+
+// CallCount returns the number of requests for role, an ARN or role name.
+// An empty role counts all requests.
+func (m *MockCredentialProvider) CallCount(role string) int {
+	count := 0
+	for _, call := range m.Calls() {
+		if role == "" || call.RoleARN == role || roleName(call.RoleARN) == role {
+			count++
+		}
+	}
+	return count
+}
+
+// Reset forgets the recorded requests.
+func (m *MockCredentialProvider) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+}
+
+// AssertCallCount fails t unless role was requested expected times.
+func (m *MockCredentialProvider) AssertCallCount(t TestingT, role string, expected int) {
+	t.Helper()
+	if count := m.CallCount(role); count != expected {
+		t.Errorf("expected %d AssumeRole calls for '%s', was %d", expected, role, count)
+	}
+}
+
+// AssertCalled fails t unless a request equal to expected was made.
+func (m *MockCredentialProvider) AssertCalled(t TestingT, expected sts.STSIssueRequest) {
+	t.Helper()
+	calls := m.Calls()
+	for _, call := range calls {
+		if reflect.DeepEqual(call, expected) {
+			return
+		}
+	}
+	t.Errorf("expected AssumeRole call %+v, calls were %+v", expected, calls)
+}
+
+// roleName returns the name of the role in arn, including its path.
+func roleName(arn string) string {
+	if i := strings.Index(arn, ":role/"); i >= 0 {
+		return arn[i+len(":role/"):]
+	}
+	return arn
+}
+
+func assumedRoleUser(request sts.STSIssueRequest) *sts.AssumedRoleUser {
+	name := roleName(request.RoleARN)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	account := "123456789012"
+	if parsed, err := sts.ParseARN(request.RoleARN); err == nil {
+		account = parsed.AccountID
+	}
+
+	return &sts.AssumedRoleUser{
+		ARN:           fmt.Sprintf("arn:aws:sts::%s:assumed-role/%s/%s", account, name, request.SessionName),
+		AssumedRoleID: fmt.Sprintf("AROAMOCK:%s", request.SessionName),
+	}
+}
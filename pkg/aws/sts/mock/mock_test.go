@@ -0,0 +1,88 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+)
+
+type recordingT struct {
+	errors []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestMockReturnsConfiguredCredentials(t *testing.T) {
+	m := NewMock()
+	expected := sts.NewCredentials("A1", "S1", "T1", time.Now().Add(time.Hour))
+	m.SetCredentials("red_role", expected)
+	m.SetError("arn:aws:iam::123456789012:role/blue_role", errors.New("access denied"))
+
+	credentials, err := m.Issue(context.Background(), &sts.STSIssueRequest{RoleARN: "arn:aws:iam::123456789012:role/red_role", SessionName: "kiam"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials != expected {
+		t.Error("unexpected credentials", credentials)
+	}
+
+	if _, err := m.Issue(context.Background(), &sts.STSIssueRequest{RoleARN: "arn:aws:iam::123456789012:role/blue_role"}); err == nil || err.Error() != "access denied" {
+		t.Error("expected configured error, was", err)
+	}
+
+	if _, err := m.Issue(context.Background(), &sts.STSIssueRequest{RoleARN: "arn:aws:iam::123456789012:role/green_role"}); !errors.Is(err, ErrRoleNotConfigured) {
+		t.Error("expected not configured error, was", err)
+	}
+
+	m.AssertCallCount(t, "red_role", 1)
+	m.AssertCallCount(t, "", 3)
+	m.AssertCalled(t, sts.STSIssueRequest{RoleARN: "arn:aws:iam::123456789012:role/red_role", SessionName: "kiam"})
+}
+
+func TestMockWithExpiryGeneratesCredentials(t *testing.T) {
+	now := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := NewMockWithExpiry(15 * time.Minute)
+	m.Now = func() time.Time { return now }
+
+	first, user, err := m.AssumeRoleWithReport(context.Background(), "arn:aws:iam::123456789012:role/red_role", "kiam", 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := m.AssumeRoleWithReport(context.Background(), "arn:aws:iam::123456789012:role/red_role", "kiam", 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.AccessKeyId == second.AccessKeyId {
+		t.Error("expected unique access keys")
+	}
+	if first.Expiration != "2018-01-01T12:15:00Z" {
+		t.Error("unexpected expiration", first.Expiration)
+	}
+	if user.ARN != "arn:aws:sts::123456789012:assumed-role/red_role/kiam" {
+		t.Error("unexpected assumed role arn", user.ARN)
+	}
+}
+
+func TestMockAssertions(t *testing.T) {
+	m := NewMockWithExpiry(time.Hour)
+	m.Issue(context.Background(), &sts.STSIssueRequest{RoleARN: "arn:aws:iam::123456789012:role/red_role"})
+
+	r := &recordingT{}
+	m.AssertCallCount(r, "red_role", 2)
+	m.AssertCalled(r, sts.STSIssueRequest{RoleARN: "arn:aws:iam::123456789012:role/blue_role"})
+	if len(r.errors) != 2 {
+		t.Error("expected assertions to fail, were", r.errors)
+	}
+
+	m.Reset()
+	m.AssertCallCount(t, "", 0)
+}
@@ -2,6 +2,8 @@ package sts
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -10,6 +12,8 @@ type RoleIdentity struct {
 	Role        ResolvedRole
 	SessionName string
 	ExternalID  string
+	// SessionTags are passed as session tags when assuming the role
+	SessionTags map[string]string
 }
 
 func NewRoleIdentity(arnResolver ARNResolver, role, sessionName, externalID string) (*RoleIdentity, error) {
@@ -26,12 +30,25 @@ func NewRoleIdentity(arnResolver ARNResolver, role, sessionName, externalID stri
 }
 
 func (i *RoleIdentity) String() string {
-	return fmt.Sprintf("%s|%s|%s", i.Role.ARN, i.SessionName, i.ExternalID)
+	if len(i.SessionTags) == 0 {
+		return fmt.Sprintf("%s|%s|%s", i.Role.ARN.String(), i.SessionName, i.ExternalID)
+	}
+	return fmt.Sprintf("%s|%s|%s|%s", i.Role.ARN.String(), i.SessionName, i.ExternalID, formatTags(i.SessionTags))
+}
+
+// formatTags returns the tags as key=value pairs sorted by key
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
 }
 
 func (i *RoleIdentity) LogFields() log.Fields {
 	return log.Fields{
 		"pod.iam.role":    i.Role,
-		"pod.iam.roleArn": i.Role.ARN,
+		"pod.iam.roleArn": i.Role.ARN.String(),
 	}
 }
@@ -34,6 +34,11 @@ func (f *Future) Get(ctx context.Context) (interface{}, error) {
 	}
 }
 
+// Done returns a channel that's closed once the value is available
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
 func New(f FutureFn) *Future {
 	future := &Future{
 		done: make(chan struct{}),
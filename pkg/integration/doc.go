@@ -0,0 +1,14 @@
+// Package integration contains tests that exercise kiam's policies against a
+// real Kubernetes API server, rather than the fakes used by the unit tests.
+//
+// The tests are built with the integration tag and run against the API server
+// in the kubeconfig named by KIAM_INTEGRATION_KUBECONFIG. make test-integration
+// creates a kind cluster for the run and deletes it afterwards; envtest needs a
+// much newer client-go than the v7 this module uses so can't be used. To run
+// against an existing cluster:
+//
+//	KIAM_INTEGRATION_KUBECONFIG=$HOME/.kube/config go test -tags=integration ./pkg/integration/...
+//
+// Each test creates its own namespaces, which are deleted when it finishes.
+// Tests fail when no kubeconfig is given.
+package integration
@@ -0,0 +1,164 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// kubeconfigEnv names the kubeconfig of the API server the tests run against
+	kubeconfigEnv = "KIAM_INTEGRATION_KUBECONFIG"
+
+	syncTimeout = 30 * time.Second
+)
+
+// environment is a client for the API server, along with the pod and
+// namespace caches kiam's server would watch it with
+type environment struct {
+	t                 *testing.T
+	client            *kubernetes.Clientset
+	pods              *k8s.PodCache
+	namespaces        *k8s.NamespaceCache
+	resolver          sts.ARNResolver
+	createdPods       []*v1.Pod
+	createdNamespaces []string
+	stop              context.CancelFunc
+}
+
+// newEnvironment connects to the API server and starts the caches. Close
+// stops them and deletes the namespaces created.
+func newEnvironment(t *testing.T) *environment {
+	kubeconfig := os.Getenv(kubeconfigEnv)
+	if kubeconfig == "" {
+		t.Fatalf("%s must name the kubeconfig of the API server to test against, make test-integration creates a kind cluster", kubeconfigEnv)
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		t.Fatal("error loading kubeconfig:", err)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		t.Fatal("error creating client:", err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	resolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+	env := &environment{
+		t:          t,
+		client:     client,
+		pods:       k8s.NewPodCache(resolver, k8s.NewListWatch(client, k8s.ResourcePods), time.Minute, 100),
+		namespaces: k8s.NewNamespaceCache(k8s.NewListWatch(client, k8s.ResourceNamespaces), time.Minute),
+		resolver:   resolver,
+		stop:       stop,
+	}
+
+	if err := env.pods.Run(ctx); err != nil {
+		stop()
+		t.Fatal("error starting pod cache:", err)
+	}
+	if err := env.namespaces.Run(ctx); err != nil {
+		stop()
+		t.Fatal("error starting namespace cache:", err)
+	}
+	return env
+}
+
+// Close stops the caches and deletes the pods and namespaces created. Pods are
+// deleted immediately, as there may be no kubelet or namespace controller to
+// remove them, so their IPs can be reused.
+func (e *environment) Close() {
+	e.stop()
+	immediately := int64(0)
+	for _, pod := range e.createdPods {
+		if err := e.client.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &metav1.DeleteOptions{GracePeriodSeconds: &immediately}); err != nil {
+			e.t.Logf("error deleting pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		}
+	}
+	for _, name := range e.createdNamespaces {
+		if err := e.client.CoreV1().Namespaces().Delete(name, &metav1.DeleteOptions{}); err != nil {
+			e.t.Logf("error deleting namespace %s: %v", name, err)
+		}
+	}
+}
+
+// createNamespace creates a uniquely named namespace with the annotations,
+// and its default service account, waiting for the namespace to be cached
+func (e *environment) createNamespace(annotations map[string]string) string {
+	name := fmt.Sprintf("kiam-integration-%d", time.Now().UnixNano())
+	_, err := e.client.CoreV1().Namespaces().Create(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}})
+	if err != nil {
+		e.t.Fatal("error creating namespace:", err)
+	}
+	e.createdNamespaces = append(e.createdNamespaces, name)
+
+	// there's no controller manager creating the default service account
+	// when the API server was started without one
+	_, err = e.client.CoreV1().ServiceAccounts(name).Create(&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		e.t.Fatal("error creating service account:", err)
+	}
+
+	e.eventually(func() (bool, error) {
+		ns, err := e.namespaces.FindNamespace(context.Background(), name)
+		return ns != nil, err
+	})
+	return name
+}
+
+// createPod creates a running pod with the ip and annotations, waiting for
+// it to be cached
+func (e *environment) createPod(namespace, name, ip string, annotations map[string]string) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Annotations: annotations},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: "app", Image: "busybox"}},
+		},
+	}
+	created, err := e.client.CoreV1().Pods(namespace).Create(pod)
+	if err != nil {
+		e.t.Fatal("error creating pod:", err)
+	}
+	e.createdPods = append(e.createdPods, created)
+
+	// there's no kubelet running the pod, report it as running
+	created.Status.Phase = v1.PodRunning
+	created.Status.PodIP = ip
+	if _, err := e.client.CoreV1().Pods(namespace).UpdateStatus(created); err != nil {
+		e.t.Fatal("error updating pod status:", err)
+	}
+
+	var cached *v1.Pod
+	e.eventually(func() (bool, error) {
+		pod, err := e.pods.GetPodByIP(ip)
+		if err == k8s.ErrPodNotFound {
+			return false, nil
+		}
+		cached = pod
+		return err == nil, err
+	})
+	return cached
+}
+
+// eventually waits for condition, failing the test if it's not met before the
+// sync timeout
+func (e *environment) eventually(condition wait.ConditionFunc) {
+	if err := wait.PollImmediate(100*time.Millisecond, syncTimeout, condition); err != nil {
+		e.t.Fatal("error waiting for cache:", err)
+	}
+}
@@ -0,0 +1,93 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	"github.com/uswitch/kiam/pkg/server"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAssumeRolePolicyChain(t *testing.T) {
+	env := newEnvironment(t)
+	defer env.Close()
+
+	permitted := env.createNamespace(map[string]string{k8s.AnnotationPermittedKey: "^reader$"})
+	unannotated := env.createNamespace(nil)
+
+	policy := server.Policies(
+		server.NewRequestingAnnotatedRolePolicy(env.pods, env.resolver),
+		server.NewNamespacePermittedRoleNamePolicy(false, env.namespaces, env.resolver),
+	)
+
+	var tests = []struct {
+		name        string
+		namespace   string
+		ip          string
+		annotations map[string]string
+		role        string
+		allowed     bool
+	}{
+		{"AnnotatedPermittedRole", permitted, "192.0.2.1", map[string]string{k8s.AnnotationIAMRoleKey: "reader"}, "reader", true},
+		{"AnnotatedPermittedRoleARN", permitted, "192.0.2.2", map[string]string{k8s.AnnotationIAMRoleKey: "arn:aws:iam::123456789012:role/reader"}, "reader", true},
+		{"RequestsOtherRole", permitted, "192.0.2.3", map[string]string{k8s.AnnotationIAMRoleKey: "reader"}, "writer", false},
+		{"AnnotatedRoleNotPermitted", permitted, "192.0.2.4", map[string]string{k8s.AnnotationIAMRoleKey: "writer"}, "writer", false},
+		{"PodNotAnnotated", permitted, "192.0.2.5", nil, "reader", false},
+		{"NamespaceNotAnnotated", unannotated, "192.0.2.6", map[string]string{k8s.AnnotationIAMRoleKey: "reader"}, "reader", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := env.createPod(tt.namespace, "pod-"+tt.ip, tt.ip, tt.annotations)
+
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), tt.role, pod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.allowed {
+				t.Errorf("expected allowed to be %t, was %t: %s", tt.allowed, decision.IsAllowed(), decision.Explanation())
+			}
+		})
+	}
+}
+
+func TestAssumeRolePolicyChainSeesNamespaceUpdates(t *testing.T) {
+	env := newEnvironment(t)
+	defer env.Close()
+
+	namespace := env.createNamespace(map[string]string{k8s.AnnotationPermittedKey: "^reader$"})
+	pod := env.createPod(namespace, "pod", "192.0.2.10", map[string]string{k8s.AnnotationIAMRoleKey: "writer"})
+	policy := server.Policies(
+		server.NewRequestingAnnotatedRolePolicy(env.pods, env.resolver),
+		server.NewNamespacePermittedRoleNamePolicy(false, env.namespaces, env.resolver),
+	)
+
+	decision, err := policy.IsAllowedAssumeRole(context.Background(), "writer", pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.IsAllowed() {
+		t.Fatal("expected writer not to be permitted")
+	}
+
+	ns, err := env.client.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns.Annotations[k8s.AnnotationPermittedKey] = "^(reader|writer)$"
+	if _, err := env.client.CoreV1().Namespaces().Update(ns); err != nil {
+		t.Fatal(err)
+	}
+
+	env.eventually(func() (bool, error) {
+		decision, err := policy.IsAllowedAssumeRole(context.Background(), "writer", pod)
+		if err != nil {
+			return false, err
+		}
+		return decision.IsAllowed(), nil
+	})
+}
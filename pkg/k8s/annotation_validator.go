@@ -0,0 +1,53 @@
+package k8s
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	v1 "k8s.io/api/core/v1"
+)
+
+var (
+	// roleARNPattern matches IAM role ARNs, arn:partition:iam::account-id:role/path/name
+	roleARNPattern = regexp.MustCompile(`^arn:aws[a-z-]*:iam::[0-9]{12}:role/([\w+=,.@-]+/)*[\w+=,.@-]{1,64}$`)
+	// roleNamePattern matches role names, optionally with a path or account
+	// alias, that are resolved into ARNs
+	roleNamePattern = regexp.MustCompile(`^/?([\w+=,.@-]+/)*[\w+=,.@-]{1,64}$`)
+)
+
+// ValidateAnnotation checks the IAM role annotation is a valid role ARN or role
+// name, catching invalid roles before pods request credentials. Annotations
+// encrypted with KMS aren't checked.
+func ValidateAnnotation(annotation string) error {
+	switch {
+	case annotation == "":
+		return fmt.Errorf("role can't be empty")
+	case strings.HasPrefix(annotation, sts.KMSCiphertextPrefix):
+		return nil
+	case strings.HasPrefix(annotation, "arn:"):
+		if !roleARNPattern.MatchString(annotation) {
+			return fmt.Errorf("invalid role arn '%s', expected arn:partition:iam::account-id:role/role-name", annotation)
+		}
+	default:
+		if !roleNamePattern.MatchString(annotation) {
+			return fmt.Errorf("invalid role name '%s'", annotation)
+		}
+	}
+	return nil
+}
+
+// HasValidIAMAnnotation returns whether the pod is annotated with a role that
+// passes ValidateAnnotation. Pods without the annotation, or with an empty or
+// whitespace value, don't have a valid annotation.
+func HasValidIAMAnnotation(pod *v1.Pod) bool {
+	if pod == nil {
+		return false
+	}
+	role, ok := pod.GetAnnotations()[AnnotationIAMRoleKey]
+	if !ok || strings.TrimSpace(role) == "" {
+		return false
+	}
+	return ValidateAnnotation(role) == nil
+}
@@ -0,0 +1,74 @@
+package k8s
+
+import (
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateAnnotation(t *testing.T) {
+	var tests = []struct {
+		annotation string
+		valid      bool
+	}{
+		{"reader", true},
+		{"/reader", true},
+		{"path/to/reader", true},
+		{"prod/reader", true},
+		{"arn:aws:iam::123456789012:role/reader", true},
+		{"arn:aws-us-gov:iam::123456789012:role/path/reader", true},
+		{"kms:v1:Y2lwaGVydGV4dA==", true},
+		{"", false},
+		{"reader role", false},
+		{"reader/", false},
+		{strings.Repeat("r", 65), false},
+		{"arn:aws:iam::123456789012:user/reader", false},
+		{"arn:aws:iam::1234:role/reader", false},
+		{"arn:aws:iam:eu-west-1:123456789012:role/reader", false},
+		{"arn:aws:s3:::bucket", false},
+		{"arn:aws:iam::123456789012:role/", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.annotation, func(t *testing.T) {
+			err := ValidateAnnotation(tt.annotation)
+			if (err == nil) != tt.valid {
+				t.Errorf("expected valid to be %t, error was %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestHasValidIAMAnnotation(t *testing.T) {
+	annotated := func(role string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{AnnotationIAMRoleKey: role}}}
+	}
+
+	var tests = []struct {
+		name  string
+		pod   *v1.Pod
+		valid bool
+	}{
+		{"NilPod", nil, false},
+		{"EmptyPod", &v1.Pod{}, false},
+		{"NilAnnotations", &v1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: nil}}, false},
+		{"OtherAnnotations", &v1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"foo": "bar"}}}, false},
+		{"EmptyValue", annotated(""), false},
+		{"WhitespaceValue", annotated("  \t"), false},
+		{"RoleName", annotated("reader"), true},
+		{"RolePath", annotated("/kiam/reader"), true},
+		{"RoleARN", annotated("arn:aws:iam::123456789012:role/reader"), true},
+		{"InvalidRoleName", annotated("reader role"), false},
+		{"InvalidARN", annotated("arn:aws:s3:::bucket"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if valid := HasValidIAMAnnotation(tt.pod); valid != tt.valid {
+				t.Errorf("expected valid to be %t, was %t", tt.valid, valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/logging"
+	v1 "k8s.io/api/core/v1"
+)
+
+// CachingNamespaceFinder wraps a NamespaceFinder keeping a snapshot of the last
+// namespace successfully found for each name. When the inner finder errors
+// (such as during Kubernetes API outages) snapshots younger than the TTL are
+// returned instead.
+type CachingNamespaceFinder struct {
+	inner NamespaceFinder
+	ttl   time.Duration
+	clock func() time.Time
+
+	mu        sync.RWMutex
+	snapshots map[string]*namespaceSnapshot
+}
+
+type namespaceSnapshot struct {
+	namespace *v1.Namespace
+	updated   time.Time
+}
+
+// NewCachingNamespaceFinder creates a finder serving snapshots up to ttl old
+// when inner fails.
+func NewCachingNamespaceFinder(inner NamespaceFinder, ttl time.Duration) *CachingNamespaceFinder {
+	return &CachingNamespaceFinder{
+		inner:     inner,
+		ttl:       ttl,
+		clock:     time.Now,
+		snapshots: map[string]*namespaceSnapshot{},
+	}
+}
+
+// Prewarm lists all namespaces from the inner finder, which must implement
+// NamespaceLister, storing snapshots of them so they're available before
+// they're first found.
+func (c *CachingNamespaceFinder) Prewarm(ctx context.Context) error {
+	lister, ok := c.inner.(NamespaceLister)
+	if !ok {
+		return fmt.Errorf("namespace finder %T can't list namespaces", c.inner)
+	}
+
+	namespaces, err := lister.ListNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	updated := c.clock()
+	for _, ns := range namespaces {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.snapshots[ns.Name] = &namespaceSnapshot{namespace: ns.DeepCopy(), updated: updated}
+	}
+
+	logging.FromContext(ctx).Info("prewarmed namespace snapshots", "namespaces", len(namespaces))
+	return nil
+}
+
+func (c *CachingNamespaceFinder) FindNamespace(ctx context.Context, name string) (*v1.Namespace, error) {
+	ns, err := c.inner.FindNamespace(ctx, name)
+	if err != nil {
+		return c.stale(ctx, name, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ns == nil {
+		delete(c.snapshots, name)
+		return nil, nil
+	}
+
+	snapshot := &namespaceSnapshot{namespace: ns.DeepCopy(), updated: c.clock()}
+	c.snapshots[name] = snapshot
+
+	return snapshot.namespace, nil
+}
+
+// stale returns the snapshot for name if it is within the TTL, otherwise err.
+func (c *CachingNamespaceFinder) stale(ctx context.Context, name string, err error) (*v1.Namespace, error) {
+	c.mu.RLock()
+	snapshot, ok := c.snapshots[name]
+	c.mu.RUnlock()
+
+	if !ok {
+		return nil, err
+	}
+
+	age := c.clock().Sub(snapshot.updated)
+	if age > c.ttl {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).With(namespaceAttrs(snapshot.namespace)...).Warn("error finding namespace, using snapshot", "snapshot.age", age, "error", err)
+	return snapshot.namespace, nil
+}
@@ -0,0 +1,122 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/testutil"
+	v1 "k8s.io/api/core/v1"
+)
+
+type flakyNamespaceFinder struct {
+	namespace *v1.Namespace
+	err       error
+}
+
+func (f *flakyNamespaceFinder) FindNamespace(ctx context.Context, name string) (*v1.Namespace, error) {
+	return f.namespace, f.err
+}
+
+func TestCachingNamespaceFinderServesSnapshotDuringErrors(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	inner := &flakyNamespaceFinder{namespace: testutil.NewNamespace("red", "red_.*")}
+	finder := NewCachingNamespaceFinder(inner, time.Minute)
+	finder.clock = func() time.Time { return now }
+
+	ns, err := finder.FindNamespace(context.Background(), "red")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// snapshots aren't affected by changes to the namespace found
+	inner.namespace.Annotations[AnnotationPermittedKey] = "changed"
+	if ns.Annotations[AnnotationPermittedKey] != "red_.*" {
+		t.Error("expected snapshot to be a copy, was", ns.Annotations[AnnotationPermittedKey])
+	}
+
+	inner.err = fmt.Errorf("connection refused")
+	now = now.Add(30 * time.Second)
+	ns, err = finder.FindNamespace(context.Background(), "red")
+	if err != nil {
+		t.Fatal("expected snapshot to be served, was", err)
+	}
+	if ns.Annotations[AnnotationPermittedKey] != "red_.*" {
+		t.Error("unexpected snapshot annotation", ns.Annotations[AnnotationPermittedKey])
+	}
+
+	now = now.Add(time.Minute)
+	_, err = finder.FindNamespace(context.Background(), "red")
+	if err != inner.err {
+		t.Error("expected error once snapshot is stale, was", err)
+	}
+
+	_, err = finder.FindNamespace(context.Background(), "blue")
+	if err != inner.err {
+		t.Error("expected error without snapshot, was", err)
+	}
+}
+
+func TestCachingNamespaceFinderForgetsMissingNamespaces(t *testing.T) {
+	inner := &flakyNamespaceFinder{namespace: testutil.NewNamespace("red", "red_.*")}
+	finder := NewCachingNamespaceFinder(inner, time.Minute)
+
+	finder.FindNamespace(context.Background(), "red")
+
+	inner.namespace = nil
+	ns, err := finder.FindNamespace(context.Background(), "red")
+	if ns != nil || err != nil {
+		t.Fatal("expected missing namespace, was", ns, err)
+	}
+
+	inner.err = fmt.Errorf("connection refused")
+	_, err = finder.FindNamespace(context.Background(), "red")
+	if err != inner.err {
+		t.Error("expected deleted namespace not to be served from snapshot, was", err)
+	}
+}
+
+type listingNamespaceFinder struct {
+	flakyNamespaceFinder
+	namespaces []*v1.Namespace
+}
+
+func (f *listingNamespaceFinder) ListNamespaces(ctx context.Context) ([]*v1.Namespace, error) {
+	return f.namespaces, f.err
+}
+
+func TestCachingNamespaceFinderPrewarm(t *testing.T) {
+	inner := &listingNamespaceFinder{namespaces: []*v1.Namespace{
+		testutil.NewNamespace("red", "red_.*"),
+		testutil.NewNamespace("blue", "blue_.*"),
+	}}
+	finder := NewCachingNamespaceFinder(inner, time.Minute)
+
+	if err := finder.Prewarm(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// snapshots are served without the namespaces having been found before
+	inner.err = fmt.Errorf("connection refused")
+	for _, name := range []string{"red", "blue"} {
+		ns, err := finder.FindNamespace(context.Background(), name)
+		if err != nil {
+			t.Fatal("expected prewarmed snapshot, was", err)
+		}
+		if ns.Name != name {
+			t.Error("unexpected namespace", ns.Name)
+		}
+	}
+
+	if err := finder.Prewarm(context.Background()); err != inner.err {
+		t.Error("expected list error, was", err)
+	}
+}
+
+func TestCachingNamespaceFinderPrewarmRequiresLister(t *testing.T) {
+	finder := NewCachingNamespaceFinder(&flakyNamespaceFinder{}, time.Minute)
+	if err := finder.Prewarm(context.Background()); err == nil {
+		t.Error("expected error prewarming without a lister")
+	}
+}
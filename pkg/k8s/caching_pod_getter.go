@@ -0,0 +1,41 @@
+package k8s
+
+import (
+	"log/slog"
+
+	v1 "k8s.io/api/core/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// CachingPodGetter implements PodGetter with the store of a shared pod
+// informer, so lookups don't make requests to the API server. The store is
+// updated by the informer as pods change; the informer must be started (for
+// example by its SharedInformerFactory) for pods to be found.
+type CachingPodGetter struct {
+	informer cache.SharedIndexInformer
+	logger   *slog.Logger
+}
+
+// NewCachingPodGetter creates a getter using informer's store, logging lookups
+// with logger. Pods are indexed by IP address, the informer must not have been
+// started.
+func NewCachingPodGetter(informer coreinformers.PodInformer, logger *slog.Logger) (*CachingPodGetter, error) {
+	i := informer.Informer()
+	if _, exists := i.GetIndexer().GetIndexers()[indexPodIP]; !exists {
+		if err := i.AddIndexers(cache.Indexers{indexPodIP: podIPIndex}); err != nil {
+			return nil, err
+		}
+	}
+	return &CachingPodGetter{informer: i, logger: logger}, nil
+}
+
+// HasSynced returns true once the informer's store has been populated
+func (g *CachingPodGetter) HasSynced() bool {
+	return g.informer.HasSynced()
+}
+
+// GetPodByIP returns the active Pod with the provided IP address
+func (g *CachingPodGetter) GetPodByIP(ip string) (*v1.Pod, error) {
+	return findPodForIP(g.logger, g.informer.GetIndexer(), ip)
+}
@@ -0,0 +1,74 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/logging"
+	"github.com/uswitch/kiam/pkg/testutil"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestCachingPodGetterUpdatesOnPodChange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pod := testutil.NewPodWithRole("ns", "name", "192.168.0.1", testutil.PhaseRunning, "foo_role")
+	client := fake.NewSimpleClientset(pod)
+	factory := informers.NewSharedInformerFactory(client, 0)
+	getter, err := NewCachingPodGetter(factory.Core().V1().Pods(), logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), getter.HasSynced) {
+		t.Fatal("cache didn't sync")
+	}
+
+	found, err := getter.GetPodByIP("192.168.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if PodRole(found) != "foo_role" {
+		t.Error("unexpected role, was", PodRole(found))
+	}
+
+	updated := pod.DeepCopy()
+	updated.Annotations[AnnotationIAMRoleKey] = "bar_role"
+	if _, err := client.CoreV1().Pods("ns").Update(updated); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		found, err = getter.GetPodByIP("192.168.0.1")
+		if err == nil && PodRole(found) == "bar_role" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil || PodRole(found) != "bar_role" {
+		t.Fatalf("expected updated pod, was %v (%v)", found, err)
+	}
+
+	completed := updated.DeepCopy()
+	completed.Status.Phase = testutil.PhaseSucceeded
+	if _, err := client.CoreV1().Pods("ns").Update(completed); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline = time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err = getter.GetPodByIP("192.168.0.1"); err == ErrPodNotFound {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != ErrPodNotFound {
+		t.Error("expected completed pod not to be found, was", err)
+	}
+}
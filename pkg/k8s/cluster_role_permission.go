@@ -0,0 +1,214 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/logging"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ClusterRolePermissionResource identifies the cluster scoped
+// ClusterRolePermission custom resource
+var ClusterRolePermissionResource = schema.GroupVersionResource{
+	Group:    "iam.amazonaws.com",
+	Version:  "v1alpha1",
+	Resource: "clusterrolepermissions",
+}
+
+// ClusterRolePermission permits pods in the namespaces matching a label
+// selector to assume roles matching any of the role regexps, in the same way
+// as the namespace permitted annotation. The regexps are the spec's roles and
+// rolePattern. An empty selector matches all namespaces.
+type ClusterRolePermission struct {
+	Name              string
+	NamespaceSelector labels.Selector
+	Roles             []string
+}
+
+// Matches returns true if the permission applies to the namespace
+func (p *ClusterRolePermission) Matches(namespace *v1.Namespace) bool {
+	return p.NamespaceSelector.Matches(labels.Set(namespace.GetLabels()))
+}
+
+// ParseClusterRolePermission reads the permission's spec. The spec requires a
+// namespaceSelector and at least one role or a rolePattern.
+func ParseClusterRolePermission(obj *unstructured.Unstructured) (*ClusterRolePermission, error) {
+	rawSelector, found, err := unstructured.NestedMap(obj.Object, "spec", "namespaceSelector")
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("clusterrolepermission %s has no namespaceSelector", obj.GetName())
+	}
+
+	labelSelector := &metav1.LabelSelector{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawSelector, labelSelector); err != nil {
+		return nil, fmt.Errorf("clusterrolepermission %s has invalid namespaceSelector: %v", obj.GetName(), err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("clusterrolepermission %s has invalid namespaceSelector: %v", obj.GetName(), err)
+	}
+
+	roles, _, err := unstructured.NestedStringSlice(obj.Object, "spec", "roles")
+	if err != nil {
+		return nil, err
+	}
+	rolePattern, _, err := unstructured.NestedString(obj.Object, "spec", "rolePattern")
+	if err != nil {
+		return nil, err
+	}
+	if rolePattern != "" {
+		roles = append(roles, rolePattern)
+	}
+	if len(roles) == 0 {
+		return nil, fmt.Errorf("clusterrolepermission %s has no roles", obj.GetName())
+	}
+
+	return &ClusterRolePermission{Name: obj.GetName(), NamespaceSelector: selector, Roles: roles}, nil
+}
+
+// NewClusterRolePermissionListWatch creates a ListWatch for ClusterRolePermissions
+// using a dynamic client for config.
+func NewClusterRolePermissionListWatch(config *rest.Config) (*cache.ListWatch, error) {
+	gv := ClusterRolePermissionResource.GroupVersion()
+	dynamicConfig := rest.CopyConfig(config)
+	dynamicConfig.GroupVersion = &gv
+	dynamicConfig.APIPath = "/apis"
+
+	client, err := dynamic.NewClient(dynamicConfig)
+	if err != nil {
+		return nil, err
+	}
+	resource := client.Resource(&metav1.APIResource{Name: ClusterRolePermissionResource.Resource, Namespaced: false}, "")
+
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return resource.List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return resource.Watch(options)
+		},
+	}, nil
+}
+
+// ClusterRolePermissionCache watches ClusterRolePermissions, implementing
+// ClusterRolePermissionFinder. Invalid permissions are logged and ignored.
+type ClusterRolePermissionCache struct {
+	controller cache.Controller
+	logger     *slog.Logger
+
+	mu          sync.RWMutex
+	permissions map[string]*ClusterRolePermission
+	updates     chan struct{}
+}
+
+// NewClusterRolePermissionCache creates the cache watching source
+func NewClusterRolePermissionCache(source cache.ListerWatcher, syncInterval time.Duration) *ClusterRolePermissionCache {
+	c := &ClusterRolePermissionCache{logger: slog.Default(), permissions: map[string]*ClusterRolePermission{}, updates: make(chan struct{}, 1)}
+	_, c.controller = cache.NewInformer(source, &unstructured.Unstructured{}, syncInterval, c)
+	return c
+}
+
+// Run starts the cache processing updates. Blocks until the cache has synced.
+// Events are logged with the logger carried by ctx.
+func (c *ClusterRolePermissionCache) Run(ctx context.Context) error {
+	c.logger = logging.FromContext(ctx)
+
+	go c.controller.Run(ctx.Done())
+	c.logger.Info("started clusterrolepermission cache controller")
+
+	ok := cache.WaitForCacheSync(ctx.Done(), c.controller.HasSynced)
+	if !ok {
+		return ErrWaitingForSync
+	}
+
+	return nil
+}
+
+// ClusterRolePermissions returns the valid permissions, sorted by name
+func (c *ClusterRolePermissionCache) ClusterRolePermissions() []*ClusterRolePermission {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	permissions := make([]*ClusterRolePermission, 0, len(c.permissions))
+	for _, permission := range c.permissions {
+		permissions = append(permissions, permission)
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i].Name < permissions[j].Name })
+	return permissions
+}
+
+// Updates receives after permissions are added, updated or deleted. Updates
+// made while the channel isn't being read are coalesced.
+func (c *ClusterRolePermissionCache) Updates() <-chan struct{} {
+	return c.updates
+}
+
+func (c *ClusterRolePermissionCache) notify() {
+	select {
+	case c.updates <- struct{}{}:
+	default:
+	}
+}
+
+func (c *ClusterRolePermissionCache) OnAdd(obj interface{}) {
+	c.update(obj)
+}
+
+func (c *ClusterRolePermissionCache) OnUpdate(old, new interface{}) {
+	c.update(new)
+}
+
+func (c *ClusterRolePermissionCache) OnDelete(obj interface{}) {
+	if deleted, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = deleted.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		c.logger.Error("OnDelete unexpected object", "object", fmt.Sprintf("%+v", obj))
+		return
+	}
+
+	c.mu.Lock()
+	delete(c.permissions, u.GetName())
+	c.mu.Unlock()
+	c.notify()
+	c.logger.Debug("deleted clusterrolepermission", "clusterrolepermission", u.GetName())
+}
+
+func (c *ClusterRolePermissionCache) update(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		c.logger.Error("unexpected object", "object", fmt.Sprintf("%+v", obj))
+		return
+	}
+
+	permission, err := ParseClusterRolePermission(u)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.notify()
+
+	if err != nil {
+		delete(c.permissions, u.GetName())
+		c.logger.Warn("ignoring invalid clusterrolepermission", "clusterrolepermission", u.GetName(), "error", err)
+		return
+	}
+	c.permissions[u.GetName()] = permission
+	c.logger.Debug("updated clusterrolepermission", "clusterrolepermission", u.GetName())
+}
@@ -0,0 +1,110 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/testutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kt "k8s.io/client-go/tools/cache/testing"
+)
+
+func newClusterRolePermission(name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "iam.amazonaws.com/v1alpha1",
+		"kind":       "ClusterRolePermission",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       spec,
+	}}
+}
+
+func TestParseClusterRolePermission(t *testing.T) {
+	var tests = []struct {
+		name     string
+		spec     map[string]interface{}
+		valid    bool
+		labels   map[string]string
+		expected bool
+	}{
+		{"match labels", map[string]interface{}{"namespaceSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"team": "data"}}, "roles": []interface{}{"data_.*"}}, true, map[string]string{"team": "data"}, true},
+		{"match expressions", map[string]interface{}{"namespaceSelector": map[string]interface{}{"matchExpressions": []interface{}{map[string]interface{}{"key": "team", "operator": "In", "values": []interface{}{"data", "ml"}}}}, "roles": []interface{}{"data_.*"}}, true, map[string]string{"team": "ml"}, true},
+		{"unmatched labels", map[string]interface{}{"namespaceSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"team": "data"}}, "roles": []interface{}{"data_.*"}}, true, map[string]string{"team": "web"}, false},
+		{"empty selector matches all", map[string]interface{}{"namespaceSelector": map[string]interface{}{}, "roles": []interface{}{"data_.*"}}, true, nil, true},
+		{"missing selector", map[string]interface{}{"roles": []interface{}{"data_.*"}}, false, nil, false},
+		{"role pattern", map[string]interface{}{"namespaceSelector": map[string]interface{}{}, "rolePattern": "logging"}, true, nil, true},
+		{"missing roles", map[string]interface{}{"namespaceSelector": map[string]interface{}{}}, false, nil, false},
+		{"invalid operator", map[string]interface{}{"namespaceSelector": map[string]interface{}{"matchExpressions": []interface{}{map[string]interface{}{"key": "team", "operator": "Near"}}}, "roles": []interface{}{"data_.*"}}, false, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			permission, err := ParseClusterRolePermission(newClusterRolePermission("perm", tt.spec))
+			if (err == nil) != tt.valid {
+				t.Fatalf("expected valid to be %t, error was %v", tt.valid, err)
+			}
+			if !tt.valid {
+				return
+			}
+
+			ns := testutil.NewNamespace("ns", "")
+			ns.Labels = tt.labels
+			if permission.Matches(ns) != tt.expected {
+				t.Errorf("expected matches to be %t", tt.expected)
+			}
+		})
+	}
+}
+
+func TestClusterRolePermissionCacheIgnoresInvalidPermissions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := kt.NewFakeControllerSource()
+	source.Add(newClusterRolePermission("valid", map[string]interface{}{"namespaceSelector": map[string]interface{}{}, "roles": []interface{}{"data_.*"}}))
+	source.Add(newClusterRolePermission("invalid", map[string]interface{}{"roles": []interface{}{"data_.*"}}))
+
+	c := NewClusterRolePermissionCache(source, time.Minute)
+	if err := c.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	permissions := c.ClusterRolePermissions()
+	if len(permissions) != 1 || permissions[0].Name != "valid" {
+		t.Fatalf("expected only valid permission, was %+v", permissions)
+	}
+
+	source.Delete(newClusterRolePermission("valid", nil))
+	deadline := time.Now().Add(time.Second)
+	for len(c.ClusterRolePermissions()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(c.ClusterRolePermissions()) != 0 {
+		t.Error("expected deleted permission to be removed")
+	}
+}
+
+func TestClusterRolePermissionCacheNotifiesUpdates(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := kt.NewFakeControllerSource()
+	c := NewClusterRolePermissionCache(source, time.Minute)
+	if err := c.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	source.Add(newClusterRolePermission("valid", map[string]interface{}{"namespaceSelector": map[string]interface{}{}, "roles": []interface{}{"data_.*"}}))
+	select {
+	case <-c.Updates():
+	case <-time.After(time.Second):
+		t.Fatal("expected update after permission added")
+	}
+
+	source.Delete(newClusterRolePermission("valid", nil))
+	select {
+	case <-c.Updates():
+	case <-time.After(time.Second):
+		t.Fatal("expected update after permission deleted")
+	}
+}
@@ -0,0 +1,84 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/logging"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ConfigMapDataFunc receives the data of a watched ConfigMap whenever it
+// changes. data is nil when the ConfigMap is deleted.
+type ConfigMapDataFunc func(data map[string]string)
+
+// ConfigMapWatcher watches a ConfigMap, notifying changes to its data
+type ConfigMapWatcher struct {
+	controller cache.Controller
+	handler    *configMapHandler
+}
+
+// NewConfigMapWatcher creates a watcher notifying onChange for ConfigMaps
+// from source. Use NewConfigMapListWatch to watch a single ConfigMap.
+func NewConfigMapWatcher(source cache.ListerWatcher, syncInterval time.Duration, onChange ConfigMapDataFunc) *ConfigMapWatcher {
+	handler := &configMapHandler{onChange: onChange, logger: slog.Default()}
+	_, controller := cache.NewInformer(source, &v1.ConfigMap{}, syncInterval, handler)
+	return &ConfigMapWatcher{controller: controller, handler: handler}
+}
+
+// Run starts the watcher processing updates. Blocks until it has synced.
+// Events are logged with the logger carried by ctx.
+func (w *ConfigMapWatcher) Run(ctx context.Context) error {
+	w.handler.logger = logging.FromContext(ctx)
+
+	go w.controller.Run(ctx.Done())
+	w.handler.logger.Info("started configmap watcher")
+
+	ok := cache.WaitForCacheSync(ctx.Done(), w.controller.HasSynced)
+	if !ok {
+		return ErrWaitingForSync
+	}
+
+	return nil
+}
+
+// HasSynced returns true once the ConfigMap has been listed
+func (w *ConfigMapWatcher) HasSynced() bool {
+	return w.controller.HasSynced()
+}
+
+type configMapHandler struct {
+	onChange ConfigMapDataFunc
+	logger   *slog.Logger
+}
+
+func (h *configMapHandler) OnAdd(obj interface{}) {
+	configMap, isConfigMap := obj.(*v1.ConfigMap)
+	if !isConfigMap {
+		h.logger.Error("OnAdd unexpected object", "object", fmt.Sprintf("%+v", obj))
+		return
+	}
+	h.logger.With(configMapAttrs(configMap)...).Debug("added configmap")
+
+	h.onChange(configMap.Data)
+}
+
+func (h *configMapHandler) OnUpdate(old, new interface{}) {
+	configMap, isConfigMap := new.(*v1.ConfigMap)
+	if !isConfigMap {
+		h.logger.Error("OnUpdate unexpected object", "object", fmt.Sprintf("%+v", new))
+		return
+	}
+	h.logger.With(configMapAttrs(configMap)...).Debug("updated configmap")
+
+	h.onChange(configMap.Data)
+}
+
+func (h *configMapHandler) OnDelete(obj interface{}) {
+	h.logger.Debug("deleted configmap")
+
+	h.onChange(nil)
+}
@@ -24,6 +24,11 @@ type PodGetter interface {
 	GetPodByIP(ip string) (*v1.Pod, error)
 }
 
+// NodeGetter finds the node pods are running on
+type NodeGetter interface {
+	GetNodeByName(name string) (*v1.Node, error)
+}
+
 type PodAnnouncer interface {
 	// Will receive a Pod whenever there's a change/addition for a Pod with a role.
 	Pods() <-chan *v1.Pod
@@ -34,3 +39,13 @@ type PodAnnouncer interface {
 type NamespaceFinder interface {
 	FindNamespace(ctx context.Context, name string) (*v1.Namespace, error)
 }
+
+// NamespaceLister is implemented by NamespaceFinders that can list all namespaces
+type NamespaceLister interface {
+	ListNamespaces(ctx context.Context) ([]*v1.Namespace, error)
+}
+
+// ClusterRolePermissionFinder returns the cluster wide role permissions
+type ClusterRolePermissionFinder interface {
+	ClusterRolePermissions() []*ClusterRolePermission
+}
@@ -3,7 +3,9 @@ package k8s
 import (
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 const (
@@ -11,9 +13,25 @@ const (
 	ResourcePods = "pods"
 	// ResourceNamespaces are Namespace resources
 	ResourceNamespaces = "namespaces"
+	// ResourceConfigMaps are ConfigMap resources
+	ResourceConfigMaps = "configmaps"
 )
 
 // NewListWatch creates a ListWatch for the specified Resource
 func NewListWatch(client *kubernetes.Clientset, resource string) *cache.ListWatch {
 	return cache.NewListWatchFromClient(client.Core().RESTClient(), resource, "", fields.Everything())
 }
+
+// NewConfigMapListWatch creates a ListWatch for the single named ConfigMap
+func NewConfigMapListWatch(client *kubernetes.Clientset, namespace, name string) *cache.ListWatch {
+	return cache.NewListWatchFromClient(client.Core().RESTClient(), ResourceConfigMaps, namespace, fields.OneTermEqualSelector("metadata.name", name))
+}
+
+// NewRESTConfig returns the in-cluster config, or the config from kubeconfig if
+// set, in the same way as the clients used for the other resources.
+func NewRESTConfig(kubeconfig string) (*rest.Config, error) {
+	if kubeconfig != "" {
+		return clientcmd.BuildConfigFromFlags("", kubeconfig)
+	}
+	return rest.InClusterConfig()
+}
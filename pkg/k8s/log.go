@@ -14,10 +14,15 @@
 package k8s
 
 import (
+	"log/slog"
+
 	"github.com/sirupsen/logrus"
 	"k8s.io/api/core/v1"
 )
 
+// PodFields returns the fields identifying pod in logrus entries.
+//
+// Deprecated: logrus logging is being replaced by log/slog, use PodAttrs.
 func PodFields(pod *v1.Pod) logrus.Fields {
 	return logrus.Fields{
 		"pod.status.phase":    pod.Status.Phase,
@@ -30,9 +35,30 @@ func PodFields(pod *v1.Pod) logrus.Fields {
 	}
 }
 
-func namespaceFields(n *v1.Namespace) logrus.Fields {
-	return logrus.Fields{
-		"namespace":           n.Name,
-		"namespace.permitted": n.GetAnnotations()[AnnotationPermittedKey],
+// PodAttrs returns the attributes identifying pod in structured logs
+func PodAttrs(pod *v1.Pod) []interface{} {
+	return []interface{}{
+		slog.String("pod.status.phase", string(pod.Status.Phase)),
+		slog.String("pod.status.ip", pod.Status.PodIP),
+		slog.String("pod.namespace", pod.ObjectMeta.Namespace),
+		slog.String("pod.name", pod.ObjectMeta.Name),
+		slog.String("pod.iam.role", pod.ObjectMeta.Annotations[AnnotationIAMRoleKey]),
+		slog.String("resource.version", pod.ObjectMeta.ResourceVersion),
+		slog.Int64("generation.metadata", pod.ObjectMeta.Generation),
+	}
+}
+
+func namespaceAttrs(n *v1.Namespace) []interface{} {
+	return []interface{}{
+		slog.String("namespace", n.Name),
+		slog.String("namespace.permitted", n.GetAnnotations()[AnnotationPermittedKey]),
+	}
+}
+
+func configMapAttrs(c *v1.ConfigMap) []interface{} {
+	return []interface{}{
+		slog.String("configmap.namespace", c.Namespace),
+		slog.String("configmap.name", c.Name),
+		slog.String("resource.version", c.ResourceVersion),
 	}
 }
@@ -15,9 +15,11 @@ package k8s
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
 	"time"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/uswitch/kiam/pkg/logging"
 	"k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/cache"
 )
@@ -26,6 +28,20 @@ const (
 	// AnnotationPermittedKey hold the name of the annotation for the regex expressing the
 	// roles that can be assumed by pods in that namespace.
 	AnnotationPermittedKey = "iam.amazonaws.com/permitted"
+	// AnnotationTimeWindowKey holds the name of the annotation for the time window
+	// during which pods in that namespace can assume roles. e.g. 08:00-18:00 UTC Mon-Fri
+	AnnotationTimeWindowKey = "iam.amazonaws.com/time-window"
+	// AnnotationMaxSessionDurationKey holds the name of the annotation for the longest
+	// session pods in that namespace can request. e.g. 30m
+	AnnotationMaxSessionDurationKey = "iam.amazonaws.com/max-session-duration"
+	// AnnotationSessionNamePatternKey holds the name of the annotation for the regex
+	// session names requested by pods in that namespace must match. The pattern can
+	// refer to the pod with {pod_name}, {namespace} and {uid}. e.g. {namespace}-{pod_name}
+	AnnotationSessionNamePatternKey = "iam.amazonaws.com/session-name-pattern"
+	// AnnotationRequiredSessionTagsKey holds the name of the annotation for the comma
+	// separated session tags pods in that namespace must request. Tags without a value
+	// can have any value. e.g. team=payments,cost-center
+	AnnotationRequiredSessionTagsKey = "iam.amazonaws.com/required-session-tags"
 )
 
 // NamespaceCache implements NamespaceFinder interface used to determine which roles
@@ -33,22 +49,27 @@ const (
 type NamespaceCache struct {
 	indexer    cache.Indexer
 	controller cache.Controller
+	handler    *namespaceLogger
 }
 
 // NewNamespaceCache creates the cache storing Namespaces
 func NewNamespaceCache(source cache.ListerWatcher, syncInterval time.Duration) *NamespaceCache {
-	namespaceLogger := &namespaceLogger{}
+	namespaceLogger := &namespaceLogger{logger: slog.Default()}
 	indexer, controller := cache.NewIndexerInformer(source, &v1.Namespace{}, syncInterval, namespaceLogger, cache.Indexers{})
 	return &NamespaceCache{
 		indexer:    indexer,
 		controller: controller,
+		handler:    namespaceLogger,
 	}
 }
 
-// Run starts the cache processing updates. Blocks until cache has synced
+// Run starts the cache processing updates. Blocks until cache has synced.
+// Events are logged with the logger carried by ctx.
 func (c *NamespaceCache) Run(ctx context.Context) error {
+	c.handler.logger = logging.FromContext(ctx)
+
 	go c.controller.Run(ctx.Done())
-	log.Infof("started namespace cache controller")
+	c.handler.logger.Info("started namespace cache controller")
 
 	ok := cache.WaitForCacheSync(ctx.Done(), c.controller.HasSynced)
 	if !ok {
@@ -70,16 +91,27 @@ func (c *NamespaceCache) FindNamespace(ctx context.Context, name string) (*v1.Na
 	return obj.(*v1.Namespace), nil
 }
 
+// ListNamespaces returns all Namespaces in the cache
+func (c *NamespaceCache) ListNamespaces(ctx context.Context) ([]*v1.Namespace, error) {
+	items := c.indexer.List()
+	namespaces := make([]*v1.Namespace, 0, len(items))
+	for _, obj := range items {
+		namespaces = append(namespaces, obj.(*v1.Namespace))
+	}
+	return namespaces, nil
+}
+
 type namespaceLogger struct {
+	logger *slog.Logger
 }
 
 func (o *namespaceLogger) OnAdd(obj interface{}) {
 	namespace, isNamespace := obj.(*v1.Namespace)
 	if !isNamespace {
-		log.Errorf("OnAdd unexpected object: %+v", obj)
+		o.logger.Error("OnAdd unexpected object", "object", fmt.Sprintf("%+v", obj))
 		return
 	}
-	log.WithFields(namespaceFields(namespace)).Debugf("added namespace")
+	o.logger.With(namespaceAttrs(namespace)...).Debug("added namespace")
 }
 
 func (o *namespaceLogger) OnDelete(obj interface{}) {
@@ -87,28 +119,28 @@ func (o *namespaceLogger) OnDelete(obj interface{}) {
 	if !isNamespace {
 		deletedObj, isDeleted := obj.(cache.DeletedFinalStateUnknown)
 		if !isDeleted {
-			log.Errorf("OnDelete unexpected object: %+v", obj)
+			o.logger.Error("OnDelete unexpected object", "object", fmt.Sprintf("%+v", obj))
 			return
 		}
 
 		namespace, isNamespace = deletedObj.Obj.(*v1.Namespace)
 		if !isNamespace {
-			log.Errorf("OnDelete unexpected DeletedFinalStateUnknown object: %+v", deletedObj.Obj)
+			o.logger.Error("OnDelete unexpected DeletedFinalStateUnknown object", "object", fmt.Sprintf("%+v", deletedObj.Obj))
 		}
-		log.WithFields(namespaceFields(namespace)).Debugf("deleted namespace")
+		o.logger.With(namespaceAttrs(namespace)...).Debug("deleted namespace")
 		return
 	}
 
-	log.WithFields(namespaceFields(namespace)).Debugf("deleted namespace")
+	o.logger.With(namespaceAttrs(namespace)...).Debug("deleted namespace")
 	return
 }
 
 func (o *namespaceLogger) OnUpdate(old, new interface{}) {
 	namespace, isNamespace := new.(*v1.Namespace)
 	if !isNamespace {
-		log.Errorf("OnUpdate unexpected object: %+v", new)
+		o.logger.Error("OnUpdate unexpected object", "object", fmt.Sprintf("%+v", new))
 		return
 	}
 
-	log.WithFields(namespaceFields(namespace)).Debugf("updated namespace")
+	o.logger.With(namespaceAttrs(namespace)...).Debug("updated namespace")
 }
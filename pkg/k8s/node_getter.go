@@ -0,0 +1,35 @@
+package k8s
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// ErrNodeNotFound is returned when the node doesn't exist
+var ErrNodeNotFound = fmt.Errorf("node not found")
+
+// APINodeGetter gets nodes from the API server. Nodes are only needed by
+// policies restricting sensitive roles so aren't cached.
+type APINodeGetter struct {
+	nodes typedcorev1.NodesGetter
+}
+
+// NewAPINodeGetter creates a NodeGetter getting nodes with nodes
+func NewAPINodeGetter(nodes typedcorev1.NodesGetter) *APINodeGetter {
+	return &APINodeGetter{nodes: nodes}
+}
+
+func (g *APINodeGetter) GetNodeByName(name string) (*v1.Node, error) {
+	node, err := g.nodes.Nodes().Get(name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, ErrNodeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting node %s: %v", name, err)
+	}
+	return node, nil
+}
@@ -0,0 +1,26 @@
+package k8s
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAPINodeGetter(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{"node-group": "secure"}}}
+	getter := NewAPINodeGetter(fake.NewSimpleClientset(node).CoreV1())
+
+	found, err := getter.GetNodeByName("node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Labels["node-group"] != "secure" {
+		t.Error("unexpected node", found)
+	}
+
+	if _, err := getter.GetNodeByName("node-2"); err != ErrNodeNotFound {
+		t.Error("expected node not found, was", err)
+	}
+}
@@ -0,0 +1,98 @@
+package k8s
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ErrOwnerNotFound is returned when the owner doesn't exist
+var ErrOwnerNotFound = fmt.Errorf("owner not found")
+
+// ErrOwnerKindUnsupported is returned for owners whose kind can't be got,
+// such as custom resources
+var ErrOwnerKindUnsupported = fmt.Errorf("owner kind unsupported")
+
+// Owner is an object owning pods, or owning other owners, e.g. a ReplicaSet
+// or Deployment
+type Owner struct {
+	metav1.ObjectMeta
+
+	// TemplateAnnotations are the annotations of the owner's pod template
+	TemplateAnnotations map[string]string
+}
+
+// OwnerGetter gets the objects referenced by ownerReferences
+type OwnerGetter interface {
+	GetOwner(namespace string, ref metav1.OwnerReference) (*Owner, error)
+}
+
+// APIOwnerGetter gets ReplicaSets, Deployments, StatefulSets, DaemonSets, Jobs
+// and CronJobs from the API server. Owners are only needed for pods without
+// role annotations so aren't cached.
+type APIOwnerGetter struct {
+	client kubernetes.Interface
+}
+
+// NewAPIOwnerGetter creates an OwnerGetter getting owners with client
+func NewAPIOwnerGetter(client kubernetes.Interface) *APIOwnerGetter {
+	return &APIOwnerGetter{client: client}
+}
+
+func (g *APIOwnerGetter) GetOwner(namespace string, ref metav1.OwnerReference) (*Owner, error) {
+	owner, err := g.getOwner(namespace, ref)
+	if errors.IsNotFound(err) {
+		return nil, ErrOwnerNotFound
+	}
+	if err != nil && err != ErrOwnerKindUnsupported {
+		return nil, fmt.Errorf("error getting %s %s/%s: %v", ref.Kind, namespace, ref.Name, err)
+	}
+	return owner, err
+}
+
+func (g *APIOwnerGetter) getOwner(namespace string, ref metav1.OwnerReference) (*Owner, error) {
+	options := metav1.GetOptions{}
+
+	switch ref.Kind {
+	case "ReplicaSet":
+		o, err := g.client.AppsV1().ReplicaSets(namespace).Get(ref.Name, options)
+		if err != nil {
+			return nil, err
+		}
+		return &Owner{ObjectMeta: o.ObjectMeta, TemplateAnnotations: o.Spec.Template.Annotations}, nil
+	case "Deployment":
+		o, err := g.client.AppsV1().Deployments(namespace).Get(ref.Name, options)
+		if err != nil {
+			return nil, err
+		}
+		return &Owner{ObjectMeta: o.ObjectMeta, TemplateAnnotations: o.Spec.Template.Annotations}, nil
+	case "StatefulSet":
+		o, err := g.client.AppsV1().StatefulSets(namespace).Get(ref.Name, options)
+		if err != nil {
+			return nil, err
+		}
+		return &Owner{ObjectMeta: o.ObjectMeta, TemplateAnnotations: o.Spec.Template.Annotations}, nil
+	case "DaemonSet":
+		o, err := g.client.AppsV1().DaemonSets(namespace).Get(ref.Name, options)
+		if err != nil {
+			return nil, err
+		}
+		return &Owner{ObjectMeta: o.ObjectMeta, TemplateAnnotations: o.Spec.Template.Annotations}, nil
+	case "Job":
+		o, err := g.client.BatchV1().Jobs(namespace).Get(ref.Name, options)
+		if err != nil {
+			return nil, err
+		}
+		return &Owner{ObjectMeta: o.ObjectMeta, TemplateAnnotations: o.Spec.Template.Annotations}, nil
+	case "CronJob":
+		o, err := g.client.BatchV1beta1().CronJobs(namespace).Get(ref.Name, options)
+		if err != nil {
+			return nil, err
+		}
+		return &Owner{ObjectMeta: o.ObjectMeta, TemplateAnnotations: o.Spec.JobTemplate.Spec.Template.Annotations}, nil
+	}
+
+	return nil, ErrOwnerKindUnsupported
+}
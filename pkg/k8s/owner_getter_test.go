@@ -0,0 +1,38 @@
+package k8s
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAPIOwnerGetter(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "ns", Annotations: map[string]string{"owner": "annotation"}},
+		Spec: appsv1.DeploymentSpec{
+			Template: v1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{AnnotationIAMRoleKey: "reader"}}},
+		},
+	}
+	getter := NewAPIOwnerGetter(fake.NewSimpleClientset(deployment))
+
+	owner, err := getter.GetOwner("ns", metav1.OwnerReference{Kind: "Deployment", Name: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner.Name != "web" || owner.Annotations["owner"] != "annotation" {
+		t.Error("unexpected owner", owner)
+	}
+	if owner.TemplateAnnotations[AnnotationIAMRoleKey] != "reader" {
+		t.Error("unexpected template annotations", owner.TemplateAnnotations)
+	}
+
+	if _, err := getter.GetOwner("ns", metav1.OwnerReference{Kind: "Deployment", Name: "api"}); err != ErrOwnerNotFound {
+		t.Error("expected owner not found, was", err)
+	}
+	if _, err := getter.GetOwner("ns", metav1.OwnerReference{Kind: "Rollout", Name: "web"}); err != ErrOwnerKindUnsupported {
+		t.Error("expected unsupported owner kind, was", err)
+	}
+}
@@ -0,0 +1,57 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// AnnotationIAMAssumedRoleARNKey is the key for the annotation kiam writes with
+// the ARN of the role session assumed for the Pod
+const AnnotationIAMAssumedRoleARNKey = "iam.amazonaws.com/assumed-role-arn"
+
+// PodAnnotationPatcher sets annotations on pods
+type PodAnnotationPatcher interface {
+	PatchPodAnnotations(ctx context.Context, pod *v1.Pod, annotations map[string]string) error
+}
+
+// StrategicMergePodAnnotationPatcher patches pods' annotations with a
+// strategic merge patch, leaving their other annotations unchanged.
+type StrategicMergePodAnnotationPatcher struct {
+	pods typedcorev1.PodsGetter
+}
+
+func NewPodAnnotationPatcher(pods typedcorev1.PodsGetter) *StrategicMergePodAnnotationPatcher {
+	return &StrategicMergePodAnnotationPatcher{pods: pods}
+}
+
+// PatchPodAnnotations sets annotations on pod. Pods already annotated with the
+// same values aren't patched.
+func (p *StrategicMergePodAnnotationPatcher) PatchPodAnnotations(ctx context.Context, pod *v1.Pod, annotations map[string]string) error {
+	changed := false
+	for key, value := range annotations {
+		if current, ok := pod.GetAnnotations()[key]; !ok || current != value {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.pods.Pods(pod.GetNamespace()).Patch(pod.GetName(), types.StrategicMergePatchType, patch); err != nil {
+		return fmt.Errorf("error patching pod %s/%s: %v", pod.GetNamespace(), pod.GetName(), err)
+	}
+	return nil
+}
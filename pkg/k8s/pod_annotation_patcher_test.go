@@ -0,0 +1,53 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/testutil"
+	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
+)
+
+func TestPodAnnotationPatcher(t *testing.T) {
+	pod := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	client := fake.NewSimpleClientset(pod)
+	patcher := NewPodAnnotationPatcher(client.CoreV1())
+
+	arn := "arn:aws:sts::123456789012:assumed-role/red_role/kiam-kiam"
+	annotations := map[string]string{AnnotationIAMAssumedRoleARNKey: arn}
+	if err := patcher.PatchPodAnnotations(context.Background(), pod, annotations); err != nil {
+		t.Fatal(err)
+	}
+
+	actions := client.Actions()
+	if len(actions) != 1 {
+		t.Fatal("expected pod to be patched, was", actions)
+	}
+	patch, ok := actions[0].(ktesting.PatchAction)
+	if !ok || patch.GetNamespace() != "red" || patch.GetName() != "foo" {
+		t.Fatal("unexpected action", actions[0])
+	}
+
+	var body struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(patch.GetPatch(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Metadata.Annotations) != 1 || body.Metadata.Annotations[AnnotationIAMAssumedRoleARNKey] != arn {
+		t.Error("expected only the assumed role annotation to be patched, was", body.Metadata.Annotations)
+	}
+
+	client.ClearActions()
+	pod.Annotations[AnnotationIAMAssumedRoleARNKey] = arn
+	if err := patcher.PatchPodAnnotations(context.Background(), pod, annotations); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.Actions()) != 0 {
+		t.Error("expected annotated pod not to be patched, was", client.Actions())
+	}
+}
@@ -16,10 +16,12 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/logging"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/cache"
 )
@@ -29,6 +31,10 @@ type PodCache struct {
 	pods       chan *v1.Pod
 	indexer    cache.Indexer
 	controller cache.Controller
+	handler    *podHandler
+	logger     *slog.Logger
+
+	identityOptions RoleIdentityOptions
 }
 
 // NewPodCache creates the cache object that uses a watcher to listen for Pod events. The cache indexes pods by their
@@ -36,22 +42,29 @@ type PodCache struct {
 // pods and can announce Pods. When announcing Pods via the channel it will drop events if the buffer
 // is full- bufferSize determines how many.
 func NewPodCache(arnResolver sts.ARNResolver, source cache.ListerWatcher, syncInterval time.Duration, bufferSize int) *PodCache {
-	indexers := cache.Indexers{
-		indexPodIP:           podIPIndex,
-		indexPodRoleIdentity: podRoleIdentityIndex(arnResolver),
-	}
 	pods := make(chan *v1.Pod, bufferSize)
-	podHandler := &podHandler{pods}
-	indexer, controller := cache.NewIndexerInformer(source, &v1.Pod{}, syncInterval, podHandler, indexers)
+	podHandler := &podHandler{pods: pods, logger: slog.Default()}
 	podCache := &PodCache{
-		pods:       pods,
-		indexer:    indexer,
-		controller: controller,
+		pods:    pods,
+		handler: podHandler,
+		logger:  slog.Default(),
+	}
+
+	indexers := cache.Indexers{
+		indexPodIP:           podIPIndex,
+		indexPodRoleIdentity: podCache.podRoleIdentityIndex(arnResolver),
 	}
+	podCache.indexer, podCache.controller = cache.NewIndexerInformer(source, &v1.Pod{}, syncInterval, podHandler, indexers)
 
 	return podCache
 }
 
+// SetRoleIdentityOptions controls how pods' identities are indexed, they must
+// match the options used to issue credentials. Must be called before Run.
+func (s *PodCache) SetRoleIdentityOptions(options RoleIdentityOptions) {
+	s.identityOptions = options
+}
+
 // ErrMultipleRunningPods indicates that multiple pods were found. This is
 // an error as we expect IP addresses to not overlap
 var ErrMultipleRunningPods = fmt.Errorf("multiple running pods found")
@@ -98,10 +111,10 @@ var (
 
 // findPodForIP returns the Pod identified by the provided IP address. The
 // Pod must be active (i.e. pending or running)
-func (s *PodCache) findPodForIP(ip string) (*v1.Pod, error) {
+func findPodForIP(logger *slog.Logger, indexer cache.Indexer, ip string) (*v1.Pod, error) {
 	found := make([]*v1.Pod, 0)
 
-	items, err := s.indexer.ByIndex(indexPodIP, ip)
+	items, err := indexer.ByIndex(indexPodIP, ip)
 	if err != nil {
 		return nil, err
 	}
@@ -119,7 +132,7 @@ func (s *PodCache) findPodForIP(ip string) (*v1.Pod, error) {
 	}
 
 	for idx, pod := range found {
-		log.WithFields(PodFields(pod)).Debugf("found %d/%d pods for ip %s", len(found), idx+1, ip)
+		logger.With(PodAttrs(pod)...).Debug("found pod for ip", "ip", ip, "pods.index", idx+1, "pods.found", len(found))
 	}
 
 	if len(found) == 0 {
@@ -135,7 +148,7 @@ func (s *PodCache) findPodForIP(ip string) (*v1.Pod, error) {
 
 // GetPodByIP returns the Pod with the provided IP address
 func (s *PodCache) GetPodByIP(ip string) (*v1.Pod, error) {
-	return s.findPodForIP(ip)
+	return findPodForIP(s.logger, s.indexer, ip)
 }
 
 const (
@@ -153,17 +166,14 @@ func podIPIndex(obj interface{}) ([]string, error) {
 	return []string{pod.Status.PodIP}, nil
 }
 
-func podRoleIdentityIndex(arnResolver sts.ARNResolver) func(obj interface{}) ([]string, error) {
+func (s *PodCache) podRoleIdentityIndex(arnResolver sts.ARNResolver) func(obj interface{}) ([]string, error) {
 	return func(obj interface{}) ([]string, error) {
 		pod := obj.(*v1.Pod)
-		role := PodRole(pod)
-		if role == "" {
+		if !HasValidIAMAnnotation(pod) {
 			return []string{}, nil
 		}
 
-		sessionName := PodSessionName(pod)
-		externalID := PodExternalID(pod)
-		identity, err := sts.NewRoleIdentity(arnResolver, role, sessionName, externalID)
+		identity, err := PodRoleIdentity(arnResolver, PodRole(pod), pod, s.identityOptions)
 		if err != nil {
 			return nil, err
 		}
@@ -172,10 +182,14 @@ func podRoleIdentityIndex(arnResolver sts.ARNResolver) func(obj interface{}) ([]
 	}
 }
 
-// Run starts the controller processing updates. Blocks until the cache has synced
+// Run starts the controller processing updates. Blocks until the cache has synced.
+// Events are logged with the logger carried by ctx.
 func (s *PodCache) Run(ctx context.Context) error {
+	s.logger = logging.FromContext(ctx)
+	s.handler.logger = s.logger
+
 	go s.controller.Run(ctx.Done())
-	log.Infof("started cache controller")
+	s.logger.Info("started cache controller")
 
 	ok := cache.WaitForCacheSync(ctx.Done(), s.controller.HasSynced)
 	if !ok {
@@ -200,6 +214,72 @@ func PodExternalID(pod *v1.Pod) string {
 	return pod.ObjectMeta.Annotations[AnnotationIAMExternalIDKey]
 }
 
+// PodRoleChain returns the intermediate IAM roles, in the order they're assumed,
+// specified in the annotation for the Pod
+func PodRoleChain(pod *v1.Pod) []string {
+	var roles []string
+	for _, role := range strings.Split(pod.ObjectMeta.Annotations[AnnotationIAMRoleChainKey], ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// PodRoleIdentity returns the identity credentials for role are issued for to
+// the Pod, according to its session name, external id and session tags
+// annotations. With PerPodSessions the session is named by the pod's UID.
+func PodRoleIdentity(arnResolver sts.ARNResolver, role string, pod *v1.Pod, options RoleIdentityOptions) (*sts.RoleIdentity, error) {
+	identity, err := sts.NewRoleIdentity(arnResolver, role, PodSessionName(pod), PodExternalID(pod))
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := PodSessionTags(pod)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session tags: %v", err)
+	}
+	if len(tags) > 0 {
+		identity.SessionTags = tags
+	}
+	if options.PerPodSessions && pod.GetUID() != "" {
+		identity.SessionName = string(pod.GetUID())
+	}
+	return identity, nil
+}
+
+// PodSessionTags returns the STS session tags specified in the annotation for
+// the Pod
+func PodSessionTags(pod *v1.Pod) (map[string]string, error) {
+	return ParseTags(pod.ObjectMeta.Annotations[AnnotationIAMSessionTagsKey])
+}
+
+// ParseTags parses comma separated key=value tags. Keys without a value have
+// an empty value. Whitespace around keys and values is ignored.
+func ParseTags(value string) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, tag := range strings.Split(value, ",") {
+		if strings.TrimSpace(tag) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(tag, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if key == "" {
+			return nil, fmt.Errorf("tag '%s' has no key", tag)
+		}
+		if _, duplicate := tags[key]; duplicate {
+			return nil, fmt.Errorf("duplicate tag '%s'", key)
+		}
+		if len(parts) == 2 {
+			tags[key] = strings.TrimSpace(parts[1])
+		} else {
+			tags[key] = ""
+		}
+	}
+	return tags, nil
+}
+
 // AnnotationIAMRoleKey is the key for the annotation specifying the IAM Role
 const AnnotationIAMRoleKey = "iam.amazonaws.com/role"
 
@@ -209,35 +289,44 @@ const AnnotationIAMSessionNameKey = "iam.amazonaws.com/session-name"
 // AnnotationIAMExternalIDKey is the key for the annotation specifying the external-id
 const AnnotationIAMExternalIDKey = "iam.amazonaws.com/external-id"
 
+// AnnotationIAMRoleChainKey is the key for the annotation specifying the comma
+// separated intermediate roles assumed before the IAM Role
+const AnnotationIAMRoleChainKey = "iam.amazonaws.com/role-chain"
+
+// AnnotationIAMSessionTagsKey is the key for the annotation specifying the
+// comma separated key=value STS session tags
+const AnnotationIAMSessionTagsKey = "iam.amazonaws.com/session-tags"
+
 type podHandler struct {
-	pods chan<- *v1.Pod
+	pods   chan<- *v1.Pod
+	logger *slog.Logger
 }
 
 func (o *podHandler) announce(pod *v1.Pod) {
-	logger := log.WithFields(PodFields(pod))
+	logger := o.logger.With(PodAttrs(pod)...)
 	if IsPodCompleted(pod) {
 		return
 	}
-	if PodRole(pod) == "" {
+	if !HasValidIAMAnnotation(pod) {
 		return
 	}
 
 	select {
 	case o.pods <- pod:
-		logger.Debugf("announced pod")
+		logger.Debug("announced pod")
 	default:
 		dropAnnounce.Inc()
-		logger.Warnf("pods announcement full, dropping")
+		logger.Warn("pods announcement full, dropping")
 	}
 }
 
 func (o *podHandler) OnAdd(obj interface{}) {
 	pod, isPod := obj.(*v1.Pod)
 	if !isPod {
-		log.Errorf("OnAdd unexpected object: %+v", obj)
+		o.logger.Error("OnAdd unexpected object", "object", fmt.Sprintf("%+v", obj))
 		return
 	}
-	log.WithFields(PodFields(pod)).Debugf("added pod")
+	o.logger.With(PodAttrs(pod)...).Debug("added pod")
 
 	o.announce(pod)
 }
@@ -247,28 +336,28 @@ func (o *podHandler) OnDelete(obj interface{}) {
 	if !isPod {
 		deletedObj, isDeleted := obj.(cache.DeletedFinalStateUnknown)
 		if !isDeleted {
-			log.Errorf("OnDelete unexpected object: %+v", obj)
+			o.logger.Error("OnDelete unexpected object", "object", fmt.Sprintf("%+v", obj))
 			return
 		}
 
 		pod, isPod = deletedObj.Obj.(*v1.Pod)
 		if !isPod {
-			log.Errorf("OnDelete unexpected DeletedFinalStateUnknown object: %+v", deletedObj.Obj)
+			o.logger.Error("OnDelete unexpected DeletedFinalStateUnknown object", "object", fmt.Sprintf("%+v", deletedObj.Obj))
 		}
-		log.WithFields(PodFields(pod)).Debugf("deleted pod")
+		o.logger.With(PodAttrs(pod)...).Debug("deleted pod")
 		return
 	}
 
-	log.WithFields(PodFields(pod)).Debugf("deleted pod")
+	o.logger.With(PodAttrs(pod)...).Debug("deleted pod")
 	return
 }
 
 func (o *podHandler) OnUpdate(old, new interface{}) {
 	pod, isPod := new.(*v1.Pod)
 	if !isPod {
-		log.Errorf("OnUpdate unexpected object: %+v", new)
+		o.logger.Error("OnUpdate unexpected object", "object", fmt.Sprintf("%+v", new))
 		return
 	}
 
-	log.WithFields(PodFields(pod)).Debugf("updated pod")
+	o.logger.With(PodAttrs(pod)...).Debug("updated pod")
 }
@@ -34,7 +34,7 @@ func TestFindsRunningPod(t *testing.T) {
 	defer cancel()
 
 	source := kt.NewFakeControllerSource()
-	arnResolver := sts.DefaultResolver("arn:account:")
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
 	c := NewPodCache(arnResolver, source, time.Second, bufferSize)
 	source.Add(testutil.NewPodWithRole("ns", "name", "192.168.0.1", "Failed", "failed_role"))
 	source.Add(testutil.NewPodWithRole("ns", "name", "192.168.0.1", "Running", "running_role"))
@@ -57,7 +57,7 @@ func TestFindRoleActive(t *testing.T) {
 	defer cancel()
 
 	source := kt.NewFakeControllerSource()
-	arnResolver := sts.DefaultResolver("arn:account:")
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
 	c := NewPodCache(arnResolver, source, time.Second, bufferSize)
 	source.Add(testutil.NewPodWithRole("ns", "name", "192.168.0.1", "Failed", "failed_role"))
 	source.Modify(testutil.NewPodWithRole("ns", "name", "192.168.0.1", "Failed", "running_role"))
@@ -85,7 +85,7 @@ func TestFindRoleActiveWithSessionName(t *testing.T) {
 	defer cancel()
 
 	source := kt.NewFakeControllerSource()
-	arnResolver := sts.DefaultResolver("arn:account:")
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
 	c := NewPodCache(arnResolver, source, time.Second, bufferSize)
 	source.Add(testutil.NewPodWithSessionName("ns", "active-reader", "192.168.0.1", "Running", "reader", "active-reader"))
 	source.Add(testutil.NewPodWithSessionName("ns", "stopped-reader", "192.168.0.2", "Succeeded", "reader", "stopped-reader"))
@@ -112,7 +112,7 @@ func TestFindRoleActiveWithExternalID(t *testing.T) {
 	defer cancel()
 
 	source := kt.NewFakeControllerSource()
-	arnResolver := sts.DefaultResolver("arn:account:")
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
 	c := NewPodCache(arnResolver, source, time.Second, bufferSize)
 	source.Add(testutil.NewPodWithExternalID("ns", "active-reader", "192.168.0.1", "Running", "reader", "1234"))
 	source.Add(testutil.NewPodWithExternalID("ns", "stopped-reader", "192.168.0.2", "Succeeded", "reader", "4321"))
@@ -132,6 +132,97 @@ func TestFindRoleActiveWithExternalID(t *testing.T) {
 	}
 }
 
+func TestParseTags(t *testing.T) {
+	tags, err := ParseTags(" team = payments, cost-center,path=/a=b ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 3 || tags["team"] != "payments" || tags["cost-center"] != "" || tags["path"] != "/a=b" {
+		t.Error("unexpected tags", tags)
+	}
+
+	for _, invalid := range []string{"=payments", "team=a,team=b"} {
+		if _, err := ParseTags(invalid); err == nil {
+			t.Error("expected error parsing", invalid)
+		}
+	}
+}
+
+func TestPodRoleIdentityIncludesSessionTags(t *testing.T) {
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+	pod := testutil.NewPodWithRole("ns", "tagged", "192.168.0.1", "Running", "reader")
+
+	untagged, _ := PodRoleIdentity(arnResolver, "reader", pod, RoleIdentityOptions{})
+	pod.Annotations[AnnotationIAMSessionTagsKey] = "team=payments"
+	tagged, err := PodRoleIdentity(arnResolver, "reader", pod, RoleIdentityOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tagged.SessionTags["team"] != "payments" {
+		t.Error("expected session tags, was", tagged.SessionTags)
+	}
+	if tagged.String() == untagged.String() {
+		t.Error("expected tagged identity to differ, was", tagged.String())
+	}
+}
+
+func TestPodRoleIdentityPerPodSessions(t *testing.T) {
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+	options := RoleIdentityOptions{PerPodSessions: true}
+	first := testutil.NewPodWithRole("ns", "first", "192.168.0.1", "Running", "reader")
+	first.UID = "first-uid"
+	second := testutil.NewPodWithRole("ns", "second", "192.168.0.2", "Running", "reader")
+	second.UID = "second-uid"
+
+	firstIdentity, err := PodRoleIdentity(arnResolver, "reader", first, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondIdentity, _ := PodRoleIdentity(arnResolver, "reader", second, options)
+
+	if firstIdentity.SessionName != "first-uid" {
+		t.Error("expected session named by pod uid, was", firstIdentity.SessionName)
+	}
+	if firstIdentity.Role.ARN.String() != "arn:aws:iam::123456789012:role/reader" {
+		t.Error("unexpected role", firstIdentity.Role.ARN)
+	}
+	if firstIdentity.String() == secondIdentity.String() {
+		t.Error("expected pods to have separate identities, was", firstIdentity.String())
+	}
+}
+
+func TestFindRoleActivePerPodSessions(t *testing.T) {
+	defer leaktest.Check(t)()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := kt.NewFakeControllerSource()
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+	options := RoleIdentityOptions{PerPodSessions: true}
+	c := NewPodCache(arnResolver, source, time.Second, bufferSize)
+	c.SetRoleIdentityOptions(options)
+	active := testutil.NewPodWithRole("ns", "active-reader", "192.168.0.1", "Running", "reader")
+	active.UID = "active-uid"
+	stopped := testutil.NewPodWithRole("ns", "stopped-reader", "192.168.0.2", "Succeeded", "reader")
+	stopped.UID = "stopped-uid"
+	source.Add(active)
+	source.Add(stopped)
+	c.Run(ctx)
+	defer source.Shutdown()
+
+	identity, _ := PodRoleIdentity(arnResolver, "reader", active, options)
+	if isActive, _ := c.IsActivePodsForRole(identity); !isActive {
+		t.Error("expected running pod for active-uid")
+	}
+
+	identity, _ = PodRoleIdentity(arnResolver, "reader", stopped, options)
+	if isActive, _ := c.IsActivePodsForRole(identity); isActive {
+		t.Error("expected no active pods for stopped-uid")
+	}
+}
+
 func BenchmarkFindPodsByIP(b *testing.B) {
 	b.StopTimer()
 
@@ -139,7 +230,7 @@ func BenchmarkFindPodsByIP(b *testing.B) {
 	defer cancel()
 
 	source := kt.NewFakeControllerSource()
-	arnResolver := sts.DefaultResolver("arn:account:")
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
 	c := NewPodCache(arnResolver, source, time.Second, bufferSize)
 	for i := 0; i < 1000; i++ {
 		source.Add(testutil.NewPodWithRole("ns", fmt.Sprintf("name-%d", i), fmt.Sprintf("ip-%d", i), "Running", "foo_role"))
@@ -167,7 +258,7 @@ func BenchmarkIsActiveRole(b *testing.B) {
 		role := i % 100
 		source.Add(testutil.NewPodWithRole("ns", fmt.Sprintf("name-%d", i), fmt.Sprintf("ip-%d", i), "Running", fmt.Sprintf("role-%d", role)))
 	}
-	arnResolver := sts.DefaultResolver("arn:account:")
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
 	c := NewPodCache(arnResolver, source, time.Second, bufferSize)
 	c.Run(ctx)
 
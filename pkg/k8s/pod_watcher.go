@@ -0,0 +1,143 @@
+package k8s
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/logging"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PodEventType identifies the change to a pod
+type PodEventType string
+
+const (
+	PodAdded    PodEventType = "added"
+	PodModified PodEventType = "modified"
+	PodDeleted  PodEventType = "deleted"
+	// RoleAnnotationChanged is sent instead of PodModified when the pod's IAM
+	// annotations (those prefixed iam.amazonaws.com/) changed
+	RoleAnnotationChanged PodEventType = "roleAnnotationChanged"
+)
+
+// PodEvent describes a change to a pod. Old is set for modifications.
+type PodEvent struct {
+	Type PodEventType
+	Pod  *v1.Pod
+	Old  *v1.Pod
+}
+
+// PodWatcher notifies subscribers of changes to pods
+type PodWatcher interface {
+	// Watch returns a channel receiving pod events until ctx is cancelled,
+	// when the channel is closed
+	Watch(ctx context.Context) (<-chan PodEvent, error)
+}
+
+// InformerPodWatcher implements PodWatcher with an informer for each call to
+// Watch. Events are delivered in order; the informer waits for each event to
+// be received.
+type InformerPodWatcher struct {
+	source       cache.ListerWatcher
+	syncInterval time.Duration
+	bufferSize   int
+}
+
+// NewPodWatcher creates a watcher listing and watching pods from source
+func NewPodWatcher(source cache.ListerWatcher, syncInterval time.Duration, bufferSize int) *InformerPodWatcher {
+	return &InformerPodWatcher{source: source, syncInterval: syncInterval, bufferSize: bufferSize}
+}
+
+// Watch starts an informer sending events until ctx is cancelled. Existing
+// pods are sent as PodAdded events. Events are logged with the logger carried
+// by ctx.
+func (w *InformerPodWatcher) Watch(ctx context.Context) (<-chan PodEvent, error) {
+	events := make(chan PodEvent, w.bufferSize)
+	handler := &podEventHandler{ctx: ctx, events: events, logger: logging.FromContext(ctx)}
+	_, controller := cache.NewInformer(w.source, &v1.Pod{}, w.syncInterval, handler)
+
+	go func() {
+		controller.Run(ctx.Done())
+		close(events)
+	}()
+
+	return events, nil
+}
+
+type podEventHandler struct {
+	ctx    context.Context
+	events chan<- PodEvent
+	logger *slog.Logger
+}
+
+func (h *podEventHandler) send(event PodEvent) {
+	select {
+	case h.events <- event:
+	case <-h.ctx.Done():
+	}
+}
+
+func (h *podEventHandler) OnAdd(obj interface{}) {
+	pod, isPod := obj.(*v1.Pod)
+	if !isPod {
+		h.logger.Error("OnAdd unexpected object", "object", obj)
+		return
+	}
+	h.send(PodEvent{Type: PodAdded, Pod: pod})
+}
+
+func (h *podEventHandler) OnUpdate(old, new interface{}) {
+	pod, isPod := new.(*v1.Pod)
+	if !isPod {
+		h.logger.Error("OnUpdate unexpected object", "object", new)
+		return
+	}
+	oldPod, _ := old.(*v1.Pod)
+
+	eventType := PodModified
+	if oldPod != nil && iamAnnotationsChanged(oldPod, pod) {
+		eventType = RoleAnnotationChanged
+	}
+	h.send(PodEvent{Type: eventType, Pod: pod, Old: oldPod})
+}
+
+func (h *podEventHandler) OnDelete(obj interface{}) {
+	if deleted, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = deleted.Obj
+	}
+	pod, isPod := obj.(*v1.Pod)
+	if !isPod {
+		h.logger.Error("OnDelete unexpected object", "object", obj)
+		return
+	}
+	h.send(PodEvent{Type: PodDeleted, Pod: pod})
+}
+
+// iamAnnotationPrefix prefixes the annotations configuring the pod's IAM role
+const iamAnnotationPrefix = "iam.amazonaws.com/"
+
+// iamAnnotationsChanged returns whether any IAM annotation was added, removed
+// or changed
+func iamAnnotationsChanged(old, new *v1.Pod) bool {
+	oldAnnotations, newAnnotations := old.GetAnnotations(), new.GetAnnotations()
+	for key, value := range newAnnotations {
+		if !strings.HasPrefix(key, iamAnnotationPrefix) {
+			continue
+		}
+		if oldValue, ok := oldAnnotations[key]; !ok || oldValue != value {
+			return true
+		}
+	}
+	for key := range oldAnnotations {
+		if !strings.HasPrefix(key, iamAnnotationPrefix) {
+			continue
+		}
+		if _, ok := newAnnotations[key]; !ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/testutil"
+	kt "k8s.io/client-go/tools/cache/testing"
+)
+
+func nextEvent(t *testing.T, events <-chan PodEvent) PodEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	return PodEvent{}
+}
+
+func TestPodWatcherSendsEvents(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := kt.NewFakeControllerSource()
+	defer source.Shutdown()
+	pod := testutil.NewPodWithRole("ns", "name", "192.168.0.1", "Running", "foo_role")
+	source.Add(pod)
+
+	events, err := NewPodWatcher(source, time.Minute, 0).Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event := nextEvent(t, events); event.Type != PodAdded || event.Pod.Name != "name" {
+		t.Error("expected pod to be added, was", event.Type)
+	}
+
+	labelled := pod.DeepCopy()
+	labelled.Labels = map[string]string{"app": "foo"}
+	source.Modify(labelled)
+	if event := nextEvent(t, events); event.Type != PodModified {
+		t.Error("expected pod to be modified, was", event.Type)
+	}
+
+	changed := labelled.DeepCopy()
+	changed.Annotations[AnnotationIAMRoleKey] = "bar_role"
+	source.Modify(changed)
+	event := nextEvent(t, events)
+	if event.Type != RoleAnnotationChanged {
+		t.Error("expected role annotation to change, was", event.Type)
+	}
+	if PodRole(event.Old) != "foo_role" || PodRole(event.Pod) != "bar_role" {
+		t.Error("unexpected roles", PodRole(event.Old), PodRole(event.Pod))
+	}
+
+	source.Delete(changed)
+	if event := nextEvent(t, events); event.Type != PodDeleted {
+		t.Error("expected pod to be deleted, was", event.Type)
+	}
+
+	cancel()
+	for range events {
+	}
+}
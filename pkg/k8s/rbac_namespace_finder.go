@@ -0,0 +1,106 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	typedauthorizationv1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// ErrNamespaceAccessDenied is returned when RBAC doesn't permit the namespace
+// to be read
+var ErrNamespaceAccessDenied = fmt.Errorf("namespace access denied")
+
+// RBACNamespaceFinder gets namespaces from the API server, rather than
+// watching all namespaces, when RBAC permits user to get them. Each namespace
+// is checked with a SubjectAccessReview; namespaces that can't be read return
+// ErrNamespaceAccessDenied. Namespaces, and denials, are cached for the TTL.
+type RBACNamespaceFinder struct {
+	namespaces typedcorev1.NamespacesGetter
+	reviews    typedauthorizationv1.SubjectAccessReviewsGetter
+	user       string
+	ttl        time.Duration
+	clock      func() time.Time
+
+	mu    sync.Mutex
+	found map[string]*rbacNamespace
+}
+
+type rbacNamespace struct {
+	namespace *v1.Namespace
+	allowed   bool
+	expires   time.Time
+}
+
+// NewRBACNamespaceFinder creates the finder reviewing access for user, e.g.
+// the server's service account system:serviceaccount:kube-system:kiam-server.
+func NewRBACNamespaceFinder(namespaces typedcorev1.NamespacesGetter, reviews typedauthorizationv1.SubjectAccessReviewsGetter, user string, ttl time.Duration) *RBACNamespaceFinder {
+	return &RBACNamespaceFinder{
+		namespaces: namespaces,
+		reviews:    reviews,
+		user:       user,
+		ttl:        ttl,
+		clock:      time.Now,
+		found:      map[string]*rbacNamespace{},
+	}
+}
+
+func (f *RBACNamespaceFinder) FindNamespace(ctx context.Context, name string) (*v1.Namespace, error) {
+	f.mu.Lock()
+	found, ok := f.found[name]
+	f.mu.Unlock()
+
+	if !ok || !f.clock().Before(found.expires) {
+		var err error
+		found, err = f.find(name)
+		if err != nil {
+			return nil, err
+		}
+
+		f.mu.Lock()
+		f.found[name] = found
+		f.mu.Unlock()
+	}
+
+	if !found.allowed {
+		return nil, ErrNamespaceAccessDenied
+	}
+	return found.namespace, nil
+}
+
+func (f *RBACNamespaceFinder) find(name string) (*rbacNamespace, error) {
+	expires := f.clock().Add(f.ttl)
+
+	review, err := f.reviews.SubjectAccessReviews().Create(&authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User: f.user,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Verb:     "get",
+				Resource: "namespaces",
+				Name:     name,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reviewing access to namespace %s: %v", name, err)
+	}
+	if !review.Status.Allowed {
+		return &rbacNamespace{allowed: false, expires: expires}, nil
+	}
+
+	namespace, err := f.namespaces.Namespaces().Get(name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return &rbacNamespace{allowed: true, expires: expires}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting namespace %s: %v", name, err)
+	}
+	return &rbacNamespace{namespace: namespace, allowed: true, expires: expires}, nil
+}
@@ -0,0 +1,82 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	kt "k8s.io/client-go/testing"
+)
+
+const kiamUser = "system:serviceaccount:kube-system:kiam-server"
+
+// newReviewingClient creates a client allowing kiamUser to get the permitted
+// namespaces, counting the reviews made
+func newReviewingClient(reviews *int, permitted ...string) *fake.Clientset {
+	client := fake.NewSimpleClientset(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "red", Annotations: map[string]string{AnnotationPermittedKey: "reader"}}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "blue"}},
+	)
+	client.PrependReactor("create", "subjectaccessreviews", func(action kt.Action) (bool, runtime.Object, error) {
+		*reviews++
+		review := action.(kt.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		for _, name := range permitted {
+			if review.Spec.User == kiamUser && attributes.Verb == "get" && attributes.Resource == "namespaces" && attributes.Name == name {
+				review.Status.Allowed = true
+			}
+		}
+		return true, review, nil
+	})
+	return client
+}
+
+func TestRBACNamespaceFinder(t *testing.T) {
+	reviews := 0
+	client := newReviewingClient(&reviews, "red", "green")
+	finder := NewRBACNamespaceFinder(client.CoreV1(), client.AuthorizationV1(), kiamUser, time.Minute)
+
+	ns, err := finder.FindNamespace(context.Background(), "red")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ns.GetAnnotations()[AnnotationPermittedKey] != "reader" {
+		t.Error("unexpected namespace", ns)
+	}
+
+	if _, err := finder.FindNamespace(context.Background(), "blue"); err != ErrNamespaceAccessDenied {
+		t.Error("expected access denied, was", err)
+	}
+
+	ns, err = finder.FindNamespace(context.Background(), "green")
+	if err != nil || ns != nil {
+		t.Error("expected missing namespace not to be found, was", ns, err)
+	}
+}
+
+func TestRBACNamespaceFinderCachesReviews(t *testing.T) {
+	reviews := 0
+	client := newReviewingClient(&reviews, "red")
+	finder := NewRBACNamespaceFinder(client.CoreV1(), client.AuthorizationV1(), kiamUser, time.Minute)
+	now := time.Now()
+	finder.clock = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		finder.FindNamespace(context.Background(), "red")
+		finder.FindNamespace(context.Background(), "blue")
+	}
+	if reviews != 2 {
+		t.Fatal("expected reviews to be cached, reviews were", reviews)
+	}
+
+	now = now.Add(time.Minute)
+	finder.FindNamespace(context.Background(), "red")
+	if reviews != 3 {
+		t.Error("expected expired review to be repeated, reviews were", reviews)
+	}
+}
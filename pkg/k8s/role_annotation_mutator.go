@@ -0,0 +1,77 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// RoleAnnotationMutator is a MutatingAdmissionWebhook handler replacing role
+// names in pods' IAM role annotations with the ARNs they resolve to, so the
+// role a pod will assume is visible when it's created. Annotations that are
+// already ARNs, encrypted with KMS, or invalid (left for the
+// RoleAnnotationValidator to reject) aren't changed.
+type RoleAnnotationMutator struct {
+	resolver sts.ARNResolver
+	logger   *slog.Logger
+}
+
+// NewRoleAnnotationMutator creates the mutator resolving role names with
+// resolver, which should match the resolver configured for the server.
+func NewRoleAnnotationMutator(resolver sts.ARNResolver, logger *slog.Logger) *RoleAnnotationMutator {
+	return &RoleAnnotationMutator{resolver: resolver, logger: logger}
+}
+
+func (m *RoleAnnotationMutator) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	serveAdmissionReview(w, req, m.logger, m.Review)
+}
+
+// jsonPatchOperation is an RFC 6902 JSON Patch operation
+type jsonPatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value string `json:"value"`
+}
+
+// Review patches the role annotation of the pod in request with the role's
+// ARN. Requests for other resources are allowed unchanged.
+func (m *RoleAnnotationMutator) Review(request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	if request.Kind.Kind != "Pod" {
+		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+	}
+
+	var pod v1.Pod
+	if err := json.Unmarshal(request.Object.Raw, &pod); err != nil {
+		return deniedResponse(fmt.Sprintf("error decoding pod: %s", err))
+	}
+
+	role := PodRole(&pod)
+	if role == "" || strings.HasPrefix(role, "arn:") || strings.HasPrefix(role, sts.KMSCiphertextPrefix) || ValidateAnnotation(role) != nil {
+		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+	}
+
+	resolved, err := m.resolver.Resolve(role)
+	if err != nil {
+		return deniedResponse(fmt.Sprintf("error resolving %s annotation '%s': %s", AnnotationIAMRoleKey, role, err))
+	}
+
+	patch, err := json.Marshal([]jsonPatchOperation{{
+		Op:    "replace",
+		Path:  "/metadata/annotations/" + strings.Replace(AnnotationIAMRoleKey, "/", "~1", -1),
+		Value: resolved.ARN.String(),
+	}})
+	if err != nil {
+		return deniedResponse(fmt.Sprintf("error encoding patch: %s", err))
+	}
+
+	m.logger.Info("patched role annotation with arn", "pod.namespace", request.Namespace, "pod.name", pod.GetName(), "pod.iam.role", role, "pod.iam.roleArn", resolved.ARN.String())
+
+	patchType := admissionv1beta1.PatchTypeJSONPatch
+	return &admissionv1beta1.AdmissionResponse{Allowed: true, Patch: patch, PatchType: &patchType}
+}
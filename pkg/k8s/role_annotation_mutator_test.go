@@ -0,0 +1,65 @@
+package k8s
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/logging"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+)
+
+type failingResolver struct{}
+
+func (r *failingResolver) Resolve(role string) (*sts.ResolvedRole, error) {
+	return nil, fmt.Errorf("unknown role")
+}
+
+func TestRoleAnnotationMutator(t *testing.T) {
+	var tests = []struct {
+		name     string
+		role     string
+		resolver sts.ARNResolver
+		allowed  bool
+		patch    string
+	}{
+		{"NotAnnotated", "", sts.DefaultResolver("arn:aws:iam::123456789012:role/"), true, ""},
+		{"RoleName", "reader", sts.DefaultResolver("arn:aws:iam::123456789012:role/"), true, `[{"op":"replace","path":"/metadata/annotations/iam.amazonaws.com~1role","value":"arn:aws:iam::123456789012:role/reader"}]`},
+		{"RolePath", "/kiam/reader", sts.DefaultResolver("arn:aws:iam::123456789012:role/"), true, `[{"op":"replace","path":"/metadata/annotations/iam.amazonaws.com~1role","value":"arn:aws:iam::123456789012:role/kiam/reader"}]`},
+		{"RoleARN", "arn:aws:iam::123456789012:role/reader", sts.DefaultResolver("arn:aws:iam::123456789012:role/"), true, ""},
+		{"Encrypted", sts.KMSCiphertextPrefix + "AQID", sts.DefaultResolver("arn:aws:iam::123456789012:role/"), true, ""},
+		{"InvalidRole", "reader role", sts.DefaultResolver("arn:aws:iam::123456789012:role/"), true, ""},
+		{"UnresolvedRole", "reader", &failingResolver{}, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			NewRoleAnnotationMutator(tt.resolver, logging.Discard()).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/mutate", bytes.NewReader(podReview(t, tt.role))))
+
+			if rr.Code != http.StatusOK {
+				t.Fatal("unexpected status", rr.Code)
+			}
+			var review admissionv1beta1.AdmissionReview
+			if err := json.NewDecoder(rr.Body).Decode(&review); err != nil {
+				t.Fatal(err)
+			}
+			if review.Response.UID != "uid" {
+				t.Error("expected response uid to match request, was", review.Response.UID)
+			}
+			if review.Response.Allowed != tt.allowed {
+				t.Errorf("expected allowed to be %t: %v", tt.allowed, review.Response.Result)
+			}
+			if string(review.Response.Patch) != tt.patch {
+				t.Errorf("unexpected patch %s", review.Response.Patch)
+			}
+			if tt.patch != "" && (review.Response.PatchType == nil || *review.Response.PatchType != admissionv1beta1.PatchTypeJSONPatch) {
+				t.Error("expected json patch type, was", review.Response.PatchType)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RoleAnnotationValidator is a ValidatingAdmissionWebhook handler rejecting
+// pods whose IAM role annotation isn't a valid role.
+type RoleAnnotationValidator struct {
+	logger *slog.Logger
+}
+
+func NewRoleAnnotationValidator(logger *slog.Logger) *RoleAnnotationValidator {
+	return &RoleAnnotationValidator{logger: logger}
+}
+
+func (v *RoleAnnotationValidator) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	serveAdmissionReview(w, req, v.logger, v.Review)
+}
+
+// serveAdmissionReview decodes the AdmissionReview in req and responds with
+// the response of review
+func serveAdmissionReview(w http.ResponseWriter, req *http.Request, logger *slog.Logger, review func(*admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var admissionReview admissionv1beta1.AdmissionReview
+	if err := json.NewDecoder(req.Body).Decode(&admissionReview); err != nil {
+		http.Error(w, fmt.Sprintf("error decoding admission review: %s", err), http.StatusBadRequest)
+		return
+	}
+	if admissionReview.Request == nil {
+		http.Error(w, "admission review has no request", http.StatusBadRequest)
+		return
+	}
+
+	admissionReview.Response = review(admissionReview.Request)
+	admissionReview.Response.UID = admissionReview.Request.UID
+	admissionReview.Request = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(admissionReview); err != nil {
+		logger.Error("error encoding admission response", "error", err)
+	}
+}
+
+// Review validates the role annotation of the pod in request. Requests for
+// other resources are allowed.
+func (v *RoleAnnotationValidator) Review(request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	if request.Kind.Kind != "Pod" {
+		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+	}
+
+	var pod v1.Pod
+	if err := json.Unmarshal(request.Object.Raw, &pod); err != nil {
+		return deniedResponse(fmt.Sprintf("error decoding pod: %s", err))
+	}
+
+	role := PodRole(&pod)
+	if role == "" {
+		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+	}
+
+	if err := ValidateAnnotation(role); err != nil {
+		v.logger.Info("denied pod with invalid role annotation", "pod.namespace", request.Namespace, "pod.name", pod.GetName(), "error", err)
+		return deniedResponse(fmt.Sprintf("invalid %s annotation: %s", AnnotationIAMRoleKey, err))
+	}
+
+	return &admissionv1beta1.AdmissionResponse{Allowed: true}
+}
+
+func deniedResponse(message string) *admissionv1beta1.AdmissionResponse {
+	return &admissionv1beta1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReasonInvalid,
+			Message: message,
+			Code:    http.StatusUnprocessableEntity,
+		},
+	}
+}
@@ -0,0 +1,79 @@
+package k8s
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/logging"
+	"github.com/uswitch/kiam/pkg/testutil"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func podReview(t *testing.T, role string) []byte {
+	pod := testutil.NewPodWithRole("ns", "name", "", "Pending", role)
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	review := admissionv1beta1.AdmissionReview{
+		Request: &admissionv1beta1.AdmissionRequest{
+			UID:       "uid",
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Namespace: "ns",
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	}
+	body, err := json.Marshal(review)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestRoleAnnotationValidator(t *testing.T) {
+	var tests = []struct {
+		role    string
+		allowed bool
+	}{
+		{"", true},
+		{"reader", true},
+		{"arn:aws:iam::123456789012:role/reader", true},
+		{"arn:aws:iam::123456789012:reader", false},
+		{"reader role", false},
+	}
+
+	validator := NewRoleAnnotationValidator(logging.Discard())
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			validator.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(podReview(t, tt.role))))
+
+			if rr.Code != http.StatusOK {
+				t.Fatal("unexpected status", rr.Code)
+			}
+			var review admissionv1beta1.AdmissionReview
+			if err := json.NewDecoder(rr.Body).Decode(&review); err != nil {
+				t.Fatal(err)
+			}
+			if review.Response.UID != "uid" {
+				t.Error("expected response uid to match request, was", review.Response.UID)
+			}
+			if review.Response.Allowed != tt.allowed {
+				t.Errorf("expected allowed to be %t: %v", tt.allowed, review.Response.Result)
+			}
+		})
+	}
+}
+
+func TestRoleAnnotationValidatorRejectsInvalidReview(t *testing.T) {
+	rr := httptest.NewRecorder()
+	NewRoleAnnotationValidator(logging.Discard()).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader([]byte("{}"))))
+	if rr.Code != http.StatusBadRequest {
+		t.Error("expected bad request, was", rr.Code)
+	}
+}
@@ -0,0 +1,11 @@
+package k8s
+
+// RoleIdentityOptions controls how PodRoleIdentity builds the identities pods'
+// credentials are issued for
+type RoleIdentityOptions struct {
+	// PerPodSessions assumes roles in a session named by the pod's UID, so
+	// that credentials are cached for each role and pod rather than shared by
+	// all pods with the role, and CloudTrail attributes API calls to
+	// individual pods
+	PerPodSessions bool
+}
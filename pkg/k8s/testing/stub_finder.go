@@ -19,6 +19,7 @@ import (
 	"github.com/uswitch/kiam/pkg/aws/sts"
 	"github.com/uswitch/kiam/pkg/k8s"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func NewStubFinder(pod *v1.Pod) *StubFinder {
@@ -70,3 +71,48 @@ func NewNamespaceFinder(n *v1.Namespace) *stubNSFinder {
 func (f *stubNSFinder) FindNamespace(ctx context.Context, name string) (*v1.Namespace, error) {
 	return f.n, nil
 }
+
+type stubNodeGetter struct {
+	nodes map[string]*v1.Node
+}
+
+// NewNodeGetter returns a NodeGetter finding the nodes by name
+func NewNodeGetter(nodes ...*v1.Node) *stubNodeGetter {
+	getter := &stubNodeGetter{nodes: map[string]*v1.Node{}}
+	for _, node := range nodes {
+		getter.nodes[node.GetName()] = node
+	}
+	return getter
+}
+
+func (f *stubNodeGetter) GetNodeByName(name string) (*v1.Node, error) {
+	node, ok := f.nodes[name]
+	if !ok {
+		return nil, k8s.ErrNodeNotFound
+	}
+	return node, nil
+}
+
+type stubOwnerGetter struct {
+	owners map[string]*k8s.Owner
+	calls  int
+}
+
+// NewOwnerGetter returns an OwnerGetter finding the owners by kind and name
+func NewOwnerGetter(owners map[string]*k8s.Owner) *stubOwnerGetter {
+	return &stubOwnerGetter{owners: owners}
+}
+
+func (f *stubOwnerGetter) GetOwner(namespace string, ref metav1.OwnerReference) (*k8s.Owner, error) {
+	f.calls++
+	owner, ok := f.owners[ref.Kind+"/"+ref.Name]
+	if !ok {
+		return nil, k8s.ErrOwnerNotFound
+	}
+	return owner, nil
+}
+
+// Calls returns the number of owners got
+func (f *stubOwnerGetter) Calls() int {
+	return f.calls
+}
@@ -0,0 +1,26 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestFromContextReturnsLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	FromContext(WithLogger(context.Background(), logger)).Info("hello", "pod.name", "foo")
+
+	if !strings.Contains(buf.String(), "pod.name=foo") {
+		t.Error("expected record to be written to context logger, was", buf.String())
+	}
+}
+
+func TestFromContextDefaultsWithoutLogger(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("expected default logger")
+	}
+}
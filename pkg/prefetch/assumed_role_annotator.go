@@ -0,0 +1,27 @@
+package prefetch
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+// AssumedRoleAnnotator returns a hook annotating pods with the ARN of the role
+// session their credentials were issued for. Pods are only annotated when the
+// session was reported when assuming the role. Errors patching pods are logged.
+func AssumedRoleAnnotator(patcher k8s.PodAnnotationPatcher, sessions sts.AssumedRoleLookup) PostAssumeHook {
+	return func(ctx context.Context, pod *v1.Pod, identity *sts.RoleIdentity) {
+		user, ok := sessions.AssumedRoleUser(identity)
+		if !ok {
+			return
+		}
+
+		annotations := map[string]string{k8s.AnnotationIAMAssumedRoleARNKey: user.ARN}
+		if err := patcher.PatchPodAnnotations(ctx, pod, annotations); err != nil {
+			log.WithFields(k8s.PodFields(pod)).Warnf("error annotating assumed role: %s", err.Error())
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package prefetch
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	kt "github.com/uswitch/kiam/pkg/k8s/testing"
+	"github.com/uswitch/kiam/pkg/testutil"
+	v1 "k8s.io/api/core/v1"
+)
+
+type stubPatcher struct {
+	patched map[string]string
+	err     error
+}
+
+func (p *stubPatcher) PatchPodAnnotations(ctx context.Context, pod *v1.Pod, annotations map[string]string) error {
+	p.patched = annotations
+	return p.err
+}
+
+type stubSessions map[string]*sts.AssumedRoleUser
+
+func (s stubSessions) AssumedRoleUser(identity *sts.RoleIdentity) (*sts.AssumedRoleUser, bool) {
+	user, ok := s[identity.String()]
+	return user, ok
+}
+
+func TestAssumedRoleAnnotator(t *testing.T) {
+	resolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+	pod := testutil.NewPodWithRole("ns", "name", "ip", "Running", "role")
+	identity, _ := k8s.PodRoleIdentity(resolver, "role", pod, k8s.RoleIdentityOptions{})
+	arn := "arn:aws:sts::123456789012:assumed-role/role/kiam-kiam"
+
+	patcher := &stubPatcher{}
+	AssumedRoleAnnotator(patcher, stubSessions{identity.String(): {ARN: arn}})(context.Background(), pod, identity)
+	if patcher.patched[k8s.AnnotationIAMAssumedRoleARNKey] != arn {
+		t.Error("expected pod to be annotated, was", patcher.patched)
+	}
+
+	patcher = &stubPatcher{}
+	AssumedRoleAnnotator(patcher, stubSessions{})(context.Background(), pod, identity)
+	if patcher.patched != nil {
+		t.Error("expected pod not to be annotated without a reported session, was", patcher.patched)
+	}
+
+	patcher = &stubPatcher{err: fmt.Errorf("forbidden")}
+	AssumedRoleAnnotator(patcher, stubSessions{identity.String(): {ARN: arn}})(context.Background(), pod, identity)
+}
+
+func TestPostAssumeHooksCalledAfterFetching(t *testing.T) {
+	cache := testutil.NewStubCredentialsCache(func(identity *sts.RoleIdentity) (*sts.Credentials, error) {
+		if identity.Role.Name == "failing" {
+			return nil, fmt.Errorf("error issuing")
+		}
+		return &sts.Credentials{}, nil
+	})
+	manager := NewManager(cache, kt.NewStubAnnouncer(), sts.DefaultResolver("arn:aws:iam::123456789012:role/"))
+
+	var hooked []string
+	manager.AddPostAssumeHook(func(ctx context.Context, pod *v1.Pod, identity *sts.RoleIdentity) {
+		hooked = append(hooked, identity.Role.Name)
+	})
+
+	manager.fetchCredentials(context.Background(), testutil.NewPodWithRole("ns", "name", "ip", "Running", "role"))
+	manager.fetchCredentials(context.Background(), testutil.NewPodWithRole("ns", "failing", "ip", "Running", "failing"))
+
+	if len(hooked) != 1 || hooked[0] != "role" {
+		t.Error("expected hook called after fetching credentials, was", hooked)
+	}
+}
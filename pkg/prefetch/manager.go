@@ -15,6 +15,7 @@ package prefetch
 
 import (
 	"context"
+	"fmt"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/uswitch/kiam/pkg/aws/sts"
@@ -22,6 +23,10 @@ import (
 	v1 "k8s.io/api/core/v1"
 )
 
+// ErrRoleNotCached is returned when invalidating a role without cached
+// credentials
+var ErrRoleNotCached = fmt.Errorf("no credentials cached for role")
+
 // CredentialManager watches for Pod changes and prefetches credentials. For any
 // expiring credentials it checks whether pods are still active and requests new
 // ones.
@@ -29,12 +34,30 @@ type CredentialManager struct {
 	cache       sts.CredentialsCache // where it stores credentials
 	announcer   k8s.PodAnnouncer     // to understand which pods are running
 	arnResolver sts.ARNResolver      // to convert from role names to fully qualified names
+	options     k8s.RoleIdentityOptions
+	hooks       []PostAssumeHook // called after credentials are fetched for a pod
 }
 
+// PostAssumeHook is called after credentials for the pod's identity have been
+// fetched
+type PostAssumeHook func(ctx context.Context, pod *v1.Pod, identity *sts.RoleIdentity)
+
 func NewManager(cache sts.CredentialsCache, announcer k8s.PodAnnouncer, resolver sts.ARNResolver) *CredentialManager {
 	return &CredentialManager{cache: cache, announcer: announcer, arnResolver: resolver}
 }
 
+// SetRoleIdentityOptions controls how pods' identities are built, they must
+// match the options used by the PodAnnouncer. Must be called before Run.
+func (m *CredentialManager) SetRoleIdentityOptions(options k8s.RoleIdentityOptions) {
+	m.options = options
+}
+
+// AddPostAssumeHook adds a hook called after credentials are fetched for a
+// pod. Must be called before Run.
+func (m *CredentialManager) AddPostAssumeHook(hook PostAssumeHook) {
+	m.hooks = append(m.hooks, hook)
+}
+
 func (m *CredentialManager) fetchCredentials(ctx context.Context, pod *v1.Pod) {
 	logger := log.WithFields(k8s.PodFields(pod))
 	if k8s.IsPodCompleted(pod) {
@@ -42,11 +65,7 @@ func (m *CredentialManager) fetchCredentials(ctx context.Context, pod *v1.Pod) {
 		return
 	}
 
-	role := k8s.PodRole(pod)
-	sessionName := k8s.PodSessionName(pod)
-	externalID := k8s.PodExternalID(pod)
-
-	identity, err := sts.NewRoleIdentity(m.arnResolver, role, sessionName, externalID)
+	identity, err := k8s.PodRoleIdentity(m.arnResolver, k8s.PodRole(pod), pod, m.options)
 	if err != nil {
 		logger.Errorf("error creating role identity: %s", err.Error())
 		return
@@ -55,8 +74,12 @@ func (m *CredentialManager) fetchCredentials(ctx context.Context, pod *v1.Pod) {
 	issued, err := m.fetchCredentialsFromCache(ctx, identity)
 	if err != nil {
 		logger.Errorf("error warming credentials: %s", err.Error())
-	} else {
-		logger.WithFields(sts.CredentialsFields(identity, issued)).Infof("fetched credentials")
+		return
+	}
+	logger.WithFields(sts.CredentialsFields(identity, issued)).Infof("fetched credentials")
+
+	for _, hook := range m.hooks {
+		hook(ctx, pod, identity)
 	}
 }
 
@@ -104,6 +127,55 @@ func (m *CredentialManager) handleExpiring(ctx context.Context, credentials *sts
 	}
 }
 
+// Stats returns a snapshot of the credentials cache's hit, miss and eviction
+// counters. The stats are empty when the cache doesn't report them.
+func (m *CredentialManager) Stats() sts.CacheStats {
+	reporter, ok := m.cache.(sts.CacheStatsReporter)
+	if !ok {
+		return sts.CacheStats{}
+	}
+	return reporter.Stats()
+}
+
+// invalidatingCache is a credentials cache roles can be invalidated in
+type invalidatingCache interface {
+	sts.CachedIdentityLister
+	sts.CredentialsEvicter
+}
+
+// Invalidate removes the credentials cached for the role, a name or ARN, and
+// fetches new credentials in the background, e.g. to rotate credentials that
+// may have been compromised. It returns ErrRoleNotCached when no credentials
+// are cached for the role.
+func (m *CredentialManager) Invalidate(role string) error {
+	cache, ok := m.cache.(invalidatingCache)
+	if !ok {
+		return fmt.Errorf("credentials cache doesn't support invalidation")
+	}
+
+	resolved, err := m.arnResolver.Resolve(role)
+	if err != nil {
+		return fmt.Errorf("error resolving role %s: %v", role, err)
+	}
+
+	identities := cache.CachedIdentities(resolved.ARN)
+	if len(identities) == 0 {
+		return ErrRoleNotCached
+	}
+
+	for _, identity := range identities {
+		cache.Evict(identity)
+		log.WithFields(identity.LogFields()).Infof("invalidated credentials, fetching new")
+
+		go func(identity *sts.RoleIdentity) {
+			if _, err := m.fetchCredentialsFromCache(context.Background(), identity); err != nil {
+				log.WithFields(identity.LogFields()).Errorf("error fetching invalidated credentials: %s", err.Error())
+			}
+		}(identity)
+	}
+	return nil
+}
+
 func (m *CredentialManager) IsRoleActive(identity *sts.RoleIdentity) (bool, error) {
 	return m.announcer.IsActivePodsForRole(identity)
 }
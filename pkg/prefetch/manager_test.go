@@ -36,7 +36,7 @@ func TestPrefetchRunningPods(t *testing.T) {
 		requestedRoles <- identity.Role.Name
 		return &sts.Credentials{}, nil
 	})
-	manager := NewManager(cache, announcer, sts.DefaultResolver("prefix"))
+	manager := NewManager(cache, announcer, sts.DefaultResolver("arn:aws:iam::123456789012:role/"))
 	go manager.Run(ctx, 1)
 
 	announcer.Announce(testutil.NewPodWithRole("ns", "name", "ip", "Running", "role"))
@@ -67,7 +67,7 @@ func TestRenewsCredentialsForRunningPod(t *testing.T) {
 		return credentials, nil
 	})
 	announcer := kt.NewStubAnnouncer()
-	manager := NewManager(cache, announcer, sts.DefaultResolver("prefix"))
+	manager := NewManager(cache, announcer, sts.DefaultResolver("arn:aws:iam::123456789012:role/"))
 	go manager.Run(ctx, 1)
 
 	announcer.Announce(testutil.NewPodWithRole("ns", "name", "ip", "Running", "role"))
@@ -96,7 +96,7 @@ func TestPodSessionName(t *testing.T) {
 		return &sts.Credentials{}, nil
 	})
 
-	manager := NewManager(cache, announcer, sts.DefaultResolver("prefix"))
+	manager := NewManager(cache, announcer, sts.DefaultResolver("arn:aws:iam::123456789012:role/"))
 	go manager.Run(ctx, 1)
 
 	announcer.Announce(testutil.NewPodWithSessionName("ns", "name", "ip", "Running", "role", "session-name"))
@@ -119,7 +119,7 @@ func TestPodExternalID(t *testing.T) {
 		return &sts.Credentials{}, nil
 	})
 
-	manager := NewManager(cache, announcer, sts.DefaultResolver("prefix"))
+	manager := NewManager(cache, announcer, sts.DefaultResolver("arn:aws:iam::123456789012:role/"))
 	go manager.Run(ctx, 1)
 
 	announcer.Announce(testutil.NewPodWithExternalID("ns", "name", "ip", "Running", "role", "external-id"))
@@ -128,3 +128,93 @@ func TestPodExternalID(t *testing.T) {
 		t.Error("should have requested external-id")
 	}
 }
+
+type statsCache struct {
+	sts.CredentialsCache
+	stats sts.CacheStats
+}
+
+func (c *statsCache) Stats() sts.CacheStats {
+	return c.stats
+}
+
+func TestManagerStats(t *testing.T) {
+	cache := testutil.NewStubCredentialsCache(func(identity *sts.RoleIdentity) (*sts.Credentials, error) {
+		return &sts.Credentials{}, nil
+	})
+
+	manager := NewManager(cache, kt.NewStubAnnouncer(), sts.DefaultResolver("arn:aws:iam::123456789012:role/"))
+	if stats := manager.Stats(); stats != (sts.CacheStats{}) {
+		t.Error("expected empty stats when the cache doesn't report them, was", stats)
+	}
+
+	stats := sts.CacheStats{Hits: 5, Misses: 2, Evictions: 1, CurrentSize: 1}
+	manager = NewManager(&statsCache{CredentialsCache: cache, stats: stats}, kt.NewStubAnnouncer(), sts.DefaultResolver("arn:aws:iam::123456789012:role/"))
+	if manager.Stats() != stats {
+		t.Error("unexpected stats", manager.Stats())
+	}
+}
+
+type invalidatedCache struct {
+	sts.CredentialsCache
+	identities []*sts.RoleIdentity
+	evicted    []*sts.RoleIdentity
+}
+
+func (c *invalidatedCache) CachedIdentities(roleARN sts.ARN) []*sts.RoleIdentity {
+	found := []*sts.RoleIdentity{}
+	for _, identity := range c.identities {
+		if identity.Role.ARN.Equals(roleARN) {
+			found = append(found, identity)
+		}
+	}
+	return found
+}
+
+func (c *invalidatedCache) Evict(identity *sts.RoleIdentity) bool {
+	c.evicted = append(c.evicted, identity)
+	return true
+}
+
+func TestInvalidateRefreshesRoleCredentials(t *testing.T) {
+	requested := make(chan *sts.RoleIdentity, 2)
+	resolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+	reader, _ := sts.NewRoleIdentity(resolver, "reader", "red", "")
+	writer, _ := sts.NewRoleIdentity(resolver, "writer", "", "")
+	cache := &invalidatedCache{
+		CredentialsCache: testutil.NewStubCredentialsCache(func(identity *sts.RoleIdentity) (*sts.Credentials, error) {
+			requested <- identity
+			return &sts.Credentials{}, nil
+		}),
+		identities: []*sts.RoleIdentity{reader, writer},
+	}
+	manager := NewManager(cache, kt.NewStubAnnouncer(), resolver)
+
+	if err := manager.Invalidate("reader"); err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.evicted) != 1 || cache.evicted[0] != reader {
+		t.Error("expected reader credentials to be evicted, was", cache.evicted)
+	}
+
+	select {
+	case identity := <-requested:
+		if identity != reader {
+			t.Error("expected reader credentials to be fetched, was", identity)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected invalidated credentials to be fetched")
+	}
+}
+
+func TestInvalidateUnknownRole(t *testing.T) {
+	resolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+	cache := &invalidatedCache{CredentialsCache: testutil.NewStubCredentialsCache(func(identity *sts.RoleIdentity) (*sts.Credentials, error) {
+		return &sts.Credentials{}, nil
+	})}
+	manager := NewManager(cache, kt.NewStubAnnouncer(), resolver)
+
+	if err := manager.Invalidate("arn:aws:iam::123456789012:role/reader"); err != ErrRoleNotCached {
+		t.Error("expected role not cached, was", err)
+	}
+}
@@ -0,0 +1,46 @@
+package prefetch
+
+import (
+	"context"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/uswitch/kiam/pkg/aws/sts"
+)
+
+// PreloadRoles concurrently fetches credentials for each role, storing them
+// in the manager's cache so the first pods requesting them don't wait for
+// STS. Roles are fetched without a session name or external id, matching pods
+// that aren't annotated with either. Errors for individual roles are logged
+// and don't stop the others being fetched; an error is only returned if ctx
+// is done before all roles are fetched.
+func PreloadRoles(ctx context.Context, roles []string, manager *CredentialManager) error {
+	var wg sync.WaitGroup
+	for _, role := range roles {
+		wg.Add(1)
+		go func(role string) {
+			defer wg.Done()
+			manager.preloadRole(ctx, role)
+		}(role)
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+func (m *CredentialManager) preloadRole(ctx context.Context, role string) {
+	logger := log.WithField("pod.iam.role", role)
+
+	identity, err := sts.NewRoleIdentity(m.arnResolver, role, "", "")
+	if err != nil {
+		logger.Errorf("error creating role identity: %s", err.Error())
+		return
+	}
+
+	issued, err := m.fetchCredentialsFromCache(ctx, identity)
+	if err != nil {
+		logger.Errorf("error preloading credentials: %s", err.Error())
+		return
+	}
+	logger.WithFields(sts.CredentialsFields(identity, issued)).Infof("preloaded credentials")
+}
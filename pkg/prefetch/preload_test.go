@@ -0,0 +1,54 @@
+package prefetch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	kt "github.com/uswitch/kiam/pkg/k8s/testing"
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+func TestPreloadRolesContinuesAfterErrors(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	cache := testutil.NewStubCredentialsCache(func(identity *sts.RoleIdentity) (*sts.Credentials, error) {
+		mu.Lock()
+		requested = append(requested, identity.Role.Name)
+		mu.Unlock()
+
+		if identity.Role.Name == "broken" {
+			return nil, fmt.Errorf("access denied")
+		}
+		return &sts.Credentials{}, nil
+	})
+	manager := NewManager(cache, kt.NewStubAnnouncer(), sts.DefaultResolver("arn:aws:iam::123456789012:role/"))
+
+	err := PreloadRoles(context.Background(), []string{"reader", "broken", "writer"}, manager)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(requested)
+	if strings.Join(requested, ",") != "broken,reader,writer" {
+		t.Error("expected all roles to be requested, was", requested)
+	}
+}
+
+func TestPreloadRolesReturnsContextError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cache := testutil.NewStubCredentialsCache(func(identity *sts.RoleIdentity) (*sts.Credentials, error) {
+		return nil, context.Canceled
+	})
+	manager := NewManager(cache, kt.NewStubAnnouncer(), sts.DefaultResolver("arn:aws:iam::123456789012:role/"))
+
+	if err := PreloadRoles(ctx, []string{"reader"}, manager); err != context.Canceled {
+		t.Error("expected context error, was", err)
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// DefaultAdaptiveIncreaseInterval is how often the adaptive rate limit is
+// increased while STS isn't throttling requests
+const DefaultAdaptiveIncreaseInterval = 10 * time.Second
+
+// AdaptiveRateLimiter adjusts the rate each pod is limited to using AIMD
+// (additive increase, multiplicative decrease). The rate is halved when STS
+// throttles requests, and increased by a step each interval without
+// throttling, up to the maximum. Throttling observed within an interval of the
+// last decrease is treated as the same event, so that concurrent throttled
+// requests only halve the rate once. The rate is never decreased below the
+// step.
+type AdaptiveRateLimiter struct {
+	max      rate.Limit
+	step     rate.Limit
+	interval time.Duration
+	clock    ClockFunc
+	gauge    prometheus.GaugeFunc
+
+	mu        sync.Mutex
+	current   rate.Limit
+	adjusted  time.Time
+	decreased time.Time
+}
+
+// NewAdaptiveRateLimiter creates the limiter starting at, and increasing up to,
+// max requests per second. The rate is increased by step every interval.
+func NewAdaptiveRateLimiter(max, step rate.Limit, interval time.Duration) *AdaptiveRateLimiter {
+	l := &AdaptiveRateLimiter{
+		max:      max,
+		step:     step,
+		interval: interval,
+		clock:    time.Now,
+		current:  max,
+	}
+	l.gauge = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: "kiam",
+			Subsystem: "policy",
+			Name:      "adaptive_rate_limit",
+			Help:      "Requests per second each pod is currently limited to by the adaptive rate limiter",
+		},
+		func() float64 { return float64(l.Limit()) },
+	)
+	return l
+}
+
+// Throttled halves the rate, unless it was decreased within the last interval
+func (l *AdaptiveRateLimiter) Throttled() {
+	now := l.clock()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.increase(now)
+	if !l.decreased.IsZero() && now.Sub(l.decreased) < l.interval {
+		return
+	}
+
+	l.current = l.current / 2
+	if l.current < l.step {
+		l.current = l.step
+	}
+	l.adjusted = now
+	l.decreased = now
+}
+
+// Limit returns the rate each pod is currently limited to
+func (l *AdaptiveRateLimiter) Limit() rate.Limit {
+	now := l.clock()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.increase(now)
+	return l.current
+}
+
+// increase adds a step for each interval since the rate was last adjusted.
+// Must be called with the lock held.
+func (l *AdaptiveRateLimiter) increase(now time.Time) {
+	if l.current >= l.max {
+		l.adjusted = now
+		return
+	}
+
+	intervals := int(now.Sub(l.adjusted) / l.interval)
+	if intervals <= 0 {
+		return
+	}
+
+	l.current += rate.Limit(intervals) * l.step
+	if l.current > l.max {
+		l.current = l.max
+	}
+	l.adjusted = l.adjusted.Add(time.Duration(intervals) * l.interval)
+}
+
+// RegisterMetrics registers the gauge reporting the current rate with reg. If
+// another limiter's gauge is already registered it's kept.
+func (l *AdaptiveRateLimiter) RegisterMetrics(reg prometheus.Registerer) error {
+	err := reg.Register(l.gauge)
+	if _, ok := err.(prometheus.AlreadyRegisteredError); ok {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/time/rate"
+)
+
+func TestAdaptiveRateLimiter(t *testing.T) {
+	clock := &stepClock{now: time.Unix(0, 0)}
+	limiter := NewAdaptiveRateLimiter(8, 1, 10*time.Second)
+	limiter.clock = clock.Now
+
+	var steps = []struct {
+		name     string
+		advance  time.Duration
+		throttle bool
+		expected rate.Limit
+	}{
+		{"StartsAtMax", 0, false, 8},
+		{"HalvedWhenThrottled", 0, true, 4},
+		{"ThrottlingWithinIntervalIgnored", 5 * time.Second, true, 4},
+		{"IncreasedEachInterval", 5 * time.Second, false, 5},
+		{"HalvedAgainAfterInterval", 0, true, 2.5},
+		{"IncreasedThenHalved", 10 * time.Second, true, 1.75},
+		{"IncreasedForElapsedIntervals", 30 * time.Second, false, 4.75},
+		{"CappedAtMax", time.Minute, false, 8},
+	}
+
+	for _, step := range steps {
+		clock.Advance(step.advance)
+		if step.throttle {
+			limiter.Throttled()
+		}
+		if limit := limiter.Limit(); limit != step.expected {
+			t.Errorf("%s: expected limit %g, was %g", step.name, float64(step.expected), float64(limit))
+		}
+	}
+}
+
+func TestAdaptiveRateLimiterNotDecreasedBelowStep(t *testing.T) {
+	limiter := NewAdaptiveRateLimiter(4, 3, 10*time.Second)
+
+	limiter.Throttled()
+	if limit := limiter.Limit(); limit != 3 {
+		t.Error("expected limit to be floored at the step, was", limit)
+	}
+}
+
+func TestAdaptiveRateLimiterGauge(t *testing.T) {
+	limiter := NewAdaptiveRateLimiter(8, 1, 10*time.Second)
+	limiter.Throttled()
+
+	if value := testutil.ToFloat64(limiter.gauge); value != 4 {
+		t.Error("expected gauge to report the current limit, was", value)
+	}
+
+	reg := prometheus.NewRegistry()
+	if err := limiter.RegisterMetrics(reg); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewAdaptiveRateLimiter(8, 1, 10*time.Second).RegisterMetrics(reg); err != nil {
+		t.Error("expected registering another limiter not to fail, was", err)
+	}
+}
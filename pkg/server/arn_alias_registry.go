@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	"github.com/uswitch/kiam/pkg/logging"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ARNAliasRegistry maps short, human-readable aliases to role ARNs so that
+// annotations can name e.g. "billing-reader" instead of the full ARN. Aliases
+// are loaded from a ConfigMap: each data key is an alias and its value the
+// role ARN. Roles that aren't aliases are resolved by the wrapped resolver.
+type ARNAliasRegistry struct {
+	resolver sts.ARNResolver
+	watcher  *k8s.ConfigMapWatcher
+	logger   *slog.Logger
+	changeNotifier
+
+	mu      sync.RWMutex
+	aliases map[string]string
+}
+
+// NewARNAliasRegistry creates the registry watching the ConfigMap from source
+// for aliases, falling back to resolver. Run must be called to start watching.
+func NewARNAliasRegistry(resolver sts.ARNResolver, source cache.ListerWatcher, syncInterval time.Duration) *ARNAliasRegistry {
+	r := &ARNAliasRegistry{resolver: resolver, logger: slog.Default(), aliases: map[string]string{}}
+	r.watcher = k8s.NewConfigMapWatcher(source, syncInterval, r.update)
+	return r
+}
+
+// Run starts watching the ConfigMap. Blocks until the aliases have been loaded
+func (r *ARNAliasRegistry) Run(ctx context.Context) error {
+	r.logger = logging.FromContext(ctx)
+	return r.watcher.Run(ctx)
+}
+
+// HealthCheck reports whether the ConfigMap has been loaded
+func (r *ARNAliasRegistry) HealthCheck() (HealthStatus, error) {
+	if !r.watcher.HasSynced() {
+		return HealthStatus{Healthy: false, Message: "arn aliases configmap not loaded"}, nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return HealthStatus{Healthy: true, Message: fmt.Sprintf("%d arn aliases", len(r.aliases))}, nil
+}
+
+// Aliases returns the registered aliases, sorted.
+func (r *ARNAliasRegistry) Aliases() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	aliases := make([]string, 0, len(r.aliases))
+	for alias := range r.aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// Lookup returns the ARN registered for alias. Values containing a : are
+// never aliases.
+func (r *ARNAliasRegistry) Lookup(alias string) (string, bool) {
+	if !isAlias(alias) {
+		return "", false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	arn, ok := r.aliases[alias]
+	return arn, ok
+}
+
+// Resolve expands role if it's a registered alias before resolving it with
+// the wrapped resolver.
+func (r *ARNAliasRegistry) Resolve(role string) (*sts.ResolvedRole, error) {
+	if arn, ok := r.Lookup(role); ok {
+		return r.resolver.Resolve(arn)
+	}
+	return r.resolver.Resolve(role)
+}
+
+func (r *ARNAliasRegistry) update(data map[string]string) {
+	aliases := map[string]string{}
+
+	for alias, value := range data {
+		alias = strings.TrimSpace(alias)
+		arn := strings.TrimSpace(value)
+		if !isAlias(alias) {
+			r.logger.Warn("ignoring invalid arn alias", "alias", alias)
+			continue
+		}
+		if _, err := sts.ParseARN(arn); err != nil {
+			r.logger.Warn("ignoring arn alias with invalid arn", "alias", alias, "arn", arn, "error", err)
+			continue
+		}
+		aliases[alias] = arn
+	}
+
+	r.mu.Lock()
+	r.aliases = aliases
+	r.mu.Unlock()
+	r.notify()
+
+	r.logger.Info("loaded arn aliases", "aliases", len(aliases))
+}
+
+func isAlias(s string) bool {
+	return s != "" && !strings.Contains(s, ":")
+}
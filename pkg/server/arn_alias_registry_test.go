@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	kt "github.com/uswitch/kiam/pkg/k8s/testing"
+	"github.com/uswitch/kiam/pkg/testutil"
+	fcache "k8s.io/client-go/tools/cache/testing"
+)
+
+func newTestARNAliasRegistry(t *testing.T, ctx context.Context, data map[string]string) (*ARNAliasRegistry, *fcache.FakeControllerSource) {
+	t.Helper()
+
+	source := fcache.NewFakeControllerSource()
+	source.Add(testutil.NewConfigMap("kube-system", "kiam-aliases", data))
+
+	registry := NewARNAliasRegistry(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), source, time.Minute)
+	if err := registry.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return registry, source
+}
+
+func TestARNAliasRegistryResolve(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry, source := newTestARNAliasRegistry(t, ctx, map[string]string{
+		"billing-reader": "arn:aws:iam::210987654321:role/billing/reader",
+		"invalid":        "not-an-arn",
+	})
+	defer source.Shutdown()
+
+	var tests = []struct {
+		name     string
+		role     string
+		expected string
+	}{
+		{"Alias", "billing-reader", "arn:aws:iam::210987654321:role/billing/reader"},
+		{"InvalidAlias", "invalid", "arn:aws:iam::123456789012:role/invalid"},
+		{"RoleName", "red_role", "arn:aws:iam::123456789012:role/red_role"},
+		{"ARN", "arn:aws:iam::123456789012:role/billing-reader", "arn:aws:iam::123456789012:role/billing-reader"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, err := registry.Resolve(tt.role)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resolved.ARN.String() != tt.expected {
+				t.Errorf("expected %s, was %s", tt.expected, resolved.ARN)
+			}
+		})
+	}
+
+	status, _ := registry.HealthCheck()
+	if !status.Healthy || status.Message != "1 arn aliases" {
+		t.Error("unexpected health", status)
+	}
+}
+
+func TestPerPodSessionsWithARNAliases(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry, source := newTestARNAliasRegistry(t, ctx, map[string]string{
+		"billing-reader": "arn:aws:iam::210987654321:role/billing/reader",
+	})
+	defer source.Shutdown()
+
+	options := k8s.RoleIdentityOptions{PerPodSessions: true}
+	first := testutil.NewPodWithRole("red", "first", "192.168.0.1", testutil.PhaseRunning, "billing-reader")
+	first.UID = "first-uid"
+	second := testutil.NewPodWithRole("red", "second", "192.168.0.2", testutil.PhaseRunning, "billing-reader")
+	second.UID = "second-uid"
+
+	pods := fcache.NewFakeControllerSource()
+	defer pods.Shutdown()
+	pods.Add(first)
+	pods.Add(second)
+	podCache := k8s.NewPodCache(registry, pods, time.Second, defaultBuffer)
+	podCache.SetRoleIdentityOptions(options)
+	if err := podCache.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	firstIdentity, err := k8s.PodRoleIdentity(registry, k8s.PodRole(first), first, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondIdentity, _ := k8s.PodRoleIdentity(registry, k8s.PodRole(second), second, options)
+
+	if firstIdentity.Role.ARN.String() != "arn:aws:iam::210987654321:role/billing/reader" {
+		t.Error("expected alias to be resolved, was", firstIdentity.Role.ARN)
+	}
+	if firstIdentity.SessionName != "first-uid" || secondIdentity.SessionName != "second-uid" {
+		t.Errorf("expected sessions named by pod uid, were %s and %s", firstIdentity.SessionName, secondIdentity.SessionName)
+	}
+	for _, identity := range []*sts.RoleIdentity{firstIdentity, secondIdentity} {
+		if active, _ := podCache.IsActivePodsForRole(identity); !active {
+			t.Error("expected pod cache to index identity", identity.String())
+		}
+	}
+}
+
+func TestNamespacePolicyExpandsARNAliases(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry, source := newTestARNAliasRegistry(t, ctx, map[string]string{
+		"billing-reader": "arn:aws:iam::210987654321:role/billing/reader",
+	})
+	defer source.Shutdown()
+
+	nf := kt.NewNamespaceFinder(testutil.NewNamespace("red", "billing-reader|arn:aws:iam::123456789012:role/red.*"))
+	pod := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "billing-reader")
+
+	for _, strict := range []bool{true, false} {
+		policy := NewNamespacePermittedRoleNamePolicy(strict, nf, registry)
+		policy.SetARNAliases(registry)
+
+		var tests = []struct {
+			role     string
+			expected bool
+		}{
+			{"billing-reader", true},
+			{"arn:aws:iam::210987654321:role/billing/reader", true},
+			{"arn:aws:iam::210987654321:role/billing/reader-admin", false},
+			{"red_role", true},
+			{"orange_role", false},
+		}
+
+		for _, tt := range tests {
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), tt.role, pod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("strict=%t role=%s: expected allowed to be %t: %s", strict, tt.role, tt.expected, decision.Explanation())
+			}
+		}
+	}
+
+	policy := NewNamespacePermittedRoleNamePolicy(true, nf, registry)
+	policy.SetARNAliases(registry)
+	eventuallyAllowed(t, registry, policy, "arn:aws:iam::210987654321:role/billing/reader", pod, true)
+
+	source.Modify(testutil.NewConfigMap("kube-system", "kiam-aliases", map[string]string{
+		"billing-reader": "arn:aws:iam::210987654321:role/billing/writer",
+	}))
+	eventuallyAllowed(t, registry, policy, "arn:aws:iam::210987654321:role/billing/reader", pod, false)
+	eventuallyAllowed(t, registry, policy, "billing-reader", pod, true)
+}
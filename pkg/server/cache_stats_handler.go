@@ -0,0 +1,21 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/logging"
+)
+
+// cacheStatsHandler serves a snapshot of the credentials cache's stats as JSON
+type cacheStatsHandler struct {
+	stats func() sts.CacheStats
+}
+
+func (h *cacheStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.stats()); err != nil {
+		logging.FromContext(r.Context()).Error("error writing cache stats", "error", err)
+	}
+}
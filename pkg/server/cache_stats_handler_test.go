@@ -0,0 +1,25 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+)
+
+func TestCacheStatsHandler(t *testing.T) {
+	handler := &cacheStatsHandler{stats: func() sts.CacheStats {
+		return sts.CacheStats{Hits: 3, Misses: 2, Evictions: 1, CurrentSize: 1}
+	}}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/debug/cache-stats", nil))
+
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Error("unexpected content type", w.Header().Get("Content-Type"))
+	}
+	expected := `{"hits":3,"misses":2,"evictions":1,"currentSize":1}` + "\n"
+	if w.Body.String() != expected {
+		t.Error("unexpected body", w.Body.String())
+	}
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/logging"
+	v1 "k8s.io/api/core/v1"
+)
+
+// credentialStreamKeepalive is how often a comment is written to idle streams,
+// stopping proxies from closing them
+const credentialStreamKeepalive = 30 * time.Second
+
+// RoleAuthorizer checks whether the pod with ip may assume role, returning
+// the pod and the identity its credentials are issued for. KiamServer
+// authorizes roles as it does when pods request credentials.
+type RoleAuthorizer interface {
+	AuthorizeRole(ctx context.Context, ip, role string) (*v1.Pod, *sts.RoleIdentity, error)
+}
+
+// ServerSentEventsCredentialStream pushes credentials to subscribed agents, as
+// JSON Server-Sent Events, when they're issued or refreshed. Agents subscribe
+// on behalf of a pod with the ip and role query parameters. The pod must be
+// allowed to assume the role when subscribing, and is checked again before
+// each credentials are sent; the stream is closed once it's no longer allowed.
+type ServerSentEventsCredentialStream struct {
+	authorizer RoleAuthorizer
+	keepalive  time.Duration
+
+	mu          sync.Mutex
+	subscribers map[string]map[chan *sts.Credentials]struct{}
+}
+
+func NewServerSentEventsCredentialStream() *ServerSentEventsCredentialStream {
+	return &ServerSentEventsCredentialStream{
+		keepalive:   credentialStreamKeepalive,
+		subscribers: map[string]map[chan *sts.Credentials]struct{}{},
+	}
+}
+
+// SetAuthorizer sets the authorizer checking subscriptions. Must be called
+// before serving.
+func (s *ServerSentEventsCredentialStream) SetAuthorizer(authorizer RoleAuthorizer) {
+	s.authorizer = authorizer
+}
+
+// Publish sends credentials to the identity's subscribers. It doesn't block,
+// subscribers that haven't received earlier credentials are sent only the
+// latest.
+func (s *ServerSentEventsCredentialStream) Publish(creds *sts.CachedCredentials) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ch := range s.subscribers[creds.Identity.String()] {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- creds.Credentials
+	}
+}
+
+// subscribe returns a channel receiving credentials for identity, and a
+// function to unsubscribe
+func (s *ServerSentEventsCredentialStream) subscribe(identity *sts.RoleIdentity) (<-chan *sts.Credentials, func()) {
+	key := identity.String()
+	ch := make(chan *sts.Credentials, 1)
+
+	s.mu.Lock()
+	if s.subscribers[key] == nil {
+		s.subscribers[key] = map[chan *sts.Credentials]struct{}{}
+	}
+	s.subscribers[key][ch] = struct{}{}
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subscribers[key], ch)
+		if len(s.subscribers[key]) == 0 {
+			delete(s.subscribers, key)
+		}
+	}
+}
+
+func (s *ServerSentEventsCredentialStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	ip, role := query.Get("ip"), query.Get("role")
+	if ip == "" || role == "" {
+		http.Error(w, "ip and role are required", http.StatusBadRequest)
+		return
+	}
+	ctx := WithServiceAccountToken(r.Context(), r.Header.Get(ServiceAccountTokenHeader))
+	_, identity, err := s.authorizer.AuthorizeRole(ctx, ip, role)
+	if err != nil {
+		http.Error(w, err.Error(), authorizationStatus(err))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	credentials, unsubscribe := s.subscribe(identity)
+	defer unsubscribe()
+
+	logger := logging.FromContext(ctx).With("credentials.role", identity.Role.ARN)
+	logger.Debug("subscribed to credentials")
+	defer logger.Debug("unsubscribed from credentials")
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(s.keepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case creds := <-credentials:
+			if !s.stillAuthorized(ctx, ip, role, identity) {
+				logger.Info("closing credential stream, pod no longer allowed role", "pod.ip", ip)
+				return
+			}
+			data, err := json.Marshal(creds)
+			if err != nil {
+				logger.Error("error encoding credentials", "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: credentials\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// stillAuthorized checks the pod with ip is still allowed role, with the
+// same identity it subscribed with
+func (s *ServerSentEventsCredentialStream) stillAuthorized(ctx context.Context, ip, role string, subscribed *sts.RoleIdentity) bool {
+	_, identity, err := s.authorizer.AuthorizeRole(ctx, ip, role)
+	return err == nil && identity.String() == subscribed.String()
+}
+
+// authorizationStatus returns the http status for an error authorizing a role
+func authorizationStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrPodNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrPolicyForbidden):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// credentialStreamServer serves the credential stream at /credentials/stream
+// over TLS, requiring clients to present certificates signed by the server's
+// CA as they do when calling the gRPC API.
+type credentialStreamServer struct {
+	address   string
+	stream    *ServerSentEventsCredentialStream
+	tlsConfig *dynamicTLSConfig
+}
+
+// Run starts listening, the server is shutdown when ctx is cancelled. Requests
+// are handled with ctx's logger.
+func (s *credentialStreamServer) Run(ctx context.Context) error {
+	listener, err := tls.Listen("tcp", s.address, &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return s.tlsConfig.LoadCert(), nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := s.tlsConfig.Load()
+			return &tls.Config{
+				Certificates: []tls.Certificate{*cert},
+				ClientCAs:    pool,
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+			}, nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/credentials/stream", s.stream)
+	server := &http.Server{Handler: mux, BaseContext: func(net.Listener) context.Context { return ctx }}
+	logger := logging.FromContext(ctx)
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	go func() {
+		logger.Info("serving credential stream", "address", s.address)
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Error("error serving credential stream", "error", err)
+		}
+	}()
+
+	return nil
+}
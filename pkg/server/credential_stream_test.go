@@ -0,0 +1,205 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/testutil"
+	v1 "k8s.io/api/core/v1"
+)
+
+func (s *ServerSentEventsCredentialStream) subscriberCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, subscribers := range s.subscribers {
+		count += len(subscribers)
+	}
+	return count
+}
+
+func waitForSubscribers(t *testing.T, stream *ServerSentEventsCredentialStream, expected int) {
+	deadline := time.Now().Add(time.Second)
+	for stream.subscriberCount() != expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d subscribers, was %d", expected, stream.subscriberCount())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// readEvent returns the data of the next event, skipping comments
+func readEvent(t *testing.T, reader *bufio.Reader) string {
+	var data string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" && data != "" {
+			return data
+		}
+		if strings.HasPrefix(line, "data: ") {
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+// fakeRoleAuthorizer allows the pods with ips in allowed to assume their role
+type fakeRoleAuthorizer struct {
+	resolver sts.ARNResolver
+
+	mu      sync.Mutex
+	allowed map[string]string
+}
+
+func (a *fakeRoleAuthorizer) AuthorizeRole(ctx context.Context, ip, role string) (*v1.Pod, *sts.RoleIdentity, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	annotated, ok := a.allowed[ip]
+	if !ok {
+		return nil, nil, ErrPodNotFound
+	}
+	if annotated != role {
+		return nil, nil, &policyForbiddenError{reason: ReasonRoleMismatch}
+	}
+	identity, err := sts.NewRoleIdentity(a.resolver, role, "", "")
+	return testutil.NewPodWithRole("red", ip, ip, testutil.PhaseRunning, role), identity, err
+}
+
+func (a *fakeRoleAuthorizer) forbid(ip string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.allowed, ip)
+}
+
+func newTestCredentialStream(allowed map[string]string) (*ServerSentEventsCredentialStream, *fakeRoleAuthorizer) {
+	authorizer := &fakeRoleAuthorizer{resolver: sts.DefaultResolver("arn:aws:iam::123456789012:role/"), allowed: allowed}
+	stream := NewServerSentEventsCredentialStream()
+	stream.SetAuthorizer(authorizer)
+	return stream, authorizer
+}
+
+func subscribeToStream(t *testing.T, ctx context.Context, url string) *http.Response {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestCredentialStreamPushesCredentialsToSubscribers(t *testing.T) {
+	stream, authorizer := newTestCredentialStream(map[string]string{"192.168.0.1": "reader", "192.168.0.2": "reader"})
+	server := httptest.NewServer(stream)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var readers []*bufio.Reader
+	for _, ip := range []string{"192.168.0.1", "192.168.0.2"} {
+		resp := subscribeToStream(t, ctx, server.URL+"?ip="+ip+"&role=reader")
+		defer resp.Body.Close()
+		if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+			t.Error("unexpected content type", contentType)
+		}
+		readers = append(readers, bufio.NewReader(resp.Body))
+	}
+	waitForSubscribers(t, stream, 2)
+
+	reader, _ := sts.NewRoleIdentity(authorizer.resolver, "reader", "", "")
+	writer, _ := sts.NewRoleIdentity(authorizer.resolver, "writer", "", "")
+	stream.Publish(&sts.CachedCredentials{Identity: writer, Credentials: &sts.Credentials{AccessKeyId: "writer"}})
+	stream.Publish(&sts.CachedCredentials{Identity: reader, Credentials: &sts.Credentials{AccessKeyId: "reader"}})
+
+	for _, r := range readers {
+		var creds sts.Credentials
+		if err := json.Unmarshal([]byte(readEvent(t, r)), &creds); err != nil {
+			t.Fatal(err)
+		}
+		if creds.AccessKeyId != "reader" {
+			t.Error("expected credentials for subscribed role, was", creds.AccessKeyId)
+		}
+	}
+
+	cancel()
+	waitForSubscribers(t, stream, 0)
+}
+
+func TestCredentialStreamAuthorizesSubscriptions(t *testing.T) {
+	var tests = []struct {
+		name     string
+		query    string
+		expected int
+	}{
+		{"MissingRole", "?ip=192.168.0.1", http.StatusBadRequest},
+		{"MissingIP", "?role=reader", http.StatusBadRequest},
+		{"UnknownPod", "?ip=192.168.0.2&role=reader", http.StatusNotFound},
+		{"ForbiddenRole", "?ip=192.168.0.1&role=admin", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, _ := newTestCredentialStream(map[string]string{"192.168.0.1": "reader"})
+
+			rr := httptest.NewRecorder()
+			stream.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/credentials/stream"+tt.query, nil))
+			if rr.Code != tt.expected {
+				t.Errorf("expected %d, was %d", tt.expected, rr.Code)
+			}
+			if stream.subscriberCount() != 0 {
+				t.Error("expected no subscribers")
+			}
+		})
+	}
+}
+
+func TestCredentialStreamClosesWhenPodNoLongerAllowed(t *testing.T) {
+	stream, authorizer := newTestCredentialStream(map[string]string{"192.168.0.1": "reader"})
+	server := httptest.NewServer(stream)
+	defer server.Close()
+
+	resp := subscribeToStream(t, context.Background(), server.URL+"?ip=192.168.0.1&role=reader")
+	defer resp.Body.Close()
+	waitForSubscribers(t, stream, 1)
+
+	authorizer.forbid("192.168.0.1")
+	reader, _ := sts.NewRoleIdentity(authorizer.resolver, "reader", "", "")
+	stream.Publish(&sts.CachedCredentials{Identity: reader, Credentials: &sts.Credentials{AccessKeyId: "reader"}})
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "reader") {
+		t.Error("expected credentials not to be sent, was", string(body))
+	}
+	waitForSubscribers(t, stream, 0)
+}
+
+func TestCredentialStreamPublishDoesntBlock(t *testing.T) {
+	stream, authorizer := newTestCredentialStream(nil)
+	identity, _ := sts.NewRoleIdentity(authorizer.resolver, "reader", "", "")
+
+	credentials, unsubscribe := stream.subscribe(identity)
+	defer unsubscribe()
+
+	stream.Publish(&sts.CachedCredentials{Identity: identity, Credentials: &sts.Credentials{AccessKeyId: "first"}})
+	stream.Publish(&sts.CachedCredentials{Identity: identity, Credentials: &sts.Credentials{AccessKeyId: "second"}})
+
+	if creds := <-credentials; creds.AccessKeyId != "second" {
+		t.Error("expected latest credentials, was", creds.AccessKeyId)
+	}
+}
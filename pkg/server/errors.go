@@ -2,6 +2,10 @@ package server
 
 import (
 	"fmt"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -11,3 +15,50 @@ var (
 	// because of a policy
 	ErrPolicyForbidden = fmt.Errorf("forbidden by policy")
 )
+
+// errorDomain identifies kiam errors in gRPC error details
+const errorDomain = "kiam.uswitch.com"
+
+// policyForbiddenError is returned by the server when a policy forbids the
+// request. It wraps ErrPolicyForbidden, with the message clients expect, and
+// includes the denial reason in the gRPC status details.
+type policyForbiddenError struct {
+	reason DenialReason
+}
+
+func (e *policyForbiddenError) Error() string {
+	return ErrPolicyForbidden.Error()
+}
+
+func (e *policyForbiddenError) Unwrap() error {
+	return ErrPolicyForbidden
+}
+
+func (e *policyForbiddenError) GRPCStatus() *status.Status {
+	s := status.New(codes.PermissionDenied, ErrPolicyForbidden.Error())
+	if e.reason == ReasonNone {
+		return s
+	}
+
+	detailed, err := s.WithDetails(&errdetails.ErrorInfo{Reason: string(e.reason), Domain: errorDomain})
+	if err != nil {
+		return s
+	}
+	return detailed
+}
+
+// DenialReasonFromError returns the reason included in a gRPC error returned
+// when a policy forbids the request, or ReasonNone.
+func DenialReasonFromError(err error) DenialReason {
+	s, ok := status.FromError(err)
+	if !ok {
+		return ReasonNone
+	}
+
+	for _, detail := range s.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetDomain() == errorDomain {
+			return DenialReason(info.GetReason())
+		}
+	}
+	return ReasonNone
+}
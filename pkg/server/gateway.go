@@ -55,7 +55,7 @@ func (g *KiamGateway) GetRole(ctx context.Context, ip string) (string, error) {
 
 // GetCredentials returns the credentials for the identified Pod
 func (g *KiamGateway) GetCredentials(ctx context.Context, ip, role string) (*sts.Credentials, error) {
-	credentials, err := g.client.GetPodCredentials(ctx, &pb.GetPodCredentialsRequest{Ip: ip, Role: role})
+	credentials, err := g.client.GetPodCredentials(forwardServiceAccountToken(ctx), &pb.GetPodCredentialsRequest{Ip: ip, Role: role})
 	if err != nil {
 		if grpcStatus, ok := status.FromError(err); ok {
 			switch grpcStatus.Message() {
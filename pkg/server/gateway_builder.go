@@ -19,6 +19,10 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	retry "github.com/grpc-ecosystem/go-grpc-middleware/retry"
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
@@ -28,8 +32,6 @@ import (
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/security/advancedtls"
-	"net"
-	"time"
 )
 
 const (
@@ -46,10 +48,18 @@ type KiamGatewayBuilder struct {
 	dialOptions     []grpc.DialOption
 	retryInterval   time.Duration
 	maxRetries      uint
+	retryPolicy     *AgentRetryPolicy
+	logger          *slog.Logger
 }
 
 func NewKiamGatewayBuilder() *KiamGatewayBuilder {
-	return &KiamGatewayBuilder{retryInterval: kDefaultRetryInterval, maxRetries: kMaxRetries}
+	return &KiamGatewayBuilder{retryInterval: kDefaultRetryInterval, maxRetries: kMaxRetries, logger: slog.Default()}
+}
+
+// WithLogger sets the logger used by the gateway. Must be called before WithTLS.
+func (b *KiamGatewayBuilder) WithLogger(logger *slog.Logger) *KiamGatewayBuilder {
+	b.logger = logger
+	return b
 }
 
 func (b *KiamGatewayBuilder) WithAddress(address string) *KiamGatewayBuilder {
@@ -67,10 +77,17 @@ func (b *KiamGatewayBuilder) WithMaxRetries(n uint) *KiamGatewayBuilder {
 	return b
 }
 
+// WithRetryPolicy retries calls failing while the server is unavailable,
+// backing off exponentially according to policy.
+func (b *KiamGatewayBuilder) WithRetryPolicy(policy AgentRetryPolicy) *KiamGatewayBuilder {
+	b.retryPolicy = &policy
+	return b
+}
+
 // WithTLS configures the gRPC client with dynamic TLS.
 func (b *KiamGatewayBuilder) WithTLS(cert, key, ca string) (*KiamGatewayBuilder, error) {
-	notifyFn := clientTLSMetrics.notifyFunc(x509.ExtKeyUsageClientAuth)
-	tlsConfig, err := newDynamicTLSConfig(cert, key, ca, notifyFn)
+	notifyFn := clientTLSMetrics.notifyFunc(x509.ExtKeyUsageClientAuth, b.logger)
+	tlsConfig, err := newDynamicTLSConfig(cert, key, ca, notifyFn, b.logger)
 	if err != nil {
 		return nil, fmt.Errorf("error reading tls certificates: %v", err)
 	}
@@ -134,9 +151,13 @@ func (b *KiamGatewayBuilder) Build(ctx context.Context) (*KiamGateway, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error dialing grpc server: %v", err)
 	}
+	client := pb.NewKiamServiceClient(conn)
+	if b.retryPolicy != nil {
+		client = NewRetryingAgentClient(client, *b.retryPolicy)
+	}
 	gw := &KiamGateway{
 		conn:      conn,
-		client:    pb.NewKiamServiceClient(conn),
+		client:    client,
 		tlsConfig: b.tlsConfig,
 	}
 	return gw, nil
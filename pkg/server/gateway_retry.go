@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	pb "github.com/uswitch/kiam/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AgentRetryPolicy controls how RetryingAgentClient retries calls failing
+// with transient errors, such as while the server is restarting
+type AgentRetryPolicy struct {
+	// RetryInterval is the initial interval between retries, which back off exponentially
+	RetryInterval time.Duration
+	// MaxRetryDuration bounds the total time spent retrying
+	MaxRetryDuration time.Duration
+}
+
+// RetryingAgentClient wraps the gRPC client, retrying calls that fail because
+// the server is unavailable. Other errors, such as permission denied or
+// invalid argument, are returned immediately.
+type RetryingAgentClient struct {
+	client pb.KiamServiceClient
+	policy AgentRetryPolicy
+}
+
+// NewRetryingAgentClient creates the client retrying client's calls
+// according to policy
+func NewRetryingAgentClient(client pb.KiamServiceClient, policy AgentRetryPolicy) *RetryingAgentClient {
+	return &RetryingAgentClient{client: client, policy: policy}
+}
+
+func (c *RetryingAgentClient) GetPodRole(ctx context.Context, in *pb.GetPodRoleRequest, opts ...grpc.CallOption) (role *pb.Role, err error) {
+	err = c.retry(ctx, func() error {
+		role, err = c.client.GetPodRole(ctx, in, opts...)
+		return err
+	})
+	return role, err
+}
+
+func (c *RetryingAgentClient) GetPodCredentials(ctx context.Context, in *pb.GetPodCredentialsRequest, opts ...grpc.CallOption) (credentials *pb.Credentials, err error) {
+	err = c.retry(ctx, func() error {
+		credentials, err = c.client.GetPodCredentials(ctx, in, opts...)
+		return err
+	})
+	return credentials, err
+}
+
+func (c *RetryingAgentClient) GetHealth(ctx context.Context, in *pb.GetHealthRequest, opts ...grpc.CallOption) (health *pb.HealthStatus, err error) {
+	err = c.retry(ctx, func() error {
+		health, err = c.client.GetHealth(ctx, in, opts...)
+		return err
+	})
+	return health, err
+}
+
+func (c *RetryingAgentClient) retry(ctx context.Context, call func() error) error {
+	op := func() error {
+		err := call()
+		if err != nil && !isRetryable(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}
+
+	strategy := backoff.NewExponentialBackOff()
+	strategy.InitialInterval = c.policy.RetryInterval
+	strategy.MaxElapsedTime = c.policy.MaxRetryDuration
+
+	return backoff.Retry(op, backoff.WithContext(strategy, ctx))
+}
+
+// isRetryable returns whether err is transient, i.e. the server couldn't be
+// reached or couldn't handle the request at the time
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
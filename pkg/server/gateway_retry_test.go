@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/uswitch/kiam/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// stubKiamServiceClient fails calls with errs, in order, before succeeding
+type stubKiamServiceClient struct {
+	pb.KiamServiceClient
+	errs  []error
+	calls int
+}
+
+func (c *stubKiamServiceClient) GetPodCredentials(ctx context.Context, in *pb.GetPodCredentialsRequest, opts ...grpc.CallOption) (*pb.Credentials, error) {
+	c.calls++
+	if len(c.errs) > 0 {
+		err := c.errs[0]
+		c.errs = c.errs[1:]
+		return nil, err
+	}
+	return &pb.Credentials{AccessKeyId: "A1234"}, nil
+}
+
+func TestRetryingAgentClient(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	denied := status.Error(codes.PermissionDenied, "forbidden by policy")
+	invalid := status.Error(codes.InvalidArgument, "invalid role")
+
+	var tests = []struct {
+		name          string
+		errs          []error
+		expectedCalls int
+		expectedCode  codes.Code
+	}{
+		{"Success", nil, 1, codes.OK},
+		{"RetriesUnavailable", []error{unavailable, unavailable}, 3, codes.OK},
+		{"PermissionDenied", []error{denied}, 1, codes.PermissionDenied},
+		{"InvalidArgument", []error{invalid}, 1, codes.InvalidArgument},
+		{"RetriesUntilPermanentError", []error{unavailable, denied}, 2, codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubKiamServiceClient{errs: tt.errs}
+			client := NewRetryingAgentClient(stub, AgentRetryPolicy{RetryInterval: time.Millisecond, MaxRetryDuration: time.Second})
+
+			credentials, err := client.GetPodCredentials(context.Background(), &pb.GetPodCredentialsRequest{Ip: "192.168.0.1", Role: "reader"})
+			if status.Code(err) != tt.expectedCode {
+				t.Errorf("expected code %s, was: %v", tt.expectedCode, err)
+			}
+			if err == nil && credentials.AccessKeyId != "A1234" {
+				t.Error("unexpected credentials", credentials)
+			}
+			if stub.calls != tt.expectedCalls {
+				t.Errorf("expected %d calls, was %d", tt.expectedCalls, stub.calls)
+			}
+		})
+	}
+}
+
+func TestRetryingAgentClientStopsAfterMaxRetryDuration(t *testing.T) {
+	errs := make([]error, 1000)
+	for i := range errs {
+		errs[i] = status.Error(codes.Unavailable, "connection refused")
+	}
+	stub := &stubKiamServiceClient{errs: errs}
+	client := NewRetryingAgentClient(stub, AgentRetryPolicy{RetryInterval: time.Millisecond, MaxRetryDuration: 20 * time.Millisecond})
+
+	_, err := client.GetPodCredentials(context.Background(), &pb.GetPodCredentialsRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Error("expected unavailable error, was", err)
+	}
+	if stub.calls < 2 || stub.calls == len(errs) {
+		t.Error("expected retries to stop after max retry duration, calls were", stub.calls)
+	}
+}
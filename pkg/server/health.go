@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/uswitch/kiam/pkg/logging"
+)
+
+// HealthStatus describes the health of a component
+type HealthStatus struct {
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
+}
+
+// HealthChecker is implemented by policies that depend on external state, such
+// as a webhook or a watched ConfigMap. An error is returned when the health
+// couldn't be checked.
+type HealthChecker interface {
+	HealthCheck() (HealthStatus, error)
+}
+
+// PolicyHealth is the health of a policy in a CompositeAssumeRolePolicy
+type PolicyHealth struct {
+	Policy string `json:"policy"`
+	HealthStatus
+}
+
+// HealthReport is the aggregate health of the policies in a
+// CompositeAssumeRolePolicy
+type HealthReport struct {
+	Healthy  bool           `json:"healthy"`
+	Policies []PolicyHealth `json:"policies"`
+}
+
+// HealthReport checks the health of each policy implementing HealthChecker.
+// The report is healthy when all policies are healthy.
+func (p *CompositeAssumeRolePolicy) HealthReport() *HealthReport {
+	report := &HealthReport{Healthy: true, Policies: []PolicyHealth{}}
+
+	for _, policy := range p.snapshot() {
+		name := policyName(policy)
+		for {
+			wrapped, ok := policy.(wrappedPolicy)
+			if !ok {
+				break
+			}
+			policy = wrapped.unwrap()
+		}
+
+		checker, ok := policy.(HealthChecker)
+		if !ok {
+			continue
+		}
+
+		status, err := checker.HealthCheck()
+		if err != nil {
+			status = HealthStatus{Healthy: false, Message: fmt.Sprintf("error checking health: %s", err.Error())}
+		}
+
+		report.Healthy = report.Healthy && status.Healthy
+		report.Policies = append(report.Policies, PolicyHealth{Policy: name, HealthStatus: status})
+	}
+
+	return report
+}
+
+// HealthCheck returns the aggregate health of the policies
+func (p *CompositeAssumeRolePolicy) HealthCheck() (HealthStatus, error) {
+	report := p.HealthReport()
+
+	var unhealthy []string
+	for _, policy := range report.Policies {
+		if !policy.Healthy {
+			unhealthy = append(unhealthy, fmt.Sprintf("%s: %s", policy.Policy, policy.Message))
+		}
+	}
+
+	if len(unhealthy) > 0 {
+		return HealthStatus{Healthy: false, Message: strings.Join(unhealthy, "; ")}, nil
+	}
+	return HealthStatus{Healthy: true, Message: "ok"}, nil
+}
+
+// healthHandler serves the policies' health report as JSON. Responds with 503
+// when any policy is unhealthy.
+type healthHandler struct {
+	policy *CompositeAssumeRolePolicy
+}
+
+func (h *healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := h.policy.HealthReport()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logging.FromContext(r.Context()).Error("error writing health report", "error", err)
+	}
+}
+
+// healthServer serves handlers inspecting the policies, such as the health
+// report at /healthz, at their paths
+type healthServer struct {
+	address  string
+	handlers map[string]http.Handler
+}
+
+// Run starts listening, the server is shutdown when ctx is cancelled. Requests
+// are handled with ctx's logger.
+func (s *healthServer) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	for path, handler := range s.handlers {
+		mux.Handle(path, handler)
+	}
+	server := &http.Server{Handler: mux, BaseContext: func(net.Listener) context.Context { return ctx }}
+	logger := logging.FromContext(ctx)
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	go func() {
+		logger.Info("serving policy health", "address", s.address)
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Error("error serving policy health", "error", err)
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeHealthChecker struct {
+	fakePolicy
+	status HealthStatus
+	err    error
+}
+
+func (c *fakeHealthChecker) HealthCheck() (HealthStatus, error) {
+	return c.status, c.err
+}
+
+func TestCompositeHealthReport(t *testing.T) {
+	var tests = []struct {
+		name     string
+		policies []AssumeRolePolicy
+		expected bool
+		checked  int
+	}{
+		{"NoCheckers", []AssumeRolePolicy{&fakePolicy{}}, true, 0},
+		{"Healthy", []AssumeRolePolicy{&fakePolicy{}, &fakeHealthChecker{status: HealthStatus{Healthy: true}}}, true, 1},
+		{"Unhealthy", []AssumeRolePolicy{&fakeHealthChecker{status: HealthStatus{Healthy: true}}, &fakeHealthChecker{status: HealthStatus{Healthy: false}}}, false, 2},
+		{"Error", []AssumeRolePolicy{&fakeHealthChecker{status: HealthStatus{Healthy: true}, err: fmt.Errorf("failed")}}, false, 1},
+		{"Named", []AssumeRolePolicy{NamedPolicy("checker", &fakeHealthChecker{status: HealthStatus{Healthy: false}})}, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Policies(tt.policies...).HealthReport()
+			if report.Healthy != tt.expected {
+				t.Errorf("expected healthy to be %t: %+v", tt.expected, report)
+			}
+			if len(report.Policies) != tt.checked {
+				t.Errorf("expected %d policies checked, was %d", tt.checked, len(report.Policies))
+			}
+		})
+	}
+}
+
+func TestHealthReportNamesPolicies(t *testing.T) {
+	report := Policies(NamedPolicy("checker", &fakeHealthChecker{}), &fakeHealthChecker{}).HealthReport()
+	if report.Policies[0].Policy != "checker" {
+		t.Error("expected named policy, was", report.Policies[0].Policy)
+	}
+	if report.Policies[1].Policy != "fakeHealthChecker" {
+		t.Error("expected policy type, was", report.Policies[1].Policy)
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	var tests = []struct {
+		name     string
+		healthy  bool
+		expected int
+	}{
+		{"Healthy", true, http.StatusOK},
+		{"Unhealthy", false, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := Policies(&fakeHealthChecker{status: HealthStatus{Healthy: tt.healthy, Message: "message"}})
+			rr := httptest.NewRecorder()
+			(&healthHandler{policy: policy}).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+			if rr.Code != tt.expected {
+				t.Errorf("expected status %d, was %d", tt.expected, rr.Code)
+			}
+
+			var report HealthReport
+			if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Healthy != tt.healthy || report.Policies[0].Message != "message" {
+				t.Errorf("unexpected report: %+v", report)
+			}
+		})
+	}
+}
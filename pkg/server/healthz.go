@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/logging"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultHealthCheckTimeout limits how long each subsystem health check takes
+const DefaultHealthCheckTimeout = 5 * time.Second
+
+// HealthCheck checks the health of a subsystem the server depends on, such as
+// STS or the Kubernetes API. Check returns an error when it's unhealthy.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// HealthzHandler serves the result of each check as a JSON object of check
+// name to "ok" or the check's error. Responds with 503 when any check fails.
+// Checks are run concurrently, each limited to DefaultHealthCheckTimeout.
+func HealthzHandler(checks ...HealthCheck) http.Handler {
+	return &healthzHandler{checks: checks, timeout: DefaultHealthCheckTimeout}
+}
+
+type healthzHandler struct {
+	checks  []HealthCheck
+	timeout time.Duration
+}
+
+func (h *healthzHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	results := make(map[string]string, len(h.checks))
+	healthy := true
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func(check HealthCheck) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+			defer cancel()
+
+			result := "ok"
+			if err := check.Check(ctx); err != nil {
+				result = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[check.Name] = result
+			healthy = healthy && result == "ok"
+		}(check)
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		logging.FromContext(r.Context()).Error("error writing health checks", "error", err)
+	}
+}
+
+// STSHealthCheck checks the gateway can reach STS
+func STSHealthCheck(gateway sts.ConnectivityChecker) HealthCheck {
+	return HealthCheck{Name: "sts", Check: gateway.CheckConnectivity}
+}
+
+// KubernetesHealthCheck checks the api server's /healthz endpoint
+func KubernetesHealthCheck(client kubernetes.Interface) HealthCheck {
+	return HealthCheck{
+		Name: "kubernetes",
+		Check: func(ctx context.Context) error {
+			return client.CoreV1().RESTClient().Get().AbsPath("/healthz").Context(ctx).Do().Error()
+		},
+	}
+}
+
+// PolicyHealthChecks returns a check, named policy:<name>, for each policy in
+// the composite implementing HealthChecker when called
+func PolicyHealthChecks(policy *CompositeAssumeRolePolicy) []HealthCheck {
+	var checks []HealthCheck
+	for _, p := range policy.snapshot() {
+		name := policyName(p)
+		for {
+			wrapped, ok := p.(wrappedPolicy)
+			if !ok {
+				break
+			}
+			p = wrapped.unwrap()
+		}
+
+		checker, ok := p.(HealthChecker)
+		if !ok {
+			continue
+		}
+		checks = append(checks, HealthCheck{
+			Name: "policy:" + name,
+			Check: func(ctx context.Context) error {
+				status, err := checker.HealthCheck()
+				if err != nil {
+					return err
+				}
+				if !status.Healthy && status.Message != "" {
+					return errors.New(status.Message)
+				}
+				if !status.Healthy {
+					return errors.New("unhealthy")
+				}
+				return nil
+			},
+		})
+	}
+	return checks
+}
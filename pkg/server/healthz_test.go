@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func healthy(ctx context.Context) error { return nil }
+
+func TestHealthzHandler(t *testing.T) {
+	var tests = []struct {
+		name     string
+		checks   []HealthCheck
+		expected int
+		results  map[string]string
+	}{
+		{"NoChecks", nil, http.StatusOK, map[string]string{}},
+		{"Healthy", []HealthCheck{{Name: "sts", Check: healthy}, {Name: "kubernetes", Check: healthy}}, http.StatusOK, map[string]string{"sts": "ok", "kubernetes": "ok"}},
+		{"Unhealthy", []HealthCheck{
+			{Name: "sts", Check: func(ctx context.Context) error { return fmt.Errorf("unreachable") }},
+			{Name: "kubernetes", Check: healthy},
+		}, http.StatusServiceUnavailable, map[string]string{"sts": "unreachable", "kubernetes": "ok"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			HealthzHandler(tt.checks...).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz/subsystems", nil))
+
+			if rr.Code != tt.expected {
+				t.Errorf("expected status %d, was %d", tt.expected, rr.Code)
+			}
+
+			var results map[string]string
+			if err := json.NewDecoder(rr.Body).Decode(&results); err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != len(tt.results) {
+				t.Errorf("expected %d results, was %v", len(tt.results), results)
+			}
+			for name, result := range tt.results {
+				if results[name] != result {
+					t.Errorf("expected %s to be %q, was %q", name, result, results[name])
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyHealthChecks(t *testing.T) {
+	policy := Policies(
+		&fakePolicy{},
+		NamedPolicy("healthy", &fakeHealthChecker{status: HealthStatus{Healthy: true}}),
+		NamedPolicy("unhealthy", &fakeHealthChecker{status: HealthStatus{Healthy: false, Message: "not loaded"}}),
+	)
+
+	checks := PolicyHealthChecks(policy)
+	if len(checks) != 2 {
+		t.Fatalf("expected checks for health checkers, was %d", len(checks))
+	}
+	if checks[0].Name != "policy:healthy" || checks[0].Check(context.Background()) != nil {
+		t.Error("expected healthy policy check")
+	}
+	if err := checks[1].Check(context.Background()); checks[1].Name != "policy:unhealthy" || err == nil || err.Error() != "not loaded" {
+		t.Error("expected unhealthy policy check, was", err)
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	"google.golang.org/grpc"
+	v1 "k8s.io/api/core/v1"
+)
+
+type requestPodKey struct{}
+
+// WithPod returns a context carrying the pod making the request
+func WithPod(ctx context.Context, pod *v1.Pod) context.Context {
+	return context.WithValue(ctx, requestPodKey{}, pod)
+}
+
+// PodFromContext returns the pod carried by ctx, if any
+func PodFromContext(ctx context.Context) (*v1.Pod, bool) {
+	pod, ok := ctx.Value(requestPodKey{}).(*v1.Pod)
+	return pod, ok && pod != nil
+}
+
+// podRequest is implemented by requests identifying the pod by its IP
+type podRequest interface {
+	GetIp() string
+}
+
+// PodUnaryServerInterceptor finds the pod identified by the request's IP
+// address and adds it to the context, so that handlers and policies don't
+// need to find it again. Requests are handled without a pod in the context
+// if it can't be found.
+func PodUnaryServerInterceptor(pods k8s.PodGetter, logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		r, ok := req.(podRequest)
+		if !ok || r.GetIp() == "" {
+			return handler(ctx, req)
+		}
+
+		pod, err := pods.GetPodByIP(r.GetIp())
+		if err != nil {
+			logger.Debug("not adding pod to context", "pod.ip", r.GetIp(), "error", err)
+			return handler(ctx, req)
+		}
+
+		return handler(WithPod(ctx, pod), req)
+	}
+}
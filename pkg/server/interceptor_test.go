@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	kt "github.com/uswitch/kiam/pkg/k8s/testing"
+	"github.com/uswitch/kiam/pkg/logging"
+	"github.com/uswitch/kiam/pkg/testutil"
+	pb "github.com/uswitch/kiam/proto"
+	"google.golang.org/grpc"
+)
+
+func TestPodInterceptorAddsPodToContext(t *testing.T) {
+	pod := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+	var tests = []struct {
+		name     string
+		pods     *kt.StubFinder
+		req      interface{}
+		expected bool
+	}{
+		{"CredentialsRequest", kt.NewStubFinder(pod), &pb.GetPodCredentialsRequest{Ip: "192.168.0.1", Role: "red_role"}, true},
+		{"RoleRequest", kt.NewStubFinder(pod), &pb.GetPodRoleRequest{Ip: "192.168.0.1"}, true},
+		{"UnknownPod", kt.NewStubFinder(nil), &pb.GetPodRoleRequest{Ip: "192.168.0.2"}, false},
+		{"NoIP", kt.NewStubFinder(pod), &pb.GetPodRoleRequest{}, false},
+		{"HealthRequest", kt.NewStubFinder(pod), &pb.GetHealthRequest{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				found, ok := PodFromContext(ctx)
+				if ok != tt.expected {
+					t.Errorf("expected pod in context to be %t", tt.expected)
+				}
+				if ok && found != pod {
+					t.Error("unexpected pod in context", found)
+				}
+				return nil, nil
+			}
+
+			interceptor := PodUnaryServerInterceptor(tt.pods, logging.Discard())
+			if _, err := interceptor(context.Background(), tt.req, &grpc.UnaryServerInfo{}, handler); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
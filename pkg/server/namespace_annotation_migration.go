@@ -0,0 +1,85 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+)
+
+// NamespaceAnnotationMigration rewrites the regexps in namespaces' permitted
+// annotations that match role names, rather than ARNs, to match the full ARN
+// the name resolves to. NamespacePermittedRoleNamePolicy matches regexps
+// against ARNs, so name regexps such as reader only match when not strict and
+// then match any ARN containing the name.
+type NamespaceAnnotationMigration struct {
+	resolver  sts.ARNResolver
+	delimiter rune
+}
+
+// NewNamespaceAnnotationMigration creates the migration resolving names with
+// resolver, splitting annotations at delimiter like
+// NamespacePermittedRoleNamePolicy.
+func NewNamespaceAnnotationMigration(resolver sts.ARNResolver, delimiter rune) *NamespaceAnnotationMigration {
+	return &NamespaceAnnotationMigration{resolver: resolver, delimiter: delimiter}
+}
+
+// Migrate returns the annotation with each name regexp (containing no :)
+// rewritten to match ARNs. The prefix added by the resolver is quoted so only
+// the name is a regexp, e.g. team-.* becomes
+// arn:aws:iam::123456789012:role/team-.* when resolved with a DefaultResolver.
+// Leading ^ anchors are kept. Regexps that already match ARNs are unchanged,
+// and an unchanged annotation is returned when no regexps are rewritten.
+func (m *NamespaceAnnotationMigration) Migrate(annotation string) (string, error) {
+	parts, err := splitExpressions(annotation, m.delimiter)
+	if err != nil {
+		return "", err
+	}
+
+	changed := false
+	for i, part := range parts {
+		if strings.Contains(part, ":") {
+			continue
+		}
+
+		migrated, err := m.migrateExpression(part)
+		if err != nil {
+			return "", fmt.Errorf("error migrating '%s': %v", part, err)
+		}
+		parts[i] = migrated
+		changed = true
+	}
+
+	if !changed {
+		return annotation, nil
+	}
+	return strings.Join(parts, string(m.delimiter)), nil
+}
+
+func (m *NamespaceAnnotationMigration) migrateExpression(expression string) (string, error) {
+	anchor := ""
+	if strings.HasPrefix(expression, "^") {
+		anchor = "^"
+		expression = strings.TrimPrefix(expression, "^")
+	}
+	name := strings.TrimPrefix(expression, "/")
+
+	resolved, err := m.resolver.Resolve(name)
+	if err != nil {
+		return "", err
+	}
+
+	// resolvers add a prefix to names, which must be quoted
+	if strings.HasSuffix(resolved.ARN.String(), name) {
+		prefix := strings.TrimSuffix(resolved.ARN.String(), name)
+		return anchor + regexp.QuoteMeta(prefix) + name, nil
+	}
+
+	// other resolvers, such as SSMARNResolver, look up names so only
+	// literal names can be migrated
+	if regexp.QuoteMeta(name) != name {
+		return "", fmt.Errorf("resolved to %s, which doesn't end with the regexp", resolved.ARN)
+	}
+	return anchor + regexp.QuoteMeta(resolved.ARN.String()), nil
+}
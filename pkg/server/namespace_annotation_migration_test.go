@@ -0,0 +1,83 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+)
+
+func TestNamespaceAnnotationMigration(t *testing.T) {
+	var tests = []struct {
+		name       string
+		annotation string
+		expected   string
+	}{
+		{"Name", "reader", "arn:aws:iam::123456789012:role/reader"},
+		{"Regexp", "team-.*", "arn:aws:iam::123456789012:role/team-.*"},
+		{"Anchored", "^reader$", "^arn:aws:iam::123456789012:role/reader$"},
+		{"LeadingSlash", "/team/reader", "arn:aws:iam::123456789012:role/team/reader"},
+		{"ARN", "arn:aws:iam::123456789012:role/reader", "arn:aws:iam::123456789012:role/reader"},
+		{"Unchanged", "^arn:aws:iam::.*:role/reader$ | arn:aws:iam::123456789012:role/writer", "^arn:aws:iam::.*:role/reader$ | arn:aws:iam::123456789012:role/writer"},
+		{"Mixed", "reader|arn:aws:iam::123456789012:role/writer|(a|b)-.*", "arn:aws:iam::123456789012:role/reader|arn:aws:iam::123456789012:role/writer|arn:aws:iam::123456789012:role/(a|b)-.*"},
+	}
+
+	migration := NewNamespaceAnnotationMigration(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), DefaultExpressionDelimiter)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrated, err := migration.Migrate(tt.annotation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if migrated != tt.expected {
+				t.Errorf("expected '%s', was '%s'", tt.expected, migrated)
+			}
+		})
+	}
+}
+
+func TestNamespaceAnnotationMigrationQuotesPrefix(t *testing.T) {
+	migration := NewNamespaceAnnotationMigration(sts.DefaultResolver("arn:aws:iam::123456789012:role/k8s.prod/"), ',')
+
+	migrated, err := migration.Migrate("reader,writer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != `arn:aws:iam::123456789012:role/k8s\.prod/reader,arn:aws:iam::123456789012:role/k8s\.prod/writer` {
+		t.Error("unexpected annotation", migrated)
+	}
+}
+
+// lookupResolver resolves names to unrelated ARNs, like SSMARNResolver
+type lookupResolver map[string]string
+
+func (r lookupResolver) Resolve(role string) (*sts.ResolvedRole, error) {
+	arn, ok := r[role]
+	if !ok {
+		return nil, fmt.Errorf("role %s not found", role)
+	}
+	return &sts.ResolvedRole{Name: role, ARN: sts.MustParseARN(arn)}, nil
+}
+
+func TestNamespaceAnnotationMigrationLookupResolver(t *testing.T) {
+	migration := NewNamespaceAnnotationMigration(lookupResolver{
+		"reader": "arn:aws:iam::123456789012:role/team.a/reader",
+		"read.*": "arn:aws:iam::123456789012:role/team.a/reader",
+	}, DefaultExpressionDelimiter)
+
+	migrated, err := migration.Migrate("reader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != `arn:aws:iam::123456789012:role/team\.a/reader` {
+		t.Error("unexpected annotation", migrated)
+	}
+
+	if _, err := migration.Migrate("read.*"); err == nil || !strings.Contains(err.Error(), "doesn't end with the regexp") {
+		t.Error("expected regexp not to be migrated, was", err)
+	}
+	if _, err := migration.Migrate("writer"); err == nil || !strings.Contains(err.Error(), "role writer not found") {
+		t.Error("expected resolver error, was", err)
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	"github.com/uswitch/kiam/pkg/logging"
+	v1 "k8s.io/api/core/v1"
+)
+
+// PodAnnotationWatchPolicy revokes cached credentials as soon as the pods
+// they were issued for change their IAM annotations or are deleted, rather
+// than waiting for them to expire. Credentials still used by other pods are
+// refreshed by the credential manager once revoked.
+type PodAnnotationWatchPolicy struct {
+	pods     k8s.PodWatcher
+	revoker  sts.CredentialsRevoker
+	resolver sts.ARNResolver
+	options  k8s.RoleIdentityOptions
+	logger   *slog.Logger
+}
+
+// NewPodAnnotationWatchPolicy creates the watcher revoking credentials from
+// revoker.
+func NewPodAnnotationWatchPolicy(pods k8s.PodWatcher, revoker sts.CredentialsRevoker, resolver sts.ARNResolver) *PodAnnotationWatchPolicy {
+	return &PodAnnotationWatchPolicy{pods: pods, revoker: revoker, resolver: resolver, logger: slog.Default()}
+}
+
+// SetRoleIdentityOptions controls how the identities revoked are built. Must
+// be called before Run.
+func (p *PodAnnotationWatchPolicy) SetRoleIdentityOptions(options k8s.RoleIdentityOptions) {
+	p.options = options
+}
+
+// Run starts watching pods until ctx is cancelled. Revocations are logged with
+// the logger carried by ctx.
+func (p *PodAnnotationWatchPolicy) Run(ctx context.Context) error {
+	p.logger = logging.FromContext(ctx)
+
+	events, err := p.pods.Watch(ctx)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for event := range events {
+			p.handle(event)
+		}
+	}()
+
+	return nil
+}
+
+func (p *PodAnnotationWatchPolicy) handle(event k8s.PodEvent) {
+	switch event.Type {
+	case k8s.RoleAnnotationChanged:
+		old, current := p.identity(event.Old), p.identity(event.Pod)
+		if old != nil && (current == nil || old.String() != current.String()) {
+			p.revoke(event.Pod, old, "role annotation changed")
+		}
+	case k8s.PodDeleted:
+		if identity := p.identity(event.Pod); identity != nil {
+			p.revoke(event.Pod, identity, "pod deleted")
+		}
+	}
+}
+
+// identity returns the identity the pod's credentials are issued for, or nil
+// when the pod has no valid role annotation
+func (p *PodAnnotationWatchPolicy) identity(pod *v1.Pod) *sts.RoleIdentity {
+	if !k8s.HasValidIAMAnnotation(pod) {
+		return nil
+	}
+
+	identity, err := k8s.PodRoleIdentity(p.resolver, k8s.PodRole(pod), pod, p.options)
+	if err != nil {
+		p.logger.With(k8s.PodAttrs(pod)...).Warn("error resolving role identity", "error", err)
+		return nil
+	}
+	return identity
+}
+
+func (p *PodAnnotationWatchPolicy) revoke(pod *v1.Pod, identity *sts.RoleIdentity, reason string) {
+	if p.revoker.Revoke(identity) {
+		p.logger.With(k8s.PodAttrs(pod)...).Info("revoked cached credentials", "credentials.role", identity.Role.ARN, "revocation.reason", reason)
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	"github.com/uswitch/kiam/pkg/logging"
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+type fakePodWatcher struct {
+	events chan k8s.PodEvent
+}
+
+func (w *fakePodWatcher) Watch(ctx context.Context) (<-chan k8s.PodEvent, error) {
+	return w.events, nil
+}
+
+type fakeRevoker struct {
+	mu      sync.Mutex
+	revoked []string
+}
+
+func (r *fakeRevoker) Revoke(identity *sts.RoleIdentity) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revoked = append(r.revoked, identity.Role.ARN.String())
+	return true
+}
+
+func (r *fakeRevoker) snapshot() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.revoked...)
+}
+
+func TestPodAnnotationWatchPolicyRevokesCredentials(t *testing.T) {
+	foo := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "foo_role")
+	bar := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "bar_role")
+	unannotated := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "")
+
+	var tests = []struct {
+		name     string
+		event    k8s.PodEvent
+		expected []string
+	}{
+		{"RoleChanged", k8s.PodEvent{Type: k8s.RoleAnnotationChanged, Old: foo, Pod: bar}, []string{"arn:aws:iam::123456789012:role/foo_role"}},
+		{"RoleRemoved", k8s.PodEvent{Type: k8s.RoleAnnotationChanged, Old: foo, Pod: unannotated}, []string{"arn:aws:iam::123456789012:role/foo_role"}},
+		{"RoleAdded", k8s.PodEvent{Type: k8s.RoleAnnotationChanged, Old: unannotated, Pod: foo}, []string{}},
+		{"Deleted", k8s.PodEvent{Type: k8s.PodDeleted, Pod: foo}, []string{"arn:aws:iam::123456789012:role/foo_role"}},
+		{"Modified", k8s.PodEvent{Type: k8s.PodModified, Old: foo, Pod: foo}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revoker := &fakeRevoker{}
+			watcher := NewPodAnnotationWatchPolicy(&fakePodWatcher{}, revoker, sts.DefaultResolver("arn:aws:iam::123456789012:role/"))
+			watcher.logger = logging.Discard()
+
+			watcher.handle(tt.event)
+
+			revoked := revoker.snapshot()
+			if len(revoked) != len(tt.expected) {
+				t.Fatalf("expected %v revoked, was %v", tt.expected, revoked)
+			}
+			for i := range revoked {
+				if revoked[i] != tt.expected[i] {
+					t.Errorf("expected %v revoked, was %v", tt.expected, revoked)
+				}
+			}
+		})
+	}
+}
+
+func TestPodAnnotationWatchPolicyHandlesWatchedEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan k8s.PodEvent, 1)
+	revoker := &fakeRevoker{}
+	watcher := NewPodAnnotationWatchPolicy(&fakePodWatcher{events: events}, revoker, sts.DefaultResolver("arn:aws:iam::123456789012:role/"))
+	if err := watcher.Run(logging.WithLogger(ctx, logging.Discard())); err != nil {
+		t.Fatal(err)
+	}
+
+	events <- k8s.PodEvent{Type: k8s.PodDeleted, Pod: testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "foo_role")}
+	close(events)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(revoker.snapshot()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for revocation")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
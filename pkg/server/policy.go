@@ -16,9 +16,16 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
+	"strings"
+	"sync"
 
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	v1 "k8s.io/api/core/v1"
 
 	"github.com/uswitch/kiam/pkg/aws/sts"
@@ -31,35 +38,332 @@ type AssumeRolePolicy interface {
 	IsAllowedAssumeRole(ctx context.Context, roleName string, pod *v1.Pod) (Decision, error)
 }
 
-// CompositeAssumeRolePolicy allows multiple policies to be checked
+var (
+	// ErrRoleMismatch is wrapped by decisions forbidding a pod from requesting a role
+	// other than the one it's annotated with.
+	ErrRoleMismatch = errors.New("requested role doesn't match annotated role")
+	// ErrNamespacePolicyForbidden is wrapped by decisions forbidding a role not
+	// permitted by the pod's namespace.
+	ErrNamespacePolicyForbidden = errors.New("role forbidden by namespace policy")
+)
+
+// Named is implemented by policies that carry an identifier, allowing them
+// to be removed from a CompositeAssumeRolePolicy at runtime.
+type Named interface {
+	Name() string
+}
+
+type namedPolicy struct {
+	AssumeRolePolicy
+	name string
+}
+
+func (p *namedPolicy) Name() string {
+	return p.name
+}
+
+func (p *namedPolicy) unwrap() AssumeRolePolicy {
+	return p.AssumeRolePolicy
+}
+
+// NamedPolicy identifies the policy with name.
+func NamedPolicy(name string, p AssumeRolePolicy) AssumeRolePolicy {
+	return &namedPolicy{AssumeRolePolicy: p, name: name}
+}
+
+// CompositeAssumeRolePolicy allows multiple policies to be checked. Policies
+// can be added and removed while the composite is in use. Created with
+// Policies all policies must allow the request, with AnyOf only one.
+// ConcurrentPolicies checks all policies in parallel.
 type CompositeAssumeRolePolicy struct {
+	tracing
+
+	mode compositeMode
+
+	mu       sync.RWMutex
 	policies []AssumeRolePolicy
 }
 
-func (p *CompositeAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
-	for _, policy := range p.policies {
-		decision, err := policy.IsAllowedAssumeRole(ctx, role, pod)
+type compositeMode int
+
+const (
+	allOf compositeMode = iota
+	anyOf
+	allOfConcurrent
+)
+
+func (m compositeMode) String() string {
+	switch m {
+	case anyOf:
+		return "anyOf"
+	case allOfConcurrent:
+		return "allOfConcurrent"
+	}
+	return "allOf"
+}
+
+func (p *CompositeAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (decision Decision, err error) {
+	ctx, span := p.startSpan(ctx, "CompositeAssumeRolePolicy.IsAllowedAssumeRole", role, pod)
+	defer func() { endSpan(span, decision, err) }()
+
+	switch p.mode {
+	case anyOf:
+		return p.isAnyAllowed(ctx, role, pod)
+	case allOfConcurrent:
+		return p.isAllAllowedConcurrently(ctx, role, pod)
+	}
+
+	policies := p.snapshot()
+	deciders := make([]string, 0, len(policies))
+	for _, policy := range policies {
+		decision, err := checkTraced(ctx, policy, role, pod)
 		if err != nil {
 			return nil, err
 		}
 		if !decision.IsAllowed() {
-			return decision, nil
+			return &chainForbidden{decision: decision, policy: decidedBy(policy, decision)}, nil
+		}
+		deciders = append(deciders, decidedBy(policy, decision))
+	}
+
+	return &allowed{policy: strings.Join(deciders, ", ")}, nil
+}
+
+type policyResult struct {
+	index    int
+	decision Decision
+	err      error
+}
+
+// isAllAllowedConcurrently checks every policy in its own goroutine, returning
+// the first error or forbidden decision received. Remaining policies' contexts
+// are cancelled once the result is known.
+func (p *CompositeAssumeRolePolicy) isAllAllowedConcurrently(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	policies := p.snapshot()
+	results := make(chan policyResult, len(policies))
+	for i, policy := range policies {
+		go func(i int, policy AssumeRolePolicy) {
+			decision, err := checkTraced(ctx, policy, role, pod)
+			results <- policyResult{index: i, decision: decision, err: err}
+		}(i, policy)
+	}
+
+	deciders := make([]string, len(policies))
+	for range policies {
+		result := <-results
+		if result.err != nil {
+			return nil, result.err
+		}
+		policy := policies[result.index]
+		if !result.decision.IsAllowed() {
+			return &chainForbidden{decision: result.decision, policy: decidedBy(policy, result.decision)}, nil
+		}
+		deciders[result.index] = decidedBy(policy, result.decision)
+	}
+
+	return &allowed{policy: strings.Join(deciders, ", ")}, nil
+}
+
+// chainForbidden is returned by CompositeAssumeRolePolicy when a policy in the
+// chain forbids the request. It is an error wrapping the policy's decision
+// (when the decision is an error), allowing callers to use errors.Is and
+// errors.As rather than inspecting the decision's type.
+type chainForbidden struct {
+	decision Decision
+	policy   string
+}
+
+func (c *chainForbidden) decidingPolicy() string {
+	return c.policy
+}
+
+func (c *chainForbidden) IsAllowed() bool {
+	return false
+}
+
+func (c *chainForbidden) Explanation() string {
+	return c.decision.Explanation()
+}
+
+func (c *chainForbidden) Reason() DenialReason {
+	return c.decision.Reason()
+}
+
+func (c *chainForbidden) Error() string {
+	return c.decision.Explanation()
+}
+
+func (c *chainForbidden) Unwrap() error {
+	err, _ := c.decision.(error)
+	return err
+}
+
+// isAnyAllowed returns allowed as soon as a policy allows the request. If no
+// policy allows the request the first error is returned, or a decision
+// forbidding the request.
+func (p *CompositeAssumeRolePolicy) isAnyAllowed(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	var (
+		decisions []Decision
+		deciders  []string
+		firstErr  error
+	)
+
+	for _, policy := range p.snapshot() {
+		decision, err := checkTraced(ctx, policy, role, pod)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if decision.IsAllowed() {
+			return &allowed{policy: decidedBy(policy, decision)}, nil
+		}
+		decisions = append(decisions, decision)
+		deciders = append(deciders, decidedBy(policy, decision))
+	}
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return &noneAllowed{decisions: decisions, policies: deciders}, nil
+}
+
+// noneAllowed is returned by an AnyOf CompositeAssumeRolePolicy when every
+// policy forbids the request. It wraps the first policy's decision.
+type noneAllowed struct {
+	decisions []Decision
+	policies  []string
+}
+
+func (n *noneAllowed) decidingPolicy() string {
+	return strings.Join(n.policies, ", ")
+}
+
+func (n *noneAllowed) IsAllowed() bool {
+	return false
+}
+
+func (n *noneAllowed) Explanation() string {
+	if len(n.decisions) == 0 {
+		return "no policies to allow request"
+	}
+
+	explanations := make([]string, 0, len(n.decisions))
+	for _, decision := range n.decisions {
+		explanations = append(explanations, decision.Explanation())
+	}
+	return fmt.Sprintf("no policy allowed request: %s", strings.Join(explanations, "; "))
+}
+
+func (n *noneAllowed) Reason() DenialReason {
+	if len(n.decisions) == 0 {
+		return ReasonNone
+	}
+	return n.decisions[0].Reason()
+}
+
+func (n *noneAllowed) Error() string {
+	return n.Explanation()
+}
+
+func (n *noneAllowed) Unwrap() error {
+	if len(n.decisions) == 0 {
+		return nil
+	}
+	err, _ := n.decisions[0].(error)
+	return err
+}
+
+// decidingPolicy is implemented by decisions naming the policies that made
+// them, such as those returned by a CompositeAssumeRolePolicy
+type decidingPolicy interface {
+	decidingPolicy() string
+}
+
+// decidedBy names the policies that made the decision: those the decision
+// names, or the policy itself
+func decidedBy(policy AssumeRolePolicy, decision Decision) string {
+	if deciding, ok := decision.(decidingPolicy); ok && deciding.decidingPolicy() != "" {
+		return deciding.decidingPolicy()
+	}
+	return policyName(policy)
+}
+
+func (p *CompositeAssumeRolePolicy) snapshot() []AssumeRolePolicy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.policies
+}
+
+// Append adds the policy to the end of the chain.
+func (p *CompositeAssumeRolePolicy) Append(policy AssumeRolePolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	policies := make([]AssumeRolePolicy, 0, len(p.policies)+1)
+	policies = append(policies, p.policies...)
+	p.policies = append(policies, policy)
+}
+
+// Remove removes all policies implementing Named with the name. Returns
+// whether any policies were removed.
+func (p *CompositeAssumeRolePolicy) Remove(name string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	policies := make([]AssumeRolePolicy, 0, len(p.policies))
+	for _, policy := range p.policies {
+		if named, ok := policy.(Named); ok && named.Name() == name {
+			continue
 		}
+		policies = append(policies, policy)
 	}
 
-	return &allowed{}, nil
+	removed := len(policies) != len(p.policies)
+	p.policies = policies
+
+	return removed
 }
 
 // Creates a AssumeRolePolicy that tests all policies pass.
 func Policies(p ...AssumeRolePolicy) *CompositeAssumeRolePolicy {
 	return &CompositeAssumeRolePolicy{
+		mode:     allOf,
+		policies: p,
+	}
+}
+
+// AnyOf creates a AssumeRolePolicy that tests any policy passes. Policies
+// are checked in order until one allows the request.
+func AnyOf(p ...AssumeRolePolicy) *CompositeAssumeRolePolicy {
+	return &CompositeAssumeRolePolicy{
+		mode:     anyOf,
+		policies: p,
+	}
+}
+
+// ConcurrentPolicies creates a AssumeRolePolicy that tests all policies pass,
+// checking them in parallel. Policies must be independent of each other; the
+// first denial received is returned.
+func ConcurrentPolicies(p ...AssumeRolePolicy) *CompositeAssumeRolePolicy {
+	return &CompositeAssumeRolePolicy{
+		mode:     allOfConcurrent,
 		policies: p,
 	}
 }
 
 // RequestingAnnotatedRolePolicy ensures the pod is requesting the role that it's
-// currently annotated with.
+// currently annotated with. The pod found for the request, if carried in the
+// context, is used in preference to the pod argument.
 type RequestingAnnotatedRolePolicy struct {
+	tracing
+
 	pods     k8s.PodGetter
 	resolver sts.ARNResolver
 }
@@ -68,8 +372,20 @@ func NewRequestingAnnotatedRolePolicy(p k8s.PodGetter, resolver sts.ARNResolver)
 	return &RequestingAnnotatedRolePolicy{pods: p, resolver: resolver}
 }
 
-func (p *RequestingAnnotatedRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
-	annotatedIdentiy, err := p.resolver.Resolve(k8s.PodRole(pod))
+func (p *RequestingAnnotatedRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (decision Decision, err error) {
+	_, span := p.startSpan(ctx, "RequestingAnnotatedRolePolicy.IsAllowedAssumeRole", role, pod)
+	defer func() { endSpan(span, decision, err) }()
+
+	if requestPod, ok := PodFromContext(ctx); ok {
+		pod = requestPod
+	}
+
+	annotatedRole := k8s.PodRole(pod)
+	if annotatedRole == "" {
+		return &notAnnotated{requested: role, namespace: pod.GetNamespace(), uid: string(pod.GetUID())}, nil
+	}
+
+	annotatedIdentiy, err := p.resolver.Resolve(annotatedRole)
 	if err != nil {
 		return nil, err
 	}
@@ -77,31 +393,142 @@ func (p *RequestingAnnotatedRolePolicy) IsAllowedAssumeRole(ctx context.Context,
 	if err != nil {
 		return nil, err
 	}
+	span.SetAttributes(attribute.String("iam.role.arn", requestedIdentity.ARN.String()))
 
 	if annotatedIdentiy.Equals(requestedIdentity) {
 		return &allowed{}, nil
 	}
 
-	return &forbidden{requested: role, annotated: annotatedIdentiy.Name}, nil
+	return &forbidden{requested: role, annotated: annotatedIdentiy.ARN.String(), namespace: pod.GetNamespace(), uid: string(pod.GetUID())}, nil
 }
 
 // NamespacePermittedRoleNamePolicy ensures the pod is requesting a role that
-// the namespace permits in its regexp annotation.
+// the namespace permits in its regexp annotation. The annotation may contain
+// several regexps separated by the delimiter (| by default), the role is
+// permitted if any match. Delimiters within groups, character classes or
+// repetitions, or escaped with a backslash (e.g. \|), don't separate regexps.
+// Roles may also be permitted by ClusterRolePermissions selecting the namespace.
 type NamespacePermittedRoleNamePolicy struct {
-	namespaces k8s.NamespaceFinder
-	resolver   sts.ARNResolver
-	strict     bool
+	tracing
+
+	namespaces  k8s.NamespaceFinder
+	permissions k8s.ClusterRolePermissionFinder
+	aliases     *ARNAliasRegistry
+	resolver    sts.ARNResolver
+	strict      bool
+	delimiter   rune
+	expressions *lru.Cache
+	decisions   *prometheus.CounterVec
 }
 
+// DefaultExpressionDelimiter separates regexps in the namespace annotation
+const DefaultExpressionDelimiter = '|'
+
+// DefaultRegexpCacheSize is the number of compiled namespace expressions kept by
+// NewNamespacePermittedRoleNamePolicy.
+const DefaultRegexpCacheSize = 256
+
 func NewNamespacePermittedRoleNamePolicy(strictRegexp bool, n k8s.NamespaceFinder, resolver sts.ARNResolver) *NamespacePermittedRoleNamePolicy {
-	return &NamespacePermittedRoleNamePolicy{namespaces: n, resolver: resolver, strict: strictRegexp}
+	return NewNamespacePermittedRoleNamePolicyWithCache(strictRegexp, n, resolver, DefaultRegexpCacheSize)
+}
+
+// NewNamespacePermittedRoleNamePolicyWithCache creates the policy keeping up to cacheSize
+// compiled expressions, least recently used are evicted first. A cacheSize of 0 or less
+// compiles the expression on every request.
+func NewNamespacePermittedRoleNamePolicyWithCache(strictRegexp bool, n k8s.NamespaceFinder, resolver sts.ARNResolver, cacheSize int) *NamespacePermittedRoleNamePolicy {
+	policy := &NamespacePermittedRoleNamePolicy{
+		namespaces: n,
+		resolver:   resolver,
+		strict:     strictRegexp,
+		delimiter:  DefaultExpressionDelimiter,
+		decisions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "kiam",
+				Subsystem: "policy",
+				Name:      "namespace_permitted_decisions_total",
+				Help:      "Number of namespace permitted role name policy decisions",
+			},
+			[]string{"namespace", "decision"},
+		),
+	}
+	if cacheSize > 0 {
+		policy.expressions, _ = lru.New(cacheSize)
+	}
+
+	return policy
+}
+
+// SetExpressionDelimiter changes the delimiter separating regexps in the
+// namespace annotation.
+func (p *NamespacePermittedRoleNamePolicy) SetExpressionDelimiter(delimiter rune) {
+	p.delimiter = delimiter
+}
+
+// SetClusterRolePermissions permits roles matching the regexps of the
+// permissions selecting the pod's namespace, in addition to those permitted by
+// the namespace's annotation.
+func (p *NamespacePermittedRoleNamePolicy) SetClusterRolePermissions(permissions k8s.ClusterRolePermissionFinder) {
+	p.permissions = permissions
+}
+
+// SetARNAliases expands regexps in the namespace annotation that are aliases
+// registered with aliases (they contain no :) to match exactly their ARN.
+func (p *NamespacePermittedRoleNamePolicy) SetARNAliases(aliases *ARNAliasRegistry) {
+	p.aliases = aliases
+}
+
+// RegisterMetrics registers the policy's decision counter with reg. If an
+// equivalent counter is already registered (by another instance of the policy)
+// it is shared.
+func (p *NamespacePermittedRoleNamePolicy) RegisterMetrics(reg prometheus.Registerer) error {
+	err := reg.Register(p.decisions)
+	if err == nil {
+		return nil
+	}
+
+	if existing, ok := err.(prometheus.AlreadyRegisteredError); ok {
+		if decisions, ok := existing.ExistingCollector.(*prometheus.CounterVec); ok {
+			p.decisions = decisions
+			return nil
+		}
+	}
+
+	return err
+}
+
+// PolicyConfig describes how namespace annotations are matched
+func (p *NamespacePermittedRoleNamePolicy) PolicyConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"strict":                 p.strict,
+		"delimiter":              string(p.delimiter),
+		"clusterRolePermissions": p.permissions != nil,
+		"arnAliases":             p.aliases != nil,
+	}
 }
 
 func (p *NamespacePermittedRoleNamePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	ctx, span := p.startSpan(ctx, "NamespacePermittedRoleNamePolicy.IsAllowedAssumeRole", role, pod)
+	decision, err := p.isAllowedAssumeRole(ctx, role, pod)
+	endSpan(span, decision, err)
+
+	switch {
+	case err != nil:
+		p.decisions.WithLabelValues(pod.GetNamespace(), "error").Inc()
+	case decision.IsAllowed():
+		p.decisions.WithLabelValues(pod.GetNamespace(), "allowed").Inc()
+	default:
+		p.decisions.WithLabelValues(pod.GetNamespace(), "forbidden").Inc()
+	}
+
+	return decision, err
+}
+
+func (p *NamespacePermittedRoleNamePolicy) isAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
 	requestedIdentity, err := p.resolver.Resolve(role)
 	if err != nil {
 		return nil, err
 	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("iam.role.arn", requestedIdentity.ARN.String()))
 
 	ns, err := p.namespaces.FindNamespace(ctx, pod.GetObjectMeta().GetNamespace())
 	if err != nil {
@@ -109,37 +536,211 @@ func (p *NamespacePermittedRoleNamePolicy) IsAllowedAssumeRole(ctx context.Conte
 	}
 
 	expression := ns.GetAnnotations()[k8s.AnnotationPermittedKey]
+	if expression != "" {
+		permitted, err := p.matches(expression, requestedIdentity.ARN.String())
+		if err != nil {
+			return nil, err
+		}
+		if permitted {
+			return &allowed{}, nil
+		}
+	}
+
+	if p.permissions != nil {
+		for _, permission := range p.permissions.ClusterRolePermissions() {
+			if !permission.Matches(ns) {
+				continue
+			}
+			for _, role := range permission.Roles {
+				permitted, err := p.matches(role, requestedIdentity.ARN.String())
+				if err != nil {
+					return nil, err
+				}
+				if permitted {
+					return &allowed{}, nil
+				}
+			}
+		}
+	}
+
 	if expression == "" {
 		return &namespacePolicyForbidden{expression: "(empty)", role: role}, nil
 	}
+	return &namespacePolicyForbidden{expression: expression, role: requestedIdentity.ARN.String()}, nil
+}
+
+// matches returns true if any of the expression's regexps match the arn
+func (p *NamespacePermittedRoleNamePolicy) matches(expression, arn string) (bool, error) {
+	expressions, err := p.compile(expression)
+	if err != nil {
+		return false, err
+	}
+
+	for _, re := range expressions {
+		if re.MatchString(arn) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
 
-	var re *regexp.Regexp
-	if p.strict {
-		re, err = regexp.Compile("^" + expression + "$")
+func (p *NamespacePermittedRoleNamePolicy) compile(annotation string) ([]*regexp.Regexp, error) {
+	// aliases can change so the cache is keyed by the expanded expressions
+	// when aliases are used
+	key := annotation
+	var parts []string
+	if p.aliases != nil {
+		var err error
+		parts, err = p.expandAliases(annotation)
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		re, err = regexp.Compile(expression)
+		key = strings.Join(parts, "\x00")
+	}
+
+	if p.expressions != nil {
+		if expressions, ok := p.expressions.Get(key); ok {
+			return expressions.([]*regexp.Regexp), nil
+		}
+	}
+
+	if parts == nil {
+		var err error
+		parts, err = splitExpressions(annotation, p.delimiter)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	if !re.MatchString(requestedIdentity.ARN) {
-		return &namespacePolicyForbidden{expression: expression, role: requestedIdentity.ARN}, nil
+	expressions := make([]*regexp.Regexp, 0, len(parts))
+	for _, expression := range parts {
+		if p.strict {
+			expression = "^" + expression + "$"
+		}
+
+		re, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, err
+		}
+		expressions = append(expressions, re)
+	}
+
+	if p.expressions != nil {
+		p.expressions.Add(key, expressions)
+	}
+
+	return expressions, nil
+}
+
+// expandAliases splits the annotation, replacing registered aliases with a
+// regexp matching only their ARN.
+func (p *NamespacePermittedRoleNamePolicy) expandAliases(annotation string) ([]string, error) {
+	parts, err := splitExpressions(annotation, p.delimiter)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, part := range parts {
+		if arn, ok := p.aliases.Lookup(part); ok {
+			parts[i] = "^" + regexp.QuoteMeta(arn) + "$"
+		}
+	}
+	return parts, nil
+}
+
+// splitExpressions splits the annotation into regexps at each delimiter that
+// isn't escaped or within a group, character class or repetition. All regexps
+// must be non-empty.
+func splitExpressions(annotation string, delimiter rune) ([]string, error) {
+	var (
+		expressions []string
+		current     []rune
+		depth       int
+		class       bool
+		escaped     bool
+	)
+
+	for _, r := range annotation {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case class:
+			class = r != ']'
+		case r == '[':
+			class = true
+		case r == '(' || r == '{':
+			depth++
+		case (r == ')' || r == '}') && depth > 0:
+			depth--
+		case r == delimiter && depth == 0:
+			expressions = append(expressions, strings.TrimSpace(string(current)))
+			current = current[:0]
+			continue
+		}
+		current = append(current, r)
+	}
+	expressions = append(expressions, strings.TrimSpace(string(current)))
+
+	for _, expression := range expressions {
+		if expression == "" {
+			return nil, fmt.Errorf("namespace expression '%s' contains an empty regexp", annotation)
+		}
 	}
 
-	return &allowed{}, nil
+	return expressions, nil
 }
 
 // Decision reports (with message) as to whether the assume role is permitted.
 type Decision interface {
 	IsAllowed() bool
 	Explanation() string
+	// Reason identifies why the request was forbidden, for use by code rather
+	// than people. Allowed decisions have no reason.
+	Reason() DenialReason
 }
 
+// DenialReason is a machine-readable identifier for why a request was forbidden
+type DenialReason string
+
+const (
+	ReasonNone              DenialReason = ""
+	ReasonRoleMismatch      DenialReason = "ROLE_MISMATCH"
+	ReasonNamespacePolicy   DenialReason = "NAMESPACE_POLICY"
+	ReasonRateLimit         DenialReason = "RATE_LIMIT"
+	ReasonTimeWindow        DenialReason = "TIME_WINDOW"
+	ReasonWebhook           DenialReason = "WEBHOOK"
+	ReasonLabelSelector     DenialReason = "LABEL_SELECTOR"
+	ReasonAllowList         DenialReason = "ALLOW_LIST"
+	ReasonDenyList          DenialReason = "DENY_LIST"
+	ReasonServiceAccount    DenialReason = "SERVICE_ACCOUNT"
+	ReasonSessionDuration   DenialReason = "SESSION_DURATION"
+	ReasonAnnotationPrefix  DenialReason = "ANNOTATION_PREFIX"
+	ReasonCircuitOpen       DenialReason = "CIRCUIT_OPEN"
+	ReasonOIDCToken         DenialReason = "OIDC_TOKEN"
+	ReasonRoleChain         DenialReason = "ROLE_CHAIN"
+	ReasonCrossAccount      DenialReason = "CROSS_ACCOUNT"
+	ReasonPodPhase          DenialReason = "POD_PHASE"
+	ReasonTimeout           DenialReason = "TIMEOUT"
+	ReasonSessionName       DenialReason = "SESSION_NAME"
+	ReasonNodeSelector      DenialReason = "NODE_SELECTOR"
+	ReasonCredentialTTL     DenialReason = "CREDENTIAL_TTL"
+	ReasonTerminating       DenialReason = "TERMINATING"
+	ReasonClusterPermission DenialReason = "CLUSTER_ROLE_PERMISSION"
+	ReasonRunAsRoot         DenialReason = "RUN_AS_ROOT"
+	ReasonPartition         DenialReason = "PARTITION"
+	ReasonSessionTags       DenialReason = "SESSION_TAGS"
+	ReasonRoleChangeRate    DenialReason = "ROLE_CHANGE_RATE"
+)
+
 type allowed struct {
+	// policy names the policies allowing the request, when known
+	policy string
+}
+
+func (a *allowed) decidingPolicy() string {
+	return a.policy
 }
 
 func (a *allowed) IsAllowed() bool {
@@ -150,16 +751,61 @@ func (a *allowed) Explanation() string {
 	return ""
 }
 
+func (a *allowed) Reason() DenialReason {
+	return ReasonNone
+}
+
 type forbidden struct {
 	requested string
 	annotated string
+	namespace string
+	uid       string
 }
 
 func (f *forbidden) IsAllowed() bool {
 	return false
 }
 func (f *forbidden) Explanation() string {
-	return fmt.Sprintf("requested '%s' but annotated with '%s', forbidden", f.requested, f.annotated)
+	return fmt.Sprintf("requested '%s' but pod (namespace '%s', uid '%s') annotated with '%s', forbidden", f.requested, f.namespace, f.uid, f.annotated)
+}
+
+func (f *forbidden) Reason() DenialReason {
+	return ReasonRoleMismatch
+}
+
+func (f *forbidden) Error() string {
+	return f.Explanation()
+}
+
+func (f *forbidden) Unwrap() error {
+	return ErrRoleMismatch
+}
+
+// notAnnotated is returned when the pod has no role annotation
+type notAnnotated struct {
+	requested string
+	namespace string
+	uid       string
+}
+
+func (f *notAnnotated) IsAllowed() bool {
+	return false
+}
+
+func (f *notAnnotated) Explanation() string {
+	return "pod has no role annotation"
+}
+
+func (f *notAnnotated) Reason() DenialReason {
+	return ReasonRoleMismatch
+}
+
+func (f *notAnnotated) Error() string {
+	return fmt.Sprintf("requested '%s' but pod (namespace '%s', uid '%s') %s", f.requested, f.namespace, f.uid, f.Explanation())
+}
+
+func (f *notAnnotated) Unwrap() error {
+	return ErrRoleMismatch
 }
 
 type namespacePolicyForbidden struct {
@@ -174,3 +820,15 @@ func (f *namespacePolicyForbidden) IsAllowed() bool {
 func (f *namespacePolicyForbidden) Explanation() string {
 	return fmt.Sprintf("namespace policy expression '%s' forbids role '%s'", f.expression, f.role)
 }
+
+func (f *namespacePolicyForbidden) Reason() DenialReason {
+	return ReasonNamespacePolicy
+}
+
+func (f *namespacePolicyForbidden) Error() string {
+	return f.Explanation()
+}
+
+func (f *namespacePolicyForbidden) Unwrap() error {
+	return ErrNamespacePolicyForbidden
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	"github.com/uswitch/kiam/pkg/logging"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// AllowListAssumeRolePolicy only permits pods to assume roles listed for their
+// namespace in a ConfigMap. Each data entry in the ConfigMap contains newline
+// separated namespace/role entries, roles may be names or ARNs. The list is
+// reloaded whenever the ConfigMap changes.
+type AllowListAssumeRolePolicy struct {
+	resolver sts.ARNResolver
+	watcher  *k8s.ConfigMapWatcher
+	logger   *slog.Logger
+	changeNotifier
+
+	mu      sync.RWMutex
+	allowed map[string]map[string]bool
+	entries int
+}
+
+// NewAllowListAssumeRolePolicy creates the policy watching the ConfigMap from
+// source. Run must be called to start watching.
+func NewAllowListAssumeRolePolicy(resolver sts.ARNResolver, source cache.ListerWatcher, syncInterval time.Duration) *AllowListAssumeRolePolicy {
+	p := &AllowListAssumeRolePolicy{resolver: resolver, logger: slog.Default(), allowed: map[string]map[string]bool{}}
+	p.watcher = k8s.NewConfigMapWatcher(source, syncInterval, p.update)
+	return p
+}
+
+// Run starts watching the ConfigMap. Blocks until the list has been loaded
+func (p *AllowListAssumeRolePolicy) Run(ctx context.Context) error {
+	p.logger = logging.FromContext(ctx)
+	return p.watcher.Run(ctx)
+}
+
+// HealthCheck reports whether the ConfigMap has been loaded
+func (p *AllowListAssumeRolePolicy) HealthCheck() (HealthStatus, error) {
+	if !p.watcher.HasSynced() {
+		return HealthStatus{Healthy: false, Message: "allow list configmap not loaded"}, nil
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return HealthStatus{Healthy: true, Message: fmt.Sprintf("%d entries for %d namespaces", p.entries, len(p.allowed))}, nil
+}
+
+// PolicyConfig describes the size of the loaded allow list
+func (p *AllowListAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return map[string]interface{}{
+		"namespaces": len(p.allowed),
+		"entries":    p.entries,
+	}
+}
+
+func (p *AllowListAssumeRolePolicy) update(data map[string]string) {
+	allowed := map[string]map[string]bool{}
+	entries := 0
+
+	for key, value := range data {
+		scanner := bufio.NewScanner(strings.NewReader(value))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			namespace, arn, err := p.parseEntry(line)
+			if err != nil {
+				p.logger.Warn("ignoring invalid allow list entry", "allowlist.key", key, "error", err)
+				continue
+			}
+
+			if allowed[namespace] == nil {
+				allowed[namespace] = map[string]bool{}
+			}
+			allowed[namespace][arn] = true
+			entries++
+		}
+	}
+
+	p.mu.Lock()
+	p.allowed = allowed
+	p.entries = entries
+	p.mu.Unlock()
+	p.notify()
+
+	p.logger.Info("loaded allow list", "allowlist.namespaces", len(allowed), "allowlist.entries", entries)
+}
+
+func (p *AllowListAssumeRolePolicy) parseEntry(line string) (string, string, error) {
+	parts := strings.SplitN(line, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("expected namespace/role, was '%s'", line)
+	}
+
+	resolved, err := p.resolver.Resolve(parts[1])
+	if err != nil {
+		return "", "", err
+	}
+
+	return parts[0], resolved.ARN.String(), nil
+}
+
+func (p *AllowListAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	requestedIdentity, err := p.resolver.Resolve(role)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := pod.GetNamespace()
+
+	p.mu.RLock()
+	permitted := p.allowed[namespace][requestedIdentity.ARN.String()]
+	p.mu.RUnlock()
+
+	if !permitted {
+		return &allowListForbidden{namespace: namespace, role: requestedIdentity.ARN.String()}, nil
+	}
+
+	return &allowed{}, nil
+}
+
+type allowListForbidden struct {
+	namespace string
+	role      string
+}
+
+func (f *allowListForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *allowListForbidden) Explanation() string {
+	return fmt.Sprintf("role '%s' is not in the allow list for namespace '%s'", f.role, f.namespace)
+}
+
+func (f *allowListForbidden) Reason() DenialReason {
+	return ReasonAllowList
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/testutil"
+	v1 "k8s.io/api/core/v1"
+	kt "k8s.io/client-go/tools/cache/testing"
+)
+
+// eventuallyAllowed checks the policy returns the expected decision, waiting
+// for changes to be reloaded until it does
+func eventuallyAllowed(t *testing.T, changes PolicyChangeNotifier, policy AssumeRolePolicy, role string, pod *v1.Pod, expected bool) {
+	t.Helper()
+
+	timeout := time.After(time.Second)
+	for {
+		decision, err := policy.IsAllowedAssumeRole(context.Background(), role, pod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decision.IsAllowed() == expected {
+			return
+		}
+
+		select {
+		case <-changes.Changed():
+		case <-timeout:
+			t.Fatalf("expected allowed to be %t: %s", expected, decision.Explanation())
+		}
+	}
+}
+
+func TestAllowListPolicy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := kt.NewFakeControllerSource()
+	defer source.Shutdown()
+	source.Add(testutil.NewConfigMap("kube-system", "kiam-allow-list", map[string]string{
+		"red":  "# red team\nred/red_role\nred/arn:aws:iam::123456789012:role/path/other_role\n",
+		"blue": "blue/blue_role\ninvalid",
+	}))
+
+	policy := NewAllowListAssumeRolePolicy(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), source, time.Minute)
+	if err := policy.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	red := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	blue := testutil.NewPodWithRole("blue", "foo", "192.168.0.2", testutil.PhaseRunning, "blue_role")
+
+	var tests = []struct {
+		role     string
+		pod      *v1.Pod
+		expected bool
+	}{
+		{"red_role", red, true},
+		{"/red_role", red, true},
+		{"arn:aws:iam::123456789012:role/red_role", red, true},
+		{"path/other_role", red, true},
+		{"blue_role", red, false},
+		{"blue_role", blue, true},
+		{"red_role", blue, false},
+	}
+
+	for _, tt := range tests {
+		decision, err := policy.IsAllowedAssumeRole(ctx, tt.role, tt.pod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decision.IsAllowed() != tt.expected {
+			t.Errorf("expected %s in %s allowed to be %t: %s", tt.role, tt.pod.Namespace, tt.expected, decision.Explanation())
+		}
+	}
+
+	decision, _ := policy.IsAllowedAssumeRole(ctx, "blue_role", red)
+	if decision.Explanation() != "role 'arn:aws:iam::123456789012:role/blue_role' is not in the allow list for namespace 'red'" {
+		t.Error("unexpected explanation, was", decision.Explanation())
+	}
+}
+
+func TestAllowListPolicyReloadsConfigMap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := kt.NewFakeControllerSource()
+	defer source.Shutdown()
+	source.Add(testutil.NewConfigMap("kube-system", "kiam-allow-list", map[string]string{"roles": "red/red_role"}))
+
+	policy := NewAllowListAssumeRolePolicy(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), source, time.Minute)
+	if err := policy.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	red := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	eventuallyAllowed(t, policy, policy, "red_role", red, true)
+	eventuallyAllowed(t, policy, policy, "orange_role", red, false)
+
+	source.Modify(testutil.NewConfigMap("kube-system", "kiam-allow-list", map[string]string{"roles": "red/orange_role"}))
+	eventuallyAllowed(t, policy, policy, "orange_role", red, true)
+	eventuallyAllowed(t, policy, policy, "red_role", red, false)
+
+	source.Delete(testutil.NewConfigMap("kube-system", "kiam-allow-list", nil))
+	eventuallyAllowed(t, policy, policy, "orange_role", red, false)
+}
+
+func TestAllowListPolicyHealthCheck(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := kt.NewFakeControllerSource()
+	defer source.Shutdown()
+	source.Add(testutil.NewConfigMap("kube-system", "kiam-allow-list", map[string]string{"roles": "red/red_role\nblue/blue_role"}))
+
+	policy := NewAllowListAssumeRolePolicy(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), source, time.Minute)
+	status, err := policy.HealthCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Healthy {
+		t.Error("expected unhealthy before configmap loaded")
+	}
+
+	if err := policy.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	status, _ = policy.HealthCheck()
+	if !status.Healthy {
+		t.Error("expected healthy once configmap loaded:", status.Message)
+	}
+	if status.Message != "2 entries for 2 namespaces" {
+		t.Error("unexpected message, was", status.Message)
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AnnotationInheritancePolicy ensures the pod is requesting the role it's
+// annotated with, like RequestingAnnotatedRolePolicy, but pods without a role
+// annotation inherit the role of their closest owner. The chain of controlling
+// owners (e.g. ReplicaSet then Deployment) is walked, at most maxDepth owners,
+// looking for the role annotation on each owner's pod template and then the
+// owner itself.
+type AnnotationInheritancePolicy struct {
+	owners   k8s.OwnerGetter
+	resolver sts.ARNResolver
+	maxDepth int
+}
+
+// NewAnnotationInheritancePolicy creates the policy getting owners with owners
+func NewAnnotationInheritancePolicy(owners k8s.OwnerGetter, resolver sts.ARNResolver, maxDepth int) *AnnotationInheritancePolicy {
+	return &AnnotationInheritancePolicy{owners: owners, resolver: resolver, maxDepth: maxDepth}
+}
+
+// PolicyConfig describes how far owners are walked
+func (p *AnnotationInheritancePolicy) PolicyConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"maxDepth": p.maxDepth,
+	}
+}
+
+// InheritedRole returns the pod's role annotation or, if the pod isn't
+// annotated, the role annotation of its closest owner. Returns an empty role
+// when neither the pod nor its owners are annotated.
+func (p *AnnotationInheritancePolicy) InheritedRole(pod *v1.Pod) (string, error) {
+	if role := k8s.PodRole(pod); role != "" {
+		return role, nil
+	}
+
+	var object metav1.Object = pod
+	for depth := 0; depth < p.maxDepth; depth++ {
+		ref := metav1.GetControllerOf(object)
+		if ref == nil {
+			return "", nil
+		}
+
+		owner, err := p.owners.GetOwner(pod.GetNamespace(), *ref)
+		if err == k8s.ErrOwnerNotFound || err == k8s.ErrOwnerKindUnsupported {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if role := owner.TemplateAnnotations[k8s.AnnotationIAMRoleKey]; role != "" {
+			return role, nil
+		}
+		if role := owner.Annotations[k8s.AnnotationIAMRoleKey]; role != "" {
+			return role, nil
+		}
+		object = owner
+	}
+
+	return "", nil
+}
+
+func (p *AnnotationInheritancePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	if requestPod, ok := PodFromContext(ctx); ok {
+		pod = requestPod
+	}
+
+	inheritedRole, err := p.InheritedRole(pod)
+	if err != nil {
+		return nil, err
+	}
+	if inheritedRole == "" {
+		return &notAnnotated{requested: role, namespace: pod.GetNamespace(), uid: string(pod.GetUID())}, nil
+	}
+
+	inheritedIdentity, err := p.resolver.Resolve(inheritedRole)
+	if err != nil {
+		return nil, err
+	}
+	requestedIdentity, err := p.resolver.Resolve(role)
+	if err != nil {
+		return nil, err
+	}
+
+	if inheritedIdentity.Equals(requestedIdentity) {
+		return &allowed{}, nil
+	}
+
+	return &forbidden{requested: role, annotated: inheritedIdentity.ARN.String(), namespace: pod.GetNamespace(), uid: string(pod.GetUID())}, nil
+}
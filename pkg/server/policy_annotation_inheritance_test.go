@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	kt "github.com/uswitch/kiam/pkg/k8s/testing"
+	"github.com/uswitch/kiam/pkg/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func controlledBy(kind, name string) []metav1.OwnerReference {
+	controller := true
+	return []metav1.OwnerReference{{Kind: kind, Name: name, Controller: &controller}}
+}
+
+func TestAnnotationInheritancePolicy(t *testing.T) {
+	owners := map[string]*k8s.Owner{
+		"ReplicaSet/web-1234": {ObjectMeta: metav1.ObjectMeta{Name: "web-1234", OwnerReferences: controlledBy("Deployment", "web")}},
+		"Deployment/web": {
+			ObjectMeta:          metav1.ObjectMeta{Name: "web"},
+			TemplateAnnotations: map[string]string{k8s.AnnotationIAMRoleKey: "reader"},
+		},
+		"Job/backup-1": {ObjectMeta: metav1.ObjectMeta{Name: "backup-1", OwnerReferences: controlledBy("CronJob", "backup")}},
+		"CronJob/backup": {
+			ObjectMeta: metav1.ObjectMeta{Name: "backup", Annotations: map[string]string{k8s.AnnotationIAMRoleKey: "writer"}},
+		},
+		"ReplicaSet/orphan-1234": {ObjectMeta: metav1.ObjectMeta{Name: "orphan-1234"}},
+	}
+
+	var tests = []struct {
+		name      string
+		ownerKind string
+		ownerName string
+		podRole   string
+		role      string
+		expected  bool
+	}{
+		{"AnnotatedPod", "ReplicaSet", "web-1234", "writer", "writer", true},
+		{"AnnotatedPodIgnoresOwners", "ReplicaSet", "web-1234", "writer", "reader", false},
+		{"InheritsTemplateAnnotation", "ReplicaSet", "web-1234", "", "reader", true},
+		{"InheritedRoleMismatch", "ReplicaSet", "web-1234", "", "writer", false},
+		{"InheritsOwnerAnnotation", "Job", "backup-1", "", "writer", true},
+		{"OwnersNotAnnotated", "ReplicaSet", "orphan-1234", "", "reader", false},
+		{"OwnerNotFound", "ReplicaSet", "missing", "", "reader", false},
+		{"NoOwner", "", "", "", "reader", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := NewAnnotationInheritancePolicy(kt.NewOwnerGetter(owners), sts.DefaultResolver("arn:aws:iam::123456789012:role/"), 3)
+
+			pod := testutil.NewPodWithRole("ns", "name", "192.168.0.1", testutil.PhaseRunning, tt.podRole)
+			if tt.ownerKind != "" {
+				pod.OwnerReferences = controlledBy(tt.ownerKind, tt.ownerName)
+			}
+
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), tt.role, pod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+		})
+	}
+}
+
+func TestAnnotationInheritancePolicyBoundsDepth(t *testing.T) {
+	// owners form a cycle, which the walk must not follow forever
+	owners := kt.NewOwnerGetter(map[string]*k8s.Owner{
+		"ReplicaSet/a": {ObjectMeta: metav1.ObjectMeta{Name: "a", OwnerReferences: controlledBy("ReplicaSet", "b")}},
+		"ReplicaSet/b": {ObjectMeta: metav1.ObjectMeta{Name: "b", OwnerReferences: controlledBy("ReplicaSet", "a")}},
+	})
+	policy := NewAnnotationInheritancePolicy(owners, sts.DefaultResolver("arn:aws:iam::123456789012:role/"), 5)
+
+	pod := testutil.NewPodWithRole("ns", "name", "192.168.0.1", testutil.PhaseRunning, "")
+	pod.OwnerReferences = controlledBy("ReplicaSet", "a")
+
+	role, err := policy.InheritedRole(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role != "" {
+		t.Error("expected no role, was", role)
+	}
+	if owners.Calls() != 5 {
+		t.Error("expected walk to stop after 5 owners, was", owners.Calls())
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const roleAnnotationName = "/role"
+
+// PodAnnotationPrefixPolicy forbids pods declaring their role with annotation keys
+// outside of a prefix. Any annotation whose key ends in /role is considered a
+// role declaration, e.g. iam.mycompany.com/role. This catches pods annotated
+// with keys that a different system (or a typo) would act upon. It runs before
+// any role resolution.
+type PodAnnotationPrefixPolicy struct {
+	prefix string
+}
+
+func NewPodAnnotationPrefixPolicy(prefix string) *PodAnnotationPrefixPolicy {
+	return &PodAnnotationPrefixPolicy{prefix: prefix}
+}
+
+// PolicyConfig describes the permitted annotation prefix
+func (p *PodAnnotationPrefixPolicy) PolicyConfig() map[string]interface{} {
+	return map[string]interface{}{"prefix": p.prefix}
+}
+
+func (p *PodAnnotationPrefixPolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	keys := make([]string, 0, len(pod.GetAnnotations()))
+	for key := range pod.GetAnnotations() {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !strings.HasSuffix(key, roleAnnotationName) {
+			continue
+		}
+		if !strings.HasPrefix(key, p.prefix) {
+			return &annotationPrefixForbidden{key: key, prefix: p.prefix}, nil
+		}
+	}
+
+	return &allowed{}, nil
+}
+
+type annotationPrefixForbidden struct {
+	key    string
+	prefix string
+}
+
+func (f *annotationPrefixForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *annotationPrefixForbidden) Explanation() string {
+	return fmt.Sprintf("pod declares role with annotation '%s', expected annotation prefix '%s'", f.key, f.prefix)
+}
+
+func (f *annotationPrefixForbidden) Reason() DenialReason {
+	return ReasonAnnotationPrefix
+}
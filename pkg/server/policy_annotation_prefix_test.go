@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+func TestAnnotationPrefixPolicy(t *testing.T) {
+	var tests = []struct {
+		name        string
+		prefix      string
+		annotations map[string]string
+		expected    bool
+	}{
+		{"DefaultKey", "iam.amazonaws.com/", map[string]string{"iam.amazonaws.com/role": "red_role"}, true},
+		{"CustomKey", "iam.mycompany.com/", map[string]string{"iam.mycompany.com/role": "red_role"}, true},
+		{"UnexpectedKey", "iam.mycompany.com/", map[string]string{"iam.amazonaws.com/role": "red_role"}, false},
+		{"MixedKeys", "iam.mycompany.com/", map[string]string{"iam.mycompany.com/role": "red_role", "iam.othercompany.com/role": "blue_role"}, false},
+		{"OtherAnnotations", "iam.mycompany.com/", map[string]string{"iam.mycompany.com/role": "red_role", "iam.amazonaws.com/session-name": "foo"}, true},
+		{"NoAnnotations", "iam.mycompany.com/", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testutil.NewPod("red", "foo", "192.168.0.1", testutil.PhaseRunning)
+			p.Annotations = tt.annotations
+
+			decision, err := NewPodAnnotationPrefixPolicy(tt.prefix).IsAllowedAssumeRole(context.Background(), "red_role", p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+		})
+	}
+}
+
+func TestAnnotationPrefixPolicyExplanation(t *testing.T) {
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	decision, _ := NewPodAnnotationPrefixPolicy("iam.mycompany.com/").IsAllowedAssumeRole(context.Background(), "red_role", p)
+
+	if decision.Explanation() != "pod declares role with annotation 'iam.amazonaws.com/role', expected annotation prefix 'iam.mycompany.com/'" {
+		t.Error("unexpected explanation, was", decision.Explanation())
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ARNPartitionPolicy forbids assuming roles outside an AWS partition (such as
+// aws-us-gov), protecting against annotations naming roles in another
+// partition.
+type ARNPartitionPolicy struct {
+	resolver  sts.ARNResolver
+	partition string
+}
+
+// NewARNPartitionPolicy creates the policy allowing roles in allowedPartition,
+// which must be a known partition.
+func NewARNPartitionPolicy(resolver sts.ARNResolver, allowedPartition string) (*ARNPartitionPolicy, error) {
+	for _, partition := range endpoints.DefaultPartitions() {
+		if partition.ID() == allowedPartition {
+			return &ARNPartitionPolicy{resolver: resolver, partition: allowedPartition}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown partition '%s'", allowedPartition)
+}
+
+// PolicyConfig describes the allowed partition
+func (p *ARNPartitionPolicy) PolicyConfig() map[string]interface{} {
+	return map[string]interface{}{"allowedPartition": p.partition}
+}
+
+func (p *ARNPartitionPolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	resolved, err := p.resolver.Resolve(role)
+	if err != nil {
+		return nil, err
+	}
+	arn := resolved.ARN
+
+	if arn.Partition != p.partition {
+		return &partitionForbidden{role: arn.String(), partition: arn.Partition, allowed: p.partition}, nil
+	}
+
+	return &allowed{}, nil
+}
+
+type partitionForbidden struct {
+	role      string
+	partition string
+	allowed   string
+}
+
+func (f *partitionForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *partitionForbidden) Explanation() string {
+	return fmt.Sprintf("role '%s' is in partition '%s', only '%s' is allowed", f.role, f.partition, f.allowed)
+}
+
+func (f *partitionForbidden) Reason() DenialReason {
+	return ReasonPartition
+}
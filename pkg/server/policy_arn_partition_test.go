@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+func TestARNPartitionPolicy(t *testing.T) {
+	resolver := sts.DefaultResolver("arn:aws-us-gov:iam::123456789012:role/")
+	policy, err := NewARNPartitionPolicy(resolver, "aws-us-gov")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+	var tests = []struct {
+		name     string
+		role     string
+		expected bool
+	}{
+		{"ResolvedInPartition", "red_role", true},
+		{"ARNInPartition", "arn:aws-us-gov:iam::210987654321:role/red_role", true},
+		{"CommercialARN", "arn:aws:iam::123456789012:role/red_role", false},
+		{"ChinaARN", "arn:aws-cn:iam::123456789012:role/red_role", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), tt.role, pod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+			if !tt.expected && decision.Reason() != ReasonPartition {
+				t.Error("unexpected reason", decision.Reason())
+			}
+		})
+	}
+}
+
+func TestARNPartitionPolicyRejectsUnknownPartition(t *testing.T) {
+	if _, err := NewARNPartitionPolicy(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), "aws-mars"); err == nil {
+		t.Error("expected error for unknown partition")
+	}
+}
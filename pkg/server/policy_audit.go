@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/logging"
+	v1 "k8s.io/api/core/v1"
+)
+
+// AuditRecord is written for every decision made by an AuditingAssumeRolePolicy.
+// RequestedRole is the requested role's ARN, or the role as requested if it
+// can't be resolved. Policy names the policies that made the decision, for
+// composites the policy within that allowed or forbade the request.
+type AuditRecord struct {
+	Time          time.Time `json:"time"`
+	PodName       string    `json:"pod.name"`
+	PodNamespace  string    `json:"pod.namespace"`
+	PodUID        string    `json:"pod.uid"`
+	RequestedRole string    `json:"pod.iam.requestedRole"`
+	Policy        string    `json:"policy"`
+	Allowed       bool      `json:"policy.allowed"`
+	Explanation   string    `json:"policy.explanation,omitempty"`
+	Reason        string    `json:"policy.reason,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// DecisionRecord is a recent decision kept by an AuditingAssumeRolePolicy.
+// Decision is allowed, denied or error.
+type DecisionRecord struct {
+	Time        time.Time `json:"time"`
+	PodName     string    `json:"podName"`
+	Namespace   string    `json:"namespace"`
+	Role        string    `json:"role"`
+	Decision    string    `json:"decision"`
+	Explanation string    `json:"explanation,omitempty"`
+}
+
+// AuditingAssumeRolePolicy delegates to another policy and writes a JSON
+// AuditRecord, one per line, for every decision or error it returns. The
+// most recent decisions can also be kept in memory, see
+// SetDecisionHistorySize.
+type AuditingAssumeRolePolicy struct {
+	policy      AssumeRolePolicy
+	arnResolver sts.ARNResolver
+
+	mu      sync.Mutex
+	encoder *json.Encoder
+	// recent is a ring buffer of the latest decisions, next is the index the
+	// next decision is stored at
+	recent []DecisionRecord
+	next   int
+	full   bool
+}
+
+// NewAuditingAssumeRolePolicy creates a policy that audits the decisions of
+// policy to w, recording requested roles as resolved by arnResolver. Records
+// aren't written when w is nil.
+func NewAuditingAssumeRolePolicy(policy AssumeRolePolicy, arnResolver sts.ARNResolver, w io.Writer) *AuditingAssumeRolePolicy {
+	p := &AuditingAssumeRolePolicy{policy: policy, arnResolver: arnResolver}
+	if w != nil {
+		p.encoder = json.NewEncoder(w)
+	}
+	return p
+}
+
+// OpenAuditLog opens the file at path to append audit records to, or returns
+// stdout if path is "-".
+func OpenAuditLog(path string) (*os.File, error) {
+	if path == "-" {
+		return os.Stdout, nil
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+}
+
+// SetDecisionHistorySize keeps the last size decisions in memory, served by
+// DebugDecisionLog. Earlier decisions are discarded.
+func (p *AuditingAssumeRolePolicy) SetDecisionHistorySize(size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.recent = make([]DecisionRecord, size)
+	p.next = 0
+	p.full = false
+}
+
+// Decisions returns the kept decisions, oldest first, optionally filtered by
+// namespace and pod name. Empty filters match all decisions.
+func (p *AuditingAssumeRolePolicy) Decisions(namespace, podName string) []DecisionRecord {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ordered := p.recent[:p.next]
+	if p.full {
+		ordered = append(append([]DecisionRecord{}, p.recent[p.next:]...), p.recent[:p.next]...)
+	}
+
+	decisions := []DecisionRecord{}
+	for _, decision := range ordered {
+		if namespace != "" && decision.Namespace != namespace {
+			continue
+		}
+		if podName != "" && decision.PodName != podName {
+			continue
+		}
+		decisions = append(decisions, decision)
+	}
+	return decisions
+}
+
+// DebugDecisionLog serves the kept decisions as JSON, filtered by the
+// namespace and pod query parameters.
+func (p *AuditingAssumeRolePolicy) DebugDecisionLog() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		decisions := p.Decisions(query.Get("namespace"), query.Get("pod"))
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(decisions); err != nil {
+			logging.FromContext(r.Context()).Error("error writing decisions", "error", err)
+		}
+	})
+}
+
+// PolicyConfig describes the audited policy
+func (p *AuditingAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	return map[string]interface{}{"policy": describePolicy(p.policy)}
+}
+
+func (p *AuditingAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	decision, err := p.policy.IsAllowedAssumeRole(ctx, role, pod)
+
+	record := &AuditRecord{
+		Time:          time.Now().UTC(),
+		PodName:       pod.GetName(),
+		PodNamespace:  pod.GetNamespace(),
+		PodUID:        string(pod.GetUID()),
+		RequestedRole: p.requestedARN(role),
+		Policy:        policyName(p.policy),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	} else {
+		record.Policy = decidedBy(p.policy, decision)
+		record.Allowed = decision.IsAllowed()
+		record.Explanation = decision.Explanation()
+		record.Reason = string(decision.Reason())
+	}
+
+	p.write(ctx, record)
+
+	return decision, err
+}
+
+// requestedARN returns the role's ARN, or the role if it can't be resolved
+func (p *AuditingAssumeRolePolicy) requestedARN(role string) string {
+	resolved, err := p.arnResolver.Resolve(role)
+	if err != nil {
+		return role
+	}
+	return resolved.ARN.String()
+}
+
+func (p *AuditingAssumeRolePolicy) write(ctx context.Context, record *AuditRecord) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.recent) > 0 {
+		p.recent[p.next] = decisionRecord(record)
+		p.next = (p.next + 1) % len(p.recent)
+		if p.next == 0 {
+			p.full = true
+		}
+	}
+
+	if p.encoder == nil {
+		return
+	}
+	if err := p.encoder.Encode(record); err != nil {
+		logging.FromContext(ctx).Error("error writing policy audit record", "error", err)
+	}
+}
+
+func decisionRecord(record *AuditRecord) DecisionRecord {
+	decision := DecisionRecord{
+		Time:        record.Time,
+		PodName:     record.PodName,
+		Namespace:   record.PodNamespace,
+		Role:        record.RequestedRole,
+		Decision:    "denied",
+		Explanation: record.Explanation,
+	}
+	switch {
+	case record.Error != "":
+		decision.Decision = "error"
+		decision.Explanation = record.Error
+	case record.Allowed:
+		decision.Decision = "allowed"
+	}
+	return decision
+}
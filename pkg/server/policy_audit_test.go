@@ -0,0 +1,236 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/testutil"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var auditResolver = sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+
+func TestAuditingPolicyWritesRecord(t *testing.T) {
+	var tests = []struct {
+		name                string
+		policy              fakePolicy
+		expectedAllowed     bool
+		expectedExplanation string
+		expectedReason      string
+		expectedError       string
+	}{
+		{"Allowed", fakePolicy{decision: &allowed{}}, true, "", "", ""},
+		{"Forbidden", fakePolicy{decision: &forbidden{requested: "red_role", annotated: "blue_role", namespace: "red", uid: "1234"}}, false, "requested 'red_role' but pod (namespace 'red', uid '1234') annotated with 'blue_role', forbidden", "ROLE_MISMATCH", ""},
+		{"Error", fakePolicy{err: fmt.Errorf("namespace not found")}, false, "", "", "namespace not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "blue_role")
+			p.UID = types.UID("1234")
+
+			var buf bytes.Buffer
+			policy := NewAuditingAssumeRolePolicy(tt.policy, auditResolver, &buf)
+			_, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+			if err != tt.policy.err {
+				t.Error("expected inner error to be returned, was", err)
+			}
+
+			var record AuditRecord
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("error decoding audit record: %s", err)
+			}
+
+			if record.PodName != "foo" || record.PodNamespace != "red" || record.PodUID != "1234" {
+				t.Error("unexpected pod in record:", record)
+			}
+			if record.RequestedRole != "arn:aws:iam::123456789012:role/red_role" {
+				t.Error("unexpected role, was", record.RequestedRole)
+			}
+			if record.Policy != "fakePolicy" {
+				t.Error("unexpected policy, was", record.Policy)
+			}
+			if record.Allowed != tt.expectedAllowed {
+				t.Error("unexpected allowed, was", record.Allowed)
+			}
+			if record.Explanation != tt.expectedExplanation {
+				t.Error("unexpected explanation, was", record.Explanation)
+			}
+			if record.Reason != tt.expectedReason {
+				t.Error("unexpected reason, was", record.Reason)
+			}
+			if record.Error != tt.expectedError {
+				t.Error("unexpected error, was", record.Error)
+			}
+		})
+	}
+}
+
+func TestAuditingPolicyInsideComposite(t *testing.T) {
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+	var buf bytes.Buffer
+	policy := Policies(
+		NewAuditingAssumeRolePolicy(fakePolicy{decision: &allowed{}}, auditResolver, &buf),
+		NewAuditingAssumeRolePolicy(fakePolicy{decision: &forbidden{}}, auditResolver, &buf),
+	)
+
+	decision, _ := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	if decision.IsAllowed() {
+		t.Error("expected to be forbidden")
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Error("expected a record per policy, was", len(lines))
+	}
+}
+
+func TestAuditingPolicyRecordsDecidingPolicy(t *testing.T) {
+	annotation := NamedPolicy("annotation", fakePolicy{decision: &allowed{}})
+	namespace := NamedPolicy("namespace", fakePolicy{decision: &forbidden{}})
+	credentials := NamedPolicy("credentials", fakePolicy{decision: &allowed{}})
+
+	var tests = []struct {
+		name     string
+		policy   AssumeRolePolicy
+		expected string
+	}{
+		{"Forbidden", Policies(annotation, namespace, credentials), "namespace"},
+		{"Allowed", Policies(annotation, credentials), "annotation, credentials"},
+		{"AllowedConcurrently", ConcurrentPolicies(annotation, credentials), "annotation, credentials"},
+		{"AnyAllowed", AnyOf(namespace, credentials), "credentials"},
+		{"NoneAllowed", AnyOf(namespace), "namespace"},
+		{"Nested", Policies(annotation, AnyOf(namespace)), "namespace"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+			var buf bytes.Buffer
+			policy := NewAuditingAssumeRolePolicy(tt.policy, auditResolver, &buf)
+			policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+
+			var record AuditRecord
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("error decoding audit record: %s", err)
+			}
+			if record.Policy != tt.expected {
+				t.Errorf("expected policy %q, was %q", tt.expected, record.Policy)
+			}
+		})
+	}
+}
+
+func TestAuditingPolicyKeepsRecentDecisions(t *testing.T) {
+	policy := NewAuditingAssumeRolePolicy(fakePolicy{decision: &allowed{}}, auditResolver, nil)
+	policy.SetDecisionHistorySize(3)
+
+	for i := 0; i < 5; i++ {
+		p := testutil.NewPodWithRole("red", fmt.Sprintf("pod-%d", i), "192.168.0.1", testutil.PhaseRunning, "red_role")
+		policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	}
+
+	decisions := policy.Decisions("", "")
+	if len(decisions) != 3 {
+		t.Fatal("expected 3 decisions, was", len(decisions))
+	}
+	for i, decision := range decisions {
+		if expected := fmt.Sprintf("pod-%d", i+2); decision.PodName != expected {
+			t.Errorf("expected decision %d for %s, was %s", i, expected, decision.PodName)
+		}
+		if decision.Decision != "allowed" || decision.Namespace != "red" || decision.Role != "arn:aws:iam::123456789012:role/red_role" {
+			t.Error("unexpected decision", decision)
+		}
+	}
+}
+
+func TestDebugDecisionLog(t *testing.T) {
+	var tests = []struct {
+		name     string
+		policy   fakePolicy
+		expected string
+	}{
+		{"Allowed", fakePolicy{decision: &allowed{}}, "allowed"},
+		{"Denied", fakePolicy{decision: &forbidden{}}, "denied"},
+		{"Error", fakePolicy{err: fmt.Errorf("namespace not found")}, "error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := NewAuditingAssumeRolePolicy(tt.policy, auditResolver, nil)
+			policy.SetDecisionHistorySize(10)
+			policy.IsAllowedAssumeRole(context.Background(), "red_role", testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role"))
+			policy.IsAllowedAssumeRole(context.Background(), "red_role", testutil.NewPodWithRole("red", "bar", "192.168.0.2", testutil.PhaseRunning, "red_role"))
+			policy.IsAllowedAssumeRole(context.Background(), "blue_role", testutil.NewPodWithRole("blue", "foo", "192.168.0.3", testutil.PhaseRunning, "blue_role"))
+
+			var tests = []struct {
+				query    string
+				expected int
+			}{
+				{"", 3},
+				{"?namespace=red", 2},
+				{"?pod=foo", 2},
+				{"?namespace=red&pod=foo", 1},
+				{"?namespace=green", 0},
+			}
+			for _, q := range tests {
+				rr := httptest.NewRecorder()
+				policy.DebugDecisionLog().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/decisions"+q.query, nil))
+
+				var decisions []DecisionRecord
+				if err := json.Unmarshal(rr.Body.Bytes(), &decisions); err != nil {
+					t.Fatal(err)
+				}
+				if len(decisions) != q.expected {
+					t.Errorf("expected %d decisions for '%s', was %d", q.expected, q.query, len(decisions))
+				}
+				for _, decision := range decisions {
+					if decision.Decision != tt.expected {
+						t.Errorf("expected decision to be %s, was %s", tt.expected, decision.Decision)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAuditLogAppendsToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	for i := 0; i < 2; i++ {
+		f, err := OpenAuditLog(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		policy := NewAuditingAssumeRolePolicy(fakePolicy{decision: &allowed{}}, auditResolver, f)
+		policy.IsAllowedAssumeRole(context.Background(), "red_role", testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role"))
+		f.Close()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 2 {
+		t.Error("expected a record per decision to be appended, was", lines)
+	}
+}
+
+func TestOpenAuditLogStdout(t *testing.T) {
+	f, err := OpenAuditLog("-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f != os.Stdout {
+		t.Error("expected - to open stdout")
+	}
+}
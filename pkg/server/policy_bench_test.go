@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	kt "github.com/uswitch/kiam/pkg/k8s/testing"
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+// benchmarkPolicy measures checking whether the pod, annotated with
+// red_role, can assume it with policy
+func benchmarkPolicy(b *testing.B, policy AssumeRolePolicy) {
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		decision, err := policy.IsAllowedAssumeRole(ctx, "red_role", p)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if !decision.IsAllowed() {
+			b.Fatal("expected role to be allowed:", decision.Explanation())
+		}
+	}
+}
+
+func benchmarkNamespacePolicy(b *testing.B, cacheSize int) {
+	n := testutil.NewNamespace("red", "arn:aws:iam::123456789012:role/(red|orange|yellow)_[a-z]+")
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+
+	benchmarkPolicy(b, NewNamespacePermittedRoleNamePolicyWithCache(true, kt.NewNamespaceFinder(n), arnResolver, cacheSize))
+}
+
+func BenchmarkNamespacePolicyWithoutCache(b *testing.B) {
+	benchmarkNamespacePolicy(b, 0)
+}
+
+func BenchmarkNamespacePolicyWithCache(b *testing.B) {
+	benchmarkNamespacePolicy(b, DefaultRegexpCacheSize)
+}
+
+func BenchmarkRequestingAnnotatedRolePolicy(b *testing.B) {
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+
+	benchmarkPolicy(b, NewRequestingAnnotatedRolePolicy(kt.NewStubFinder(p), arnResolver))
+}
+
+func BenchmarkCompositePolicy(b *testing.B) {
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	nf := kt.NewNamespaceFinder(testutil.NewNamespace("red", "arn:aws:iam::123456789012:role/(red|orange|yellow)_[a-z]+"))
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+
+	benchmarkPolicy(b, Policies(
+		NewRequestingAnnotatedRolePolicy(kt.NewStubFinder(p), arnResolver),
+		NewNamespacePermittedRoleNamePolicy(true, nf, arnResolver),
+		NewTimeWindowAssumeRolePolicy(nf, time.Now),
+		NewMaxSessionDurationAssumeRolePolicy(nf),
+		NewRoleSessionNamePolicy(nf),
+	))
+}
+
+func BenchmarkExternalWebhookPolicy(b *testing.B) {
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"allowed": true}`)
+	}))
+	defer webhook.Close()
+
+	policy, err := NewExternalWebhookAssumeRolePolicy(&WebhookConfig{
+		URL:              webhook.URL,
+		Timeout:          time.Second,
+		RetryInterval:    time.Millisecond,
+		MaxRetryDuration: 100 * time.Millisecond,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	benchmarkPolicy(b, policy)
+}
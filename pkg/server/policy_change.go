@@ -0,0 +1,41 @@
+package server
+
+import "sync"
+
+// PolicyChangeNotifier is implemented by policies that reload their
+// configuration, such as from a ConfigMap or file, allowing callers to wait
+// for changes to be applied.
+type PolicyChangeNotifier interface {
+	// Changed returns a channel that receives a value after the configuration
+	// is reloaded. Notifications are sent without blocking: the channel
+	// buffers a single notification and further changes are dropped until
+	// it's received. Receivers are notified at least once of any changes made
+	// since they last received, but not once per change.
+	Changed() <-chan struct{}
+}
+
+// changeNotifier implements PolicyChangeNotifier, the zero value is ready to
+// use
+type changeNotifier struct {
+	once sync.Once
+	ch   chan struct{}
+}
+
+func (n *changeNotifier) channel() chan struct{} {
+	n.once.Do(func() {
+		n.ch = make(chan struct{}, 1)
+	})
+	return n.ch
+}
+
+func (n *changeNotifier) Changed() <-chan struct{} {
+	return n.channel()
+}
+
+// notify sends a notification, dropping it if one is already pending
+func (n *changeNotifier) notify() {
+	select {
+	case n.channel() <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,35 @@
+package server
+
+import "testing"
+
+var (
+	_ PolicyChangeNotifier = &AllowListAssumeRolePolicy{}
+	_ PolicyChangeNotifier = &DenyListAssumeRolePolicy{}
+	_ PolicyChangeNotifier = &CrossAccountRoleValidator{}
+	_ PolicyChangeNotifier = &ServiceAccountAssumeRolePolicy{}
+	_ PolicyChangeNotifier = &ARNAliasRegistry{}
+)
+
+func TestChangeNotifierDropsPendingNotifications(t *testing.T) {
+	var n changeNotifier
+
+	select {
+	case <-n.Changed():
+		t.Fatal("expected no notification before changes")
+	default:
+	}
+
+	n.notify()
+	n.notify()
+
+	select {
+	case <-n.Changed():
+	default:
+		t.Fatal("expected notification after changes")
+	}
+	select {
+	case <-n.Changed():
+		t.Fatal("expected notifications to be coalesced")
+	default:
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/sony/gobreaker"
+	v1 "k8s.io/api/core/v1"
+)
+
+// CircuitBreakerConfig configures when a CircuitBreakerAssumeRolePolicy trips
+type CircuitBreakerConfig struct {
+	// ConsecutiveFailures is the number of consecutive errors that open the circuit
+	ConsecutiveFailures uint32
+	// OpenTimeout is how long the circuit stays open before a request is
+	// let through to test whether the inner policy has recovered
+	OpenTimeout time.Duration
+	// FailOpen allows requests while the circuit is open, otherwise they
+	// are forbidden
+	FailOpen bool
+}
+
+// CircuitBreakerAssumeRolePolicy wraps a policy, stopping calls to it after
+// repeated errors (such as failing namespace lookups). Only errors count as
+// failures, forbidden decisions don't trip the circuit.
+type CircuitBreakerAssumeRolePolicy struct {
+	policy   AssumeRolePolicy
+	breaker  *gobreaker.TwoStepCircuitBreaker
+	failOpen bool
+}
+
+func NewCircuitBreakerAssumeRolePolicy(name string, policy AssumeRolePolicy, config CircuitBreakerConfig, logger *slog.Logger) *CircuitBreakerAssumeRolePolicy {
+	breaker := gobreaker.NewTwoStepCircuitBreaker(gobreaker.Settings{
+		Name:    name,
+		Timeout: config.OpenTimeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= config.ConsecutiveFailures
+		},
+		OnStateChange: func(name string, from, to gobreaker.State) {
+			logger.Warn("circuit breaker changed state", "policy.circuit", name, "circuit.from", from.String(), "circuit.to", to.String())
+		},
+	})
+
+	return &CircuitBreakerAssumeRolePolicy{policy: policy, breaker: breaker, failOpen: config.FailOpen}
+}
+
+// Name returns the circuit breaker's name
+func (p *CircuitBreakerAssumeRolePolicy) Name() string {
+	return p.breaker.Name()
+}
+
+// PolicyConfig describes the circuit's state and the policy it protects
+func (p *CircuitBreakerAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"state":    p.breaker.State().String(),
+		"failOpen": p.failOpen,
+		"policy":   describePolicy(p.policy),
+	}
+}
+
+func (p *CircuitBreakerAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	done, err := p.breaker.Allow()
+	if err != nil {
+		return &circuitOpen{name: p.breaker.Name(), failOpen: p.failOpen}, nil
+	}
+
+	decision, err := p.policy.IsAllowedAssumeRole(ctx, role, pod)
+	done(err == nil)
+
+	return decision, err
+}
+
+type circuitOpen struct {
+	name     string
+	failOpen bool
+}
+
+func (c *circuitOpen) IsAllowed() bool {
+	return c.failOpen
+}
+
+func (c *circuitOpen) Explanation() string {
+	if c.failOpen {
+		return fmt.Sprintf("circuit breaker '%s' open, policy skipped", c.name)
+	}
+	return fmt.Sprintf("circuit breaker '%s' open, forbidden", c.name)
+}
+
+func (c *circuitOpen) Reason() DenialReason {
+	return ReasonCircuitOpen
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/logging"
+	"github.com/uswitch/kiam/pkg/testutil"
+	v1 "k8s.io/api/core/v1"
+)
+
+type countingPolicy struct {
+	calls    int
+	decision Decision
+	err      error
+}
+
+func (c *countingPolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	c.calls++
+	return c.decision, c.err
+}
+
+func TestCircuitBreakerPolicyTripsAfterConsecutiveErrors(t *testing.T) {
+	var tests = []struct {
+		name     string
+		failOpen bool
+		expected bool
+	}{
+		{"FailClosed", false, false},
+		{"FailOpen", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &countingPolicy{err: fmt.Errorf("namespace lookup timed out")}
+			policy := NewCircuitBreakerAssumeRolePolicy("namespace", inner, CircuitBreakerConfig{ConsecutiveFailures: 3, OpenTimeout: time.Minute, FailOpen: tt.failOpen}, logging.Discard())
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+			for i := 0; i < 3; i++ {
+				if _, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p); err == nil {
+					t.Fatal("expected inner error while closed")
+				}
+			}
+
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+			if err != nil {
+				t.Fatal("expected no error while open, was", err)
+			}
+			if _, ok := decision.(*circuitOpen); !ok {
+				t.Fatalf("expected circuit open decision, was %T", decision)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+			if inner.calls != 3 {
+				t.Error("expected inner policy not to be called while open, calls:", inner.calls)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerPolicyIgnoresForbiddenDecisions(t *testing.T) {
+	inner := &countingPolicy{decision: &forbidden{}}
+	policy := NewCircuitBreakerAssumeRolePolicy("namespace", inner, CircuitBreakerConfig{ConsecutiveFailures: 1, OpenTimeout: time.Minute}, logging.Discard())
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+	for i := 0; i < 5; i++ {
+		decision, _ := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+		if _, ok := decision.(*forbidden); !ok {
+			t.Fatalf("expected inner forbidden decision, was %T", decision)
+		}
+	}
+}
+
+func TestCircuitBreakerPolicyRecovers(t *testing.T) {
+	inner := &countingPolicy{err: fmt.Errorf("namespace lookup timed out")}
+	policy := NewCircuitBreakerAssumeRolePolicy("namespace", inner, CircuitBreakerConfig{ConsecutiveFailures: 1, OpenTimeout: 10 * time.Millisecond}, logging.Discard())
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+	policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	decision, _ := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	if _, ok := decision.(*circuitOpen); !ok {
+		t.Fatalf("expected circuit to be open, was %T", decision)
+	}
+
+	inner.err = nil
+	inner.decision = &allowed{}
+	time.Sleep(20 * time.Millisecond)
+
+	decision, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	if err != nil || !decision.IsAllowed() {
+		t.Error("expected inner policy to be called after timeout")
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ClusterRolePermissionPolicy permits pods to assume roles granted to their
+// namespace by ClusterRolePermission resources, independently of the
+// namespace's annotation. Role regexps must match the whole role ARN. Use
+// NamespacePermittedRoleNamePolicy's SetClusterRolePermissions to permit roles
+// with either the annotation or the permissions.
+type ClusterRolePermissionPolicy struct {
+	permissions k8s.ClusterRolePermissionFinder
+	namespaces  k8s.NamespaceFinder
+	resolver    sts.ARNResolver
+
+	// expressions caches compiled role regexps, permissions are few and
+	// rarely change
+	expressions sync.Map
+}
+
+func NewClusterRolePermissionPolicy(permissions k8s.ClusterRolePermissionFinder, namespaces k8s.NamespaceFinder, resolver sts.ARNResolver) *ClusterRolePermissionPolicy {
+	return &ClusterRolePermissionPolicy{permissions: permissions, namespaces: namespaces, resolver: resolver}
+}
+
+func (p *ClusterRolePermissionPolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	resolved, err := p.resolver.Resolve(role)
+	if err != nil {
+		return nil, err
+	}
+
+	ns, err := p.namespaces.FindNamespace(ctx, pod.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	if ns == nil {
+		return &clusterPermissionForbidden{role: resolved.ARN.String(), namespace: pod.GetNamespace()}, nil
+	}
+
+	for _, permission := range p.permissions.ClusterRolePermissions() {
+		if !permission.Matches(ns) {
+			continue
+		}
+		for _, expression := range permission.Roles {
+			permitted, err := p.matches(expression, resolved.ARN.String())
+			if err != nil {
+				return nil, fmt.Errorf("clusterrolepermission %s: %w", permission.Name, err)
+			}
+			if permitted {
+				return &allowed{}, nil
+			}
+		}
+	}
+
+	return &clusterPermissionForbidden{role: resolved.ARN.String(), namespace: pod.GetNamespace()}, nil
+}
+
+// matches returns true if any of the expression's regexps match the whole arn
+func (p *ClusterRolePermissionPolicy) matches(expression, arn string) (bool, error) {
+	var expressions []*regexp.Regexp
+	if cached, ok := p.expressions.Load(expression); ok {
+		expressions = cached.([]*regexp.Regexp)
+	} else {
+		parts, err := splitExpressions(expression, DefaultExpressionDelimiter)
+		if err != nil {
+			return false, err
+		}
+		for _, part := range parts {
+			re, err := regexp.Compile("^" + part + "$")
+			if err != nil {
+				return false, err
+			}
+			expressions = append(expressions, re)
+		}
+		p.expressions.Store(expression, expressions)
+	}
+
+	for _, re := range expressions {
+		if re.MatchString(arn) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type clusterPermissionForbidden struct {
+	role      string
+	namespace string
+}
+
+func (f *clusterPermissionForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *clusterPermissionForbidden) Explanation() string {
+	return fmt.Sprintf("no clusterrolepermission grants role '%s' to namespace '%s'", f.role, f.namespace)
+}
+
+func (f *clusterPermissionForbidden) Reason() DenialReason {
+	return ReasonClusterPermission
+}
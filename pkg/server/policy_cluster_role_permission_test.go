@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	kt "github.com/uswitch/kiam/pkg/k8s/testing"
+	"github.com/uswitch/kiam/pkg/testutil"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestClusterRolePermissionPolicy(t *testing.T) {
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+	permissions := clusterRolePermissions{
+		{Name: "logging", NamespaceSelector: labels.Everything(), Roles: []string{"arn:aws:iam::123456789012:role/logging"}},
+		{Name: "teams", NamespaceSelector: labels.SelectorFromSet(labels.Set{"team": "data"}), Roles: []string{".*/data_.*|.*/ml_.*"}},
+	}
+
+	var tests = []struct {
+		name     string
+		labels   map[string]string
+		role     string
+		expected bool
+	}{
+		{"shared role", nil, "logging", true},
+		{"shared role prefix", nil, "logging-admin", false},
+		{"selected namespace", map[string]string{"team": "data"}, "ml_role", true},
+		{"unselected namespace", map[string]string{"team": "web"}, "data_role", false},
+		{"ungranted role", map[string]string{"team": "data"}, "red_role", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := testutil.NewNamespace("red", "")
+			ns.Labels = tt.labels
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, tt.role)
+
+			policy := NewClusterRolePermissionPolicy(permissions, kt.NewNamespaceFinder(ns), arnResolver)
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), tt.role, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+			if !tt.expected && decision.Reason() != ReasonClusterPermission {
+				t.Error("unexpected reason", decision.Reason())
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/uswitch/kiam/pkg/logging"
+)
+
+// PolicyConfigurer is implemented by policies that can describe their
+// configuration, allowing the active policies to be inspected.
+type PolicyConfigurer interface {
+	PolicyConfig() map[string]interface{}
+}
+
+// PolicyDescriptor describes a policy in a CompositeAssumeRolePolicy
+type PolicyDescriptor struct {
+	Name   string                 `json:"name"`
+	Type   string                 `json:"type"`
+	Config map[string]interface{} `json:"config,omitempty"`
+}
+
+// Descriptors describes each of the composite's policies. Wrapping policies
+// (such as PolicyMetrics) are described by the policy they wrap.
+func (p *CompositeAssumeRolePolicy) Descriptors() []PolicyDescriptor {
+	descriptors := []PolicyDescriptor{}
+	for _, policy := range p.snapshot() {
+		descriptors = append(descriptors, describePolicy(policy))
+	}
+	return descriptors
+}
+
+// MarshalJSON encodes the composite's policies as an array of PolicyDescriptor
+func (p *CompositeAssumeRolePolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Descriptors())
+}
+
+// UnmarshalPolicyDescriptors decodes policies encoded by MarshalJSON
+func UnmarshalPolicyDescriptors(data []byte) ([]PolicyDescriptor, error) {
+	var descriptors []PolicyDescriptor
+	if err := json.Unmarshal(data, &descriptors); err != nil {
+		return nil, err
+	}
+	return descriptors, nil
+}
+
+// PolicyConfig describes how the composite combines its policies, and the
+// policies themselves.
+func (p *CompositeAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"mode":     p.mode.String(),
+		"policies": p.Descriptors(),
+	}
+}
+
+func describePolicy(policy AssumeRolePolicy) PolicyDescriptor {
+	name := policyName(policy)
+	for {
+		wrapped, ok := policy.(wrappedPolicy)
+		if !ok {
+			break
+		}
+		policy = wrapped.unwrap()
+	}
+
+	descriptor := PolicyDescriptor{Name: name, Type: policyTypeName(policy)}
+	if configurer, ok := policy.(PolicyConfigurer); ok {
+		descriptor.Config = configurer.PolicyConfig()
+	}
+	return descriptor
+}
+
+// policiesHandler serves the composite's policy descriptors as JSON
+type policiesHandler struct {
+	policy *CompositeAssumeRolePolicy
+}
+
+func (h *policiesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.policy); err != nil {
+		logging.FromContext(r.Context()).Error("error writing policies", "error", err)
+	}
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompositePolicyMarshalsDescriptors(t *testing.T) {
+	policy := Policies(
+		NamedPolicy("prefix", NewPodAnnotationPrefixPolicy("iam.amazonaws.com/")),
+		NewPolicyMetrics(NewRateLimitingAssumeRolePolicy(2, 5, time.Minute)),
+		AnyOf(fakePolicy{decision: &allowed{}}),
+	)
+
+	data, err := json.Marshal(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	descriptors, err := UnmarshalPolicyDescriptors(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []PolicyDescriptor{
+		{Name: "prefix", Type: "PodAnnotationPrefixPolicy", Config: map[string]interface{}{"prefix": "iam.amazonaws.com/"}},
+		{Name: "RateLimitingAssumeRolePolicy", Type: "RateLimitingAssumeRolePolicy", Config: map[string]interface{}{"limit": 2.0, "burst": 5.0, "idleTTL": "1m0s"}},
+		{Name: "CompositeAssumeRolePolicy", Type: "CompositeAssumeRolePolicy", Config: map[string]interface{}{
+			"mode": "anyOf",
+			"policies": []interface{}{
+				map[string]interface{}{"name": "fakePolicy", "type": "fakePolicy"},
+			},
+		}},
+	}
+	if !reflect.DeepEqual(descriptors, expected) {
+		t.Errorf("unexpected descriptors: %s", data)
+	}
+}
+
+func TestPoliciesHandlerServesDescriptors(t *testing.T) {
+	policy := Policies(NewPodAnnotationPrefixPolicy("iam.amazonaws.com/"))
+
+	rr := httptest.NewRecorder()
+	(&policiesHandler{policy: policy}).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/policies", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Error("unexpected status", rr.Code)
+	}
+	descriptors, err := UnmarshalPolicyDescriptors(rr.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(descriptors) != 1 || descriptors[0].Type != "PodAnnotationPrefixPolicy" {
+		t.Errorf("unexpected descriptors: %+v", descriptors)
+	}
+}
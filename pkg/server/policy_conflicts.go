@@ -0,0 +1,251 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	"github.com/uswitch/kiam/pkg/logging"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// PolicyConflict is a pair of ClusterRolePermissions that may grant the same
+// roles to the same namespaces
+type PolicyConflict struct {
+	First          string   `json:"first"`
+	Second         string   `json:"second"`
+	FirstSelector  string   `json:"firstSelector"`
+	SecondSelector string   `json:"secondSelector"`
+	Roles          []string `json:"roles"`
+}
+
+func (c PolicyConflict) key() string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s", c.First, c.Second, c.FirstSelector, c.SecondSelector, strings.Join(c.Roles, ","))
+}
+
+// NamespacePolicyConflictDetector warns about ClusterRolePermissions granting
+// overlapping permissions, which are often accidental. Permissions overlap
+// when their namespace selectors can match the same namespace and their role
+// regexps can match the same role.
+//
+// Overlap is detected from the permissions' fields, rather than the
+// namespaces and roles that exist. Regexps overlap when either matches the
+// other's source, e.g. `data_.*` and `data_reader`, or `.*` and `data_.*`;
+// other regexps that match the same roles aren't reported. Selectors overlap
+// unless they have contradictory requirements for a label.
+type NamespacePolicyConflictDetector struct {
+	permissions k8s.ClusterRolePermissionFinder
+	updates     <-chan struct{}
+
+	mu        sync.Mutex
+	conflicts []PolicyConflict
+	reported  map[string]bool
+}
+
+// NewNamespacePolicyConflictDetector creates the detector checking the
+// permissions when it runs, and again after each receive from updates
+func NewNamespacePolicyConflictDetector(permissions k8s.ClusterRolePermissionFinder, updates <-chan struct{}) *NamespacePolicyConflictDetector {
+	return &NamespacePolicyConflictDetector{permissions: permissions, updates: updates, reported: map[string]bool{}}
+}
+
+// Run checks the permissions, then checks again on updates until ctx is done
+func (d *NamespacePolicyConflictDetector) Run(ctx context.Context) error {
+	d.Detect(ctx)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-d.updates:
+				d.Detect(ctx)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Detect checks the permissions, logging a warning for each conflict that
+// hasn't already been reported
+func (d *NamespacePolicyConflictDetector) Detect(ctx context.Context) []PolicyConflict {
+	conflicts := FindPolicyConflicts(d.permissions.ClusterRolePermissions())
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	reported := map[string]bool{}
+	for _, conflict := range conflicts {
+		key := conflict.key()
+		reported[key] = true
+		if d.reported[key] {
+			continue
+		}
+		logging.FromContext(ctx).Warn("clusterrolepermissions overlap",
+			"clusterrolepermission", conflict.First,
+			"clusterrolepermission.other", conflict.Second,
+			"namespaceSelector", conflict.FirstSelector,
+			"namespaceSelector.other", conflict.SecondSelector,
+			"roles", strings.Join(conflict.Roles, ", "))
+	}
+	d.reported = reported
+	d.conflicts = conflicts
+
+	return conflicts
+}
+
+// Conflicts returns the conflicts found when the permissions were last checked
+func (d *NamespacePolicyConflictDetector) Conflicts() []PolicyConflict {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.conflicts
+}
+
+// FindPolicyConflicts returns the overlapping pairs of permissions
+func FindPolicyConflicts(permissions []*k8s.ClusterRolePermission) []PolicyConflict {
+	conflicts := []PolicyConflict{}
+	for i, first := range permissions {
+		for _, second := range permissions[i+1:] {
+			if !selectorsOverlap(first.NamespaceSelector, second.NamespaceSelector) {
+				continue
+			}
+			roles := overlappingRoles(first.Roles, second.Roles)
+			if len(roles) == 0 {
+				continue
+			}
+			conflicts = append(conflicts, PolicyConflict{
+				First:          first.Name,
+				Second:         second.Name,
+				FirstSelector:  first.NamespaceSelector.String(),
+				SecondSelector: second.NamespaceSelector.String(),
+				Roles:          roles,
+			})
+		}
+	}
+	return conflicts
+}
+
+// overlappingRoles returns the role regexps of first that overlap with those
+// of second, formatted as "first ~ second"
+func overlappingRoles(first, second []string) []string {
+	var overlaps []string
+	for _, a := range rolePatterns(first) {
+		for _, b := range rolePatterns(second) {
+			if a == b || fullMatch(a, b) || fullMatch(b, a) {
+				overlaps = append(overlaps, fmt.Sprintf("%s ~ %s", a, b))
+			}
+		}
+	}
+	return overlaps
+}
+
+// rolePatterns splits the permission's role expressions into their regexps.
+// Expressions that can't be split are kept whole.
+func rolePatterns(expressions []string) []string {
+	var patterns []string
+	for _, expression := range expressions {
+		parts, err := splitExpressions(expression, DefaultExpressionDelimiter)
+		if err != nil {
+			parts = []string{expression}
+		}
+		patterns = append(patterns, parts...)
+	}
+	return patterns
+}
+
+// fullMatch returns true if the regexp pattern matches the whole of text
+func fullMatch(pattern, text string) bool {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return false
+	}
+	return re.MatchString(text)
+}
+
+// selectorsOverlap returns false when no set of labels can match both
+// selectors
+func selectorsOverlap(first, second labels.Selector) bool {
+	firstRequirements, selectable := first.Requirements()
+	if !selectable {
+		return false
+	}
+	secondRequirements, selectable := second.Requirements()
+	if !selectable {
+		return false
+	}
+
+	byKey := map[string][]labels.Requirement{}
+	for _, requirements := range []labels.Requirements{firstRequirements, secondRequirements} {
+		for _, requirement := range requirements {
+			byKey[requirement.Key()] = append(byKey[requirement.Key()], requirement)
+		}
+	}
+
+	for _, requirements := range byKey {
+		if !satisfiable(requirements) {
+			return false
+		}
+	}
+	return true
+}
+
+// satisfiable returns whether a value, or the label's absence, can meet all
+// the requirements for a label. Numeric comparisons are assumed satisfiable.
+func satisfiable(requirements []labels.Requirement) bool {
+	var (
+		present  bool
+		absent   bool
+		allowed  sets.String
+		excluded = sets.NewString()
+	)
+
+	for _, requirement := range requirements {
+		switch requirement.Operator() {
+		case selection.In, selection.Equals, selection.DoubleEquals:
+			present = true
+			if allowed == nil {
+				allowed = requirement.Values()
+			} else {
+				allowed = allowed.Intersection(requirement.Values())
+			}
+		case selection.NotIn, selection.NotEquals:
+			excluded = excluded.Union(requirement.Values())
+		case selection.Exists, selection.GreaterThan, selection.LessThan:
+			present = true
+		case selection.DoesNotExist:
+			absent = true
+		}
+	}
+
+	if present && absent {
+		return false
+	}
+	if allowed != nil && allowed.Difference(excluded).Len() == 0 {
+		return false
+	}
+	return true
+}
+
+// policyConflictsHandler serves the detector's conflicts as JSON
+type policyConflictsHandler struct {
+	detector *NamespacePolicyConflictDetector
+}
+
+func (h *policyConflictsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conflicts := h.detector.Conflicts()
+	if conflicts == nil {
+		conflicts = []PolicyConflict{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(conflicts); err != nil {
+		logging.FromContext(r.Context()).Error("error writing policy conflicts", "error", err)
+	}
+}
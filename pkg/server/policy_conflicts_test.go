@@ -0,0 +1,98 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	"github.com/uswitch/kiam/pkg/logging"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func mustSelector(t *testing.T, selector string) labels.Selector {
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestFindPolicyConflicts(t *testing.T) {
+	var tests = []struct {
+		name           string
+		firstSelector  string
+		firstRoles     []string
+		secondSelector string
+		secondRoles    []string
+		expectedRoles  []string
+	}{
+		{"same role everywhere", "", []string{"logging"}, "", []string{"logging"}, []string{"logging ~ logging"}},
+		{"pattern matches role", "team=data", []string{"data_.*"}, "team in (data,ml)", []string{"data_reader"}, []string{"data_.* ~ data_reader"}},
+		{"wider pattern", "", []string{"red|.*"}, "env=prod", []string{"data_.*"}, []string{".* ~ data_.*"}},
+		{"different roles", "", []string{"data_.*"}, "", []string{"web_.*"}, nil},
+		{"different label values", "team=data", []string{"data_.*"}, "team=web", []string{"data_.*"}, nil},
+		{"excluded label value", "team=data", []string{"data_.*"}, "team!=data", []string{"data_.*"}, nil},
+		{"label must not exist", "team", []string{"data_.*"}, "!team", []string{"data_.*"}, nil},
+		{"disjoint label sets", "team in (data,ml)", []string{"data_.*"}, "team notin (data,ml)", []string{"data_.*"}, nil},
+		{"different labels", "team=data", []string{"data_.*"}, "env=prod", []string{"data_.*"}, []string{"data_.* ~ data_.*"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			permissions := []*k8s.ClusterRolePermission{
+				{Name: "first", NamespaceSelector: mustSelector(t, tt.firstSelector), Roles: tt.firstRoles},
+				{Name: "second", NamespaceSelector: mustSelector(t, tt.secondSelector), Roles: tt.secondRoles},
+			}
+
+			conflicts := FindPolicyConflicts(permissions)
+			if len(tt.expectedRoles) == 0 {
+				if len(conflicts) != 0 {
+					t.Errorf("expected no conflicts, was %+v", conflicts)
+				}
+				return
+			}
+			if len(conflicts) != 1 || conflicts[0].First != "first" || conflicts[0].Second != "second" {
+				t.Fatalf("expected conflict between first and second, was %+v", conflicts)
+			}
+			if strings.Join(conflicts[0].Roles, ",") != strings.Join(tt.expectedRoles, ",") {
+				t.Errorf("expected overlapping roles %v, was %v", tt.expectedRoles, conflicts[0].Roles)
+			}
+		})
+	}
+}
+
+func TestPolicyConflictDetectorReportsConflictsOnce(t *testing.T) {
+	permissions := clusterRolePermissions{
+		{Name: "data", NamespaceSelector: labels.Everything(), Roles: []string{"data_.*"}},
+		{Name: "reader", NamespaceSelector: labels.Everything(), Roles: []string{"data_reader"}},
+	}
+	detector := NewNamespacePolicyConflictDetector(permissions, nil)
+
+	var buf bytes.Buffer
+	ctx := logging.WithLogger(context.Background(), slog.New(slog.NewTextHandler(&buf, nil)))
+	detector.Detect(ctx)
+	detector.Detect(ctx)
+
+	if count := strings.Count(buf.String(), "clusterrolepermissions overlap"); count != 1 {
+		t.Fatalf("expected conflict to be logged once, was logged %d times: %s", count, buf.String())
+	}
+	if !strings.Contains(buf.String(), "clusterrolepermission=data") || !strings.Contains(buf.String(), "clusterrolepermission.other=reader") {
+		t.Error("expected log to name both permissions", buf.String())
+	}
+
+	w := httptest.NewRecorder()
+	(&policyConflictsHandler{detector: detector}).ServeHTTP(w, httptest.NewRequest("GET", "/debug/policy-conflicts", nil))
+
+	var conflicts []PolicyConflict
+	if err := json.NewDecoder(w.Body).Decode(&conflicts); err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || conflicts[0].First != "data" || conflicts[0].Second != "reader" {
+		t.Errorf("unexpected conflicts %+v", conflicts)
+	}
+}
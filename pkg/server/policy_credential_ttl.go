@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+// CredentialTTLAssumeRolePolicy forbids requests while the credentials cached
+// for the requested role expire within the minimum TTL, so that pods don't
+// receive credentials that expire during long running AWS API calls. Callers
+// retry once fresh credentials have been fetched. Requests for roles without
+// cached credentials are allowed.
+type CredentialTTLAssumeRolePolicy struct {
+	credentials sts.CredentialsExpiration
+	resolver    sts.ARNResolver
+	options     k8s.RoleIdentityOptions
+	minimum     time.Duration
+	clock       ClockFunc
+}
+
+func NewCredentialTTLAssumeRolePolicy(credentials sts.CredentialsExpiration, resolver sts.ARNResolver, minimum time.Duration, clock ClockFunc) *CredentialTTLAssumeRolePolicy {
+	return &CredentialTTLAssumeRolePolicy{credentials: credentials, resolver: resolver, minimum: minimum, clock: clock}
+}
+
+// SetRoleIdentityOptions controls how the identities whose credentials are
+// checked are built
+func (p *CredentialTTLAssumeRolePolicy) SetRoleIdentityOptions(options k8s.RoleIdentityOptions) {
+	p.options = options
+}
+
+// PolicyConfig describes the minimum TTL
+func (p *CredentialTTLAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	return map[string]interface{}{"minimum": p.minimum.String()}
+}
+
+func (p *CredentialTTLAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	identity, err := k8s.PodRoleIdentity(p.resolver, role, pod, p.options)
+	if err != nil {
+		return nil, err
+	}
+
+	expiration, ok := p.credentials.Expiration(identity)
+	if !ok {
+		return &allowed{}, nil
+	}
+
+	remaining := expiration.Sub(p.clock())
+	if remaining < p.minimum {
+		return &credentialTTLForbidden{role: identity.Role.ARN.String(), remaining: remaining, minimum: p.minimum}, nil
+	}
+
+	return &allowed{}, nil
+}
+
+type credentialTTLForbidden struct {
+	role      string
+	remaining time.Duration
+	minimum   time.Duration
+}
+
+func (f *credentialTTLForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *credentialTTLForbidden) Explanation() string {
+	return fmt.Sprintf("cached credentials for role '%s' expire in %s, less than the minimum %s", f.role, f.remaining.Round(time.Second), f.minimum)
+}
+
+func (f *credentialTTLForbidden) Reason() DenialReason {
+	return ReasonCredentialTTL
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+type fakeCredentialsExpiration map[string]time.Time
+
+func (f fakeCredentialsExpiration) Expiration(identity *sts.RoleIdentity) (time.Time, bool) {
+	expiration, ok := f[identity.Role.ARN.String()]
+	return expiration, ok
+}
+
+func TestCredentialTTLPolicy(t *testing.T) {
+	now := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	var tests = []struct {
+		name     string
+		cached   fakeCredentialsExpiration
+		expected bool
+	}{
+		{"NotCached", fakeCredentialsExpiration{}, true},
+		{"FreshCredentials", fakeCredentialsExpiration{"arn:aws:iam::123456789012:role/red_role": now.Add(time.Hour)}, true},
+		{"ExpiringCredentials", fakeCredentialsExpiration{"arn:aws:iam::123456789012:role/red_role": now.Add(2 * time.Minute)}, false},
+		{"ExpiredCredentials", fakeCredentialsExpiration{"arn:aws:iam::123456789012:role/red_role": now.Add(-time.Minute)}, false},
+		{"OtherRoleExpiring", fakeCredentialsExpiration{"arn:aws:iam::123456789012:role/blue_role": now.Add(time.Minute)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+			policy := NewCredentialTTLAssumeRolePolicy(tt.cached, resolver, 5*time.Minute, clock)
+
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+			if !tt.expected && decision.Reason() != ReasonCredentialTTL {
+				t.Error("unexpected reason", decision.Reason())
+			}
+		})
+	}
+}
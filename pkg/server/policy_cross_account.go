@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	"github.com/uswitch/kiam/pkg/logging"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+var accountIDPattern = regexp.MustCompile(`^[0-9]{12}$`)
+
+// CrossAccountRoleValidator forbids assuming roles in AWS accounts that aren't
+// allowed, protecting against annotations naming roles in external accounts.
+// Allowed accounts can be reloaded from a ConfigMap: each data entry contains
+// account IDs separated by whitespace or commas, lines starting with # are
+// ignored.
+type CrossAccountRoleValidator struct {
+	resolver sts.ARNResolver
+	watcher  *k8s.ConfigMapWatcher
+	logger   *slog.Logger
+	changeNotifier
+
+	mu       sync.RWMutex
+	accounts map[string]bool
+}
+
+// NewCrossAccountRoleValidator creates the policy allowing roles in
+// allowedAccounts.
+func NewCrossAccountRoleValidator(resolver sts.ARNResolver, allowedAccounts []string) (*CrossAccountRoleValidator, error) {
+	accounts := map[string]bool{}
+	for _, account := range allowedAccounts {
+		if !accountIDPattern.MatchString(account) {
+			return nil, fmt.Errorf("invalid account id '%s'", account)
+		}
+		accounts[account] = true
+	}
+	return &CrossAccountRoleValidator{resolver: resolver, logger: slog.Default(), accounts: accounts}, nil
+}
+
+// NewCrossAccountRoleValidatorFromConfigMap creates the policy watching the
+// ConfigMap from source for the allowed accounts. Run must be called to start
+// watching.
+func NewCrossAccountRoleValidatorFromConfigMap(resolver sts.ARNResolver, source cache.ListerWatcher, syncInterval time.Duration) *CrossAccountRoleValidator {
+	p := &CrossAccountRoleValidator{resolver: resolver, logger: slog.Default(), accounts: map[string]bool{}}
+	p.watcher = k8s.NewConfigMapWatcher(source, syncInterval, p.update)
+	return p
+}
+
+// Run starts watching the ConfigMap. Blocks until the accounts have been loaded
+func (p *CrossAccountRoleValidator) Run(ctx context.Context) error {
+	if p.watcher == nil {
+		return fmt.Errorf("allowed accounts aren't loaded from a configmap")
+	}
+	p.logger = logging.FromContext(ctx)
+	return p.watcher.Run(ctx)
+}
+
+// HealthCheck reports whether the ConfigMap has been loaded
+func (p *CrossAccountRoleValidator) HealthCheck() (HealthStatus, error) {
+	if p.watcher != nil && !p.watcher.HasSynced() {
+		return HealthStatus{Healthy: false, Message: "allowed accounts configmap not loaded"}, nil
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return HealthStatus{Healthy: true, Message: fmt.Sprintf("%d allowed accounts", len(p.accounts))}, nil
+}
+
+// PolicyConfig describes the allowed accounts
+func (p *CrossAccountRoleValidator) PolicyConfig() map[string]interface{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	accounts := make([]string, 0, len(p.accounts))
+	for account := range p.accounts {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	return map[string]interface{}{"allowedAccounts": accounts}
+}
+
+func (p *CrossAccountRoleValidator) update(data map[string]string) {
+	accounts := map[string]bool{}
+
+	for key, value := range data {
+		for _, line := range strings.Split(value, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			for _, account := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+				if !accountIDPattern.MatchString(account) {
+					p.logger.Warn("ignoring invalid account id", "configmap.key", key, "account", account)
+					continue
+				}
+				accounts[account] = true
+			}
+		}
+	}
+
+	p.mu.Lock()
+	p.accounts = accounts
+	p.mu.Unlock()
+	p.notify()
+
+	p.logger.Info("loaded allowed accounts", "accounts", len(accounts))
+}
+
+func (p *CrossAccountRoleValidator) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	resolved, err := p.resolver.Resolve(role)
+	if err != nil {
+		return nil, err
+	}
+	arn := resolved.ARN
+
+	p.mu.RLock()
+	permitted := p.accounts[arn.AccountID]
+	p.mu.RUnlock()
+
+	if !permitted {
+		return &crossAccountForbidden{role: arn.String(), account: arn.AccountID}, nil
+	}
+
+	return &allowed{}, nil
+}
+
+type crossAccountForbidden struct {
+	role    string
+	account string
+}
+
+func (f *crossAccountForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *crossAccountForbidden) Explanation() string {
+	return fmt.Sprintf("role '%s' is in account '%s' which isn't allowed", f.role, f.account)
+}
+
+func (f *crossAccountForbidden) Reason() DenialReason {
+	return ReasonCrossAccount
+}
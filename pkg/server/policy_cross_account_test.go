@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/testutil"
+	kt "k8s.io/client-go/tools/cache/testing"
+)
+
+func TestCrossAccountRoleValidator(t *testing.T) {
+	resolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+	policy, err := NewCrossAccountRoleValidator(resolver, []string{"123456789012", "210987654321"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+	var tests = []struct {
+		name     string
+		role     string
+		expected bool
+	}{
+		{"ResolvedInBaseAccount", "red_role", true},
+		{"AllowedAccount", "arn:aws:iam::210987654321:role/red_role", true},
+		{"ExternalAccount", "arn:aws:iam::999999999999:role/red_role", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), tt.role, pod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+			if !tt.expected && decision.Reason() != ReasonCrossAccount {
+				t.Error("unexpected reason", decision.Reason())
+			}
+		})
+	}
+}
+
+func TestCrossAccountRoleValidatorRejectsInvalidAccounts(t *testing.T) {
+	if _, err := NewCrossAccountRoleValidator(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), []string{"1234"}); err == nil {
+		t.Error("expected error for invalid account id")
+	}
+}
+
+func TestCrossAccountRoleValidatorReloadsConfigMap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := kt.NewFakeControllerSource()
+	defer source.Shutdown()
+	source.Add(testutil.NewConfigMap("kube-system", "kiam-accounts", map[string]string{
+		"accounts": "# production\n123456789012, 210987654321\ninvalid\n",
+	}))
+
+	policy := NewCrossAccountRoleValidatorFromConfigMap(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), source, time.Minute)
+	if err := policy.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	pod := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	eventuallyAllowed(t, policy, policy, "arn:aws:iam::210987654321:role/red_role", pod, true)
+
+	source.Modify(testutil.NewConfigMap("kube-system", "kiam-accounts", map[string]string{
+		"accounts": "123456789012",
+	}))
+	eventuallyAllowed(t, policy, policy, "arn:aws:iam::210987654321:role/red_role", pod, false)
+	eventuallyAllowed(t, policy, policy, "red_role", pod, true)
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/logging"
+	"gopkg.in/fsnotify.v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// DenyListAssumeRolePolicy forbids any pod from assuming roles matching a set
+// of ARN patterns. Patterns are resolved in the same way as requested roles
+// and may contain * and ? wildcards.
+type DenyListAssumeRolePolicy struct {
+	resolver sts.ARNResolver
+	path     string
+	changeNotifier
+
+	mu       sync.RWMutex
+	patterns []string
+}
+
+// NewDenyListAssumeRolePolicy creates a policy denying roles matching any of
+// patterns.
+func NewDenyListAssumeRolePolicy(resolver sts.ARNResolver, patterns []string) (*DenyListAssumeRolePolicy, error) {
+	p := &DenyListAssumeRolePolicy{resolver: resolver}
+	compiled, err := p.compile(patterns)
+	if err != nil {
+		return nil, err
+	}
+	p.patterns = compiled
+	return p, nil
+}
+
+// NewDenyListAssumeRolePolicyFromFile creates a policy with patterns read from
+// path, one per line. Blank lines and lines starting with # are ignored. Run
+// watches the file for changes.
+func NewDenyListAssumeRolePolicyFromFile(resolver sts.ARNResolver, path string) (*DenyListAssumeRolePolicy, error) {
+	p := &DenyListAssumeRolePolicy{resolver: resolver, path: filepath.Clean(path)}
+	if err := p.read(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Run watches the deny list file, reloading it when changed. The watch stops
+// when ctx is cancelled, reloads are logged with ctx's logger.
+func (p *DenyListAssumeRolePolicy) Run(ctx context.Context) error {
+	if p.path == "" {
+		return fmt.Errorf("deny list wasn't loaded from a file")
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// watch the directory so that replaced files (such as mounted ConfigMaps)
+	// are noticed
+	if err := w.Add(filepath.Dir(p.path)); err != nil {
+		w.Close()
+		return err
+	}
+
+	go p.watch(ctx, w)
+	return nil
+}
+
+func (p *DenyListAssumeRolePolicy) watch(ctx context.Context, w *fsnotify.Watcher) {
+	defer w.Close()
+	logger := logging.FromContext(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if err := p.read(); err != nil {
+				logger.Error("error reloading deny list", "error", err)
+				continue
+			}
+			logger.Info("reloaded deny list", "denylist.patterns", p.size())
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			logger.Error("deny list watch error", "error", err)
+		}
+	}
+}
+
+// read loads the patterns from the file. The current patterns are kept if
+// the file can't be read or contains invalid patterns.
+func (p *DenyListAssumeRolePolicy) read() error {
+	contents, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("error reading deny list: %v", err)
+	}
+
+	var patterns []string
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+
+	compiled, err := p.compile(patterns)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.patterns = compiled
+	p.mu.Unlock()
+	p.notify()
+
+	return nil
+}
+
+func (p *DenyListAssumeRolePolicy) size() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.patterns)
+}
+
+func (p *DenyListAssumeRolePolicy) compile(patterns []string) ([]string, error) {
+	compiled := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		resolved, err := p.resolver.Resolve(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid deny list pattern '%s': %v", pattern, err)
+		}
+		compiled = append(compiled, resolved.ARN.String())
+	}
+	return compiled, nil
+}
+
+// PolicyConfig describes the denied patterns and the file they're read from
+func (p *DenyListAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	p.mu.RLock()
+	patterns := append([]string{}, p.patterns...)
+	p.mu.RUnlock()
+
+	config := map[string]interface{}{"patterns": patterns}
+	if p.path != "" {
+		config["path"] = p.path
+	}
+	return config
+}
+
+func (p *DenyListAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	requestedIdentity, err := p.resolver.Resolve(role)
+	if err != nil {
+		return nil, err
+	}
+	arn := requestedIdentity.ARN
+
+	p.mu.RLock()
+	patterns := p.patterns
+	p.mu.RUnlock()
+
+	for _, pattern := range patterns {
+		if arn.IsWildcardMatch(pattern) {
+			return &denyListed{role: arn.String(), pattern: pattern}, nil
+		}
+	}
+
+	return &allowed{}, nil
+}
+
+type denyListed struct {
+	role    string
+	pattern string
+}
+
+func (f *denyListed) IsAllowed() bool {
+	return false
+}
+
+func (f *denyListed) Explanation() string {
+	return fmt.Sprintf("role '%s' is forbidden by deny list pattern '%s'", f.role, f.pattern)
+}
+
+func (f *denyListed) Reason() DenialReason {
+	return ReasonDenyList
+}
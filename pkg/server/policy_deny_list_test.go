@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+func TestDenyListPolicy(t *testing.T) {
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+	policy, err := NewDenyListAssumeRolePolicy(arnResolver, []string{
+		"OrganizationAccountAccessRole",
+		"arn:aws:iam::*:role/admin/*",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name     string
+		role     string
+		expected bool
+	}{
+		{"Name", "OrganizationAccountAccessRole", false},
+		{"ARN", "arn:aws:iam::123456789012:role/OrganizationAccountAccessRole", false},
+		{"Wildcard", "arn:aws:iam::987654321098:role/admin/root", false},
+		{"WildcardName", "admin/root", false},
+		{"Prefix", "OrganizationAccountAccessRoleReadOnly", true},
+		{"Other", "red_role", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, tt.role)
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), tt.role, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+		})
+	}
+}
+
+func TestDenyListPolicyExplanation(t *testing.T) {
+	policy, _ := NewDenyListAssumeRolePolicy(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), []string{"admin/*"})
+
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "admin/root")
+	decision, _ := policy.IsAllowedAssumeRole(context.Background(), "admin/root", p)
+
+	if decision.Explanation() != "role 'arn:aws:iam::123456789012:role/admin/root' is forbidden by deny list pattern 'arn:aws:iam::123456789012:role/admin/*'" {
+		t.Error("unexpected explanation, was", decision.Explanation())
+	}
+}
+
+func TestDenyListPolicyReloadsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kiam-deny-list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "deny-list")
+	if err := ioutil.WriteFile(path, []byte("# never assumable\nred_role\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := NewDenyListAssumeRolePolicyFromFile(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := policy.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	red := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	eventuallyAllowed(t, policy, policy, "red_role", red, false)
+	eventuallyAllowed(t, policy, policy, "blue_role", red, true)
+
+	if err := ioutil.WriteFile(path, []byte("blue_role\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	eventuallyAllowed(t, policy, policy, "blue_role", red, false)
+	eventuallyAllowed(t, policy, policy, "red_role", red, true)
+}
+
+func TestDenyListPolicyMissingFile(t *testing.T) {
+	_, err := NewDenyListAssumeRolePolicyFromFile(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), "/does/not/exist")
+	if err == nil {
+		t.Error("expected error reading missing file")
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RenderDOT renders the policy, and the policies it combines, as a Graphviz
+// DOT directed graph, e.g. to be drawn with dot -Tpng. Each policy is a node
+// labelled with its name and type. CompositeAssumeRolePolicy nodes have edges
+// to their policies, numbered in the order they're checked, and
+// FallbackAssumeRolePolicy nodes have edges to their primary and secondary
+// policies. Wrapping policies (such as PolicyMetrics) are drawn as the policy
+// they wrap.
+func RenderDOT(p AssumeRolePolicy) string {
+	r := &dotRenderer{}
+	r.b.WriteString("digraph policies {\n")
+	r.b.WriteString("  node [shape=box];\n")
+	r.render(p)
+	r.b.WriteString("}\n")
+	return r.b.String()
+}
+
+type dotRenderer struct {
+	b     strings.Builder
+	nodes int
+}
+
+// render writes the node for policy, and its children, returning its id
+func (r *dotRenderer) render(policy AssumeRolePolicy) string {
+	name := policyName(policy)
+	for {
+		wrapped, ok := policy.(wrappedPolicy)
+		if !ok {
+			break
+		}
+		policy = wrapped.unwrap()
+	}
+
+	id := fmt.Sprintf("n%d", r.nodes)
+	r.nodes++
+
+	label := dotEscape(name)
+	if typeName := policyTypeName(policy); typeName != name {
+		label += fmt.Sprintf("\\n(%s)", dotEscape(typeName))
+	}
+
+	switch p := policy.(type) {
+	case *CompositeAssumeRolePolicy:
+		fmt.Fprintf(&r.b, "  %s [label=\"%s\\n%s\"];\n", id, label, p.mode)
+		for i, child := range p.snapshot() {
+			childID := r.render(child)
+			fmt.Fprintf(&r.b, "  %s -> %s [label=\"%d\"];\n", id, childID, i+1)
+		}
+	case *FallbackAssumeRolePolicy:
+		fmt.Fprintf(&r.b, "  %s [label=\"%s\"];\n", id, label)
+		primaryID := r.render(p.primary)
+		fmt.Fprintf(&r.b, "  %s -> %s [label=\"primary\"];\n", id, primaryID)
+		secondaryID := r.render(p.secondary)
+		fmt.Fprintf(&r.b, "  %s -> %s [label=\"secondary\", style=dashed];\n", id, secondaryID)
+	default:
+		fmt.Fprintf(&r.b, "  %s [label=\"%s\"];\n", id, label)
+	}
+
+	return id
+}
+
+// dotEscape escapes s to be used within a quoted DOT string
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// policiesDOTHandler serves the composite's policies as a DOT graph
+type policiesDOTHandler struct {
+	policy AssumeRolePolicy
+}
+
+func (h *policiesDOTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/vnd.graphviz")
+	w.Write([]byte(RenderDOT(h.policy)))
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRenderDOT(t *testing.T) {
+	policy := Policies(
+		&fakePolicy{},
+		NamedPolicy("namespace", Fallback(&fakePolicy{}, NewPolicyMetrics(&fakePolicy{}))),
+		AnyOf(NamedPolicy(`deny "admin"`, &fakePolicy{})),
+	)
+
+	expected := `digraph policies {
+  node [shape=box];
+  n0 [label="CompositeAssumeRolePolicy\nallOf"];
+  n1 [label="fakePolicy"];
+  n0 -> n1 [label="1"];
+  n2 [label="namespace\n(FallbackAssumeRolePolicy)"];
+  n3 [label="fakePolicy"];
+  n2 -> n3 [label="primary"];
+  n4 [label="fakePolicy"];
+  n2 -> n4 [label="secondary", style=dashed];
+  n0 -> n2 [label="2"];
+  n5 [label="CompositeAssumeRolePolicy\nanyOf"];
+  n6 [label="deny \"admin\"\n(fakePolicy)"];
+  n5 -> n6 [label="1"];
+  n0 -> n5 [label="3"];
+}
+`
+	if dot := RenderDOT(policy); dot != expected {
+		t.Errorf("unexpected dot:\n%s", dot)
+	}
+}
+
+func TestPoliciesDOTHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	(&policiesDOTHandler{policy: Policies(&fakePolicy{})}).ServeHTTP(w, httptest.NewRequest("GET", "/debug/policies.dot", nil))
+
+	if w.Header().Get("Content-Type") != "text/vnd.graphviz" {
+		t.Error("unexpected content type", w.Header().Get("Content-Type"))
+	}
+	if w.Body.String() != RenderDOT(Policies(&fakePolicy{})) {
+		t.Error("unexpected body", w.Body.String())
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	"github.com/uswitch/kiam/pkg/logging"
+	v1 "k8s.io/api/core/v1"
+)
+
+// DryRunAssumeRolePolicy calls another policy but never blocks requests. Denials
+// and errors are logged as warnings, allowing policies to be introduced without
+// causing outages.
+type DryRunAssumeRolePolicy struct {
+	policy AssumeRolePolicy
+}
+
+// DryRun wraps inner, logging what it would deny and allowing every request.
+func DryRun(inner AssumeRolePolicy) AssumeRolePolicy {
+	return &DryRunAssumeRolePolicy{policy: inner}
+}
+
+// Name returns the wrapped policy's name, so the policy can still be removed
+// from a CompositeAssumeRolePolicy by name.
+func (p *DryRunAssumeRolePolicy) Name() string {
+	return policyName(p.policy)
+}
+
+// PolicyConfig describes the policy run in dry run mode
+func (p *DryRunAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	return map[string]interface{}{"dryRun": true, "policy": describePolicy(p.policy)}
+}
+
+// HealthCheck reports that the policy isn't enforced, along with the health of
+// the wrapped policy when it implements HealthChecker.
+func (p *DryRunAssumeRolePolicy) HealthCheck() (HealthStatus, error) {
+	policy := p.policy
+	for {
+		wrapped, ok := policy.(wrappedPolicy)
+		if !ok {
+			break
+		}
+		policy = wrapped.unwrap()
+	}
+
+	checker, ok := policy.(HealthChecker)
+	if !ok {
+		return HealthStatus{Healthy: true, Message: "dry run, not enforced"}, nil
+	}
+
+	status, err := checker.HealthCheck()
+	if err != nil {
+		return status, err
+	}
+	status.Message = fmt.Sprintf("dry run, not enforced: %s", status.Message)
+	return status, nil
+}
+
+func (p *DryRunAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	decision, err := p.policy.IsAllowedAssumeRole(ctx, role, pod)
+
+	logger := logging.FromContext(ctx).With(k8s.PodAttrs(pod)...).With("pod.iam.requestedRole", role, "policy.name", p.Name())
+	switch {
+	case err != nil:
+		logger.Warn("dry run policy errored, allowing request", "error", err)
+	case !decision.IsAllowed():
+		logger.Warn("dry run policy would deny request, allowing", "policy.explanation", decision.Explanation(), "policy.reason", string(decision.Reason()))
+	}
+
+	return &allowed{}, nil
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/logging"
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+func TestDryRunPolicyAllowsRequests(t *testing.T) {
+	var tests = []struct {
+		name   string
+		policy AssumeRolePolicy
+		logged string
+	}{
+		{"Allowed", fakePolicy{decision: &allowed{}}, ""},
+		{"Forbidden", fakePolicy{decision: &forbidden{requested: "foo_role", annotated: "bar_role"}}, "would deny"},
+		{"Error", fakePolicy{err: errors.New("lookup failed")}, "lookup failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			ctx := logging.WithLogger(context.Background(), slog.New(slog.NewTextHandler(&buf, nil)))
+			pod := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "bar_role")
+
+			decision, err := DryRun(tt.policy).IsAllowedAssumeRole(ctx, "foo_role", pod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !decision.IsAllowed() {
+				t.Errorf("expected allowed to be true: %s", decision.Explanation())
+			}
+
+			if tt.logged == "" && buf.Len() > 0 {
+				t.Error("expected nothing logged, was", buf.String())
+			}
+			if tt.logged != "" && (!strings.Contains(buf.String(), tt.logged) || !strings.Contains(buf.String(), "level=WARN")) {
+				t.Error("expected warning to be logged, was", buf.String())
+			}
+		})
+	}
+}
+
+func TestDryRunPolicyHealth(t *testing.T) {
+	policy := Policies(
+		DryRun(fakePolicy{decision: &allowed{}}),
+		NewPolicyMetrics(DryRun(&fakeHealthChecker{status: HealthStatus{Healthy: false, Message: "webhook down"}})),
+	)
+
+	report := policy.HealthReport()
+	if report.Healthy {
+		t.Error("expected wrapped policy health to be reported")
+	}
+	if len(report.Policies) != 2 {
+		t.Fatalf("expected dry run policies to be reported, was %+v", report.Policies)
+	}
+	if report.Policies[0].Message != "dry run, not enforced" {
+		t.Error("unexpected message", report.Policies[0].Message)
+	}
+	if report.Policies[1].Message != "dry run, not enforced: webhook down" {
+		t.Error("unexpected message", report.Policies[1].Message)
+	}
+}
+
+func TestDryRunPoliciesByName(t *testing.T) {
+	policy := Policies(NamedPolicy("webhook", fakePolicy{decision: &forbidden{}}), fakePolicy{decision: &allowed{}})
+
+	if err := dryRunPolicies(policy, []string{"webhook"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := policy.policies[0].(*DryRunAssumeRolePolicy); !ok {
+		t.Error("expected named policy to be run in dry run mode")
+	}
+	if _, ok := policy.policies[1].(*DryRunAssumeRolePolicy); ok {
+		t.Error("expected other policies to be enforced")
+	}
+
+	if err := dryRunPolicies(policy, []string{"missing"}); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
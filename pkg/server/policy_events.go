@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// EventRoleForbidden is the reason of events recorded when a pod is
+	// forbidden from assuming a role
+	EventRoleForbidden = "KiamRoleForbidden"
+	// EventPolicyError is the reason of events recorded when a policy fails
+	// to decide whether a pod may assume a role
+	EventPolicyError = "KiamPolicyError"
+)
+
+// EventEmittingAssumeRolePolicy records a Warning event on the pod whenever
+// the wrapped policy forbids it from assuming a role or returns an error, so
+// that denials are visible with kubectl get events.
+type EventEmittingAssumeRolePolicy struct {
+	policy   AssumeRolePolicy
+	recorder record.EventRecorder
+}
+
+// NewEventEmittingAssumeRolePolicy wraps policy, recording events with
+// recorder.
+func NewEventEmittingAssumeRolePolicy(policy AssumeRolePolicy, recorder record.EventRecorder) *EventEmittingAssumeRolePolicy {
+	return &EventEmittingAssumeRolePolicy{policy: policy, recorder: recorder}
+}
+
+// Name returns the wrapped policy's name
+func (p *EventEmittingAssumeRolePolicy) Name() string {
+	return policyName(p.policy)
+}
+
+func (p *EventEmittingAssumeRolePolicy) unwrap() AssumeRolePolicy {
+	return p.policy
+}
+
+func (p *EventEmittingAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	decision, err := p.policy.IsAllowedAssumeRole(ctx, role, pod)
+	if err != nil {
+		p.recorder.Eventf(pod, v1.EventTypeWarning, EventPolicyError, "failed checking policy for role %q: %s", role, err)
+		return decision, err
+	}
+
+	if !decision.IsAllowed() {
+		p.recorder.Eventf(pod, v1.EventTypeWarning, EventRoleForbidden, "failed assuming role %q: %s", role, decision.Explanation())
+	}
+
+	return decision, nil
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/testutil"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestEventEmittingPolicy(t *testing.T) {
+	var tests = []struct {
+		name     string
+		policy   fakePolicy
+		expected string
+	}{
+		{"Allowed", fakePolicy{decision: &allowed{}}, ""},
+		{"Forbidden", fakePolicy{decision: &namespacePolicyForbidden{expression: "^blue.*$", role: "red_role"}}, "Warning KiamRoleForbidden failed assuming role \"red_role\": namespace policy expression '^blue.*$' forbids role 'red_role'"},
+		{"Error", fakePolicy{err: errors.New("namespace not found")}, "Warning KiamPolicyError failed checking policy for role \"red_role\": namespace not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(1)
+			policy := NewEventEmittingAssumeRolePolicy(tt.policy, recorder)
+			pod := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+			policy.IsAllowedAssumeRole(context.Background(), "red_role", pod)
+
+			select {
+			case event := <-recorder.Events:
+				if event != tt.expected {
+					t.Error("unexpected event, was", event)
+				}
+			default:
+				if tt.expected != "" {
+					t.Error("expected event", tt.expected)
+				}
+			}
+		})
+	}
+}
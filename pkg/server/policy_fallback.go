@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	"github.com/uswitch/kiam/pkg/logging"
+	v1 "k8s.io/api/core/v1"
+)
+
+// FallbackAssumeRolePolicy checks a primary policy, using a secondary policy's
+// decision only when the primary errors (such as when the namespace informer
+// is unavailable). Denials from the primary policy are returned as is.
+type FallbackAssumeRolePolicy struct {
+	primary   AssumeRolePolicy
+	secondary AssumeRolePolicy
+}
+
+// Fallback creates a policy checking primary, falling back to secondary when
+// primary returns an error.
+func Fallback(primary, secondary AssumeRolePolicy) AssumeRolePolicy {
+	return &FallbackAssumeRolePolicy{primary: primary, secondary: secondary}
+}
+
+// PolicyConfig describes the primary and secondary policies
+func (p *FallbackAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"primary":   describePolicy(p.primary),
+		"secondary": describePolicy(p.secondary),
+	}
+}
+
+func (p *FallbackAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	decision, err := p.primary.IsAllowedAssumeRole(ctx, role, pod)
+	if err == nil {
+		return decision, nil
+	}
+
+	logging.FromContext(ctx).With(k8s.PodAttrs(pod)...).Warn("primary policy errored, using fallback", "policy.primary", policyName(p.primary), "policy.secondary", policyName(p.secondary), "error", err)
+
+	fallback, fallbackErr := p.secondary.IsAllowedAssumeRole(ctx, role, pod)
+	if fallbackErr != nil {
+		return nil, fallbackErr
+	}
+
+	return &FallbackDecision{Decision: fallback, FallbackActive: true, PrimaryError: err}, nil
+}
+
+// FallbackDecision is returned by FallbackAssumeRolePolicy when the decision
+// was made by the secondary policy. It wraps the secondary's decision (when
+// the decision is an error), allowing errors.Is and errors.As to be used.
+type FallbackDecision struct {
+	Decision
+	// FallbackActive is true when the secondary policy made the decision
+	FallbackActive bool
+	// PrimaryError is the error returned by the primary policy
+	PrimaryError error
+}
+
+func (d *FallbackDecision) Error() string {
+	return d.Explanation()
+}
+
+func (d *FallbackDecision) Unwrap() error {
+	err, _ := d.Decision.(error)
+	return err
+}
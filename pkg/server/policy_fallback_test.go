@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+func TestFallbackPolicy(t *testing.T) {
+	deny := fakePolicy{decision: &forbidden{requested: "red_role", annotated: "blue_role"}}
+	allow := fakePolicy{decision: &allowed{}}
+	failing := fakePolicy{err: errors.New("informer unavailable")}
+
+	var tests = []struct {
+		name     string
+		policy   AssumeRolePolicy
+		expected bool
+		fallback bool
+		err      bool
+	}{
+		{"PrimaryAllows", Fallback(allow, deny), true, false, false},
+		{"PrimaryDenies", Fallback(deny, allow), false, false, false},
+		{"SecondaryAllows", Fallback(failing, allow), true, true, false},
+		{"SecondaryDenies", Fallback(failing, deny), false, true, false},
+		{"BothError", Fallback(failing, failing), false, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+			decision, err := tt.policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error to be %t, was %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+
+			fallback, ok := decision.(*FallbackDecision)
+			if ok != tt.fallback || (ok && !fallback.FallbackActive) {
+				t.Errorf("expected fallback to be %t, was %#v", tt.fallback, decision)
+			}
+		})
+	}
+}
+
+func TestFallbackDecisionWrapsSecondaryDecision(t *testing.T) {
+	policy := Policies(Fallback(fakePolicy{err: errors.New("informer unavailable")}, fakePolicy{decision: &forbidden{requested: "red_role", annotated: "blue_role"}}))
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+	decision, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Reason() != ReasonRoleMismatch {
+		t.Error("expected secondary decision's reason, was", decision.Reason())
+	}
+	if !errors.Is(decision.(error), ErrRoleMismatch) {
+		t.Error("expected to wrap secondary decision")
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// PolicyDeps are the dependencies of policies loaded from a file
+type PolicyDeps struct {
+	Pods       k8s.PodGetter
+	Namespaces k8s.NamespaceFinder
+	Resolver   sts.ARNResolver
+}
+
+// policyFile is the structure of files loaded by LoadPoliciesFromFile, e.g.
+//
+//	mode: allOf
+//	policies:
+//	- type: requestingAnnotatedRole
+//	- type: namespacePermittedRoleName
+//	  config:
+//	    strictRegexp: true
+//	- type: gracePeriod
+//	  name: terminating
+//	  config:
+//	    period: 30s
+type policyFile struct {
+	Mode     string            `json:"mode"`
+	Policies []policyFileEntry `json:"policies"`
+}
+
+type policyFileEntry struct {
+	Type   string          `json:"type"`
+	Name   string          `json:"name"`
+	Config json.RawMessage `json:"config"`
+}
+
+// policyFactory creates a policy of a type from its config
+type policyFactory func(deps PolicyDeps, config json.RawMessage) (AssumeRolePolicy, error)
+
+var policyFactories = map[string]policyFactory{
+	"annotationPrefix": func(deps PolicyDeps, config json.RawMessage) (AssumeRolePolicy, error) {
+		var c struct{ Prefix string }
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		return NewPodAnnotationPrefixPolicy(c.Prefix), nil
+	},
+	"crossAccount": func(deps PolicyDeps, config json.RawMessage) (AssumeRolePolicy, error) {
+		var c struct{ Accounts []string }
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		return NewCrossAccountRoleValidator(deps.Resolver, c.Accounts)
+	},
+	"denyList": func(deps PolicyDeps, config json.RawMessage) (AssumeRolePolicy, error) {
+		var c struct{ Roles []string }
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		return NewDenyListAssumeRolePolicy(deps.Resolver, c.Roles)
+	},
+	"gracePeriod": func(deps PolicyDeps, config json.RawMessage) (AssumeRolePolicy, error) {
+		var c struct{ Period string }
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		period, err := time.ParseDuration(c.Period)
+		if err != nil {
+			return nil, err
+		}
+		return NewGracePeriodAssumeRolePolicy(period, time.Now), nil
+	},
+	"maxSessionDuration": func(deps PolicyDeps, config json.RawMessage) (AssumeRolePolicy, error) {
+		return NewMaxSessionDurationAssumeRolePolicy(deps.Namespaces), nil
+	},
+	"namespacePermittedRoleName": func(deps PolicyDeps, config json.RawMessage) (AssumeRolePolicy, error) {
+		c := struct{ StrictRegexp bool }{StrictRegexp: true}
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		return NewNamespacePermittedRoleNamePolicy(c.StrictRegexp, deps.Namespaces, deps.Resolver), nil
+	},
+	"nonRoot": func(deps PolicyDeps, config json.RawMessage) (AssumeRolePolicy, error) {
+		var c struct{ Roles []string }
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		return NewPodSecurityContextAssumeRolePolicy(deps.Resolver, c.Roles)
+	},
+	"partition": func(deps PolicyDeps, config json.RawMessage) (AssumeRolePolicy, error) {
+		var c struct{ Partition string }
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		return NewARNPartitionPolicy(deps.Resolver, c.Partition)
+	},
+	"podPhase": func(deps PolicyDeps, config json.RawMessage) (AssumeRolePolicy, error) {
+		return NewPodPhaseAssumeRolePolicy(deps.Pods), nil
+	},
+	"rateLimit": func(deps PolicyDeps, config json.RawMessage) (AssumeRolePolicy, error) {
+		var c struct {
+			Limit float64
+			Burst int
+		}
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		return NewRateLimitingAssumeRolePolicy(rate.Limit(c.Limit), c.Burst, 10*time.Minute), nil
+	},
+	"requestingAnnotatedRole": func(deps PolicyDeps, config json.RawMessage) (AssumeRolePolicy, error) {
+		return NewRequestingAnnotatedRolePolicy(deps.Pods, deps.Resolver), nil
+	},
+	"roleSessionName": func(deps PolicyDeps, config json.RawMessage) (AssumeRolePolicy, error) {
+		return NewRoleSessionNamePolicy(deps.Namespaces), nil
+	},
+	"sessionTags": func(deps PolicyDeps, config json.RawMessage) (AssumeRolePolicy, error) {
+		return NewSessionTagAssumeRolePolicy(deps.Namespaces), nil
+	},
+	"timeWindow": func(deps PolicyDeps, config json.RawMessage) (AssumeRolePolicy, error) {
+		return NewTimeWindowAssumeRolePolicy(deps.Namespaces, time.Now), nil
+	},
+}
+
+// LoadPoliciesFromFile creates the policies listed in the YAML (or JSON) file
+// at path, combined according to the file's mode. The file is validated
+// against PolicyFileSchema before policies are created.
+func LoadPoliciesFromFile(path string, deps PolicyDeps) (*CompositeAssumeRolePolicy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := parsePolicies(data, deps)
+	if err != nil {
+		return nil, fmt.Errorf("error loading policies from %s: %v", path, err)
+	}
+	return policy, nil
+}
+
+// parsePolicies creates the policies described by a YAML (or JSON) policy
+// file
+func parsePolicies(data []byte, deps PolicyDeps) (*CompositeAssumeRolePolicy, error) {
+	data, err := yaml.ToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if errs := validateSchema(policyFileSchema, document, "$"); len(errs) > 0 {
+		messages := make([]string, len(errs))
+		for i, err := range errs {
+			messages[i] = err.Error()
+		}
+		return nil, fmt.Errorf("invalid policy file: %s", strings.Join(messages, "; "))
+	}
+
+	var file policyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	policies := make([]AssumeRolePolicy, 0, len(file.Policies))
+	for i, entry := range file.Policies {
+		config := entry.Config
+		if len(config) == 0 {
+			config = json.RawMessage("{}")
+		}
+
+		policy, err := policyFactories[entry.Type](deps, config)
+		if err != nil {
+			return nil, fmt.Errorf("policies[%d] (%s): %v", i, entry.Type, err)
+		}
+		if entry.Name != "" {
+			policy = NamedPolicy(entry.Name, policy)
+		}
+		policies = append(policies, policy)
+	}
+
+	switch file.Mode {
+	case "anyOf":
+		return AnyOf(policies...), nil
+	case "allOfConcurrent":
+		return ConcurrentPolicies(policies...), nil
+	default:
+		return Policies(policies...), nil
+	}
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PolicyFileSchema is the JSON Schema of policy files loaded by
+// LoadPoliciesFromFile. Each policy's config is validated according to its
+// type.
+const PolicyFileSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "kiam policies",
+  "type": "object",
+  "additionalProperties": false,
+  "required": ["policies"],
+  "properties": {
+    "mode": {"enum": ["allOf", "anyOf", "allOfConcurrent"]},
+    "policies": {
+      "type": "array",
+      "minItems": 1,
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "required": ["type"],
+        "properties": {
+          "type": {"enum": [
+            "annotationPrefix", "crossAccount", "denyList", "gracePeriod",
+            "maxSessionDuration", "namespacePermittedRoleName", "nonRoot",
+            "partition", "podPhase", "rateLimit", "requestingAnnotatedRole",
+            "roleSessionName", "sessionTags", "timeWindow"
+          ]},
+          "name": {"type": "string", "minLength": 1},
+          "config": {"type": "object"}
+        },
+        "allOf": [
+          {
+            "if": {"properties": {"type": {"const": "annotationPrefix"}}},
+            "then": {"required": ["config"], "properties": {"config": {
+              "additionalProperties": false,
+              "required": ["prefix"],
+              "properties": {"prefix": {"type": "string", "minLength": 1}}
+            }}}
+          },
+          {
+            "if": {"properties": {"type": {"const": "crossAccount"}}},
+            "then": {"required": ["config"], "properties": {"config": {
+              "additionalProperties": false,
+              "required": ["accounts"],
+              "properties": {"accounts": {"type": "array", "minItems": 1, "items": {"type": "string", "pattern": "^[0-9]{12}$"}}}
+            }}}
+          },
+          {
+            "if": {"properties": {"type": {"const": "denyList"}}},
+            "then": {"required": ["config"], "properties": {"config": {
+              "additionalProperties": false,
+              "required": ["roles"],
+              "properties": {"roles": {"type": "array", "items": {"type": "string", "minLength": 1}}}
+            }}}
+          },
+          {
+            "if": {"properties": {"type": {"const": "gracePeriod"}}},
+            "then": {"required": ["config"], "properties": {"config": {
+              "additionalProperties": false,
+              "required": ["period"],
+              "properties": {"period": {"type": "string", "pattern": "^([0-9]+(\\.[0-9]+)?(ns|us|ms|s|m|h))+$"}}
+            }}}
+          },
+          {
+            "if": {"properties": {"type": {"const": "namespacePermittedRoleName"}}},
+            "then": {"properties": {"config": {
+              "additionalProperties": false,
+              "properties": {"strictRegexp": {"type": "boolean"}}
+            }}}
+          },
+          {
+            "if": {"properties": {"type": {"const": "nonRoot"}}},
+            "then": {"required": ["config"], "properties": {"config": {
+              "additionalProperties": false,
+              "required": ["roles"],
+              "properties": {"roles": {"type": "array", "minItems": 1, "items": {"type": "string", "minLength": 1}}}
+            }}}
+          },
+          {
+            "if": {"properties": {"type": {"const": "partition"}}},
+            "then": {"required": ["config"], "properties": {"config": {
+              "additionalProperties": false,
+              "required": ["partition"],
+              "properties": {"partition": {"type": "string", "minLength": 1}}
+            }}}
+          },
+          {
+            "if": {"properties": {"type": {"const": "rateLimit"}}},
+            "then": {"required": ["config"], "properties": {"config": {
+              "additionalProperties": false,
+              "required": ["limit", "burst"],
+              "properties": {
+                "limit": {"type": "number", "exclusiveMinimum": 0},
+                "burst": {"type": "integer", "minimum": 1}
+              }
+            }}}
+          },
+          {
+            "if": {"properties": {"type": {"enum": ["maxSessionDuration", "podPhase", "requestingAnnotatedRole", "roleSessionName", "sessionTags", "timeWindow"]}}},
+            "then": {"properties": {"config": {"additionalProperties": false}}}
+          }
+        ]
+      }
+    }
+  }
+}`
+
+// policyFileSchema is PolicyFileSchema decoded
+var policyFileSchema = func() map[string]interface{} {
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(PolicyFileSchema), &schema); err != nil {
+		panic(fmt.Sprintf("invalid policy file schema: %s", err))
+	}
+	return schema
+}()
+
+// validateSchema validates value, decoded from JSON, against schema. It
+// supports the subset of JSON Schema used by PolicyFileSchema: type, enum,
+// const, properties, required, additionalProperties, items, minItems,
+// minLength, pattern, minimum, exclusiveMinimum, allOf and if/then. Errors
+// are prefixed with the path of the invalid value.
+func validateSchema(schema map[string]interface{}, value interface{}, path string) []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	if t, ok := schema["type"].(string); ok && !hasSchemaType(value, t) {
+		fail("must be of type %s", t)
+		return errs
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if reflect.DeepEqual(e, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			options := make([]string, len(enum))
+			for i, e := range enum {
+				options[i] = fmt.Sprint(e)
+			}
+			fail("must be one of %s", strings.Join(options, ", "))
+		}
+	}
+
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(c, value) {
+		fail("must be %v", c)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		errs = append(errs, validateObject(schema, v, path)...)
+	case []interface{}:
+		if min, ok := schema["minItems"].(float64); ok && float64(len(v)) < min {
+			fail("must have at least %v items", min)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				errs = append(errs, validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case string:
+		if min, ok := schema["minLength"].(float64); ok && float64(len(v)) < min {
+			fail("must be at least %v characters", min)
+		}
+		if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(v) {
+			fail("must match %s", pattern)
+		}
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && v < min {
+			fail("must be at least %v", min)
+		}
+		if min, ok := schema["exclusiveMinimum"].(float64); ok && v <= min {
+			fail("must be greater than %v", min)
+		}
+	}
+
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, s := range allOf {
+			if sub, ok := s.(map[string]interface{}); ok {
+				errs = append(errs, validateSchema(sub, value, path)...)
+			}
+		}
+	}
+
+	if condition, ok := schema["if"].(map[string]interface{}); ok {
+		if len(validateSchema(condition, value, path)) == 0 {
+			if then, ok := schema["then"].(map[string]interface{}); ok {
+				errs = append(errs, validateSchema(then, value, path)...)
+			}
+		}
+	}
+
+	return errs
+}
+
+func validateObject(schema map[string]interface{}, value map[string]interface{}, path string) []error {
+	var errs []error
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, r := range required {
+			if _, ok := value[r.(string)]; !ok {
+				errs = append(errs, fmt.Errorf("%s: %s is required", path, r))
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		propertyPath := fmt.Sprintf("%s.%s", path, key)
+		property, ok := properties[key].(map[string]interface{})
+		if !ok {
+			if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+				errs = append(errs, fmt.Errorf("%s: unknown property", propertyPath))
+			}
+			continue
+		}
+		errs = append(errs, validateSchema(property, value[key], propertyPath)...)
+	}
+
+	return errs
+}
+
+func hasSchemaType(value interface{}, t string) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == float64(int64(n))
+	case "null":
+		return value == nil
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	kt "github.com/uswitch/kiam/pkg/k8s/testing"
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+func testPolicyDeps() PolicyDeps {
+	return PolicyDeps{
+		Pods:       kt.NewStubFinder(testutil.NewPodWithRole("ns", "name", "192.168.0.1", "Running", "reader")),
+		Namespaces: kt.NewNamespaceFinder(testutil.NewNamespace("ns", ".*")),
+		Resolver:   sts.DefaultResolver("arn:aws:iam::123456789012:role/"),
+	}
+}
+
+func TestLoadPoliciesFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "policies.yaml")
+	ioutil.WriteFile(path, []byte(`
+mode: allOf
+policies:
+- type: requestingAnnotatedRole
+- type: namespacePermittedRoleName
+  config:
+    strictRegexp: true
+- type: denyList
+  name: deny-admin
+  config:
+    roles: ["admin*"]
+- type: gracePeriod
+  config:
+    period: 30s
+`), 0644)
+
+	policy, err := LoadPoliciesFromFile(path, testPolicyDeps())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, descriptor := range policy.Descriptors() {
+		names = append(names, descriptor.Name)
+	}
+	if strings.Join(names, ",") != "RequestingAnnotatedRolePolicy,NamespacePermittedRoleNamePolicy,deny-admin,GracePeriodAssumeRolePolicy" {
+		t.Error("unexpected policies", names)
+	}
+
+	pod := testutil.NewPodWithRole("ns", "name", "192.168.0.1", "Running", "reader")
+	decision, err := policy.IsAllowedAssumeRole(context.Background(), "reader", pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decision.IsAllowed() {
+		t.Error("expected reader to be allowed:", decision.Explanation())
+	}
+
+	pod = testutil.NewPodWithRole("ns", "name", "192.168.0.1", "Running", "admin")
+	decision, _ = policy.IsAllowedAssumeRole(context.Background(), "admin", pod)
+	if decision.IsAllowed() {
+		t.Error("expected admin to be denied")
+	}
+}
+
+func TestParsePoliciesMode(t *testing.T) {
+	var tests = []struct {
+		mode     string
+		expected compositeMode
+	}{
+		{"Default", allOf},
+		{"allOf", allOf},
+		{"anyOf", anyOf},
+		{"allOfConcurrent", allOfConcurrent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			document := "policies: [{type: podPhase}]\n"
+			if tt.mode != "Default" {
+				document += "mode: " + tt.mode + "\n"
+			}
+			policy, err := parsePolicies([]byte(document), testPolicyDeps())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if policy.mode != tt.expected {
+				t.Errorf("expected mode %v, was %v", tt.expected, policy.mode)
+			}
+		})
+	}
+}
+
+func TestParsePoliciesValidatesSchema(t *testing.T) {
+	var tests = []struct {
+		name     string
+		document string
+		error    string
+	}{
+		{"NoPolicies", "mode: allOf", "$: policies is required"},
+		{"EmptyPolicies", "policies: []", "$.policies: must have at least 1 items"},
+		{"UnknownMode", "mode: someOf\npolicies: [{type: podPhase}]", "$.mode: must be one of allOf, anyOf, allOfConcurrent"},
+		{"UnknownProperty", "policies: [{type: podPhase}]\nextra: true", "$.extra: unknown property"},
+		{"UnknownType", "policies: [{type: unknown}]", "$.policies[0].type: must be one of"},
+		{"MissingType", "policies: [{name: foo}]", "$.policies[0]: type is required"},
+		{"MissingConfig", "policies: [{type: gracePeriod}]", "$.policies[0]: config is required"},
+		{"InvalidDuration", "policies: [{type: gracePeriod, config: {period: soon}}]", "$.policies[0].config.period: must match"},
+		{"InvalidAccount", "policies: [{type: crossAccount, config: {accounts: ['1234']}}]", "$.policies[0].config.accounts[0]: must match"},
+		{"UnknownConfig", "policies: [{type: podPhase, config: {phase: Running}}]", "$.policies[0].config.phase: unknown property"},
+		{"NonIntegerBurst", "policies: [{type: rateLimit, config: {limit: 1, burst: 1.5}}]", "$.policies[0].config.burst: must be of type integer"},
+		{"NonPositiveLimit", "policies: [{type: rateLimit, config: {limit: 0, burst: 1}}]", "$.policies[0].config.limit: must be greater than 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePolicies([]byte(tt.document), testPolicyDeps())
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error containing '%s', was: %s", tt.error, err)
+			}
+		})
+	}
+}
+
+func TestParsePoliciesReportsPolicyErrors(t *testing.T) {
+	_, err := parsePolicies([]byte("policies: [{type: partition, config: {partition: aws-mars}}]"), testPolicyDeps())
+	if err == nil || !strings.Contains(err.Error(), "policies[0] (partition)") {
+		t.Error("expected error creating policy, was", err)
+	}
+}
+
+func TestPolicyFileSchemaTypesHaveFactories(t *testing.T) {
+	items := policyFileSchema["properties"].(map[string]interface{})["policies"].(map[string]interface{})["items"].(map[string]interface{})
+	enum := items["properties"].(map[string]interface{})["type"].(map[string]interface{})["enum"].([]interface{})
+
+	var schemaTypes, factoryTypes []string
+	for _, t := range enum {
+		schemaTypes = append(schemaTypes, t.(string))
+	}
+	for t := range policyFactories {
+		factoryTypes = append(factoryTypes, t)
+	}
+	sort.Strings(schemaTypes)
+	sort.Strings(factoryTypes)
+
+	if strings.Join(schemaTypes, ",") != strings.Join(factoryTypes, ",") {
+		t.Errorf("schema types %v don't match factories %v", schemaTypes, factoryTypes)
+	}
+}
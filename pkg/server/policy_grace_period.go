@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// GracePeriodAssumeRolePolicy forbids terminating pods from assuming roles once
+// the grace period since their deletion started has passed. Requests in flight
+// while the pod shuts down can still refresh credentials within the grace
+// period.
+type GracePeriodAssumeRolePolicy struct {
+	gracePeriod time.Duration
+	clock       ClockFunc
+}
+
+func NewGracePeriodAssumeRolePolicy(gracePeriod time.Duration, clock ClockFunc) *GracePeriodAssumeRolePolicy {
+	return &GracePeriodAssumeRolePolicy{gracePeriod: gracePeriod, clock: clock}
+}
+
+// PolicyConfig describes the grace period
+func (p *GracePeriodAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	return map[string]interface{}{"gracePeriod": p.gracePeriod.String()}
+}
+
+func (p *GracePeriodAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	deleted := pod.GetDeletionTimestamp()
+	if deleted == nil {
+		return &allowed{}, nil
+	}
+
+	terminating := p.clock().Sub(deleted.Time)
+	if terminating > p.gracePeriod {
+		return &gracePeriodForbidden{pod: pod.GetName(), terminating: terminating, gracePeriod: p.gracePeriod}, nil
+	}
+
+	return &allowed{}, nil
+}
+
+type gracePeriodForbidden struct {
+	pod         string
+	terminating time.Duration
+	gracePeriod time.Duration
+}
+
+func (f *gracePeriodForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *gracePeriodForbidden) Explanation() string {
+	return fmt.Sprintf("pod '%s' has been terminating for %s, longer than the grace period %s", f.pod, f.terminating.Round(time.Second), f.gracePeriod)
+}
+
+func (f *gracePeriodForbidden) Reason() DenialReason {
+	return ReasonTerminating
+}
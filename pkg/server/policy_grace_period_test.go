@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGracePeriodPolicy(t *testing.T) {
+	now := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	policy := NewGracePeriodAssumeRolePolicy(30*time.Second, clock)
+
+	var tests = []struct {
+		name     string
+		deleted  *time.Time
+		expected bool
+	}{
+		{"Running", nil, true},
+		{"WithinGracePeriod", timePtr(now.Add(-10 * time.Second)), true},
+		{"AtGracePeriod", timePtr(now.Add(-30 * time.Second)), true},
+		{"GracePeriodExpired", timePtr(now.Add(-time.Minute)), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+			if tt.deleted != nil {
+				deleted := metav1.NewTime(*tt.deleted)
+				p.DeletionTimestamp = &deleted
+			}
+
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+			if !tt.expected && decision.Reason() != ReasonTerminating {
+				t.Error("unexpected reason", decision.Reason())
+			}
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
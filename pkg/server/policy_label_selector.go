@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// PodLabelSelectorAssumeRolePolicy ensures pods requesting a restricted role
+// match the label selector for that role. Roles without a selector aren't
+// restricted.
+type PodLabelSelectorAssumeRolePolicy struct {
+	resolver  sts.ARNResolver
+	selectors map[string]labels.Selector
+}
+
+// NewPodLabelSelectorAssumeRolePolicy creates the policy from a map of role to the
+// selector pods must match. Roles are resolved with the resolver so may be names
+// or ARNs.
+func NewPodLabelSelectorAssumeRolePolicy(resolver sts.ARNResolver, roleSelectors map[string]metav1.LabelSelector) (*PodLabelSelectorAssumeRolePolicy, error) {
+	selectors := make(map[string]labels.Selector, len(roleSelectors))
+
+	for role, labelSelector := range roleSelectors {
+		resolved, err := resolver.Resolve(role)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving role '%s': %s", role, err)
+		}
+
+		labelSelector := labelSelector
+		selector, err := metav1.LabelSelectorAsSelector(&labelSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector for role '%s': %s", role, err)
+		}
+
+		selectors[resolved.ARN.String()] = selector
+	}
+
+	return &PodLabelSelectorAssumeRolePolicy{resolver: resolver, selectors: selectors}, nil
+}
+
+// PolicyConfig describes the label selector required for each role
+func (p *PodLabelSelectorAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	selectors := map[string]string{}
+	for role, selector := range p.selectors {
+		selectors[role] = selector.String()
+	}
+	return map[string]interface{}{"selectors": selectors}
+}
+
+func (p *PodLabelSelectorAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	requestedIdentity, err := p.resolver.Resolve(role)
+	if err != nil {
+		return nil, err
+	}
+
+	selector, ok := p.selectors[requestedIdentity.ARN.String()]
+	if !ok {
+		return &allowed{}, nil
+	}
+
+	if !selector.Matches(labels.Set(pod.GetLabels())) {
+		return &labelSelectorForbidden{role: requestedIdentity.ARN.String(), selector: selector.String()}, nil
+	}
+
+	return &allowed{}, nil
+}
+
+type labelSelectorForbidden struct {
+	role     string
+	selector string
+}
+
+func (f *labelSelectorForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *labelSelectorForbidden) Explanation() string {
+	return fmt.Sprintf("pod labels don't match selector '%s' required for role '%s'", f.selector, f.role)
+}
+
+func (f *labelSelectorForbidden) Reason() DenialReason {
+	return ReasonLabelSelector
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLabelSelectorPolicy(t *testing.T) {
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+	policy, err := NewPodLabelSelectorAssumeRolePolicy(arnResolver, map[string]metav1.LabelSelector{
+		"PlatformAdminRole": {MatchLabels: map[string]string{"team": "platform"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name     string
+		role     string
+		labels   map[string]string
+		expected bool
+	}{
+		{"MatchingLabels", "PlatformAdminRole", map[string]string{"team": "platform"}, true},
+		{"MatchingLabelsWithARN", "arn:aws:iam::123456789012:role/PlatformAdminRole", map[string]string{"team": "platform", "app": "foo"}, true},
+		{"MatchingLabelsWithSlash", "/PlatformAdminRole", map[string]string{"team": "platform"}, true},
+		{"WrongLabels", "PlatformAdminRole", map[string]string{"team": "data"}, false},
+		{"NoLabels", "arn:aws:iam::123456789012:role/PlatformAdminRole", nil, false},
+		{"UnrestrictedRole", "red_role", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, tt.role)
+			p.Labels = tt.labels
+
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), tt.role, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+		})
+	}
+}
+
+func TestLabelSelectorPolicyExplanation(t *testing.T) {
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+	policy, _ := NewPodLabelSelectorAssumeRolePolicy(arnResolver, map[string]metav1.LabelSelector{
+		"PlatformAdminRole": {MatchLabels: map[string]string{"team": "platform"}},
+	})
+
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "PlatformAdminRole")
+	decision, _ := policy.IsAllowedAssumeRole(context.Background(), "PlatformAdminRole", p)
+
+	if decision.Explanation() != "pod labels don't match selector 'team=platform' required for role 'arn:aws:iam::123456789012:role/PlatformAdminRole'" {
+		t.Error("unexpected explanation, was", decision.Explanation())
+	}
+}
+
+func TestLabelSelectorPolicyRejectsInvalidSelector(t *testing.T) {
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+	_, err := NewPodLabelSelectorAssumeRolePolicy(arnResolver, map[string]metav1.LabelSelector{
+		"PlatformAdminRole": {MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "team", Operator: "Sometimes"}}},
+	})
+
+	if err == nil {
+		t.Error("expected error for invalid selector")
+	}
+}
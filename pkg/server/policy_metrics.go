@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// PolicyMetrics wraps a policy, recording how long it takes to make each
+// decision. Latency is labelled with the policy's name, from Named if
+// implemented or otherwise its type.
+type PolicyMetrics struct {
+	policy  AssumeRolePolicy
+	name    string
+	latency *prometheus.HistogramVec
+}
+
+func NewPolicyMetrics(policy AssumeRolePolicy) *PolicyMetrics {
+	return &PolicyMetrics{
+		policy: policy,
+		name:   policyName(policy),
+		latency: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: "kiam",
+				Subsystem: "policy",
+				Name:      "decision_duration_seconds",
+				Help:      "Bucketed histogram of the time taken by each policy to make a decision",
+
+				// 0.1ms to ~3s
+				Buckets: prometheus.ExponentialBuckets(.0001, 2, 15),
+			},
+			[]string{"policy_name"},
+		),
+	}
+}
+
+// RegisterMetrics registers the latency histogram with reg. If an equivalent
+// histogram is already registered (by another wrapped policy) it is shared.
+func (p *PolicyMetrics) RegisterMetrics(reg prometheus.Registerer) error {
+	err := reg.Register(p.latency)
+	if err == nil {
+		return nil
+	}
+
+	if existing, ok := err.(prometheus.AlreadyRegisteredError); ok {
+		if latency, ok := existing.ExistingCollector.(*prometheus.HistogramVec); ok {
+			p.latency = latency
+			return nil
+		}
+	}
+
+	return err
+}
+
+// Name returns the wrapped policy's name, so the policy can still be removed
+// from a CompositeAssumeRolePolicy by name.
+func (p *PolicyMetrics) Name() string {
+	return p.name
+}
+
+func (p *PolicyMetrics) unwrap() AssumeRolePolicy {
+	return p.policy
+}
+
+func (p *PolicyMetrics) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	start := time.Now()
+	defer func() {
+		p.latency.WithLabelValues(p.name).Observe(time.Since(start).Seconds())
+	}()
+
+	return p.policy.IsAllowedAssumeRole(ctx, role, pod)
+}
+
+// wrappedPolicy is implemented by policies decorating another policy
+type wrappedPolicy interface {
+	unwrap() AssumeRolePolicy
+}
+
+// policyName returns the name of a Named policy, or the name of its type
+func policyName(policy AssumeRolePolicy) string {
+	if named, ok := policy.(Named); ok {
+		return named.Name()
+	}
+	return policyTypeName(policy)
+}
+
+// policyTypeName returns the name of the policy's type, without pointers
+func policyTypeName(policy AssumeRolePolicy) string {
+	t := reflect.TypeOf(policy)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
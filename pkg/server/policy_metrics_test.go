@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+func TestPolicyMetricsRecordsLatency(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+	named := NewPolicyMetrics(NamedPolicy("named", fakePolicy{decision: &allowed{}}))
+	typed := NewPolicyMetrics(&fakeHealthChecker{fakePolicy: fakePolicy{decision: &allowed{}}})
+	for _, policy := range []*PolicyMetrics{named, typed} {
+		if err := policy.RegisterMetrics(reg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		decision, err := Policies(named, typed).IsAllowedAssumeRole(context.Background(), "red_role", p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !decision.IsAllowed() {
+			t.Error("expected decision to be passed through:", decision.Explanation())
+		}
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]uint64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			counts[metric.GetLabel()[0].GetValue()] = metric.GetHistogram().GetSampleCount()
+		}
+	}
+
+	for _, name := range []string{"named", "fakeHealthChecker"} {
+		if counts[name] != 2 {
+			t.Errorf("expected 2 observations for %s, was %d", name, counts[name])
+		}
+	}
+}
+
+func TestPolicyMetricsKeepsName(t *testing.T) {
+	policy := Policies(NewPolicyMetrics(NamedPolicy("removable", fakePolicy{})))
+	if !policy.Remove("removable") {
+		t.Error("expected wrapped policy to be removed by name")
+	}
+}
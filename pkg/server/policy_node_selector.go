@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// NodeSelectorAssumeRolePolicy restricts high-privilege roles to pods running
+// on nodes matching a label selector, such as a dedicated node group. Roles
+// not matching any of the policy's ARN patterns aren't restricted.
+type NodeSelectorAssumeRolePolicy struct {
+	resolver sts.ARNResolver
+	nodes    k8s.NodeGetter
+	patterns []string
+	selector labels.Selector
+}
+
+// NewNodeSelectorAssumeRolePolicy creates the policy requiring pods assuming
+// roles matching patterns (which may contain * and ? wildcards) to run on
+// nodes matching selector.
+func NewNodeSelectorAssumeRolePolicy(resolver sts.ARNResolver, nodes k8s.NodeGetter, patterns []string, nodeSelector metav1.LabelSelector) (*NodeSelectorAssumeRolePolicy, error) {
+	compiled := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		resolved, err := resolver.Resolve(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid role pattern '%s': %v", pattern, err)
+		}
+		compiled = append(compiled, resolved.ARN.String())
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&nodeSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node selector: %v", err)
+	}
+
+	return &NodeSelectorAssumeRolePolicy{resolver: resolver, nodes: nodes, patterns: compiled, selector: selector}, nil
+}
+
+// PolicyConfig describes the restricted role patterns and the node selector
+func (p *NodeSelectorAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"patterns": p.patterns,
+		"selector": p.selector.String(),
+	}
+}
+
+func (p *NodeSelectorAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	requestedIdentity, err := p.resolver.Resolve(role)
+	if err != nil {
+		return nil, err
+	}
+	arn := requestedIdentity.ARN
+
+	restricted := false
+	for _, pattern := range p.patterns {
+		if arn.IsWildcardMatch(pattern) {
+			restricted = true
+			break
+		}
+	}
+	if !restricted {
+		return &allowed{}, nil
+	}
+
+	nodeName := pod.Spec.NodeName
+	if nodeName == "" {
+		return &nodeSelectorForbidden{role: arn.String(), node: nodeName, selector: p.selector.String()}, nil
+	}
+
+	node, err := p.nodes.GetNodeByName(nodeName)
+	if err == k8s.ErrNodeNotFound {
+		return &nodeSelectorForbidden{role: arn.String(), node: nodeName, selector: p.selector.String()}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.selector.Matches(labels.Set(node.GetLabels())) {
+		return &nodeSelectorForbidden{role: arn.String(), node: nodeName, selector: p.selector.String()}, nil
+	}
+
+	return &allowed{}, nil
+}
+
+type nodeSelectorForbidden struct {
+	role     string
+	node     string
+	selector string
+}
+
+func (f *nodeSelectorForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *nodeSelectorForbidden) Explanation() string {
+	if f.node == "" {
+		return fmt.Sprintf("pod isn't scheduled on a node matching selector '%s' required for role '%s'", f.selector, f.role)
+	}
+	return fmt.Sprintf("node '%s' doesn't match selector '%s' required for role '%s'", f.node, f.selector, f.role)
+}
+
+func (f *nodeSelectorForbidden) Reason() DenialReason {
+	return ReasonNodeSelector
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	kt "github.com/uswitch/kiam/pkg/k8s/testing"
+	"github.com/uswitch/kiam/pkg/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNodeSelectorPolicy(t *testing.T) {
+	secure := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "secure-1", Labels: map[string]string{"node-group": "secure"}}}
+	general := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "general-1", Labels: map[string]string{"node-group": "general"}}}
+	selector := metav1.LabelSelector{MatchLabels: map[string]string{"node-group": "secure"}}
+
+	var tests = []struct {
+		name     string
+		role     string
+		node     string
+		expected bool
+	}{
+		{"UnrestrictedRole", "reader", "general-1", true},
+		{"RestrictedRoleOnMatchingNode", "admin/deploy", "secure-1", true},
+		{"RestrictedRoleOnOtherNode", "admin/deploy", "general-1", false},
+		{"RestrictedRoleUnscheduled", "admin/deploy", "", false},
+		{"RestrictedRoleNodeNotFound", "admin/deploy", "missing-1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+			policy, err := NewNodeSelectorAssumeRolePolicy(resolver, kt.NewNodeGetter(secure, general), []string{"admin/*"}, selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, tt.role)
+			p.Spec.NodeName = tt.node
+
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), tt.role, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+			if !tt.expected && decision.Reason() != ReasonNodeSelector {
+				t.Error("unexpected reason", decision.Reason())
+			}
+		})
+	}
+}
+
+func TestNodeSelectorPolicyInvalidSelector(t *testing.T) {
+	selector := metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "node-group", Operator: "Unknown"}}}
+	_, err := NewNodeSelectorAssumeRolePolicy(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), kt.NewNodeGetter(), []string{"admin/*"}, selector)
+	if err == nil {
+		t.Error("expected invalid selector to error")
+	}
+}
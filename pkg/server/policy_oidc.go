@@ -0,0 +1,267 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/logging"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+	v1 "k8s.io/api/core/v1"
+)
+
+// OIDCConfig configures how OIDCFederatedAssumeRolePolicy verifies tokens
+type OIDCConfig struct {
+	// JWKSURI is the URI of the issuer's JSON Web Key Set
+	JWKSURI string
+	// Issuer is matched against the token's iss claim, if set
+	Issuer string
+	// Audience is matched against the token's aud claim, if set
+	Audience string
+	// Timeout for fetching the key set
+	Timeout time.Duration
+	// AllowMissingToken allows pods that don't present a token, easing
+	// migration while workloads are changed to present them
+	AllowMissingToken bool
+}
+
+// jwksRefreshInterval is the minimum interval between fetches of the key set,
+// so that requests with invalid tokens can't make the server hammer the issuer
+const jwksRefreshInterval = time.Minute
+
+// OIDCFederatedAssumeRolePolicy verifies the projected service account token
+// presented by the pod, in the X-Kiam-Service-Account-Token header, before
+// allowing it to assume roles. The token must be signed by a key in the
+// issuer's key set, its subject must be the pod's service account and, if it's
+// bound to a pod, it must be bound to the requesting pod. Pods that don't
+// present a token are denied unless AllowMissingToken is set.
+type OIDCFederatedAssumeRolePolicy struct {
+	config OIDCConfig
+	client *http.Client
+	clock  func() time.Time
+
+	mu          sync.RWMutex
+	keys        *jose.JSONWebKeySet
+	lastRefresh time.Time
+}
+
+func NewOIDCFederatedAssumeRolePolicy(config OIDCConfig) *OIDCFederatedAssumeRolePolicy {
+	return &OIDCFederatedAssumeRolePolicy{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		clock:  time.Now,
+	}
+}
+
+// PolicyConfig describes the key set and the claims verified
+func (p *OIDCFederatedAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"jwksURI":           p.config.JWKSURI,
+		"issuer":            p.config.Issuer,
+		"audience":          p.config.Audience,
+		"timeout":           p.config.Timeout.String(),
+		"allowMissingToken": p.config.AllowMissingToken,
+	}
+}
+
+// kubernetesClaims are the claims Kubernetes adds to service account tokens
+// identifying the pod the token is bound to
+type kubernetesClaims struct {
+	Kubernetes struct {
+		Pod *struct {
+			Name string `json:"name"`
+			UID  string `json:"uid"`
+		} `json:"pod"`
+	} `json:"kubernetes.io"`
+}
+
+func (p *OIDCFederatedAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	token, ok := ServiceAccountTokenFromContext(ctx)
+	if !ok {
+		if p.config.AllowMissingToken {
+			return &allowed{}, nil
+		}
+		return &oidcTokenMissing{}, nil
+	}
+
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return &oidcTokenInvalid{err: err}, nil
+	}
+
+	claims, bound, err := p.verify(ctx, parsed)
+	if err != nil {
+		if _, ok := err.(*jwksFetchError); ok {
+			return nil, err
+		}
+		return &oidcTokenInvalid{err: err}, nil
+	}
+
+	expected := fmt.Sprintf("system:serviceaccount:%s:%s", pod.GetNamespace(), podServiceAccount(pod))
+	if claims.Subject != expected {
+		return &oidcSubjectForbidden{subject: claims.Subject, expected: expected}, nil
+	}
+
+	if boundPod := bound.Kubernetes.Pod; boundPod != nil && boundPod.UID != string(pod.GetUID()) {
+		return &oidcTokenInvalid{err: fmt.Errorf("token is bound to pod %s", boundPod.Name)}, nil
+	}
+
+	return &allowed{}, nil
+}
+
+// verify checks the token's signature and claims. If verification fails the key
+// set is fetched again, in case the issuer's keys have been rotated, and
+// verification retried. The key set is fetched at most once every
+// jwksRefreshInterval.
+func (p *OIDCFederatedAssumeRolePolicy) verify(ctx context.Context, token *jwt.JSONWebToken) (*jwt.Claims, *kubernetesClaims, error) {
+	p.mu.RLock()
+	keys := p.keys
+	p.mu.RUnlock()
+
+	if keys != nil {
+		claims, bound, err := p.verifyWithKeys(token, keys)
+		if err == nil {
+			return claims, bound, nil
+		}
+		if !p.shouldRefresh() {
+			return nil, nil, err
+		}
+		logging.FromContext(ctx).Debug("token verification failed, refreshing keys", "oidc.jwks", p.config.JWKSURI, "error", err)
+	} else if !p.shouldRefresh() {
+		return nil, nil, &jwksFetchError{err: fmt.Errorf("key set unavailable, retrying after %s", jwksRefreshInterval)}
+	}
+
+	keys, err := p.refreshKeys(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return p.verifyWithKeys(token, keys)
+}
+
+// shouldRefresh returns whether the key set can be fetched, recording the
+// attempt if so
+func (p *OIDCFederatedAssumeRolePolicy) shouldRefresh() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock()
+	if !p.lastRefresh.IsZero() && now.Sub(p.lastRefresh) < jwksRefreshInterval {
+		return false
+	}
+	p.lastRefresh = now
+	return true
+}
+
+func (p *OIDCFederatedAssumeRolePolicy) verifyWithKeys(token *jwt.JSONWebToken, keys *jose.JSONWebKeySet) (*jwt.Claims, *kubernetesClaims, error) {
+	claims := &jwt.Claims{}
+	bound := &kubernetesClaims{}
+	if err := token.Claims(keys, claims, bound); err != nil {
+		return nil, nil, err
+	}
+
+	expected := jwt.Expected{Issuer: p.config.Issuer, Time: p.clock()}
+	if p.config.Audience != "" {
+		expected.Audience = jwt.Audience{p.config.Audience}
+	}
+	if err := claims.Validate(expected); err != nil {
+		return nil, nil, err
+	}
+
+	return claims, bound, nil
+}
+
+type jwksFetchError struct {
+	err error
+}
+
+func (e *jwksFetchError) Error() string {
+	return fmt.Sprintf("error fetching key set: %s", e.err.Error())
+}
+
+func (p *OIDCFederatedAssumeRolePolicy) refreshKeys(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	req, err := http.NewRequest(http.MethodGet, p.config.JWKSURI, nil)
+	if err != nil {
+		return nil, &jwksFetchError{err: err}
+	}
+
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, &jwksFetchError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &jwksFetchError{err: fmt.Errorf("unexpected status %d", resp.StatusCode)}
+	}
+
+	keys := &jose.JSONWebKeySet{}
+	if err := json.NewDecoder(resp.Body).Decode(keys); err != nil {
+		return nil, &jwksFetchError{err: err}
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	logging.FromContext(ctx).Info("fetched keys", "oidc.jwks", p.config.JWKSURI, "oidc.keys", len(keys.Keys))
+	return keys, nil
+}
+
+func podServiceAccount(pod *v1.Pod) string {
+	if pod.Spec.ServiceAccountName == "" {
+		return defaultServiceAccountName
+	}
+	return pod.Spec.ServiceAccountName
+}
+
+type oidcTokenMissing struct{}
+
+func (f *oidcTokenMissing) IsAllowed() bool {
+	return false
+}
+
+func (f *oidcTokenMissing) Explanation() string {
+	return fmt.Sprintf("pod didn't present a service account token in the %s header", ServiceAccountTokenHeader)
+}
+
+func (f *oidcTokenMissing) Reason() DenialReason {
+	return ReasonOIDCToken
+}
+
+type oidcTokenInvalid struct {
+	err error
+}
+
+func (f *oidcTokenInvalid) IsAllowed() bool {
+	return false
+}
+
+func (f *oidcTokenInvalid) Explanation() string {
+	return fmt.Sprintf("service account token failed verification: %s", f.err.Error())
+}
+
+func (f *oidcTokenInvalid) Reason() DenialReason {
+	return ReasonOIDCToken
+}
+
+type oidcSubjectForbidden struct {
+	subject  string
+	expected string
+}
+
+func (f *oidcSubjectForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *oidcSubjectForbidden) Explanation() string {
+	return fmt.Sprintf("token subject '%s' doesn't match pod service account '%s'", f.subject, f.expected)
+}
+
+func (f *oidcSubjectForbidden) Reason() DenialReason {
+	return ReasonOIDCToken
+}
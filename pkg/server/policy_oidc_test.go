@@ -0,0 +1,252 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/testutil"
+	"google.golang.org/grpc/metadata"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type testIssuer struct {
+	mu      sync.Mutex
+	key     *rsa.PrivateKey
+	keyID   string
+	fetches int
+}
+
+func newTestIssuer(t *testing.T, keyID string) *testIssuer {
+	issuer := &testIssuer{}
+	issuer.rotate(t, keyID)
+	return issuer
+}
+
+func (i *testIssuer) rotate(t *testing.T, keyID string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.key = key
+	i.keyID = keyID
+}
+
+func (i *testIssuer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.fetches++
+
+	keys := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: i.key.Public(), KeyID: i.keyID, Algorithm: "RS256", Use: "sig"}}}
+	json.NewEncoder(w).Encode(keys)
+}
+
+func (i *testIssuer) sign(t *testing.T, claims interface{}) string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: i.key}, (&jose.SignerOptions{}).WithHeader("kid", i.keyID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func oidcTestPod(serviceAccount string) *v1.Pod {
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	p.UID = types.UID("foo-uid")
+	p.Spec.ServiceAccountName = serviceAccount
+	return p
+}
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func TestOIDCPolicy(t *testing.T) {
+	issuer := newTestIssuer(t, "one")
+	server := httptest.NewServer(issuer)
+	defer server.Close()
+
+	now := time.Now()
+	var tests = []struct {
+		name     string
+		claims   interface{}
+		expected bool
+	}{
+		{"MatchingSubject", jwt.Claims{Subject: "system:serviceaccount:red:builder", Issuer: "https://issuer", Audience: jwt.Audience{"sts.amazonaws.com"}, Expiry: jwt.NewNumericDate(now.Add(time.Hour))}, true},
+		{"OtherServiceAccount", jwt.Claims{Subject: "system:serviceaccount:red:deployer", Issuer: "https://issuer", Audience: jwt.Audience{"sts.amazonaws.com"}, Expiry: jwt.NewNumericDate(now.Add(time.Hour))}, false},
+		{"WrongIssuer", jwt.Claims{Subject: "system:serviceaccount:red:builder", Issuer: "https://other", Audience: jwt.Audience{"sts.amazonaws.com"}, Expiry: jwt.NewNumericDate(now.Add(time.Hour))}, false},
+		{"WrongAudience", jwt.Claims{Subject: "system:serviceaccount:red:builder", Issuer: "https://issuer", Audience: jwt.Audience{"other"}, Expiry: jwt.NewNumericDate(now.Add(time.Hour))}, false},
+		{"Expired", jwt.Claims{Subject: "system:serviceaccount:red:builder", Issuer: "https://issuer", Audience: jwt.Audience{"sts.amazonaws.com"}, Expiry: jwt.NewNumericDate(now.Add(-time.Hour))}, false},
+		{"BoundToPod", boundClaims("system:serviceaccount:red:builder", "foo-uid", now), true},
+		{"BoundToOtherPod", boundClaims("system:serviceaccount:red:builder", "bar-uid", now), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := NewOIDCFederatedAssumeRolePolicy(OIDCConfig{JWKSURI: server.URL, Issuer: "https://issuer", Audience: "sts.amazonaws.com", Timeout: time.Second})
+
+			ctx := WithServiceAccountToken(context.Background(), issuer.sign(t, tt.claims))
+			decision, err := policy.IsAllowedAssumeRole(ctx, "red_role", oidcTestPod("builder"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+		})
+	}
+}
+
+func boundClaims(subject, uid string, now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"sub": subject,
+		"iss": "https://issuer",
+		"aud": []string{"sts.amazonaws.com"},
+		"exp": now.Add(time.Hour).Unix(),
+		"kubernetes.io": map[string]interface{}{
+			"namespace": "red",
+			"pod":       map[string]string{"name": "foo", "uid": uid},
+		},
+	}
+}
+
+func TestOIDCPolicyDeniesPodsWithoutToken(t *testing.T) {
+	policy := NewOIDCFederatedAssumeRolePolicy(OIDCConfig{JWKSURI: "http://127.0.0.1:0"})
+
+	decision, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", oidcTestPod(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.IsAllowed() {
+		t.Error("expected pod without token to be denied")
+	}
+	if decision.Explanation() != "pod didn't present a service account token in the X-Kiam-Service-Account-Token header" {
+		t.Error("unexpected explanation, was", decision.Explanation())
+	}
+}
+
+func TestOIDCPolicyAllowsMissingTokenWhenConfigured(t *testing.T) {
+	policy := NewOIDCFederatedAssumeRolePolicy(OIDCConfig{JWKSURI: "http://127.0.0.1:0", AllowMissingToken: true})
+
+	decision, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", oidcTestPod(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decision.IsAllowed() {
+		t.Error("expected pod without token to be allowed")
+	}
+}
+
+func TestOIDCPolicyReadsTokenFromMetadata(t *testing.T) {
+	issuer := newTestIssuer(t, "one")
+	server := httptest.NewServer(issuer)
+	defer server.Close()
+
+	claims := jwt.Claims{Subject: "system:serviceaccount:red:default", Expiry: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+	policy := NewOIDCFederatedAssumeRolePolicy(OIDCConfig{JWKSURI: server.URL, Timeout: time.Second})
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-kiam-service-account-token", issuer.sign(t, claims)))
+	decision, err := policy.IsAllowedAssumeRole(ctx, "red_role", oidcTestPod(""))
+	if err != nil || !decision.IsAllowed() {
+		t.Fatal("expected token forwarded by agent to be allowed", err)
+	}
+}
+
+func TestOIDCPolicyRefetchesRotatedKeys(t *testing.T) {
+	issuer := newTestIssuer(t, "one")
+	server := httptest.NewServer(issuer)
+	defer server.Close()
+
+	claims := jwt.Claims{Subject: "system:serviceaccount:red:default", Expiry: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+	clock := &testClock{now: time.Now()}
+	policy := NewOIDCFederatedAssumeRolePolicy(OIDCConfig{JWKSURI: server.URL, Timeout: time.Second})
+	policy.clock = clock.Now
+
+	isAllowed := func() bool {
+		ctx := WithServiceAccountToken(context.Background(), issuer.sign(t, claims))
+		decision, err := policy.IsAllowedAssumeRole(ctx, "red_role", oidcTestPod(""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decision.IsAllowed()
+	}
+
+	for i := 0; i < 2; i++ {
+		if !isAllowed() {
+			t.Fatal("expected to be allowed")
+		}
+	}
+	if issuer.fetches != 1 {
+		t.Error("expected key set to be cached, fetches:", issuer.fetches)
+	}
+
+	issuer.rotate(t, "two")
+	if isAllowed() {
+		t.Error("expected to be denied until the key set can be refreshed")
+	}
+	if issuer.fetches != 1 {
+		t.Error("expected key set not to be fetched within refresh interval, fetches:", issuer.fetches)
+	}
+
+	clock.now = clock.now.Add(jwksRefreshInterval)
+	if !isAllowed() {
+		t.Fatal("expected to be allowed after rotation")
+	}
+	if issuer.fetches != 2 {
+		t.Error("expected key set to be fetched after rotation, fetches:", issuer.fetches)
+	}
+}
+
+func TestOIDCPolicyReturnsErrorFetchingKeys(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	issuer := newTestIssuer(t, "one")
+	claims := jwt.Claims{Subject: "system:serviceaccount:red:default"}
+	policy := NewOIDCFederatedAssumeRolePolicy(OIDCConfig{JWKSURI: server.URL, Timeout: time.Second})
+
+	ctx := WithServiceAccountToken(context.Background(), issuer.sign(t, claims))
+	_, err := policy.IsAllowedAssumeRole(ctx, "red_role", oidcTestPod(""))
+	if err == nil {
+		t.Error("expected error fetching key set")
+	}
+}
+
+func TestOIDCPolicyExplanation(t *testing.T) {
+	issuer := newTestIssuer(t, "one")
+	server := httptest.NewServer(issuer)
+	defer server.Close()
+
+	claims := jwt.Claims{Subject: "system:serviceaccount:red:deployer"}
+	policy := NewOIDCFederatedAssumeRolePolicy(OIDCConfig{JWKSURI: server.URL, Timeout: time.Second})
+
+	ctx := WithServiceAccountToken(context.Background(), issuer.sign(t, claims))
+	decision, _ := policy.IsAllowedAssumeRole(ctx, "red_role", oidcTestPod("builder"))
+	if decision.Explanation() != "token subject 'system:serviceaccount:red:deployer' doesn't match pod service account 'system:serviceaccount:red:builder'" {
+		t.Error("unexpected explanation, was", decision.Explanation())
+	}
+}
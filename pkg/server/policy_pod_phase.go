@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+// PodPhaseAssumeRolePolicy forbids completed (succeeded or failed) pods from
+// assuming roles, as processes outliving their pod may still request
+// credentials. The pod's current phase is found with the PodGetter; errors
+// finding the pod are returned rather than forbidding the request, other than
+// the pod no longer being found.
+type PodPhaseAssumeRolePolicy struct {
+	pods k8s.PodGetter
+}
+
+// NewPodPhaseAssumeRolePolicy creates the policy finding pods with the
+// PodGetter.
+func NewPodPhaseAssumeRolePolicy(pods k8s.PodGetter) *PodPhaseAssumeRolePolicy {
+	return &PodPhaseAssumeRolePolicy{pods: pods}
+}
+
+func (p *PodPhaseAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	if k8s.IsPodCompleted(pod) {
+		return &podPhaseForbidden{pod: pod.GetName(), phase: string(pod.Status.Phase)}, nil
+	}
+
+	current, err := p.pods.GetPodByIP(pod.Status.PodIP)
+	if err == k8s.ErrPodNotFound {
+		// completed pods aren't found by IP
+		return &podPhaseForbidden{pod: pod.GetName(), phase: "not found"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if current.GetUID() != pod.GetUID() || k8s.IsPodCompleted(current) {
+		return &podPhaseForbidden{pod: pod.GetName(), phase: string(current.Status.Phase)}, nil
+	}
+
+	return &allowed{}, nil
+}
+
+type podPhaseForbidden struct {
+	pod   string
+	phase string
+}
+
+func (f *podPhaseForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *podPhaseForbidden) Explanation() string {
+	return fmt.Sprintf("pod '%s' isn't active (%s)", f.pod, f.phase)
+}
+
+func (f *podPhaseForbidden) Reason() DenialReason {
+	return ReasonPodPhase
+}
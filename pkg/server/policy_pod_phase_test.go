@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	"github.com/uswitch/kiam/pkg/testutil"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type podGetterFunc func(ip string) (*v1.Pod, error)
+
+func (f podGetterFunc) GetPodByIP(ip string) (*v1.Pod, error) {
+	return f(ip)
+}
+
+func TestPodPhasePolicy(t *testing.T) {
+	running := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	failed := testutil.NewPodWithRole("red", "foo", "192.168.0.1", "Failed", "red_role")
+	replaced := running.DeepCopy()
+	replaced.UID = types.UID("other")
+
+	var tests = []struct {
+		name     string
+		pod      *v1.Pod
+		current  *v1.Pod
+		err      error
+		expected bool
+	}{
+		{"Running", running, running, nil, true},
+		{"RequestFromFailedPod", failed, running, nil, false},
+		{"CurrentlyFailed", running, failed, nil, false},
+		{"NoLongerFound", running, nil, k8s.ErrPodNotFound, false},
+		{"IPReused", running, replaced, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := NewPodPhaseAssumeRolePolicy(podGetterFunc(func(ip string) (*v1.Pod, error) {
+				return tt.current, tt.err
+			}))
+
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", tt.pod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+			if !tt.expected && decision.Reason() != ReasonPodPhase {
+				t.Error("unexpected reason", decision.Reason())
+			}
+		})
+	}
+}
+
+func TestPodPhasePolicyReturnsLookupErrors(t *testing.T) {
+	lookupErr := errors.New("connection refused")
+	policy := NewPodPhaseAssumeRolePolicy(podGetterFunc(func(ip string) (*v1.Pod, error) {
+		return nil, lookupErr
+	}))
+	pod := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+	if _, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", pod); err != lookupErr {
+		t.Error("expected lookup error, was", err)
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	v1 "k8s.io/api/core/v1"
+)
+
+// PodSecurityContextAssumeRolePolicy forbids pods with containers running as
+// root (UID 0) from assuming roles matching the policy's ARN patterns. A
+// container's RunAsUser takes precedence over the pod's. Containers without a
+// RunAsUser (which run as the image's user) aren't considered root. Roles not
+// matching any of the patterns aren't restricted.
+type PodSecurityContextAssumeRolePolicy struct {
+	resolver sts.ARNResolver
+	patterns []string
+}
+
+// NewPodSecurityContextAssumeRolePolicy creates the policy requiring pods
+// assuming roles matching patterns (which may contain * and ? wildcards) not to
+// run as root.
+func NewPodSecurityContextAssumeRolePolicy(resolver sts.ARNResolver, patterns []string) (*PodSecurityContextAssumeRolePolicy, error) {
+	compiled := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		resolved, err := resolver.Resolve(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid role pattern '%s': %v", pattern, err)
+		}
+		compiled = append(compiled, resolved.ARN.String())
+	}
+
+	return &PodSecurityContextAssumeRolePolicy{resolver: resolver, patterns: compiled}, nil
+}
+
+// PolicyConfig describes the role patterns requiring non-root pods
+func (p *PodSecurityContextAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	return map[string]interface{}{"patterns": p.patterns}
+}
+
+func (p *PodSecurityContextAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	requestedIdentity, err := p.resolver.Resolve(role)
+	if err != nil {
+		return nil, err
+	}
+	arn := requestedIdentity.ARN
+
+	restricted := false
+	for _, pattern := range p.patterns {
+		if arn.IsWildcardMatch(pattern) {
+			restricted = true
+			break
+		}
+	}
+	if !restricted {
+		return &allowed{}, nil
+	}
+
+	if container, ok := rootContainer(pod); ok {
+		return &rootContainerForbidden{role: arn.String(), container: container}, nil
+	}
+
+	return &allowed{}, nil
+}
+
+// rootContainer returns the name of the first container running as root
+func rootContainer(pod *v1.Pod) (string, bool) {
+	var podUser *int64
+	if pod.Spec.SecurityContext != nil {
+		podUser = pod.Spec.SecurityContext.RunAsUser
+	}
+
+	containers := append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, container := range containers {
+		user := podUser
+		if container.SecurityContext != nil && container.SecurityContext.RunAsUser != nil {
+			user = container.SecurityContext.RunAsUser
+		}
+		if user != nil && *user == 0 {
+			return container.Name, true
+		}
+	}
+	return "", false
+}
+
+type rootContainerForbidden struct {
+	role      string
+	container string
+}
+
+func (f *rootContainerForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *rootContainerForbidden) Explanation() string {
+	return fmt.Sprintf("container '%s' runs as root, which isn't permitted for role '%s'", f.container, f.role)
+}
+
+func (f *rootContainerForbidden) Reason() DenialReason {
+	return ReasonRunAsRoot
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/testutil"
+	v1 "k8s.io/api/core/v1"
+)
+
+func uid(id int64) *int64 {
+	return &id
+}
+
+func TestPodSecurityContextPolicy(t *testing.T) {
+	var tests = []struct {
+		name           string
+		role           string
+		podUser        *int64
+		containerUser  *int64
+		initContainers bool
+		expected       bool
+	}{
+		{"UnrestrictedRoleAsRoot", "reader", uid(0), nil, false, true},
+		{"RestrictedRoleUserUnset", "admin/deploy", nil, nil, false, true},
+		{"RestrictedRoleAsNonRoot", "admin/deploy", uid(1000), nil, false, true},
+		{"RestrictedRolePodAsRoot", "admin/deploy", uid(0), nil, false, false},
+		{"RestrictedRoleContainerAsRoot", "admin/deploy", uid(1000), uid(0), false, false},
+		{"RestrictedRoleContainerOverridesPod", "admin/deploy", uid(0), uid(1000), false, true},
+		{"RestrictedRoleInitContainerAsRoot", "admin/deploy", nil, uid(0), true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+			policy, err := NewPodSecurityContextAssumeRolePolicy(resolver, []string{"admin/*"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, tt.role)
+			p.Spec.SecurityContext = &v1.PodSecurityContext{RunAsUser: tt.podUser}
+			container := v1.Container{Name: "app", SecurityContext: &v1.SecurityContext{RunAsUser: tt.containerUser}}
+			if tt.initContainers {
+				p.Spec.InitContainers = []v1.Container{container}
+				p.Spec.Containers = []v1.Container{{Name: "app"}}
+			} else {
+				p.Spec.Containers = []v1.Container{container}
+			}
+
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), tt.role, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+			if !tt.expected && decision.Reason() != ReasonRunAsRoot {
+				t.Error("unexpected reason", decision.Reason())
+			}
+		})
+	}
+}
+
+func TestPodSecurityContextPolicyInvalidPattern(t *testing.T) {
+	_, err := NewPodSecurityContextAssumeRolePolicy(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), []string{"arn:invalid"})
+	if err == nil {
+		t.Error("expected error with invalid pattern")
+	}
+}
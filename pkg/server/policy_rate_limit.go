@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	v1 "k8s.io/api/core/v1"
+)
+
+// RateLimitingAssumeRolePolicy throttles how frequently each pod can request
+// to assume roles. Limiters for pods that haven't made a request within the
+// idle TTL are discarded. The limit is either fixed or adjusted by an
+// AdaptiveRateLimiter.
+type RateLimitingAssumeRolePolicy struct {
+	limit    rate.Limit
+	burst    int
+	idleTTL  time.Duration
+	clock    ClockFunc
+	adaptive *AdaptiveRateLimiter
+
+	mu        sync.Mutex
+	limiters  map[string]*podLimiter
+	lastSweep time.Time
+}
+
+type podLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewRateLimitingAssumeRolePolicy creates a policy permitting each pod limit
+// requests per second, with bursts of up to burst requests.
+func NewRateLimitingAssumeRolePolicy(limit rate.Limit, burst int, idleTTL time.Duration) *RateLimitingAssumeRolePolicy {
+	return &RateLimitingAssumeRolePolicy{
+		limit:    limit,
+		burst:    burst,
+		idleTTL:  idleTTL,
+		clock:    time.Now,
+		limiters: make(map[string]*podLimiter),
+	}
+}
+
+// NewAdaptiveRateLimitingAssumeRolePolicy creates a policy permitting each
+// pod the rate of requests per second set by limiter, with bursts of up to
+// burst requests.
+func NewAdaptiveRateLimitingAssumeRolePolicy(limiter *AdaptiveRateLimiter, burst int, idleTTL time.Duration) *RateLimitingAssumeRolePolicy {
+	policy := NewRateLimitingAssumeRolePolicy(limiter.max, burst, idleTTL)
+	policy.adaptive = limiter
+	return policy
+}
+
+// PolicyConfig describes the rate each pod is limited to, and its current
+// rate when adaptive
+func (p *RateLimitingAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	var limit interface{} = float64(p.limit)
+	if p.limit == rate.Inf {
+		limit = "inf"
+	}
+	config := map[string]interface{}{
+		"limit":   limit,
+		"burst":   p.burst,
+		"idleTTL": p.idleTTL.String(),
+	}
+	if p.adaptive != nil {
+		config["adaptive"] = true
+		config["currentLimit"] = float64(p.adaptive.Limit())
+	}
+	return config
+}
+
+func (p *RateLimitingAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	now := p.clock()
+	key := podKey(pod)
+	limit := p.limit
+	if p.adaptive != nil {
+		limit = p.adaptive.Limit()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.sweep(now)
+
+	l, ok := p.limiters[key]
+	if !ok {
+		l = &podLimiter{limiter: rate.NewLimiter(limit, p.burst)}
+		p.limiters[key] = l
+	} else if l.limiter.Limit() != limit {
+		l.limiter.SetLimitAt(now, limit)
+	}
+	l.lastSeen = now
+
+	if !l.limiter.AllowN(now, 1) {
+		return &rateLimitForbidden{pod: fmt.Sprintf("%s/%s", pod.GetNamespace(), pod.GetName()), limit: limit, burst: p.burst}, nil
+	}
+
+	return &allowed{}, nil
+}
+
+// sweep removes idle limiters, at most once per idle TTL. Must be called
+// with the lock held.
+func (p *RateLimitingAssumeRolePolicy) sweep(now time.Time) {
+	if now.Sub(p.lastSweep) < p.idleTTL {
+		return
+	}
+
+	for key, l := range p.limiters {
+		if now.Sub(l.lastSeen) >= p.idleTTL {
+			delete(p.limiters, key)
+		}
+	}
+	p.lastSweep = now
+}
+
+// podKey identifies the pod by its UID, falling back to its namespace
+// and name when the UID isn't set.
+func podKey(pod *v1.Pod) string {
+	if pod.GetUID() != "" {
+		return string(pod.GetUID())
+	}
+
+	return fmt.Sprintf("%s/%s", pod.GetNamespace(), pod.GetName())
+}
+
+type rateLimitForbidden struct {
+	pod   string
+	limit rate.Limit
+	burst int
+}
+
+func (f *rateLimitForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *rateLimitForbidden) Explanation() string {
+	return fmt.Sprintf("pod '%s' exceeded rate limit of %g requests per second (burst %d)", f.pod, float64(f.limit), f.burst)
+}
+
+func (f *rateLimitForbidden) Reason() DenialReason {
+	return ReasonRateLimit
+}
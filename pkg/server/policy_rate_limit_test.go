@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/testutil"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type stepClock struct {
+	now time.Time
+}
+
+func (c *stepClock) Now() time.Time {
+	return c.now
+}
+
+func (c *stepClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestRateLimitingPolicy(t *testing.T) {
+	clock := &stepClock{now: time.Unix(0, 0)}
+	policy := NewRateLimitingAssumeRolePolicy(1, 2, time.Minute)
+	policy.clock = clock.Now
+
+	red := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	red.UID = types.UID("red")
+	blue := testutil.NewPodWithRole("blue", "foo", "192.168.0.2", testutil.PhaseRunning, "blue_role")
+	blue.UID = types.UID("blue")
+
+	for i := 0; i < 2; i++ {
+		decision, _ := policy.IsAllowedAssumeRole(context.Background(), "red_role", red)
+		if !decision.IsAllowed() {
+			t.Error("expected to be allowed- within burst")
+		}
+	}
+
+	decision, _ := policy.IsAllowedAssumeRole(context.Background(), "red_role", red)
+	if decision.IsAllowed() {
+		t.Error("expected to be forbidden- burst exceeded")
+	}
+	if _, ok := decision.(*rateLimitForbidden); !ok {
+		t.Errorf("expected rate limit decision, was %T", decision)
+	}
+	if decision.Explanation() != "pod 'red/foo' exceeded rate limit of 1 requests per second (burst 2)" {
+		t.Error("unexpected explanation, was", decision.Explanation())
+	}
+
+	decision, _ = policy.IsAllowedAssumeRole(context.Background(), "blue_role", blue)
+	if !decision.IsAllowed() {
+		t.Error("expected to be allowed- other pods have their own limit")
+	}
+
+	clock.Advance(time.Second)
+	decision, _ = policy.IsAllowedAssumeRole(context.Background(), "red_role", red)
+	if !decision.IsAllowed() {
+		t.Error("expected to be allowed- limit replenished")
+	}
+}
+
+func TestRateLimitingPolicyExpiresIdleLimiters(t *testing.T) {
+	clock := &stepClock{now: time.Unix(0, 0)}
+	policy := NewRateLimitingAssumeRolePolicy(1, 1, time.Minute)
+	policy.clock = clock.Now
+
+	red := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	red.UID = types.UID("red")
+	blue := testutil.NewPodWithRole("blue", "foo", "192.168.0.2", testutil.PhaseRunning, "blue_role")
+	blue.UID = types.UID("blue")
+
+	policy.IsAllowedAssumeRole(context.Background(), "red_role", red)
+	clock.Advance(30 * time.Second)
+	policy.IsAllowedAssumeRole(context.Background(), "blue_role", blue)
+
+	if len(policy.limiters) != 2 {
+		t.Error("expected a limiter per pod, was", len(policy.limiters))
+	}
+
+	clock.Advance(45 * time.Second)
+	policy.IsAllowedAssumeRole(context.Background(), "blue_role", blue)
+
+	if _, ok := policy.limiters["red"]; ok {
+		t.Error("expected idle limiter to be removed")
+	}
+	if _, ok := policy.limiters["blue"]; !ok {
+		t.Error("expected active limiter to be kept")
+	}
+}
+
+func TestAdaptiveRateLimitingPolicy(t *testing.T) {
+	clock := &stepClock{now: time.Unix(0, 0)}
+	limiter := NewAdaptiveRateLimiter(2, 1, 10*time.Second)
+	limiter.clock = clock.Now
+	policy := NewAdaptiveRateLimitingAssumeRolePolicy(limiter, 1, time.Minute)
+	policy.clock = clock.Now
+
+	red := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	red.UID = types.UID("red")
+
+	decision, _ := policy.IsAllowedAssumeRole(context.Background(), "red_role", red)
+	if !decision.IsAllowed() {
+		t.Fatal("expected to be allowed- within burst")
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	decision, _ = policy.IsAllowedAssumeRole(context.Background(), "red_role", red)
+	if !decision.IsAllowed() {
+		t.Error("expected to be allowed- limit replenished at 2 requests per second")
+	}
+
+	limiter.Throttled()
+	policy.IsAllowedAssumeRole(context.Background(), "red_role", red)
+	clock.Advance(500 * time.Millisecond)
+	decision, _ = policy.IsAllowedAssumeRole(context.Background(), "red_role", red)
+	if decision.IsAllowed() {
+		t.Error("expected to be forbidden- limit halved to 1 request per second")
+	}
+	if decision.Explanation() != "pod 'red/foo' exceeded rate limit of 1 requests per second (burst 1)" {
+		t.Error("unexpected explanation, was", decision.Explanation())
+	}
+
+	if config := policy.PolicyConfig(); config["adaptive"] != true || config["currentLimit"] != 1.0 {
+		t.Error("unexpected config", config)
+	}
+}
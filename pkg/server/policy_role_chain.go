@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ChainedRoleAssumeRolePolicy validates the chain of roles assumed to reach the
+// requested role. Pods list the intermediate roles in their role chain
+// annotation; each intermediate role must be permitted by the hop policy
+// (normally the namespace policy), the chain must not contain a role more than
+// once and must have no more than maxDepth roles, including the requested
+// role. Pods without the annotation are allowed.
+type ChainedRoleAssumeRolePolicy struct {
+	hops     AssumeRolePolicy
+	resolver sts.ARNResolver
+	maxDepth int
+}
+
+func NewChainedRoleAssumeRolePolicy(hops AssumeRolePolicy, resolver sts.ARNResolver, maxDepth int) *ChainedRoleAssumeRolePolicy {
+	return &ChainedRoleAssumeRolePolicy{hops: hops, resolver: resolver, maxDepth: maxDepth}
+}
+
+// PolicyConfig describes the longest permitted chain and the policy checking
+// each intermediate role
+func (p *ChainedRoleAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"maxDepth": p.maxDepth,
+		"hops":     describePolicy(p.hops),
+	}
+}
+
+func (p *ChainedRoleAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	intermediates := k8s.PodRoleChain(pod)
+	if len(intermediates) == 0 {
+		return &allowed{}, nil
+	}
+
+	chain := append(intermediates, role)
+	if len(chain) > p.maxDepth {
+		return &roleChainForbidden{chain: chain, explanation: fmt.Sprintf("exceeds maximum depth %d", p.maxDepth)}, nil
+	}
+
+	var seen []*sts.ResolvedRole
+	for _, hop := range chain {
+		identity, err := p.resolver.Resolve(hop)
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range seen {
+			if identity.Equals(other) {
+				return &roleChainForbidden{chain: chain, explanation: fmt.Sprintf("contains '%s' more than once", identity.ARN)}, nil
+			}
+		}
+		seen = append(seen, identity)
+	}
+
+	for _, hop := range intermediates {
+		decision, err := p.hops.IsAllowedAssumeRole(ctx, hop, pod)
+		if err != nil {
+			return nil, err
+		}
+		if !decision.IsAllowed() {
+			return &roleChainForbidden{chain: chain, explanation: fmt.Sprintf("intermediate role '%s' forbidden: %s", hop, decision.Explanation())}, nil
+		}
+	}
+
+	return &allowed{}, nil
+}
+
+type roleChainForbidden struct {
+	chain       []string
+	explanation string
+}
+
+func (f *roleChainForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *roleChainForbidden) Explanation() string {
+	return fmt.Sprintf("role chain '%s' %s", strings.Join(f.chain, " -> "), f.explanation)
+}
+
+func (f *roleChainForbidden) Reason() DenialReason {
+	return ReasonRoleChain
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	kt "github.com/uswitch/kiam/pkg/k8s/testing"
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+func TestChainedRolePolicy(t *testing.T) {
+	resolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+	namespaces := kt.NewNamespaceFinder(testutil.NewNamespace("red", ".*/gateway_.*|.*/red_.*"))
+	hops := NewNamespacePermittedRoleNamePolicy(true, namespaces, resolver)
+
+	var tests = []struct {
+		name     string
+		chain    string
+		expected bool
+	}{
+		{"NoChain", "", true},
+		{"PermittedIntermediate", "gateway_a", true},
+		{"PermittedIntermediates", "gateway_a, gateway_b", true},
+		{"ForbiddenIntermediate", "gateway_a,blue_gateway", false},
+		{"ExceedsMaxDepth", "gateway_a,gateway_b,gateway_c", false},
+		{"Cycle", "gateway_a,red_role", false},
+		{"CycleResolvingToSameARN", "gateway_a,arn:aws:iam::123456789012:role/gateway_a", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+			if tt.chain != "" {
+				p.Annotations[k8s.AnnotationIAMRoleChainKey] = tt.chain
+			}
+
+			policy := NewChainedRoleAssumeRolePolicy(hops, resolver, 3)
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+			if !decision.IsAllowed() && decision.Reason() != ReasonRoleChain {
+				t.Errorf("expected reason %s, was %s", ReasonRoleChain, decision.Reason())
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+// RoleChangeRateAssumeRolePolicy forbids pods that changed their role
+// annotation more than the limit within the history's window, as rapid
+// changes can indicate an attempt to enumerate roles. Pods are forbidden until
+// enough changes fall outside the window.
+type RoleChangeRateAssumeRolePolicy struct {
+	history *RoleAnnotationHistory
+	limit   int
+	clock   ClockFunc
+}
+
+// NewRoleChangeRateAssumeRolePolicy creates the policy recording role
+// annotations in history. The history's depth must exceed the limit for
+// changes beyond it to be counted.
+func NewRoleChangeRateAssumeRolePolicy(history *RoleAnnotationHistory, limit int, clock ClockFunc) *RoleChangeRateAssumeRolePolicy {
+	return &RoleChangeRateAssumeRolePolicy{history: history, limit: limit, clock: clock}
+}
+
+// PolicyConfig describes the change limit and the history's window
+func (p *RoleChangeRateAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"limit":  p.limit,
+		"window": p.history.window.String(),
+		"depth":  p.history.depth,
+	}
+}
+
+func (p *RoleChangeRateAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	changes := p.history.Record(pod, k8s.PodRole(pod), p.clock())
+	if changes > p.limit {
+		return &roleChangeRateForbidden{pod: fmt.Sprintf("%s/%s", pod.GetNamespace(), pod.GetName()), changes: changes, limit: p.limit, window: p.history.window}, nil
+	}
+
+	return &allowed{}, nil
+}
+
+type roleChangeRateForbidden struct {
+	pod     string
+	changes int
+	limit   int
+	window  time.Duration
+}
+
+func (f *roleChangeRateForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *roleChangeRateForbidden) Explanation() string {
+	return fmt.Sprintf("pod '%s' changed its role annotation %d times within %s, more than the limit of %d", f.pod, f.changes, f.window, f.limit)
+}
+
+func (f *roleChangeRateForbidden) Reason() DenialReason {
+	return ReasonRoleChangeRate
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+func TestRoleChangeRateAssumeRolePolicy(t *testing.T) {
+	now := time.Now()
+	policy := NewRoleChangeRateAssumeRolePolicy(NewRoleAnnotationHistory(5, time.Minute), 2, func() time.Time { return now })
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+	for i, role := range []string{"red_role", "blue_role", "green_role", "red_role"} {
+		p.Annotations[k8s.AnnotationIAMRoleKey] = role
+		decision, err := policy.IsAllowedAssumeRole(context.Background(), role, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := i <= 2
+		if decision.IsAllowed() != expected {
+			t.Errorf("%s: expected allowed to be %t: %s", role, expected, decision.Explanation())
+		}
+		if !expected && decision.Reason() != ReasonRoleChangeRate {
+			t.Error("unexpected reason", decision.Reason())
+		}
+	}
+
+	now = now.Add(time.Minute)
+	decision, _ := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	if !decision.IsAllowed() {
+		t.Error("expected changes outside the window to be allowed:", decision.Explanation())
+	}
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
+	"github.com/uswitch/kiam/pkg/logging"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const defaultServiceAccountName = "default"
+
+// ServiceAccountAssumeRolePolicy only permits pods to assume roles mapped to
+// their service account in a ConfigMap. ConfigMap keys are namespaces, each
+// value contains newline separated serviceaccount=regexp entries. The regexp
+// is matched against the full role ARN. The mapping is reloaded whenever the
+// ConfigMap changes.
+type ServiceAccountAssumeRolePolicy struct {
+	resolver sts.ARNResolver
+	watcher  *k8s.ConfigMapWatcher
+	logger   *slog.Logger
+	changeNotifier
+
+	mu       sync.RWMutex
+	accounts map[string]map[string][]*regexp.Regexp
+}
+
+// NewServiceAccountAssumeRolePolicy creates the policy watching the ConfigMap
+// from source. Run must be called to start watching.
+func NewServiceAccountAssumeRolePolicy(resolver sts.ARNResolver, source cache.ListerWatcher, syncInterval time.Duration) *ServiceAccountAssumeRolePolicy {
+	p := &ServiceAccountAssumeRolePolicy{resolver: resolver, logger: slog.Default(), accounts: map[string]map[string][]*regexp.Regexp{}}
+	p.watcher = k8s.NewConfigMapWatcher(source, syncInterval, p.update)
+	return p
+}
+
+// Run starts watching the ConfigMap. Blocks until the mapping has been loaded
+func (p *ServiceAccountAssumeRolePolicy) Run(ctx context.Context) error {
+	p.logger = logging.FromContext(ctx)
+	return p.watcher.Run(ctx)
+}
+
+// PolicyConfig describes the size of the loaded mapping
+func (p *ServiceAccountAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return map[string]interface{}{
+		"namespaces": len(p.accounts),
+	}
+}
+
+func (p *ServiceAccountAssumeRolePolicy) update(data map[string]string) {
+	accounts := map[string]map[string][]*regexp.Regexp{}
+
+	for namespace, value := range data {
+		scanner := bufio.NewScanner(strings.NewReader(value))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			serviceAccount, expression, err := parseServiceAccountEntry(line)
+			if err != nil {
+				p.logger.Warn("ignoring invalid service account entry", "pod.namespace", namespace, "error", err)
+				continue
+			}
+
+			if accounts[namespace] == nil {
+				accounts[namespace] = map[string][]*regexp.Regexp{}
+			}
+			accounts[namespace][serviceAccount] = append(accounts[namespace][serviceAccount], expression)
+		}
+	}
+
+	p.mu.Lock()
+	p.accounts = accounts
+	p.mu.Unlock()
+	p.notify()
+
+	p.logger.Info("loaded service account roles", "serviceaccount.namespaces", len(accounts))
+}
+
+func parseServiceAccountEntry(line string) (string, *regexp.Regexp, error) {
+	parts := strings.SplitN(line, "=", 2)
+	if len(parts) != 2 {
+		return "", nil, fmt.Errorf("expected serviceaccount=regexp, was '%s'", line)
+	}
+
+	serviceAccount := strings.TrimSpace(parts[0])
+	expression := strings.TrimSpace(parts[1])
+	if serviceAccount == "" || expression == "" {
+		return "", nil, fmt.Errorf("expected serviceaccount=regexp, was '%s'", line)
+	}
+
+	re, err := regexp.Compile(fmt.Sprintf("^%s$", expression))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return serviceAccount, re, nil
+}
+
+func (p *ServiceAccountAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	requestedIdentity, err := p.resolver.Resolve(role)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceAccount := podServiceAccount(pod)
+
+	p.mu.RLock()
+	expressions := p.accounts[pod.GetNamespace()][serviceAccount]
+	p.mu.RUnlock()
+
+	for _, expression := range expressions {
+		if expression.MatchString(requestedIdentity.ARN.String()) {
+			return &allowed{}, nil
+		}
+	}
+
+	return &serviceAccountForbidden{namespace: pod.GetNamespace(), serviceAccount: serviceAccount, role: requestedIdentity.ARN.String()}, nil
+}
+
+type serviceAccountForbidden struct {
+	namespace      string
+	serviceAccount string
+	role           string
+}
+
+func (f *serviceAccountForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *serviceAccountForbidden) Explanation() string {
+	return fmt.Sprintf("service account '%s' in namespace '%s' not permitted to assume '%s'", f.serviceAccount, f.namespace, f.role)
+}
+
+func (f *serviceAccountForbidden) Reason() DenialReason {
+	return ReasonServiceAccount
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/testutil"
+	kt "k8s.io/client-go/tools/cache/testing"
+)
+
+func TestServiceAccountPolicy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := kt.NewFakeControllerSource()
+	defer source.Shutdown()
+	source.Add(testutil.NewConfigMap("kube-system", "kiam-service-accounts", map[string]string{
+		"red":  "# red team\nbuilder=arn:aws:iam::123456789012:role/red_.*\ndefault=arn:aws:iam::123456789012:role/readonly\n",
+		"blue": "deployer=arn:aws:iam::123456789012:role/blue_role\ninvalid",
+	}))
+
+	policy := NewServiceAccountAssumeRolePolicy(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), source, time.Minute)
+	if err := policy.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name           string
+		namespace      string
+		serviceAccount string
+		role           string
+		expected       bool
+	}{
+		{"MatchingServiceAccount", "red", "builder", "red_role", true},
+		{"MatchingServiceAccountWithARN", "red", "builder", "arn:aws:iam::123456789012:role/red_other", true},
+		{"DefaultServiceAccount", "red", "", "readonly", true},
+		{"WrongServiceAccount", "red", "builder", "readonly", false},
+		{"RoleNotMatched", "red", "builder", "blue_role", false},
+		{"UnmappedServiceAccount", "red", "other", "red_role", false},
+		{"OtherNamespace", "blue", "deployer", "blue_role", true},
+		{"UnmappedNamespace", "green", "default", "readonly", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testutil.NewPodWithRole(tt.namespace, "foo", "192.168.0.1", testutil.PhaseRunning, tt.role)
+			p.Spec.ServiceAccountName = tt.serviceAccount
+
+			decision, err := policy.IsAllowedAssumeRole(ctx, tt.role, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+		})
+	}
+
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "blue_role")
+	p.Spec.ServiceAccountName = "builder"
+	decision, _ := policy.IsAllowedAssumeRole(ctx, "blue_role", p)
+	if decision.Explanation() != "service account 'builder' in namespace 'red' not permitted to assume 'arn:aws:iam::123456789012:role/blue_role'" {
+		t.Error("unexpected explanation, was", decision.Explanation())
+	}
+}
+
+func TestServiceAccountPolicyReloadsConfigMap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := kt.NewFakeControllerSource()
+	defer source.Shutdown()
+	source.Add(testutil.NewConfigMap("kube-system", "kiam-service-accounts", map[string]string{"red": "builder=.*/red_role"}))
+
+	policy := NewServiceAccountAssumeRolePolicy(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), source, time.Minute)
+	if err := policy.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	red := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	red.Spec.ServiceAccountName = "builder"
+	eventuallyAllowed(t, policy, policy, "red_role", red, true)
+
+	source.Modify(testutil.NewConfigMap("kube-system", "kiam-service-accounts", map[string]string{"red": "deployer=.*/red_role"}))
+	eventuallyAllowed(t, policy, policy, "red_role", red, false)
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+// SessionRequest describes the STS session that will be created if the assume
+// role request is permitted. It is carried in the context passed to
+// IsAllowedAssumeRole, rather than as an argument, so that policies which
+// don't need it are unaffected.
+type SessionRequest struct {
+	Duration time.Duration
+	// Name is the session name requested by the pod, empty when the default
+	// session name is used
+	Name string
+	// Tags are the session tags requested by the pod
+	Tags map[string]string
+}
+
+type sessionRequestKey struct{}
+
+// WithSessionRequest returns a context carrying the session request
+func WithSessionRequest(ctx context.Context, request *SessionRequest) context.Context {
+	return context.WithValue(ctx, sessionRequestKey{}, request)
+}
+
+// SessionRequestFromContext returns the session request carried by ctx, if any
+func SessionRequestFromContext(ctx context.Context) (*SessionRequest, bool) {
+	request, ok := ctx.Value(sessionRequestKey{}).(*SessionRequest)
+	return request, ok
+}
+
+// MaxSessionDurationAssumeRolePolicy forbids sessions longer than the maximum
+// duration annotated on the pod's namespace. Requests without a SessionRequest
+// in their context, or in namespaces without the annotation, are allowed.
+type MaxSessionDurationAssumeRolePolicy struct {
+	namespaces k8s.NamespaceFinder
+}
+
+func NewMaxSessionDurationAssumeRolePolicy(n k8s.NamespaceFinder) *MaxSessionDurationAssumeRolePolicy {
+	return &MaxSessionDurationAssumeRolePolicy{namespaces: n}
+}
+
+func (p *MaxSessionDurationAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	request, ok := SessionRequestFromContext(ctx)
+	if !ok {
+		return &allowed{}, nil
+	}
+
+	ns, err := p.namespaces.FindNamespace(ctx, pod.GetObjectMeta().GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	if ns == nil {
+		return &allowed{}, nil
+	}
+
+	annotation := ns.GetAnnotations()[k8s.AnnotationMaxSessionDurationKey]
+	if annotation == "" {
+		return &allowed{}, nil
+	}
+
+	max, err := time.ParseDuration(annotation)
+	if err != nil {
+		return nil, fmt.Errorf("invalid max session duration '%s': %v", annotation, err)
+	}
+
+	if request.Duration > max {
+		return &sessionDurationForbidden{requested: request.Duration, max: max}, nil
+	}
+
+	return &allowed{}, nil
+}
+
+type sessionDurationForbidden struct {
+	requested time.Duration
+	max       time.Duration
+}
+
+func (f *sessionDurationForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *sessionDurationForbidden) Explanation() string {
+	return fmt.Sprintf("requested session duration '%s' exceeds namespace maximum '%s'", f.requested, f.max)
+}
+
+func (f *sessionDurationForbidden) Reason() DenialReason {
+	return ReasonSessionDuration
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	kt "github.com/uswitch/kiam/pkg/k8s/testing"
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+func TestMaxSessionDurationPolicy(t *testing.T) {
+	var tests = []struct {
+		name       string
+		annotation string
+		request    *SessionRequest
+		expected   bool
+	}{
+		{"WithinMaximum", "1h", &SessionRequest{Duration: 15 * time.Minute}, true},
+		{"AtMaximum", "1h", &SessionRequest{Duration: time.Hour}, true},
+		{"ExceedsMaximum", "30m", &SessionRequest{Duration: time.Hour}, false},
+		{"NoAnnotation", "", &SessionRequest{Duration: 12 * time.Hour}, true},
+		{"NoSessionRequest", "30m", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := testutil.NewNamespace("red", ".*")
+			if tt.annotation != "" {
+				n.Annotations[k8s.AnnotationMaxSessionDurationKey] = tt.annotation
+			}
+			policy := NewMaxSessionDurationAssumeRolePolicy(kt.NewNamespaceFinder(n))
+
+			ctx := context.Background()
+			if tt.request != nil {
+				ctx = WithSessionRequest(ctx, tt.request)
+			}
+
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+			decision, err := policy.IsAllowedAssumeRole(ctx, "red_role", p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+		})
+	}
+}
+
+func TestMaxSessionDurationPolicyExplanation(t *testing.T) {
+	n := testutil.NewNamespace("red", ".*")
+	n.Annotations[k8s.AnnotationMaxSessionDurationKey] = "30m"
+	policy := NewMaxSessionDurationAssumeRolePolicy(kt.NewNamespaceFinder(n))
+
+	ctx := WithSessionRequest(context.Background(), &SessionRequest{Duration: time.Hour})
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	decision, _ := policy.IsAllowedAssumeRole(ctx, "red_role", p)
+
+	if decision.Explanation() != "requested session duration '1h0m0s' exceeds namespace maximum '30m0s'" {
+		t.Error("unexpected explanation, was", decision.Explanation())
+	}
+}
+
+func TestMaxSessionDurationPolicyInvalidAnnotation(t *testing.T) {
+	n := testutil.NewNamespace("red", ".*")
+	n.Annotations[k8s.AnnotationMaxSessionDurationKey] = "forever"
+	policy := NewMaxSessionDurationAssumeRolePolicy(kt.NewNamespaceFinder(n))
+
+	ctx := WithSessionRequest(context.Background(), &SessionRequest{Duration: time.Hour})
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	_, err := policy.IsAllowedAssumeRole(ctx, "red_role", p)
+	if err == nil {
+		t.Error("expected error for invalid annotation")
+	}
+}
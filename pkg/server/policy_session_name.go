@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+// RoleSessionNamePolicy ensures the session name requested by the pod matches
+// the pattern annotated on its namespace. The pattern is a regexp that must
+// match the whole session name, with {pod_name}, {namespace} and {uid}
+// replaced by the pod's (quoted) values. Pods that don't request a session
+// name, or in namespaces without the annotation, are allowed.
+type RoleSessionNamePolicy struct {
+	namespaces k8s.NamespaceFinder
+}
+
+func NewRoleSessionNamePolicy(n k8s.NamespaceFinder) *RoleSessionNamePolicy {
+	return &RoleSessionNamePolicy{namespaces: n}
+}
+
+func (p *RoleSessionNamePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	requested := k8s.PodSessionName(pod)
+	if request, ok := SessionRequestFromContext(ctx); ok {
+		requested = request.Name
+	}
+	if requested == "" {
+		return &allowed{}, nil
+	}
+
+	ns, err := p.namespaces.FindNamespace(ctx, pod.GetObjectMeta().GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	if ns == nil {
+		return &allowed{}, nil
+	}
+
+	pattern := ns.GetAnnotations()[k8s.AnnotationSessionNamePatternKey]
+	if pattern == "" {
+		return &allowed{}, nil
+	}
+
+	expression, err := sessionNameExpression(pattern, pod)
+	if err != nil {
+		return nil, err
+	}
+
+	if !expression.MatchString(requested) {
+		return &sessionNameForbidden{requested: requested, pattern: pattern}, nil
+	}
+
+	return &allowed{}, nil
+}
+
+// sessionNameExpression substitutes the pod's identity into pattern, anchoring
+// the resulting expression.
+func sessionNameExpression(pattern string, pod *v1.Pod) (*regexp.Regexp, error) {
+	replacer := strings.NewReplacer(
+		"{pod_name}", regexp.QuoteMeta(pod.GetName()),
+		"{namespace}", regexp.QuoteMeta(pod.GetNamespace()),
+		"{uid}", regexp.QuoteMeta(string(pod.GetUID())),
+	)
+
+	expression, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", replacer.Replace(pattern)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid session name pattern '%s': %v", pattern, err)
+	}
+	return expression, nil
+}
+
+type sessionNameForbidden struct {
+	requested string
+	pattern   string
+}
+
+func (f *sessionNameForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *sessionNameForbidden) Explanation() string {
+	return fmt.Sprintf("requested session name '%s' doesn't match namespace pattern '%s'", f.requested, f.pattern)
+}
+
+func (f *sessionNameForbidden) Reason() DenialReason {
+	return ReasonSessionName
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	kt "github.com/uswitch/kiam/pkg/k8s/testing"
+	"github.com/uswitch/kiam/pkg/testutil"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestRoleSessionNamePolicy(t *testing.T) {
+	var tests = []struct {
+		name        string
+		pattern     string
+		sessionName string
+		expected    bool
+	}{
+		{"NoPattern", "", "anything", true},
+		{"NoSessionName", "{pod_name}", "", true},
+		{"MatchesPodName", "{pod_name}", "foo", true},
+		{"MatchesNamespaceAndPodName", "{namespace}-{pod_name}", "red-foo", true},
+		{"MatchesUID", "{uid}", "1234-abcd", true},
+		{"MatchesExpression", "{namespace}-.*", "red-anything", true},
+		{"Mismatch", "{pod_name}", "bar", false},
+		{"MustMatchWholeName", "{pod_name}", "foo-bar", false},
+		{"OtherNamespace", "{namespace}-.*", "blue-foo", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := testutil.NewNamespace("red", ".*")
+			if tt.pattern != "" {
+				n.Annotations[k8s.AnnotationSessionNamePatternKey] = tt.pattern
+			}
+			policy := NewRoleSessionNamePolicy(kt.NewNamespaceFinder(n))
+
+			p := testutil.NewPodWithSessionName("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role", tt.sessionName)
+			p.UID = types.UID("1234-abcd")
+
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+		})
+	}
+}
+
+func TestRoleSessionNamePolicyUsesSessionRequest(t *testing.T) {
+	n := testutil.NewNamespace("red", ".*")
+	n.Annotations[k8s.AnnotationSessionNamePatternKey] = "{pod_name}"
+	policy := NewRoleSessionNamePolicy(kt.NewNamespaceFinder(n))
+
+	p := testutil.NewPodWithSessionName("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role", "foo")
+	ctx := WithSessionRequest(context.Background(), &SessionRequest{Name: "bar"})
+
+	decision, err := policy.IsAllowedAssumeRole(ctx, "red_role", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.IsAllowed() {
+		t.Error("expected requested session name to be checked")
+	}
+	if decision.Reason() != ReasonSessionName {
+		t.Error("unexpected reason", decision.Reason())
+	}
+}
+
+func TestRoleSessionNamePolicyInvalidPattern(t *testing.T) {
+	n := testutil.NewNamespace("red", ".*")
+	n.Annotations[k8s.AnnotationSessionNamePatternKey] = "{pod_name}(["
+	policy := NewRoleSessionNamePolicy(kt.NewNamespaceFinder(n))
+
+	p := testutil.NewPodWithSessionName("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role", "foo")
+	if _, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p); err == nil {
+		t.Error("expected invalid pattern to error")
+	}
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	maxSessionTags           = 50
+	maxSessionTagKeyLength   = 128
+	maxSessionTagValueLength = 256
+)
+
+// SessionTagAssumeRolePolicy ensures the STS session tags requested by the pod
+// are valid, and include the tags annotated as required on its namespace.
+// Required tags without a value can have any value. Pods in namespaces without
+// the annotation are only checked for valid tags.
+type SessionTagAssumeRolePolicy struct {
+	namespaces k8s.NamespaceFinder
+}
+
+func NewSessionTagAssumeRolePolicy(n k8s.NamespaceFinder) *SessionTagAssumeRolePolicy {
+	return &SessionTagAssumeRolePolicy{namespaces: n}
+}
+
+func (p *SessionTagAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	var requested map[string]string
+	if request, ok := SessionRequestFromContext(ctx); ok {
+		requested = request.Tags
+	} else {
+		tags, err := k8s.PodSessionTags(pod)
+		if err != nil {
+			return nil, fmt.Errorf("invalid session tags: %v", err)
+		}
+		requested = tags
+	}
+
+	if problems := validateSessionTags(requested); len(problems) > 0 {
+		return &invalidTags{problems: problems}, nil
+	}
+
+	ns, err := p.namespaces.FindNamespace(ctx, pod.GetObjectMeta().GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	if ns == nil {
+		return &allowed{}, nil
+	}
+
+	annotation := ns.GetAnnotations()[k8s.AnnotationRequiredSessionTagsKey]
+	if annotation == "" {
+		return &allowed{}, nil
+	}
+	required, err := k8s.ParseTags(annotation)
+	if err != nil {
+		return nil, fmt.Errorf("invalid required session tags '%s': %v", annotation, err)
+	}
+
+	var missing []string
+	for key, value := range required {
+		requestedValue, ok := requested[key]
+		if !ok || (value != "" && requestedValue != value) {
+			missing = append(missing, formatRequiredTag(key, value))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return &sessionTagsForbidden{missing: missing}, nil
+	}
+
+	return &allowed{}, nil
+}
+
+func formatRequiredTag(key, value string) string {
+	if value == "" {
+		return key
+	}
+	return key + "=" + value
+}
+
+// validateSessionTags returns why tags can't be used as STS session tags, if
+// they can't
+func validateSessionTags(tags map[string]string) []string {
+	var problems []string
+	if len(tags) > maxSessionTags {
+		problems = append(problems, fmt.Sprintf("%d tags requested, at most %d are allowed", len(tags), maxSessionTags))
+	}
+
+	keys := map[string]string{}
+	for key, value := range tags {
+		switch {
+		case len(key) > maxSessionTagKeyLength:
+			problems = append(problems, fmt.Sprintf("key '%s' is longer than %d characters", key, maxSessionTagKeyLength))
+		case !validTagCharacters(key):
+			problems = append(problems, fmt.Sprintf("key '%s' contains invalid characters", key))
+		case strings.HasPrefix(strings.ToLower(key), "aws:"):
+			problems = append(problems, fmt.Sprintf("key '%s' uses the reserved aws: prefix", key))
+		}
+
+		// keys must be unique regardless of case
+		if other, duplicate := keys[strings.ToLower(key)]; duplicate {
+			problems = append(problems, fmt.Sprintf("keys '%s' and '%s' differ only in case", other, key))
+		}
+		keys[strings.ToLower(key)] = key
+
+		switch {
+		case len(value) > maxSessionTagValueLength:
+			problems = append(problems, fmt.Sprintf("value of '%s' is longer than %d characters", key, maxSessionTagValueLength))
+		case !validTagCharacters(value):
+			problems = append(problems, fmt.Sprintf("value of '%s' contains invalid characters", key))
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+// validTagCharacters reports whether s only contains letters, numbers, spaces
+// and the characters _ . : / = + - @
+func validTagCharacters(s string) bool {
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsSpace(r) || strings.ContainsRune("_.:/=+-@", r) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+type invalidTags struct {
+	problems []string
+}
+
+func (f *invalidTags) IsAllowed() bool {
+	return false
+}
+
+func (f *invalidTags) Explanation() string {
+	return fmt.Sprintf("invalid session tags: %s", strings.Join(f.problems, "; "))
+}
+
+func (f *invalidTags) Reason() DenialReason {
+	return ReasonSessionTags
+}
+
+type sessionTagsForbidden struct {
+	missing []string
+}
+
+func (f *sessionTagsForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *sessionTagsForbidden) Explanation() string {
+	return fmt.Sprintf("session tags required by namespace not requested: %s", strings.Join(f.missing, ", "))
+}
+
+func (f *sessionTagsForbidden) Reason() DenialReason {
+	return ReasonSessionTags
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	kt "github.com/uswitch/kiam/pkg/k8s/testing"
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+func TestSessionTagAssumeRolePolicy(t *testing.T) {
+	var tests = []struct {
+		name     string
+		required string
+		tags     string
+		expected bool
+	}{
+		{"NoRequiredTags", "", "", true},
+		{"RequiredTagRequested", "team=payments", "team=payments", true},
+		{"RequiredKeyWithAnyValue", "team,cost-center=1234", "team=payments, cost-center=1234", true},
+		{"AdditionalTags", "team", "team=payments,env=prod", true},
+		{"RequiredTagMissing", "team", "env=prod", false},
+		{"RequiredTagDifferentValue", "team=payments", "team=search", false},
+		{"NoTagsRequested", "team", "", false},
+		{"ValidTagsWithoutRequirement", "", "team=payments,path=/a/b:c@d+e", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := testutil.NewNamespace("red", ".*")
+			if tt.required != "" {
+				n.Annotations[k8s.AnnotationRequiredSessionTagsKey] = tt.required
+			}
+			policy := NewSessionTagAssumeRolePolicy(kt.NewNamespaceFinder(n))
+
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+			p.Annotations[k8s.AnnotationIAMSessionTagsKey] = tt.tags
+
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+			if !tt.expected && decision.Reason() != ReasonSessionTags {
+				t.Error("unexpected reason", decision.Reason())
+			}
+		})
+	}
+}
+
+func TestSessionTagAssumeRolePolicyInvalidTags(t *testing.T) {
+	tooMany := map[string]string{}
+	for i := 0; i <= maxSessionTags; i++ {
+		tooMany[fmt.Sprintf("tag%d", i)] = "value"
+	}
+
+	var tests = []struct {
+		name string
+		tags map[string]string
+	}{
+		{"TooMany", tooMany},
+		{"KeyTooLong", map[string]string{strings.Repeat("k", 129): "value"}},
+		{"ValueTooLong", map[string]string{"key": strings.Repeat("v", 257)}},
+		{"InvalidKeyCharacters", map[string]string{"team!": "payments"}},
+		{"InvalidValueCharacters", map[string]string{"team": "pay#ments"}},
+		{"ReservedPrefix", map[string]string{"AWS:team": "payments"}},
+		{"KeysDifferingInCase", map[string]string{"team": "payments", "Team": "search"}},
+	}
+
+	policy := NewSessionTagAssumeRolePolicy(kt.NewNamespaceFinder(testutil.NewNamespace("red", ".*")))
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithSessionRequest(context.Background(), &SessionRequest{Tags: tt.tags})
+			decision, err := policy.IsAllowedAssumeRole(ctx, "red_role", p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := decision.(*invalidTags); !ok {
+				t.Errorf("expected invalid tags, was %T: %s", decision, decision.Explanation())
+			}
+		})
+	}
+}
+
+func TestSessionTagAssumeRolePolicyUsesSessionRequest(t *testing.T) {
+	n := testutil.NewNamespace("red", ".*")
+	n.Annotations[k8s.AnnotationRequiredSessionTagsKey] = "team"
+	policy := NewSessionTagAssumeRolePolicy(kt.NewNamespaceFinder(n))
+
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	p.Annotations[k8s.AnnotationIAMSessionTagsKey] = "team=payments"
+	ctx := WithSessionRequest(context.Background(), &SessionRequest{Tags: map[string]string{}})
+
+	decision, err := policy.IsAllowedAssumeRole(ctx, "red_role", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.IsAllowed() {
+		t.Error("expected requested tags to be checked")
+	}
+}
+
+func TestSessionTagAssumeRolePolicyMalformedAnnotation(t *testing.T) {
+	policy := NewSessionTagAssumeRolePolicy(kt.NewNamespaceFinder(testutil.NewNamespace("red", ".*")))
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	p.Annotations[k8s.AnnotationIAMSessionTagsKey] = "=payments"
+
+	if _, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p); err == nil {
+		t.Error("expected error with malformed tags")
+	}
+}
@@ -15,12 +15,20 @@ package server
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/uswitch/kiam/pkg/aws/sts"
+	"github.com/uswitch/kiam/pkg/k8s"
 	kt "github.com/uswitch/kiam/pkg/k8s/testing"
 	"github.com/uswitch/kiam/pkg/testutil"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 type fakePolicy struct {
@@ -29,11 +37,12 @@ type fakePolicy struct {
 }
 
 func (f fakePolicy) IsAllowedAssumeRole(ctx context.Context, roleName string, pod *v1.Pod) (Decision, error) {
-	return f.decision, nil
+	return f.decision, f.err
 }
 
 func TestRequestedRolePolicy(t *testing.T) {
 	p := testutil.NewPodWithRole("namespace", "name", "192.168.0.1", testutil.PhaseRunning, "myrole")
+	p.UID = types.UID("abc-123")
 	f := kt.NewStubFinder(p)
 
 	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
@@ -62,7 +71,7 @@ func TestRequestedRolePolicy(t *testing.T) {
 		t.Error("role is different, should be denied", decision.Explanation())
 	}
 
-	if decision.Explanation() != "requested 'wrongrole' but annotated with 'myrole', forbidden" {
+	if decision.Explanation() != "requested 'wrongrole' but pod (namespace 'namespace', uid 'abc-123') annotated with 'arn:aws:iam::123456789012:role/myrole', forbidden" {
 		t.Error("unexpected explanation, was", decision.Explanation())
 	}
 
@@ -72,6 +81,26 @@ func TestRequestedRolePolicy(t *testing.T) {
 	}
 }
 
+func TestRequestedRolePolicyWithoutAnnotation(t *testing.T) {
+	p := testutil.NewPodWithRole("namespace", "name", "192.168.0.1", testutil.PhaseRunning, "")
+	p.Annotations = nil
+	policy := NewRequestingAnnotatedRolePolicy(kt.NewStubFinder(p), sts.DefaultResolver("arn:aws:iam::123456789012:role/"))
+
+	decision, err := policy.IsAllowedAssumeRole(context.Background(), "myrole", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.IsAllowed() {
+		t.Error("pod isn't annotated, should be denied")
+	}
+	if decision.Explanation() != "pod has no role annotation" {
+		t.Error("unexpected explanation, was", decision.Explanation())
+	}
+	if !errors.Is(decision.(error), ErrRoleMismatch) {
+		t.Error("expected to wrap ErrRoleMismatch")
+	}
+}
+
 func TestRequestedRolePolicyWithSlash(t *testing.T) {
 	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
 	p := testutil.NewPodWithRole("namespace", "name", "192.168.0.1", testutil.PhaseRunning, "/myrole")
@@ -109,10 +138,10 @@ func TestRequestedRolePolicyWithSlash(t *testing.T) {
 }
 
 func TestNamespacePolicy(t *testing.T) {
-	n := testutil.NewNamespace("red", "^red.*$|^.red.*$")
+	n := testutil.NewNamespace("red", "^arn:aws:iam::123456789012:role/red.*$")
 	nf := kt.NewNamespaceFinder(n)
 	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
-	arnResolver := sts.DefaultResolver("")
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
 
 	policy := NewNamespacePermittedRoleNamePolicy(true, nf, arnResolver)
 	decision, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
@@ -139,7 +168,7 @@ func TestNamespacePolicy(t *testing.T) {
 		t.Errorf("expected to be forbidden- requesting role that fails regexp")
 	}
 
-	if decision.Explanation() != "namespace policy expression '^red.*$|^.red.*$' forbids role 'orange_role'" {
+	if decision.Explanation() != "namespace policy expression '^arn:aws:iam::123456789012:role/red.*$' forbids role 'arn:aws:iam::123456789012:role/orange_role'" {
 		t.Error("unexpected explanation, was", decision.Explanation())
 	}
 
@@ -150,10 +179,10 @@ func TestNamespacePolicy(t *testing.T) {
 }
 
 func TestNamespacePolicyWithSlash(t *testing.T) {
-	n := testutil.NewNamespace("red", "^red.*$|^.red.*$")
+	n := testutil.NewNamespace("red", "^arn:aws:iam::123456789012:role/red.*$")
 	nf := kt.NewNamespaceFinder(n)
 	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "/red_role")
-	arnResolver := sts.DefaultResolver("")
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
 
 	policy := NewNamespacePermittedRoleNamePolicy(true, nf, arnResolver)
 	decision, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
@@ -374,17 +403,13 @@ func TestNotAllowedWithoutARNResolverBaseRole(t *testing.T) {
 	arnResolver := sts.DefaultResolver("")
 
 	policy := NewNamespacePermittedRoleNamePolicy(true, nf, arnResolver)
-	decision, _ := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
-
-	if decision.IsAllowed() {
-		t.Error("expected to be forbidden- requesting role that fails base role regexp")
+	if _, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p); err == nil {
+		t.Error("expected error- role doesn't resolve to an arn without a base role")
 	}
 
 	policy = NewNamespacePermittedRoleNamePolicy(true, nf, arnResolver)
-	decision, _ = policy.IsAllowedAssumeRole(context.Background(), "/red_role", p)
-
-	if decision.IsAllowed() {
-		t.Error("expected to be forbidden- requesting role that fails base role regexp")
+	if _, err := policy.IsAllowedAssumeRole(context.Background(), "/red_role", p); err == nil {
+		t.Error("expected error- role doesn't resolve to an arn without a base role")
 	}
 }
 
@@ -471,3 +496,495 @@ func TestNotAllowedWithoutExactSubPathInNamespace(t *testing.T) {
 		t.Error("expected to be forbidden- namespace role DOES NOT match role subpath")
 	}
 }
+
+func TestCompositePolicyAppend(t *testing.T) {
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+	policy := Policies(fakePolicy{decision: &allowed{}})
+	decision, _ := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	if !decision.IsAllowed() {
+		t.Error("expected to be allowed")
+	}
+
+	policy.Append(fakePolicy{decision: &forbidden{}})
+	decision, _ = policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	if decision.IsAllowed() {
+		t.Error("expected to be forbidden by appended policy")
+	}
+}
+
+func TestCompositePolicyRemove(t *testing.T) {
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+	policy := Policies(
+		fakePolicy{decision: &allowed{}},
+		NamedPolicy("deny", fakePolicy{decision: &forbidden{}}),
+	)
+
+	if policy.Remove("unknown") {
+		t.Error("expected nothing to be removed")
+	}
+
+	decision, _ := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	if decision.IsAllowed() {
+		t.Error("expected to be forbidden")
+	}
+
+	if !policy.Remove("deny") {
+		t.Error("expected named policy to be removed")
+	}
+
+	decision, _ = policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	if !decision.IsAllowed() {
+		t.Error("expected to be allowed once named policy removed")
+	}
+}
+
+func TestCompositePolicyConcurrentModification(t *testing.T) {
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	policy := Policies()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			policy.Append(NamedPolicy("allow", fakePolicy{decision: &allowed{}}))
+			policy.Remove("allow")
+		}()
+		go func() {
+			defer wg.Done()
+			policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNamespacePolicyCachesCompiledExpressions(t *testing.T) {
+	n := testutil.NewNamespace("red", "^arn:aws:iam::123456789012:role/red.*$")
+	nf := kt.NewNamespaceFinder(n)
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+
+	policy := NewNamespacePermittedRoleNamePolicyWithCache(true, nf, arnResolver, 1)
+	for i := 0; i < 2; i++ {
+		decision, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		if !decision.IsAllowed() {
+			t.Error("expected to be allowed- pod in correct namespace")
+		}
+	}
+
+	if policy.expressions.Len() != 1 {
+		t.Error("expected compiled expression to be cached, was", policy.expressions.Len())
+	}
+
+	n.Annotations["iam.amazonaws.com/permitted"] = "^orange.*$"
+	decision, _ := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	if decision.IsAllowed() {
+		t.Error("expected to be forbidden- namespace expression changed")
+	}
+
+	if !policy.expressions.Contains("^orange.*$") || policy.expressions.Len() != 1 {
+		t.Error("expected least recently used expression to be evicted")
+	}
+}
+
+func TestNamespacePolicyMultipleExpressions(t *testing.T) {
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+
+	cases := []struct {
+		name       string
+		annotation string
+		delimiter  rune
+		role       string
+		expected   bool
+	}{
+		{"first pattern matches", ".*/red_.* | .*/blue_.*", '|', "red_role", true},
+		{"second pattern matches", ".*/red_.* | .*/blue_.*", '|', "blue_role", true},
+		{"no pattern matches", ".*/red_.* | .*/blue_.*", '|', "green_role", false},
+		{"patterns anchored individually", ".*/red_role|.*/blue_role", '|', "red_role_other", false},
+		{"grouped alternation not split", ".*/(red|blue)_role", '|', "blue_role", true},
+		{"escaped delimiter is literal", `.*/red\|role`, '|', "red|role", true},
+		{"custom delimiter", ".*/red_.*,.*/(blue|green)_.*", ',', "green_role", true},
+		{"custom delimiter keeps pipe alternation", ".*/red_role|.*/blue_role", ',', "blue_role", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			nf := kt.NewNamespaceFinder(testutil.NewNamespace("red", c.annotation))
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, c.role)
+
+			policy := NewNamespacePermittedRoleNamePolicy(true, nf, arnResolver)
+			policy.SetExpressionDelimiter(c.delimiter)
+
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), c.role, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != c.expected {
+				t.Errorf("expected allowed to be %t: %s", c.expected, decision.Explanation())
+			}
+		})
+	}
+}
+
+type clusterRolePermissions []*k8s.ClusterRolePermission
+
+func (p clusterRolePermissions) ClusterRolePermissions() []*k8s.ClusterRolePermission {
+	return p
+}
+
+func TestNamespacePolicyClusterRolePermissions(t *testing.T) {
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+	permissions := clusterRolePermissions{
+		{Name: "teams", NamespaceSelector: labels.SelectorFromSet(labels.Set{"team": "data"}), Roles: []string{".*/data_.*"}},
+	}
+
+	var tests = []struct {
+		name       string
+		annotation string
+		labels     map[string]string
+		role       string
+		expected   bool
+	}{
+		{"annotation permits", ".*/red_.*", nil, "red_role", true},
+		{"selected namespace permits", "", map[string]string{"team": "data"}, "data_role", true},
+		{"either source permits", ".*/red_.*", map[string]string{"team": "data"}, "data_role", true},
+		{"unselected namespace forbidden", ".*/red_.*", map[string]string{"team": "web"}, "data_role", false},
+		{"selected namespace forbids other roles", "", map[string]string{"team": "data"}, "red_role", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := testutil.NewNamespace("red", tt.annotation)
+			ns.Labels = tt.labels
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, tt.role)
+
+			policy := NewNamespacePermittedRoleNamePolicy(true, kt.NewNamespaceFinder(ns), arnResolver)
+			policy.SetClusterRolePermissions(permissions)
+
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), tt.role, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+		})
+	}
+}
+
+func TestNamespacePolicyRejectsEmptyExpressions(t *testing.T) {
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+
+	for _, annotation := range []string{".*/red_role|", "| .*/red_role", ".*/red_role||.*/blue_role", ".*/red_role| |.*/blue_role"} {
+		nf := kt.NewNamespaceFinder(testutil.NewNamespace("red", annotation))
+		p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+		policy := NewNamespacePermittedRoleNamePolicy(true, nf, arnResolver)
+		_, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+		if err == nil {
+			t.Errorf("expected error for empty expression in '%s'", annotation)
+		}
+	}
+}
+
+func TestNamespacePolicyCountsDecisions(t *testing.T) {
+	nf := kt.NewNamespaceFinder(testutil.NewNamespace("red", "arn:aws:iam::123456789012:role/red_.*"))
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+
+	reg := prometheus.NewRegistry()
+	policy := NewNamespacePermittedRoleNamePolicy(true, nf, arnResolver)
+	if err := policy.RegisterMetrics(reg); err != nil {
+		t.Fatal(err)
+	}
+
+	// a second instance shares the registered counter
+	other := NewNamespacePermittedRoleNamePolicy(true, nf, arnResolver)
+	if err := other.RegisterMetrics(reg); err != nil {
+		t.Fatal(err)
+	}
+
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	other.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	policy.IsAllowedAssumeRole(context.Background(), "blue_role", p)
+
+	if count := promtestutil.ToFloat64(policy.decisions.WithLabelValues("red", "allowed")); count != 2 {
+		t.Error("expected 2 allowed decisions, was", count)
+	}
+	if count := promtestutil.ToFloat64(policy.decisions.WithLabelValues("red", "forbidden")); count != 1 {
+		t.Error("expected 1 forbidden decision, was", count)
+	}
+
+	policy.IsAllowedAssumeRole(context.Background(), "", p)
+	if count := promtestutil.ToFloat64(policy.decisions.WithLabelValues("red", "error")); count != 1 {
+		t.Error("expected 1 error decision, was", count)
+	}
+}
+
+func TestCompositePolicyWrapsForbiddenDecisions(t *testing.T) {
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+
+	var tests = []struct {
+		name     string
+		role     string
+		expected error
+	}{
+		{"RoleMismatch", "blue_role", ErrRoleMismatch},
+		{"NamespacePolicy", "red_role", ErrNamespacePolicyForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := Policies(
+				NewRequestingAnnotatedRolePolicy(kt.NewStubFinder(p), arnResolver),
+				NewNamespacePermittedRoleNamePolicy(true, kt.NewNamespaceFinder(testutil.NewNamespace("red", "blue_.*")), arnResolver),
+			)
+
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), tt.role, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err, ok := decision.(error)
+			if !ok {
+				t.Fatalf("expected forbidden decision to be an error, was %T", decision)
+			}
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("expected %s, was %s", tt.expected, err)
+			}
+			if err.Error() != decision.Explanation() {
+				t.Error("expected error to match explanation, was", err.Error())
+			}
+		})
+	}
+}
+
+func TestCompositePolicyForbiddenErrorsAs(t *testing.T) {
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+
+	policy := Policies(
+		NewRequestingAnnotatedRolePolicy(kt.NewStubFinder(p), arnResolver),
+		NewNamespacePermittedRoleNamePolicy(true, kt.NewNamespaceFinder(testutil.NewNamespace("red", "blue_.*")), arnResolver),
+	)
+
+	decision, _ := policy.IsAllowedAssumeRole(context.Background(), "blue_role", p)
+	var mismatch *forbidden
+	if !errors.As(decision.(error), &mismatch) {
+		t.Fatal("expected role mismatch")
+	}
+	if mismatch.annotated != "arn:aws:iam::123456789012:role/red_role" {
+		t.Error("unexpected annotated role", mismatch.annotated)
+	}
+
+	decision, _ = policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	var namespaceForbidden *namespacePolicyForbidden
+	if !errors.As(decision.(error), &namespaceForbidden) {
+		t.Fatal("expected namespace policy forbidden")
+	}
+	if namespaceForbidden.expression != "blue_.*" {
+		t.Error("unexpected expression", namespaceForbidden.expression)
+	}
+}
+
+func TestCompositePolicyWrapsNonErrorDecisions(t *testing.T) {
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	policy := Policies(fakePolicy{decision: &timeWindowForbidden{}})
+
+	decision, _ := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	err := decision.(error)
+	if errors.Is(err, ErrRoleMismatch) || errors.Is(err, ErrNamespacePolicyForbidden) {
+		t.Error("expected no sentinel error, was", err)
+	}
+}
+
+func TestDecisionReasons(t *testing.T) {
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+	policy := Policies(
+		NewRequestingAnnotatedRolePolicy(kt.NewStubFinder(p), arnResolver),
+		NewNamespacePermittedRoleNamePolicy(true, kt.NewNamespaceFinder(testutil.NewNamespace("red", "blue_.*")), arnResolver),
+	)
+
+	var tests = []struct {
+		role     string
+		expected DenialReason
+	}{
+		{"blue_role", ReasonRoleMismatch},
+		{"red_role", ReasonNamespacePolicy},
+	}
+
+	for _, tt := range tests {
+		decision, _ := policy.IsAllowedAssumeRole(context.Background(), tt.role, p)
+		if decision.Reason() != tt.expected {
+			t.Errorf("expected reason %s, was %s", tt.expected, decision.Reason())
+		}
+	}
+
+	allowed, _ := Policies().IsAllowedAssumeRole(context.Background(), "red_role", p)
+	if allowed.Reason() != ReasonNone {
+		t.Error("expected no reason when allowed, was", allowed.Reason())
+	}
+}
+
+func TestRequestedRolePolicyWithMultiAccountResolver(t *testing.T) {
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "prod/red_role")
+	arnResolver := sts.NewMultiAccountARNResolver(map[string]string{"prod": "123456789012", "dev": "210987654321"}, "aws")
+	policy := NewRequestingAnnotatedRolePolicy(kt.NewStubFinder(p), arnResolver)
+
+	var tests = []struct {
+		role     string
+		expected bool
+	}{
+		{"prod/red_role", true},
+		{"arn:aws:iam::123456789012:role/red_role", true},
+		{"dev/red_role", false},
+		{"arn:aws:iam::210987654321:role/red_role", false},
+	}
+
+	for _, tt := range tests {
+		decision, err := policy.IsAllowedAssumeRole(context.Background(), tt.role, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decision.IsAllowed() != tt.expected {
+			t.Errorf("expected %s allowed to be %t: %s", tt.role, tt.expected, decision.Explanation())
+		}
+	}
+}
+
+func TestRequestedRolePolicyPrefersPodFromContext(t *testing.T) {
+	stale := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	current := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "blue_role")
+	policy := NewRequestingAnnotatedRolePolicy(kt.NewStubFinder(stale), sts.DefaultResolver("arn:aws:iam::123456789012:role/"))
+
+	decision, err := policy.IsAllowedAssumeRole(WithPod(context.Background(), current), "blue_role", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decision.IsAllowed() {
+		t.Error("expected to be allowed- role annotated on pod in context:", decision.Explanation())
+	}
+
+	decision, _ = policy.IsAllowedAssumeRole(context.Background(), "blue_role", stale)
+	if decision.IsAllowed() {
+		t.Error("expected to be forbidden- role not annotated on pod argument")
+	}
+}
+
+func TestAnyOfPolicy(t *testing.T) {
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	deny := fakePolicy{decision: &forbidden{requested: "red_role", annotated: "blue_role"}}
+	allow := fakePolicy{decision: &allowed{}}
+	failing := fakePolicy{err: errors.New("failed")}
+
+	var tests = []struct {
+		name     string
+		policy   AssumeRolePolicy
+		expected bool
+		err      bool
+	}{
+		{"NoPolicies", AnyOf(), false, false},
+		{"AllDeny", AnyOf(deny, deny), false, false},
+		{"OneAllows", AnyOf(deny, allow), true, false},
+		{"AllowsDespiteError", AnyOf(failing, allow), true, false},
+		{"ErrorWhenNoneAllow", AnyOf(deny, failing), false, true},
+		{"NestedInPolicies", Policies(allow, AnyOf(deny, allow)), true, false},
+		{"NestedDeniesInPolicies", Policies(AnyOf(deny, allow), deny), false, false},
+		{"PoliciesNestedInAnyOf", AnyOf(Policies(allow, deny), allow), true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := tt.policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error to be %t, was %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+		})
+	}
+}
+
+func TestAnyOfPolicyWrapsFirstDecision(t *testing.T) {
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	policy := AnyOf(
+		fakePolicy{decision: &forbidden{requested: "red_role", annotated: "blue_role"}},
+		fakePolicy{decision: &timeWindowForbidden{}},
+	)
+
+	decision, _ := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	if decision.Reason() != ReasonRoleMismatch {
+		t.Error("expected first decision's reason, was", decision.Reason())
+	}
+	if !errors.Is(decision.(error), ErrRoleMismatch) {
+		t.Error("expected to wrap first decision")
+	}
+}
+
+func TestConcurrentPolicies(t *testing.T) {
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	deny := fakePolicy{decision: &forbidden{requested: "red_role", annotated: "blue_role"}}
+	allow := fakePolicy{decision: &allowed{}}
+	failing := fakePolicy{err: errors.New("failed")}
+
+	var tests = []struct {
+		name     string
+		policy   AssumeRolePolicy
+		expected bool
+		err      bool
+	}{
+		{"NoPolicies", ConcurrentPolicies(), true, false},
+		{"AllAllow", ConcurrentPolicies(allow, allow), true, false},
+		{"OneDenies", ConcurrentPolicies(allow, deny), false, false},
+		{"Error", ConcurrentPolicies(allow, failing), false, true},
+		{"DeniesBeforeSlowPolicy", ConcurrentPolicies(&slowPolicy{delay: time.Minute, respectCtx: true}, deny), false, false},
+		{"NestedAnyOf", ConcurrentPolicies(allow, AnyOf(deny, allow)), true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := tt.policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error to be %t, was %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+		})
+	}
+}
+
+func TestConcurrentPoliciesRunInParallel(t *testing.T) {
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	policy := ConcurrentPolicies(
+		&slowPolicy{delay: 100 * time.Millisecond},
+		&slowPolicy{delay: 100 * time.Millisecond},
+		&slowPolicy{delay: 100 * time.Millisecond},
+	)
+
+	start := time.Now()
+	decision, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decision.IsAllowed() {
+		t.Error("expected to be allowed:", decision.Explanation())
+	}
+	if elapsed := time.Since(start); elapsed >= 300*time.Millisecond {
+		t.Error("expected policies to run in parallel, took", elapsed)
+	}
+}
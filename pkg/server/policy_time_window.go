@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ClockFunc returns the current time.
+type ClockFunc func() time.Time
+
+// TimeWindowAssumeRolePolicy ensures the pod is requesting a role within the
+// time window permitted by its namespace's annotation. Namespaces without the
+// annotation aren't restricted.
+type TimeWindowAssumeRolePolicy struct {
+	namespaces k8s.NamespaceFinder
+	clock      ClockFunc
+}
+
+func NewTimeWindowAssumeRolePolicy(n k8s.NamespaceFinder, clock ClockFunc) *TimeWindowAssumeRolePolicy {
+	return &TimeWindowAssumeRolePolicy{namespaces: n, clock: clock}
+}
+
+func (p *TimeWindowAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	ns, err := p.namespaces.FindNamespace(ctx, pod.GetObjectMeta().GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	if ns == nil {
+		return &allowed{}, nil
+	}
+
+	expression := ns.GetAnnotations()[k8s.AnnotationTimeWindowKey]
+	if expression == "" {
+		return &allowed{}, nil
+	}
+
+	window, err := parseTimeWindow(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	now := p.clock().In(window.location)
+	if !window.contains(now) {
+		return &timeWindowForbidden{now: now, window: expression}, nil
+	}
+
+	return &allowed{}, nil
+}
+
+// timeWindow is a daily range of minutes, optionally restricted to
+// days of the week. Ranges where the end is before the start span midnight.
+type timeWindow struct {
+	start    int
+	end      int
+	location *time.Location
+	days     [7]bool
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseTimeWindow parses expressions of the form HH:MM-HH:MM [location] [days]
+// where days is a range (Mon-Fri) or list (Sat,Sun) of weekdays. Location
+// defaults to UTC and days to every day.
+func parseTimeWindow(expression string) (*timeWindow, error) {
+	fields := strings.Fields(strings.Replace(expression, "–", "-", -1))
+	if len(fields) == 0 || len(fields) > 3 {
+		return nil, fmt.Errorf("invalid time window '%s', expected HH:MM-HH:MM [location] [days]", expression)
+	}
+
+	times := strings.Split(fields[0], "-")
+	if len(times) != 2 {
+		return nil, fmt.Errorf("invalid time range '%s', expected HH:MM-HH:MM", fields[0])
+	}
+
+	start, err := parseMinuteOfDay(times[0])
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseMinuteOfDay(times[1])
+	if err != nil {
+		return nil, err
+	}
+
+	window := &timeWindow{start: start, end: end, location: time.UTC}
+	for i := range window.days {
+		window.days[i] = true
+	}
+
+	for _, field := range fields[1:] {
+		if _, isDay := weekdays[strings.ToLower(strings.SplitN(field, "-", 2)[0])]; isDay || strings.Contains(field, ",") {
+			days, err := parseWeekdays(field)
+			if err != nil {
+				return nil, err
+			}
+			window.days = days
+			continue
+		}
+
+		location, err := time.LoadLocation(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time window location '%s': %s", field, err)
+		}
+		window.location = location
+	}
+
+	return window, nil
+}
+
+func parseMinuteOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time '%s', expected HH:MM", s)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	day, ok := weekdays[strings.ToLower(s)]
+	if !ok {
+		return 0, fmt.Errorf("invalid day '%s', expected Mon, Tue, Wed, Thu, Fri, Sat or Sun", s)
+	}
+
+	return day, nil
+}
+
+func parseWeekdays(s string) ([7]bool, error) {
+	var days [7]bool
+
+	for _, item := range strings.Split(s, ",") {
+		bounds := strings.Split(item, "-")
+		if len(bounds) > 2 {
+			return days, fmt.Errorf("invalid day range '%s', expected Mon-Fri", item)
+		}
+
+		first, err := parseWeekday(bounds[0])
+		if err != nil {
+			return days, err
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, err = parseWeekday(bounds[1])
+			if err != nil {
+				return days, err
+			}
+		}
+
+		for day := first; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+
+	return days, nil
+}
+
+func (w *timeWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+
+	if w.start <= w.end {
+		return w.days[t.Weekday()] && minute >= w.start && minute < w.end
+	}
+
+	// window spans midnight, the early hours belong to the previous day's window
+	if minute >= w.start {
+		return w.days[t.Weekday()]
+	}
+	if minute < w.end {
+		return w.days[(t.Weekday()+6)%7]
+	}
+
+	return false
+}
+
+type timeWindowForbidden struct {
+	now    time.Time
+	window string
+}
+
+func (f *timeWindowForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *timeWindowForbidden) Explanation() string {
+	return fmt.Sprintf("current time '%s' is outside namespace time window '%s'", f.now.Format("Mon 15:04 MST"), f.window)
+}
+
+func (f *timeWindowForbidden) Reason() DenialReason {
+	return ReasonTimeWindow
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	kt "github.com/uswitch/kiam/pkg/k8s/testing"
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+func fixedClock(value string) ClockFunc {
+	return func() time.Time {
+		t, _ := time.Parse(time.RFC3339, value)
+		return t
+	}
+}
+
+func TestTimeWindowPolicy(t *testing.T) {
+	var tests = []struct {
+		name     string
+		window   string
+		now      string
+		expected bool
+	}{
+		{"NoAnnotation", "", "2020-06-03T03:00:00Z", true},
+		{"InsideWindow", "08:00-18:00", "2020-06-03T12:00:00Z", true},
+		{"BeforeWindow", "08:00-18:00", "2020-06-03T07:59:00Z", false},
+		{"AtWindowEnd", "08:00-18:00", "2020-06-03T18:00:00Z", false},
+		{"InsideWindowOnWeekday", "08:00-18:00 UTC Mon-Fri", "2020-06-03T12:00:00Z", true},
+		{"InsideWindowOnWeekend", "08:00-18:00 UTC Mon-Fri", "2020-06-06T12:00:00Z", false},
+		{"DayList", "08:00-18:00 Sat,Sun", "2020-06-07T12:00:00Z", true},
+		{"EnDash", "08:00–18:00 UTC Mon-Fri", "2020-06-03T12:00:00Z", true},
+		{"SpansMidnightLate", "22:00-06:00 Fri", "2020-06-05T23:00:00Z", true},
+		{"SpansMidnightEarly", "22:00-06:00 Fri", "2020-06-06T05:00:00Z", true},
+		{"SpansMidnightWrongDay", "22:00-06:00 Fri", "2020-06-05T05:00:00Z", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := testutil.NewNamespace("red", ".*")
+			n.Annotations[k8s.AnnotationTimeWindowKey] = tt.window
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+			policy := NewTimeWindowAssumeRolePolicy(kt.NewNamespaceFinder(n), fixedClock(tt.now))
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+			if err != nil {
+				t.Fatalf(err.Error())
+			}
+
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+		})
+	}
+}
+
+func TestTimeWindowPolicyExplanation(t *testing.T) {
+	n := testutil.NewNamespace("red", ".*")
+	n.Annotations[k8s.AnnotationTimeWindowKey] = "08:00-18:00 UTC Mon-Fri"
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+	policy := NewTimeWindowAssumeRolePolicy(kt.NewNamespaceFinder(n), fixedClock("2020-06-06T12:00:00Z"))
+	decision, _ := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+
+	if decision.Explanation() != "current time 'Sat 12:00 UTC' is outside namespace time window '08:00-18:00 UTC Mon-Fri'" {
+		t.Error("unexpected explanation, was", decision.Explanation())
+	}
+}
+
+func TestTimeWindowPolicyInvalidAnnotation(t *testing.T) {
+	for _, window := range []string{"8-18", "08:00", "08:00-18:00 UTC Mon-Fri extra", "08:00-18:00 Mon-Funday", "08:00-18:00 Nowhere/Land"} {
+		n := testutil.NewNamespace("red", ".*")
+		n.Annotations[k8s.AnnotationTimeWindowKey] = window
+		p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+		policy := NewTimeWindowAssumeRolePolicy(kt.NewNamespaceFinder(n), time.Now)
+		_, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+		if err == nil {
+			t.Errorf("expected error for window '%s'", window)
+		}
+	}
+}
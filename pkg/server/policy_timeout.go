@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// TimeoutAssumeRolePolicy limits how long another policy (such as an
+// ExternalWebhookAssumeRolePolicy) may take to decide. When the timeout
+// expires the request is forbidden, or the deadline error is returned when
+// failing open.
+type TimeoutAssumeRolePolicy struct {
+	policy   AssumeRolePolicy
+	timeout  time.Duration
+	failOpen bool
+}
+
+// WithTimeout wraps inner, forbidding requests it doesn't decide within d.
+func WithTimeout(inner AssumeRolePolicy, d time.Duration) AssumeRolePolicy {
+	return &TimeoutAssumeRolePolicy{policy: inner, timeout: d}
+}
+
+// WithTimeoutFailOpen wraps inner, returning context.DeadlineExceeded for
+// requests it doesn't decide within d rather than forbidding them.
+func WithTimeoutFailOpen(inner AssumeRolePolicy, d time.Duration) AssumeRolePolicy {
+	return &TimeoutAssumeRolePolicy{policy: inner, timeout: d, failOpen: true}
+}
+
+// Name returns the wrapped policy's name
+func (p *TimeoutAssumeRolePolicy) Name() string {
+	return policyName(p.policy)
+}
+
+func (p *TimeoutAssumeRolePolicy) unwrap() AssumeRolePolicy {
+	return p.policy
+}
+
+type timeoutResult struct {
+	decision Decision
+	err      error
+}
+
+func (p *TimeoutAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	// the policy is called in a goroutine so that policies ignoring the
+	// context still can't block the request
+	result := make(chan timeoutResult, 1)
+	go func() {
+		decision, err := p.policy.IsAllowedAssumeRole(ctx, role, pod)
+		result <- timeoutResult{decision: decision, err: err}
+	}()
+
+	var decision Decision
+	var err error
+	select {
+	case r := <-result:
+		decision, err = r.decision, r.err
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil && errors.Is(err, context.DeadlineExceeded) && !p.failOpen {
+		return &timeoutForbidden{policy: p.Name(), timeout: p.timeout}, nil
+	}
+
+	return decision, err
+}
+
+type timeoutForbidden struct {
+	policy  string
+	timeout time.Duration
+}
+
+func (f *timeoutForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *timeoutForbidden) Explanation() string {
+	return fmt.Sprintf("policy %s didn't decide within %s", f.policy, f.timeout)
+}
+
+func (f *timeoutForbidden) Reason() DenialReason {
+	return ReasonTimeout
+}
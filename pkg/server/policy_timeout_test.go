@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/testutil"
+	v1 "k8s.io/api/core/v1"
+)
+
+// slowPolicy waits for delay, or the context to be done when respectCtx
+type slowPolicy struct {
+	delay      time.Duration
+	respectCtx bool
+}
+
+func (p *slowPolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	if !p.respectCtx {
+		time.Sleep(p.delay)
+		return &allowed{}, nil
+	}
+
+	select {
+	case <-time.After(p.delay):
+		return &allowed{}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestTimeoutPolicy(t *testing.T) {
+	var tests = []struct {
+		name     string
+		inner    AssumeRolePolicy
+		failOpen bool
+		allowed  bool
+		err      error
+	}{
+		{"Fast", fakePolicy{decision: &allowed{}}, false, true, nil},
+		{"FastForbidden", fakePolicy{decision: &forbidden{requested: "foo_role", annotated: "bar_role"}}, false, false, nil},
+		{"InnerError", fakePolicy{err: errors.New("lookup failed")}, false, false, errors.New("lookup failed")},
+		{"SlowFailClosed", &slowPolicy{delay: time.Second, respectCtx: true}, false, false, nil},
+		{"SlowIgnoringContext", &slowPolicy{delay: time.Second}, false, false, nil},
+		{"SlowFailOpen", &slowPolicy{delay: time.Second, respectCtx: true}, true, false, context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := WithTimeout(tt.inner, 10*time.Millisecond)
+			if tt.failOpen {
+				policy = WithTimeoutFailOpen(tt.inner, 10*time.Millisecond)
+			}
+			pod := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "bar_role")
+
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), "foo_role", pod)
+			if tt.err != nil {
+				if err == nil || err.Error() != tt.err.Error() {
+					t.Errorf("expected error %s, was %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.IsAllowed() != tt.allowed {
+				t.Errorf("expected allowed to be %t: %s", tt.allowed, decision.Explanation())
+			}
+		})
+	}
+}
+
+func TestTimeoutPolicyForbiddenReason(t *testing.T) {
+	policy := WithTimeout(&slowPolicy{delay: time.Second, respectCtx: true}, 10*time.Millisecond)
+	pod := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "bar_role")
+
+	decision, err := policy.IsAllowedAssumeRole(context.Background(), "foo_role", pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Reason() != ReasonTimeout {
+		t.Error("unexpected reason", decision.Reason())
+	}
+}
+
+func TestTimeoutPolicyReportsWrappedHealth(t *testing.T) {
+	policy := Policies(DryRun(WithTimeout(&fakeHealthChecker{status: HealthStatus{Healthy: false, Message: "webhook down"}}, time.Second)))
+
+	report := policy.HealthReport()
+	if report.Healthy {
+		t.Error("expected wrapped policy health to be reported")
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// PolicyTrace records the decision of a policy and those of the policies it
+// checked, when the policy is a CompositeAssumeRolePolicy (or wraps one).
+// Policies skipped by a composite (e.g. after a denial) have no trace, and
+// policies a concurrent composite stopped waiting for may be recorded late.
+type PolicyTrace struct {
+	PolicyName string
+	Decision   Decision
+	Err        error
+	// Children are the traces of the policies checked by the policy, in the
+	// order they were checked
+	Children []*PolicyTrace
+
+	mu sync.Mutex
+}
+
+func (t *PolicyTrace) add(child *PolicyTrace) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Children = append(t.Children, child)
+}
+
+func (t *PolicyTrace) record(decision Decision, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Decision, t.Err = decision, err
+}
+
+type policyTraceJSON struct {
+	Policy      string         `json:"policy"`
+	Allowed     *bool          `json:"allowed,omitempty"`
+	Explanation string         `json:"explanation,omitempty"`
+	Reason      DenialReason   `json:"reason,omitempty"`
+	Error       string         `json:"error,omitempty"`
+	Children    []*PolicyTrace `json:"children,omitempty"`
+}
+
+// MarshalJSON encodes the trace with its decision's explanation and reason
+func (t *PolicyTrace) MarshalJSON() ([]byte, error) {
+	t.mu.Lock()
+	out := policyTraceJSON{Policy: t.PolicyName, Children: t.Children}
+	if t.Err != nil {
+		out.Error = t.Err.Error()
+	} else if t.Decision != nil {
+		allowed := t.Decision.IsAllowed()
+		out.Allowed = &allowed
+		if !allowed {
+			out.Explanation = t.Decision.Explanation()
+			out.Reason = t.Decision.Reason()
+		}
+	}
+	t.mu.Unlock()
+
+	return json.Marshal(out)
+}
+
+type policyTraceKey struct{}
+
+// TraceEvaluation checks whether policy allows pod to assume role, recording
+// the decision of each policy checked. The trace is returned along with any
+// error.
+func TraceEvaluation(ctx context.Context, policy AssumeRolePolicy, role string, pod *v1.Pod) (*PolicyTrace, error) {
+	trace := &PolicyTrace{PolicyName: policyName(policy)}
+	decision, err := policy.IsAllowedAssumeRole(context.WithValue(ctx, policyTraceKey{}, trace), role, pod)
+	trace.record(decision, err)
+	return trace, err
+}
+
+// checkTraced checks policy, adding its decision to the trace in ctx (if any)
+func checkTraced(ctx context.Context, policy AssumeRolePolicy, role string, pod *v1.Pod) (Decision, error) {
+	parent, ok := ctx.Value(policyTraceKey{}).(*PolicyTrace)
+	if !ok {
+		return policy.IsAllowedAssumeRole(ctx, role, pod)
+	}
+
+	trace := &PolicyTrace{PolicyName: policyName(policy)}
+	parent.add(trace)
+
+	decision, err := policy.IsAllowedAssumeRole(context.WithValue(ctx, policyTraceKey{}, trace), role, pod)
+	trace.record(decision, err)
+	return decision, err
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+func TestTraceEvaluation(t *testing.T) {
+	forbidden := &namespacePolicyForbidden{expression: "^blue.*$", role: "red_role"}
+	policy := Policies(
+		NamedPolicy("annotated", fakePolicy{decision: &allowed{}}),
+		NamedPolicy("any", AnyOf(
+			NamedPolicy("namespace", fakePolicy{decision: forbidden}),
+			NamedPolicy("allow-list", fakePolicy{decision: &allowed{}}),
+		)),
+		NamedPolicy("deny-list", fakePolicy{decision: forbidden}),
+		NamedPolicy("skipped", fakePolicy{decision: &allowed{}}),
+	)
+	pod := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+	trace, err := TraceEvaluation(context.Background(), policy, "red_role", pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if trace.Decision.IsAllowed() {
+		t.Error("expected composite to forbid request")
+	}
+	if len(trace.Children) != 3 {
+		t.Fatal("expected checked policies to be traced, were", len(trace.Children))
+	}
+
+	any := trace.Children[1]
+	if any.PolicyName != "any" || !any.Decision.IsAllowed() || len(any.Children) != 2 {
+		t.Error("unexpected nested trace", any.PolicyName, len(any.Children))
+	}
+	if any.Children[0].PolicyName != "namespace" || any.Children[0].Decision.IsAllowed() {
+		t.Error("unexpected nested decision", any.Children[0].PolicyName)
+	}
+	if trace.Children[2].PolicyName != "deny-list" || trace.Children[2].Decision.Explanation() != forbidden.Explanation() {
+		t.Error("unexpected denying policy", trace.Children[2].PolicyName)
+	}
+
+	encoded, err := json.Marshal(trace.Children[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != `{"policy":"deny-list","allowed":false,"explanation":"namespace policy expression '^blue.*$' forbids role 'red_role'","reason":"NAMESPACE_POLICY"}` {
+		t.Error("unexpected json", string(encoded))
+	}
+}
+
+func TestTraceEvaluationError(t *testing.T) {
+	policy := Policies(NamedPolicy("broken", fakePolicy{err: errors.New("namespace not found")}))
+	pod := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+
+	trace, err := TraceEvaluation(context.Background(), policy, "red_role", pod)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if len(trace.Children) != 1 || trace.Children[0].Err == nil {
+		t.Error("expected error to be traced")
+	}
+}
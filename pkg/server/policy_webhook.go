@@ -0,0 +1,220 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/uswitch/kiam/pkg/logging"
+	v1 "k8s.io/api/core/v1"
+)
+
+// WebhookConfig controls how the ExternalWebhookAssumeRolePolicy calls the webhook
+type WebhookConfig struct {
+	URL string
+	// Timeout for each request made to the webhook
+	Timeout time.Duration
+	// RetryInterval is the initial interval between retries, which back off exponentially
+	RetryInterval time.Duration
+	// MaxRetryDuration bounds the total time spent retrying
+	MaxRetryDuration time.Duration
+	// ClientCert and ClientKey are optional paths to a certificate presented to the webhook
+	ClientCert string
+	ClientKey  string
+	// CA is an optional path to the CA used to verify the webhook
+	CA string
+}
+
+// ExternalWebhookAssumeRolePolicy delegates the decision to an HTTP webhook. The
+// webhook receives a JSON description of the pod and requested role and must
+// respond with 200 and {"allowed": true} to permit the request.
+type ExternalWebhookAssumeRolePolicy struct {
+	config *WebhookConfig
+	client *http.Client
+}
+
+type webhookRequest struct {
+	Role        string            `json:"role"`
+	PodName     string            `json:"pod_name"`
+	Namespace   string            `json:"namespace"`
+	Annotations map[string]string `json:"annotations"`
+	Labels      map[string]string `json:"labels"`
+}
+
+type webhookResponse struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+}
+
+func NewExternalWebhookAssumeRolePolicy(config *WebhookConfig) (*ExternalWebhookAssumeRolePolicy, error) {
+	tlsConfig, err := webhookTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Timeout:   config.Timeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+	}
+
+	return &ExternalWebhookAssumeRolePolicy{config: config, client: client}, nil
+}
+
+func webhookTLSConfig(config *WebhookConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if config.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCert, config.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading webhook client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if config.CA != "" {
+		ca, err := ioutil.ReadFile(config.CA)
+		if err != nil {
+			return nil, fmt.Errorf("error reading webhook ca: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("error parsing webhook ca")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// PolicyConfig describes how the webhook is called. Certificate paths are
+// omitted, only whether they're used is described.
+func (p *ExternalWebhookAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"url":              p.config.URL,
+		"timeout":          p.config.Timeout.String(),
+		"retryInterval":    p.config.RetryInterval.String(),
+		"maxRetryDuration": p.config.MaxRetryDuration.String(),
+		"clientCert":       p.config.ClientCert != "",
+		"ca":               p.config.CA != "",
+	}
+}
+
+func (p *ExternalWebhookAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	body, err := json.Marshal(&webhookRequest{
+		Role:        role,
+		PodName:     pod.GetName(),
+		Namespace:   pod.GetNamespace(),
+		Annotations: pod.GetAnnotations(),
+		Labels:      pod.GetLabels(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var decision Decision
+	op := func() error {
+		var err error
+		decision, err = p.call(ctx, role, body)
+		return err
+	}
+
+	strategy := backoff.NewExponentialBackOff()
+	strategy.InitialInterval = p.config.RetryInterval
+	strategy.MaxElapsedTime = p.config.MaxRetryDuration
+
+	err = backoff.Retry(op, backoff.WithContext(strategy, ctx))
+	if err != nil {
+		// the webhook kept failing with server errors, deny rather than error
+		if decision != nil && ctx.Err() == nil {
+			return decision, nil
+		}
+		return nil, err
+	}
+
+	return decision, nil
+}
+
+// call requests a decision from the webhook. Errors returned are retried, server
+// errors are retried until they're exhausted and then treated as forbidden.
+func (p *ExternalWebhookAssumeRolePolicy) call(ctx context.Context, role string, body []byte) (Decision, error) {
+	req, err := http.NewRequest(http.MethodPost, p.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, backoff.Permanent(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		logging.FromContext(ctx).Warn("error calling webhook", "policy.webhook", p.config.URL, "error", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		decision := &webhookForbidden{role: role, reason: fmt.Sprintf("webhook responded with status %d", resp.StatusCode)}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return decision, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+		}
+		return decision, nil
+	}
+
+	var response webhookResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, backoff.Permanent(fmt.Errorf("error decoding webhook response: %s", err))
+	}
+
+	if !response.Allowed {
+		return &webhookForbidden{role: role, reason: response.Reason}, nil
+	}
+
+	return &allowed{}, nil
+}
+
+// HealthCheck reports whether the webhook can be reached. Any response other
+// than a server error is considered healthy, the webhook isn't asked for a
+// decision.
+func (p *ExternalWebhookAssumeRolePolicy) HealthCheck() (HealthStatus, error) {
+	req, err := http.NewRequest(http.MethodHead, p.config.URL, nil)
+	if err != nil {
+		return HealthStatus{}, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return HealthStatus{Healthy: false, Message: fmt.Sprintf("webhook unreachable: %s", err.Error())}, nil
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return HealthStatus{Healthy: false, Message: fmt.Sprintf("webhook responded with status %d", resp.StatusCode)}, nil
+	}
+	return HealthStatus{Healthy: true, Message: "webhook reachable"}, nil
+}
+
+type webhookForbidden struct {
+	role   string
+	reason string
+}
+
+func (f *webhookForbidden) IsAllowed() bool {
+	return false
+}
+
+func (f *webhookForbidden) Explanation() string {
+	if f.reason == "" {
+		return fmt.Sprintf("webhook forbids role '%s'", f.role)
+	}
+	return fmt.Sprintf("webhook forbids role '%s': %s", f.role, f.reason)
+}
+
+func (f *webhookForbidden) Reason() DenialReason {
+	return ReasonWebhook
+}
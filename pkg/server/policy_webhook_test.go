@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+func newTestWebhookPolicy(t *testing.T, url string) *ExternalWebhookAssumeRolePolicy {
+	policy, err := NewExternalWebhookAssumeRolePolicy(&WebhookConfig{
+		URL:              url,
+		Timeout:          time.Second,
+		RetryInterval:    time.Millisecond,
+		MaxRetryDuration: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return policy
+}
+
+func TestWebhookPolicy(t *testing.T) {
+	var tests = []struct {
+		name                string
+		status              int
+		body                string
+		expectedAllowed     bool
+		expectedExplanation string
+	}{
+		{"Allowed", http.StatusOK, `{"allowed": true}`, true, ""},
+		{"Denied", http.StatusOK, `{"allowed": false}`, false, "webhook forbids role 'red_role'"},
+		{"DeniedWithReason", http.StatusOK, `{"allowed": false, "reason": "not on a weekend"}`, false, "webhook forbids role 'red_role': not on a weekend"},
+		{"NotOK", http.StatusForbidden, `{"allowed": true}`, false, "webhook forbids role 'red_role': webhook responded with status 403"},
+		{"ServerError", http.StatusInternalServerError, ``, false, "webhook forbids role 'red_role': webhook responded with status 500"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received webhookRequest
+			webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&received)
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer webhook.Close()
+
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+			p.Labels = map[string]string{"team": "platform"}
+
+			decision, err := newTestWebhookPolicy(t, webhook.URL).IsAllowedAssumeRole(context.Background(), "red_role", p)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if decision.IsAllowed() != tt.expectedAllowed {
+				t.Errorf("expected allowed to be %t", tt.expectedAllowed)
+			}
+			if decision.Explanation() != tt.expectedExplanation {
+				t.Error("unexpected explanation, was", decision.Explanation())
+			}
+
+			if received.Role != "red_role" || received.PodName != "foo" || received.Namespace != "red" {
+				t.Error("unexpected request", received)
+			}
+			if received.Labels["team"] != "platform" || received.Annotations["iam.amazonaws.com/role"] != "red_role" {
+				t.Error("expected labels and annotations in request", received)
+			}
+		})
+	}
+}
+
+func TestWebhookPolicyRetriesServerErrors(t *testing.T) {
+	calls := 0
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"allowed": true}`)
+	}))
+	defer webhook.Close()
+
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	decision, err := newTestWebhookPolicy(t, webhook.URL).IsAllowedAssumeRole(context.Background(), "red_role", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !decision.IsAllowed() {
+		t.Error("expected to be allowed after retrying:", decision.Explanation())
+	}
+	if calls != 3 {
+		t.Error("expected webhook to be called 3 times, was", calls)
+	}
+}
+
+func TestWebhookPolicyReturnsErrorWhenUnreachable(t *testing.T) {
+	webhook := httptest.NewServer(http.NotFoundHandler())
+	webhook.Close()
+
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	_, err := newTestWebhookPolicy(t, webhook.URL).IsAllowedAssumeRole(context.Background(), "red_role", p)
+	if err == nil {
+		t.Error("expected error when webhook unreachable")
+	}
+}
+
+func TestWebhookPolicyInsideComposite(t *testing.T) {
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"allowed": false, "reason": "nope"}`)
+	}))
+	defer webhook.Close()
+
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	policy := Policies(fakePolicy{decision: &allowed{}}, newTestWebhookPolicy(t, webhook.URL))
+
+	decision, _ := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	if decision.IsAllowed() {
+		t.Error("expected webhook to forbid")
+	}
+}
+
+func TestWebhookPolicyHealthCheck(t *testing.T) {
+	var tests = []struct {
+		name     string
+		status   int
+		closed   bool
+		expected bool
+	}{
+		{"Reachable", http.StatusMethodNotAllowed, false, true},
+		{"ServerError", http.StatusBadGateway, false, false},
+		{"Unreachable", http.StatusOK, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer webhook.Close()
+			if tt.closed {
+				webhook.Close()
+			}
+
+			status, err := newTestWebhookPolicy(t, webhook.URL).HealthCheck()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Healthy != tt.expected {
+				t.Errorf("expected healthy to be %t: %s", tt.expected, status.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+
+	"github.com/uswitch/kiam/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+// NamespaceWildcard is the permitted annotation allowing pods in the
+// namespace to assume any role when WildcardAssumeRolePolicy is used
+const NamespaceWildcard = "*"
+
+// WildcardAssumeRolePolicy permits pods to assume any role when their
+// namespace's permitted annotation is exactly *, without evaluating regexps.
+// Other namespaces are checked by the wrapped policy, normally the
+// NamespacePermittedRoleNamePolicy.
+//
+// The wildcard hands the choice of roles to whoever can annotate the
+// namespace: its pods can assume every role the server is able to assume.
+// Only use it when namespace annotations are restricted to trusted admins.
+type WildcardAssumeRolePolicy struct {
+	namespaces k8s.NamespaceFinder
+	policy     AssumeRolePolicy
+}
+
+func NewWildcardAssumeRolePolicy(namespaces k8s.NamespaceFinder, policy AssumeRolePolicy) *WildcardAssumeRolePolicy {
+	return &WildcardAssumeRolePolicy{namespaces: namespaces, policy: policy}
+}
+
+// PolicyConfig describes the wildcard and the policy checking other namespaces
+func (p *WildcardAssumeRolePolicy) PolicyConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"wildcard": NamespaceWildcard,
+		"policy":   describePolicy(p.policy),
+	}
+}
+
+func (p *WildcardAssumeRolePolicy) IsAllowedAssumeRole(ctx context.Context, role string, pod *v1.Pod) (Decision, error) {
+	ns, err := p.namespaces.FindNamespace(ctx, pod.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+
+	if ns != nil && ns.GetAnnotations()[k8s.AnnotationPermittedKey] == NamespaceWildcard {
+		return &allowed{}, nil
+	}
+
+	return p.policy.IsAllowedAssumeRole(ctx, role, pod)
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	kt "github.com/uswitch/kiam/pkg/k8s/testing"
+	"github.com/uswitch/kiam/pkg/testutil"
+)
+
+func TestWildcardAssumeRolePolicy(t *testing.T) {
+	var tests = []struct {
+		name       string
+		annotation string
+		expected   bool
+	}{
+		{"wildcard", "*", true},
+		{"regexp", ".*", true},
+		{"unpermitted role", "^red$", false},
+		{"wildcard within expression", "*|red", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := testutil.NewNamespace("red", tt.annotation)
+			p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "admin")
+			namespaces := kt.NewNamespaceFinder(ns)
+
+			namespacePolicy := NewNamespacePermittedRoleNamePolicy(false, namespaces, sts.DefaultResolver("arn:aws:iam::123456789012:role/"))
+			policy := NewWildcardAssumeRolePolicy(namespaces, namespacePolicy)
+
+			decision, err := policy.IsAllowedAssumeRole(context.Background(), "admin", p)
+			if err != nil {
+				if tt.expected {
+					t.Fatal(err)
+				}
+				return
+			}
+			if decision.IsAllowed() != tt.expected {
+				t.Errorf("expected allowed to be %t: %s", tt.expected, decision.Explanation())
+			}
+		})
+	}
+}
+
+func TestWildcardAssumeRolePolicySkipsWrappedPolicy(t *testing.T) {
+	ns := testutil.NewNamespace("red", "*")
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "admin")
+	policy := NewWildcardAssumeRolePolicy(kt.NewNamespaceFinder(ns), &fakePolicy{decision: &forbidden{}})
+
+	decision, err := policy.IsAllowedAssumeRole(context.Background(), "admin", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decision.IsAllowed() {
+		t.Error("expected wildcard to allow role without checking wrapped policy:", decision.Explanation())
+	}
+}
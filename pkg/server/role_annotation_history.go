@@ -0,0 +1,172 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/logging"
+	v1 "k8s.io/api/core/v1"
+)
+
+// RoleAnnotationHistory records the last role annotations each pod used,
+// keyed by the pod's UID, counting how often the annotation changed. Each
+// pod's history is a ring buffer of depth annotations. Pods that haven't made
+// a request within the window are discarded.
+type RoleAnnotationHistory struct {
+	depth  int
+	window time.Duration
+
+	mu        sync.Mutex
+	pods      map[string]*roleHistory
+	lastSweep time.Time
+}
+
+// roleHistory is a ring buffer of a pod's role annotations
+type roleHistory struct {
+	pod      string
+	records  []RoleAnnotationRecord
+	next     int
+	lastSeen time.Time
+}
+
+// RoleAnnotationRecord is a role annotation a pod used, from when it was first
+// seen. Changed is false for the pod's first annotation.
+type RoleAnnotationRecord struct {
+	Role    string    `json:"role"`
+	Since   time.Time `json:"since"`
+	Changed bool      `json:"changed"`
+}
+
+// NewRoleAnnotationHistory creates the history keeping depth annotations for
+// each pod, counting changes made within window. At least one annotation is
+// kept.
+func NewRoleAnnotationHistory(depth int, window time.Duration) *RoleAnnotationHistory {
+	if depth < 1 {
+		depth = 1
+	}
+	return &RoleAnnotationHistory{depth: depth, window: window, pods: map[string]*roleHistory{}}
+}
+
+// Record notes that pod used role at now, returning how many times the pod
+// has changed its role within the window. At most depth changes are counted.
+func (h *RoleAnnotationHistory) Record(pod *v1.Pod, role string, now time.Time) int {
+	key := podKey(pod)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sweep(now)
+
+	history, ok := h.pods[key]
+	if !ok {
+		history = &roleHistory{pod: pod.GetNamespace() + "/" + pod.GetName(), records: make([]RoleAnnotationRecord, 0, h.depth)}
+		h.pods[key] = history
+	}
+	history.lastSeen = now
+
+	if latest, ok := history.latest(); !ok || latest.Role != role {
+		history.add(RoleAnnotationRecord{Role: role, Since: now, Changed: ok}, h.depth)
+	}
+
+	return history.changes(now.Add(-h.window))
+}
+
+// sweep removes pods idle for longer than the window, at most once per
+// window. Must be called with the lock held.
+func (h *RoleAnnotationHistory) sweep(now time.Time) {
+	if now.Sub(h.lastSweep) < h.window {
+		return
+	}
+
+	for key, history := range h.pods {
+		if now.Sub(history.lastSeen) >= h.window {
+			delete(h.pods, key)
+		}
+	}
+	h.lastSweep = now
+}
+
+func (r *roleHistory) add(record RoleAnnotationRecord, depth int) {
+	if len(r.records) < depth {
+		r.records = append(r.records, record)
+		return
+	}
+	r.records[r.next] = record
+	r.next = (r.next + 1) % depth
+}
+
+func (r *roleHistory) latest() (RoleAnnotationRecord, bool) {
+	if len(r.records) == 0 {
+		return RoleAnnotationRecord{}, false
+	}
+	if len(r.records) < cap(r.records) || r.next == 0 {
+		return r.records[len(r.records)-1], true
+	}
+	return r.records[r.next-1], true
+}
+
+// ordered returns the records, oldest first
+func (r *roleHistory) ordered() []RoleAnnotationRecord {
+	ordered := make([]RoleAnnotationRecord, 0, len(r.records))
+	ordered = append(ordered, r.records[r.next:]...)
+	return append(ordered, r.records[:r.next]...)
+}
+
+// changes counts the changes made since
+func (r *roleHistory) changes(since time.Time) int {
+	changes := 0
+	for _, record := range r.records {
+		if record.Changed && !record.Since.Before(since) {
+			changes++
+		}
+	}
+	return changes
+}
+
+// RoleHistorySnapshot describes the history of the pods' role annotations
+type RoleHistorySnapshot struct {
+	Depth  int              `json:"depth"`
+	Window string           `json:"window"`
+	Pods   []PodRoleHistory `json:"pods"`
+}
+
+// PodRoleHistory describes a pod's role annotations, oldest first, and how
+// many times they changed within the window
+type PodRoleHistory struct {
+	Pod     string                 `json:"pod"`
+	Roles   []RoleAnnotationRecord `json:"roles"`
+	Changes int                    `json:"changes"`
+}
+
+// Snapshot returns the history of each pod, sorted by pod
+func (h *RoleAnnotationHistory) Snapshot(now time.Time) RoleHistorySnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshot := RoleHistorySnapshot{Depth: h.depth, Window: h.window.String(), Pods: []PodRoleHistory{}}
+	for _, history := range h.pods {
+		snapshot.Pods = append(snapshot.Pods, PodRoleHistory{
+			Pod:     history.pod,
+			Roles:   history.ordered(),
+			Changes: history.changes(now.Add(-h.window)),
+		})
+	}
+	sort.Slice(snapshot.Pods, func(i, j int) bool { return snapshot.Pods[i].Pod < snapshot.Pods[j].Pod })
+	return snapshot
+}
+
+// roleHistoryHandler serves a snapshot of the history as JSON
+type roleHistoryHandler struct {
+	history *RoleAnnotationHistory
+	clock   ClockFunc
+}
+
+func (h *roleHistoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.history.Snapshot(h.clock())); err != nil {
+		logging.FromContext(r.Context()).Error("error writing role history", "error", err)
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/uswitch/kiam/pkg/testutil"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestRoleAnnotationHistoryCountsChanges(t *testing.T) {
+	history := NewRoleAnnotationHistory(3, time.Minute)
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	p.UID = types.UID("abc-123")
+	now := time.Now()
+
+	var tests = []struct {
+		role     string
+		after    time.Duration
+		expected int
+	}{
+		{"red_role", 0, 0},
+		{"red_role", time.Second, 0},
+		{"blue_role", time.Second, 1},
+		{"green_role", time.Second, 2},
+		{"red_role", time.Second, 3},
+		// the oldest change is overwritten once the buffer is full
+		{"blue_role", time.Second, 3},
+		// changes age out of the window
+		{"blue_role", 59 * time.Second, 2},
+	}
+
+	for _, tt := range tests {
+		now = now.Add(tt.after)
+		if changes := history.Record(p, tt.role, now); changes != tt.expected {
+			t.Errorf("%s: expected %d changes, was %d", tt.role, tt.expected, changes)
+		}
+	}
+
+	snapshot := history.Snapshot(now)
+	if snapshot.Depth != 3 || len(snapshot.Pods) != 1 {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+	roles := snapshot.Pods[0].Roles
+	if len(roles) != 3 || roles[0].Role != "green_role" || roles[1].Role != "red_role" || roles[2].Role != "blue_role" {
+		t.Errorf("expected roles oldest first, was %+v", roles)
+	}
+}
+
+func TestRoleAnnotationHistoryDiscardsIdlePods(t *testing.T) {
+	history := NewRoleAnnotationHistory(3, time.Minute)
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	other := testutil.NewPodWithRole("red", "bar", "192.168.0.2", testutil.PhaseRunning, "red_role")
+	now := time.Now()
+
+	history.Record(p, "red_role", now)
+	history.Record(p, "blue_role", now)
+	history.Record(other, "red_role", now.Add(time.Minute))
+
+	snapshot := history.Snapshot(now.Add(time.Minute))
+	if len(snapshot.Pods) != 1 || snapshot.Pods[0].Pod != "red/bar" {
+		t.Errorf("expected idle pod to be discarded, was %+v", snapshot.Pods)
+	}
+}
+
+func TestRoleHistoryHandler(t *testing.T) {
+	history := NewRoleAnnotationHistory(2, time.Minute)
+	now := time.Now()
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	history.Record(p, "red_role", now)
+	history.Record(p, "blue_role", now)
+
+	w := httptest.NewRecorder()
+	(&roleHistoryHandler{history: history, clock: func() time.Time { return now }}).ServeHTTP(w, httptest.NewRequest("GET", "/debug/role-history", nil))
+
+	var snapshot RoleHistorySnapshot
+	if err := json.NewDecoder(w.Body).Decode(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Depth != 2 || snapshot.Window != "1m0s" || len(snapshot.Pods) != 1 || snapshot.Pods[0].Changes != 1 {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+}
@@ -17,13 +17,15 @@ package server
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net"
+	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	log "github.com/sirupsen/logrus"
 	"github.com/uswitch/kiam/pkg/aws/sts"
 	"github.com/uswitch/kiam/pkg/k8s"
+	"github.com/uswitch/kiam/pkg/logging"
 	"github.com/uswitch/kiam/pkg/prefetch"
 	pb "github.com/uswitch/kiam/proto"
 	"google.golang.org/grpc"
@@ -41,15 +43,75 @@ type Config struct {
 	SessionName                  string
 	SessionDuration              time.Duration
 	SessionRefresh               time.Duration
+	SessionRefreshJitter         time.Duration
+	AnnotateAssumedRole          bool
+	PrewarmThreshold             float64
+	PrewarmInterval              time.Duration
 	RoleBaseARN                  string
 	AutoDetectBaseARN            bool
 	DisableStrictNamespaceRegexp bool
+	NamespaceRegexpDelimiter     string
 	TLS                          TLSConfig
 	ParallelFetcherProcesses     int
 	PrefetchBufferSize           int
 	AssumeRoleArn                string
+	WebIdentityTokenFile         string
+	WebIdentityRoleARN           string
 	Region                       string
+	STSRegionalEndpoints         map[string]string
 	KeepaliveParams              keepalive.ServerParameters
+	AssumeRoleRateLimit          float64
+	AssumeRoleRateBurst          int
+	PolicyWebhook                WebhookConfig
+	AllowListConfigMap           string
+	DenyListFile                 string
+	PolicyFile                   string
+	DenyListWatch                bool
+	ServiceAccountConfigMap      string
+	NamespacePolicyBreaker       CircuitBreakerConfig
+	RoleAnnotationPrefix         string
+	OIDC                         OIDCConfig
+	RoleAccountAliases           map[string]string
+	RolePartition                string
+	RoleRegionOverride           string
+	AnnotationKMSKeyID           string
+	RequireEncryptedAnnotations  bool
+	CredentialsPerNamespace      int
+	MaxRoleChainDepth            int
+	PolicyHealthAddress          string
+	DecisionLogSize              int
+	NamespacePrewarmTimeout      time.Duration
+	ClusterRolePermissions       bool
+	DryRunPolicies               []string
+	AllowedAccounts              []string
+	AllowedAccountsConfigMap     string
+	DenyCompletedPods            bool
+	TerminationGracePeriod       time.Duration
+	PolicyTimeout                time.Duration
+	PolicyTimeoutFailOpen        bool
+	NodeSelector                 string
+	NodeSelectorRoles            []string
+	NonRootRoles                 []string
+	AllowedPartition             string
+	MinCredentialTTL             time.Duration
+	CredentialStreamAddress      string
+	RevokeOnAnnotationChange     bool
+	ARNAliasesConfigMap          string
+	PreloadRoles                 []string
+	RoleSSMParameterPrefix       string
+	RoleSSMCacheTTL              time.Duration
+	RoleInheritanceDepth         int
+	NamespaceAccessReviewUser    string
+	STSRetryBaseDelay            time.Duration
+	STSRetryMaxDelay             time.Duration
+	AssumeRoleRateAdaptive       bool
+	PerPodSessions               bool
+	EnableGRPCReflection         bool
+	PermitNamespaceWildcard      bool
+	RoleChangeLimit              int
+	RoleChangeWindow             time.Duration
+	RoleHistoryDepth             int
+	PolicyAuditLog               string
 }
 
 // TLSConfig controls TLS
@@ -72,6 +134,21 @@ type KiamServer struct {
 	assumePolicy        AssumeRolePolicy
 	parallelFetchers    int
 	arnResolver         sts.ARNResolver
+	identityOptions     k8s.RoleIdentityOptions
+	sessionDuration     time.Duration
+	watchers            []watcher
+	auditLog            *os.File
+	namespaceFinder     *k8s.CachingNamespaceFinder
+	prewarmTimeout      time.Duration
+	preloadRoles        []string
+	inheritance         *AnnotationInheritancePolicy
+	logger              *slog.Logger
+}
+
+// watcher is implemented by components that watch Kubernetes and must be
+// running before the server accepts requests.
+type watcher interface {
+	Run(ctx context.Context) error
 }
 
 func simplifyAWSErrorMessage(err error) string {
@@ -86,44 +163,62 @@ func simplifyAWSErrorMessage(err error) string {
 // GetPodCredentials returns credentials for the Pod, according to the role it's
 // annotated with. It will additionally check policy before returning credentials.
 func (k *KiamServer) GetPodCredentials(ctx context.Context, req *pb.GetPodCredentialsRequest) (*pb.Credentials, error) {
-	pod, err := k.pods.GetPodByIP(req.Ip)
+	pod, identity, err := k.AuthorizeRole(ctx, req.Ip, req.Role)
 	if err != nil {
-		if err == k8s.ErrPodNotFound {
-			return nil, ErrPodNotFound
-		}
-
 		return nil, err
 	}
-	logger := log.WithFields(k8s.PodFields(pod)).WithField("pod.iam.requestedRole", req.Role)
+	logger := k.logger.With(k8s.PodAttrs(pod)...).With("pod.iam.requestedRole", req.Role)
+	ctx = logging.WithLogger(ctx, logger)
 
-	decision, err := k.assumePolicy.IsAllowedAssumeRole(ctx, req.Role, pod)
+	creds, err := k.credentialsProvider.CredentialsForRole(sts.WithNamespace(ctx, pod.GetNamespace()), identity)
 	if err != nil {
-		logger.Errorf("error checking policy: %s", err.Error())
+		logger.Error("error retrieving credentials", "error", err)
+		k.recordEvent(pod, v1.EventTypeWarning, "KiamCredentialError", fmt.Sprintf("failed retrieving credentials: %s", simplifyAWSErrorMessage(err)))
 		return nil, err
 	}
 
-	if !decision.IsAllowed() {
-		logger.WithField("policy.explanation", decision.Explanation()).Errorf("pod denied by policy")
-		k.recordEvent(pod, v1.EventTypeWarning, "KiamRoleForbidden", fmt.Sprintf("failed assuming role %q: %s", req.Role, decision.Explanation()))
-		return nil, ErrPolicyForbidden
+	return translateCredentialsToProto(creds), nil
+}
+
+// AuthorizeRole finds the pod with ip and checks the policy allows it to
+// assume role, returning the pod and the identity its credentials are issued
+// for. Returns ErrPodNotFound when there's no pod with ip, and an error
+// wrapping ErrPolicyForbidden when the policy forbids the role.
+func (k *KiamServer) AuthorizeRole(ctx context.Context, ip, role string) (*v1.Pod, *sts.RoleIdentity, error) {
+	pod, err := k.findPod(ctx, ip)
+	if err != nil {
+		if err == k8s.ErrPodNotFound {
+			return nil, nil, ErrPodNotFound
+		}
+
+		return nil, nil, err
 	}
+	logger := k.logger.With(k8s.PodAttrs(pod)...).With("pod.iam.requestedRole", role)
+	ctx = logging.WithLogger(ctx, logger)
 
 	sessionName := k8s.PodSessionName(pod)
-	externalID := k8s.PodExternalID(pod)
-
-	identity, err := sts.NewRoleIdentity(k.arnResolver, req.Role, sessionName, externalID)
+	sessionTags, err := k8s.PodSessionTags(pod)
 	if err != nil {
-		return nil, err
+		logger.Error("invalid session tags", "error", err)
+		return nil, nil, err
 	}
-
-	creds, err := k.credentialsProvider.CredentialsForRole(ctx, identity)
+	sessionCtx := WithSessionRequest(ctx, &SessionRequest{Duration: k.sessionDuration, Name: sessionName, Tags: sessionTags})
+	decision, err := k.assumePolicy.IsAllowedAssumeRole(sessionCtx, role, pod)
 	if err != nil {
-		logger.Errorf("error retrieving credentials: %s", err.Error())
-		k.recordEvent(pod, v1.EventTypeWarning, "KiamCredentialError", fmt.Sprintf("failed retrieving credentials: %s", simplifyAWSErrorMessage(err)))
-		return nil, err
+		logger.Error("error checking policy", "error", err)
+		return nil, nil, err
 	}
 
-	return translateCredentialsToProto(creds), nil
+	if !decision.IsAllowed() {
+		logger.Error("pod denied by policy", "policy.explanation", decision.Explanation(), "policy.reason", string(decision.Reason()))
+		return nil, nil, &policyForbiddenError{reason: decision.Reason()}
+	}
+
+	identity, err := k8s.PodRoleIdentity(k.arnResolver, role, pod, k.identityOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pod, identity, nil
 }
 
 // GetHealth returns ok to allow a command to ensure the sever is operating well
@@ -133,19 +228,35 @@ func (k *KiamServer) GetHealth(ctx context.Context, _ *pb.GetHealthRequest) (*pb
 
 // GetPodRole determines which role a Pod is annotated with
 func (k *KiamServer) GetPodRole(ctx context.Context, req *pb.GetPodRoleRequest) (*pb.Role, error) {
-	logger := log.WithField("pod.ip", req.Ip)
-	pod, err := k.pods.GetPodByIP(req.Ip)
+	logger := k.logger.With("pod.ip", req.Ip)
+	pod, err := k.findPod(ctx, req.Ip)
 	if err != nil {
-		logger.Errorf("error finding pod: %s", err.Error())
+		logger.Error("error finding pod", "error", err)
 		return nil, err
 	}
 
 	role := k8s.PodRole(pod)
+	if role == "" && k.inheritance != nil {
+		role, err = k.inheritance.InheritedRole(pod)
+		if err != nil {
+			logger.Error("error finding inherited role", "error", err)
+			return nil, err
+		}
+	}
 
-	logger.WithField("pod.iam.role", role).Infof("found role")
+	logger.Info("found role", "pod.iam.role", role)
 	return &pb.Role{Name: role}, nil
 }
 
+// findPod returns the pod added to the context by PodUnaryServerInterceptor or
+// finds the pod with the ip
+func (k *KiamServer) findPod(ctx context.Context, ip string) (*v1.Pod, error) {
+	if pod, ok := PodFromContext(ctx); ok && pod.Status.PodIP == ip {
+		return pod, nil
+	}
+	return k.pods.GetPodByIP(ip)
+}
+
 func translateCredentialsToProto(credentials *sts.Credentials) *pb.Credentials {
 	return &pb.Credentials{
 		Code:            credentials.Code,
@@ -158,18 +269,33 @@ func translateCredentialsToProto(credentials *sts.Credentials) *pb.Credentials {
 	}
 }
 
-// Serve starts the server, starting all components and listening for gRPC
+// Serve starts the server, starting all components and listening for gRPC.
+// Components log with the server's logger, carried by their contexts.
 func (k *KiamServer) Serve(ctx context.Context) {
+	ctx = logging.WithLogger(ctx, k.logger)
+
 	k.manager.Run(ctx, k.parallelFetchers)
 	err := k.pods.Run(ctx)
 	if err != nil {
-		log.Fatalf("error starting pod cache: %s", err)
+		k.fatal("error starting pod cache", err)
 	}
 	err = k.namespaces.Run(ctx)
 	if err != nil {
-		log.Fatalf("error starting namespace cache: %s", err)
+		k.fatal("error starting namespace cache", err)
+	}
+	for _, w := range k.watchers {
+		err = w.Run(ctx)
+		if err != nil {
+			k.fatal("error starting watcher", err)
+		}
+	}
+	if k.namespaceFinder != nil {
+		k.prewarmNamespaces(ctx)
 	}
-	log.Infof("listening")
+	if len(k.preloadRoles) > 0 {
+		k.preloadCredentials(ctx)
+	}
+	k.logger.Info("listening")
 	k.server.Serve(k.listener)
 }
 
@@ -180,6 +306,33 @@ func (k *KiamServer) Stop() {
 	if k.tlsConfig != nil {
 		k.tlsConfig.Close()
 	}
+	if k.auditLog != nil && k.auditLog != os.Stdout {
+		k.auditLog.Close()
+	}
+}
+
+// prewarmNamespaces blocks until namespace snapshots have been stored, or the
+// prewarm timeout expires. Failing to prewarm doesn't stop the server.
+func (k *KiamServer) prewarmNamespaces(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, k.prewarmTimeout)
+	defer cancel()
+
+	if err := k.namespaceFinder.Prewarm(ctx); err != nil {
+		k.logger.Warn("error prewarming namespace cache", "error", err)
+	}
+}
+
+// preloadCredentials blocks until credentials for the preload roles have
+// been fetched. Failing to preload doesn't stop the server.
+func (k *KiamServer) preloadCredentials(ctx context.Context) {
+	if err := prefetch.PreloadRoles(ctx, k.preloadRoles, k.manager); err != nil {
+		k.logger.Warn("error preloading credentials", "error", err)
+	}
+}
+
+func (k *KiamServer) fatal(msg string, err error) {
+	k.logger.Error(msg, "error", err)
+	os.Exit(1)
 }
 
 func (k *KiamServer) recordEvent(object runtime.Object, eventtype, reason, message string) {
@@ -18,20 +18,30 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"io"
+	"log/slog"
 	"net"
+	"net/http"
+	"os"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
-	log "github.com/sirupsen/logrus"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/uswitch/k8sc/official"
 	"github.com/uswitch/kiam/pkg/aws/sts"
 	"github.com/uswitch/kiam/pkg/k8s"
 	"github.com/uswitch/kiam/pkg/prefetch"
 	pb "github.com/uswitch/kiam/proto"
+	"golang.org/x/time/rate"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/security/advancedtls"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -48,10 +58,35 @@ type KiamServerBuilder struct {
 	transportCredentials credentials.TransportCredentials
 	tlsConfig            *dynamicTLSConfig
 	grpcServer           *grpc.Server
+	allowList            *AllowListAssumeRolePolicy
+	denyList             *DenyListAssumeRolePolicy
+	serviceAccounts      *ServiceAccountAssumeRolePolicy
+	crossAccount         *CrossAccountRoleValidator
+	aliases              *ARNAliasRegistry
+	nodes                k8s.NodeGetter
+	owners               k8s.OwnerGetter
+	rbacNamespaces       *k8s.RBACNamespaceFinder
+	adaptiveLimiter      *AdaptiveRateLimiter
+	roleHistory          *RoleAnnotationHistory
+	inheritance          *AnnotationInheritancePolicy
+	annotationPatcher    k8s.PodAnnotationPatcher
+	podWatcher           k8s.PodWatcher
+	namespaceFinder      *k8s.CachingNamespaceFinder
+	permissions          *k8s.ClusterRolePermissionCache
+	healthChecks         []HealthCheck
+	logger               *slog.Logger
 }
 
 func NewKiamServerBuilder(c *Config) *KiamServerBuilder {
-	return &KiamServerBuilder{config: c}
+	return &KiamServerBuilder{config: c, logger: slog.Default()}
+}
+
+// WithLogger sets the logger used by the server and its components, policies
+// receive it through their request contexts. Must be called before the other
+// builder methods.
+func (b *KiamServerBuilder) WithLogger(logger *slog.Logger) *KiamServerBuilder {
+	b.logger = logger
+	return b
 }
 
 // WithAWSSTSGateway creates the server with an STS Gateway that interacts
@@ -61,30 +96,106 @@ func (b *KiamServerBuilder) WithAWSSTSGateway() (*KiamServerBuilder, error) {
 	if err != nil {
 		return nil, err
 	}
+	if b.config.WebIdentityTokenFile != "" {
+		cfg, err = cfg.WithCredentialsFromWebIdentity(b.config.WebIdentityTokenFile, b.config.WebIdentityRoleARN, b.config.SessionName)
+		if err != nil {
+			return nil, err
+		}
+	}
 	cfg.WithCredentialsFromAssumedRole(sts.NewSTSCredentialsProvider(), b.config.AssumeRoleArn)
-	stsGateway, err := sts.DefaultGateway(cfg.Config())
-	if err != nil {
-		return nil, err
+
+	var stsGateway interface {
+		sts.STSGateway
+		sts.ConnectivityChecker
+	}
+	if len(b.config.STSRegionalEndpoints) > 0 {
+		stsGateway = sts.NewMultiRegionSTSClient(b.config.Region, b.config.STSRegionalEndpoints, cfg.Config())
+	} else {
+		stsGateway, err = sts.DefaultGateway(cfg.Config())
+		if err != nil {
+			return nil, err
+		}
 	}
+	b.healthChecks = append(b.healthChecks, STSHealthCheck(stsGateway))
 
-	b.WithSTSGateway(stsGateway)
+	var gateway sts.STSGateway = stsGateway
+	if limiter := b.adaptiveRateLimiter(); limiter != nil {
+		gateway = sts.NewThrottleObservingSTSGateway(gateway, limiter.Throttled)
+	}
+	if b.config.STSRetryMaxDelay > 0 {
+		gateway = sts.NewRetryingSTSGateway(gateway, b.config.STSRetryBaseDelay, b.config.STSRetryMaxDelay)
+	}
+	b.WithSTSGateway(sts.NewDeduplicatingSTSGateway(gateway))
 
 	return b, nil
 }
 
+// roleIdentityOptions returns how pods' identities are built, shared by
+// everything that issues, indexes or revokes credentials so that they agree
+// on the identities' cache keys
+func (b *KiamServerBuilder) roleIdentityOptions() k8s.RoleIdentityOptions {
+	return k8s.RoleIdentityOptions{PerPodSessions: b.config.PerPodSessions}
+}
+
+// adaptiveRateLimiter returns the limiter adjusting the assume role rate
+// limit, or nil when the limit isn't adaptive
+func (b *KiamServerBuilder) adaptiveRateLimiter() *AdaptiveRateLimiter {
+	if b.config.AssumeRoleRateLimit <= 0 || !b.config.AssumeRoleRateAdaptive {
+		return nil
+	}
+	if b.adaptiveLimiter == nil {
+		limit := rate.Limit(b.config.AssumeRoleRateLimit)
+		b.adaptiveLimiter = NewAdaptiveRateLimiter(limit, limit/10, DefaultAdaptiveIncreaseInterval)
+	}
+	return b.adaptiveLimiter
+}
+
 // WithSTSGateway specifies the STS Gateway to use when issuing credentials
 func (b *KiamServerBuilder) WithSTSGateway(gateway sts.STSGateway) {
 	b.stsGateway = gateway
 }
 
-func newRoleARNResolver(config *Config) (sts.ARNResolver, error) {
+func newRoleARNResolver(config *Config, logger *slog.Logger) (sts.ARNResolver, error) {
+	resolver, err := newBaseRoleARNResolver(config, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.RoleRegionOverride != "" {
+		resolver = sts.NewRegionOverrideARNResolver(resolver, config.RoleRegionOverride)
+	}
+
+	if config.AnnotationKMSKeyID != "" {
+		client, err := sts.NewKMSClient(config.Region)
+		if err != nil {
+			return nil, err
+		}
+		resolver = sts.NewKMSAnnotationDecryptingResolver(resolver, client, config.AnnotationKMSKeyID, config.RequireEncryptedAnnotations)
+	}
+
+	return resolver, nil
+}
+
+func newBaseRoleARNResolver(config *Config, logger *slog.Logger) (sts.ARNResolver, error) {
+	if len(config.RoleAccountAliases) > 0 {
+		return sts.NewMultiAccountARNResolver(config.RoleAccountAliases, config.RolePartition), nil
+	}
+
+	if config.RoleSSMParameterPrefix != "" {
+		client, err := sts.NewSSMClient(config.Region)
+		if err != nil {
+			return nil, err
+		}
+		return sts.SSMARNResolver(client, config.RoleSSMParameterPrefix, sts.WithSSMCacheTTL(config.RoleSSMCacheTTL)), nil
+	}
+
 	if config.AutoDetectBaseARN {
-		log.Infof("detecting arn prefix")
+		logger.Info("detecting arn prefix")
 		prefix, err := sts.DetectARNPrefix()
 		if err != nil {
 			return nil, fmt.Errorf("error detecting arn prefix: %s", err)
 		}
-		log.Infof("using detected prefix: %s", prefix)
+		logger.Info("using detected prefix", "arn.prefix", prefix)
 		return sts.DefaultResolver(prefix), nil
 	}
 
@@ -102,6 +213,15 @@ func eventRecorder(kubeClient *kubernetes.Clientset) record.EventRecorder {
 	return broadcaster.NewRecorder(scheme.Scheme, source)
 }
 
+// parseConfigMapName splits a namespace/name ConfigMap reference
+func parseConfigMapName(s string) (string, string, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("configmap should be namespace/name, was: %s", s)
+	}
+	return parts[0], parts[1], nil
+}
+
 // WithKubernetesClient configures the server to use the Kubernetes client to watch
 // Pods and Namespaces.
 func (b *KiamServerBuilder) WithKubernetesClient() (*KiamServerBuilder, error) {
@@ -109,23 +229,83 @@ func (b *KiamServerBuilder) WithKubernetesClient() (*KiamServerBuilder, error) {
 	if err != nil {
 		return nil, err
 	}
+	b.healthChecks = append(b.healthChecks, KubernetesHealthCheck(client))
 
-	arnResolver, err := newRoleARNResolver(b.config)
+	arnResolver, err := newRoleARNResolver(b.config, b.logger)
 	if err != nil {
 		return nil, err
 	}
 
+	if b.config.ARNAliasesConfigMap != "" {
+		namespace, name, err := parseConfigMapName(b.config.ARNAliasesConfigMap)
+		if err != nil {
+			return nil, err
+		}
+		b.aliases = NewARNAliasRegistry(arnResolver, k8s.NewConfigMapListWatch(client, namespace, name), time.Minute)
+		arnResolver = b.aliases
+	}
+
 	podCache := k8s.NewPodCache(arnResolver, k8s.NewListWatch(client, k8s.ResourcePods), b.config.PodSyncInterval, b.config.PrefetchBufferSize)
+	podCache.SetRoleIdentityOptions(b.roleIdentityOptions())
 	nsCache := k8s.NewNamespaceCache(k8s.NewListWatch(client, k8s.ResourceNamespaces), time.Minute)
 
 	b.WithCaches(podCache, nsCache)
 
+	if b.config.RevokeOnAnnotationChange {
+		b.podWatcher = k8s.NewPodWatcher(k8s.NewListWatch(client, k8s.ResourcePods), b.config.PodSyncInterval, b.config.PrefetchBufferSize)
+	}
+
+	if b.config.AllowListConfigMap != "" {
+		namespace, name, err := parseConfigMapName(b.config.AllowListConfigMap)
+		if err != nil {
+			return nil, err
+		}
+		b.allowList = NewAllowListAssumeRolePolicy(arnResolver, k8s.NewConfigMapListWatch(client, namespace, name), time.Minute)
+	}
+
+	if b.config.ServiceAccountConfigMap != "" {
+		namespace, name, err := parseConfigMapName(b.config.ServiceAccountConfigMap)
+		if err != nil {
+			return nil, err
+		}
+		b.serviceAccounts = NewServiceAccountAssumeRolePolicy(arnResolver, k8s.NewConfigMapListWatch(client, namespace, name), time.Minute)
+	}
+
+	if b.config.AllowedAccountsConfigMap != "" {
+		namespace, name, err := parseConfigMapName(b.config.AllowedAccountsConfigMap)
+		if err != nil {
+			return nil, err
+		}
+		b.crossAccount = NewCrossAccountRoleValidatorFromConfigMap(arnResolver, k8s.NewConfigMapListWatch(client, namespace, name), time.Minute)
+	}
+
+	if b.config.ClusterRolePermissions {
+		restConfig, err := k8s.NewRESTConfig(b.config.KubeConfig)
+		if err != nil {
+			return nil, err
+		}
+		source, err := k8s.NewClusterRolePermissionListWatch(restConfig)
+		if err != nil {
+			return nil, err
+		}
+		b.permissions = k8s.NewClusterRolePermissionCache(source, time.Minute)
+	}
+
+	b.nodes = k8s.NewAPINodeGetter(client.CoreV1())
+	b.owners = k8s.NewAPIOwnerGetter(client)
+	if b.config.NamespaceAccessReviewUser != "" {
+		b.rbacNamespaces = k8s.NewRBACNamespaceFinder(client.CoreV1(), client.AuthorizationV1(), b.config.NamespaceAccessReviewUser, time.Minute)
+	}
+	if b.config.AnnotateAssumedRole {
+		b.annotationPatcher = k8s.NewPodAnnotationPatcher(client.CoreV1())
+	}
 	b.eventRecorder = eventRecorder(client)
 
 	return b, nil
 }
 
 // WithCaches configures the Pod and Namespace caches used for watching for Kubernetes objects.
+// The pod cache must be given the same RoleIdentityOptions as the config.
 func (b *KiamServerBuilder) WithCaches(podCache *k8s.PodCache, nsCache *k8s.NamespaceCache) *KiamServerBuilder {
 	b.podCache = podCache
 	b.namespaceCache = nsCache
@@ -134,9 +314,10 @@ func (b *KiamServerBuilder) WithCaches(podCache *k8s.PodCache, nsCache *k8s.Name
 }
 
 // WithTLS configures the Kiam server to use mutual TLS. Should always be used in production.
+// Pods are added to request contexts when the caches have already been configured.
 func (b *KiamServerBuilder) WithTLS() (*KiamServerBuilder, error) {
-	notifyFn := serverTLSMetrics.notifyFunc(x509.ExtKeyUsageServerAuth)
-	tlsConfig, err := newDynamicTLSConfig(b.config.TLS.ServerCert, b.config.TLS.ServerKey, b.config.TLS.CA, notifyFn)
+	notifyFn := serverTLSMetrics.notifyFunc(x509.ExtKeyUsageServerAuth, b.logger)
+	tlsConfig, err := newDynamicTLSConfig(b.config.TLS.ServerCert, b.config.TLS.ServerKey, b.config.TLS.CA, notifyFn, b.logger)
 	if err != nil {
 		return nil, err
 	}
@@ -165,10 +346,15 @@ func (b *KiamServerBuilder) WithTLS() (*KiamServerBuilder, error) {
 	b.transportCredentials = creds
 	b.tlsConfig = tlsConfig
 
+	unaryInterceptors := []grpc.UnaryServerInterceptor{grpc_prometheus.UnaryServerInterceptor}
+	if b.podCache != nil {
+		unaryInterceptors = append(unaryInterceptors, PodUnaryServerInterceptor(b.podCache, b.logger))
+	}
+
 	b.grpcServer = grpc.NewServer(
 		grpc.Creds(b.transportCredentials),
 		grpc.StreamInterceptor(grpc_prometheus.StreamServerInterceptor),
-		grpc.UnaryInterceptor(grpc_prometheus.UnaryServerInterceptor),
+		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(unaryInterceptors...)),
 		grpc.KeepaliveParams(b.config.KeepaliveParams),
 	)
 
@@ -181,24 +367,304 @@ func (b *KiamServerBuilder) WithGRPCServer(server *grpc.Server) *KiamServerBuild
 	return b
 }
 
+// namespaceSnapshotTTL is how long prewarmed namespace snapshots are served when
+// finding namespaces fails
+const namespaceSnapshotTTL = 5 * time.Minute
+
+func (b *KiamServerBuilder) assumeRolePolicy(arnResolver sts.ARNResolver, credentials sts.CredentialsExpiration) (*CompositeAssumeRolePolicy, error) {
+	var namespaces k8s.NamespaceFinder = b.namespaceCache
+	if b.rbacNamespaces != nil {
+		namespaces = b.rbacNamespaces
+	}
+	if b.config.NamespacePrewarmTimeout > 0 {
+		b.namespaceFinder = k8s.NewCachingNamespaceFinder(namespaces, namespaceSnapshotTTL)
+		namespaces = b.namespaceFinder
+	}
+
+	var policy *CompositeAssumeRolePolicy
+	var err error
+	if b.config.PolicyFile != "" {
+		policy, err = LoadPoliciesFromFile(b.config.PolicyFile, PolicyDeps{Pods: b.podCache, Namespaces: namespaces, Resolver: arnResolver})
+	} else {
+		policy, err = b.configuredPolicies(arnResolver, credentials, namespaces)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if b.config.PolicyTimeout > 0 {
+		for i, p := range policy.policies {
+			if b.config.PolicyTimeoutFailOpen {
+				policy.policies[i] = WithTimeoutFailOpen(p, b.config.PolicyTimeout)
+			} else {
+				policy.policies[i] = WithTimeout(p, b.config.PolicyTimeout)
+			}
+		}
+	}
+
+	if err := dryRunPolicies(policy, b.config.DryRunPolicies); err != nil {
+		return nil, err
+	}
+
+	for i, p := range policy.policies {
+		metrics := NewPolicyMetrics(p)
+		if err := metrics.RegisterMetrics(prometheus.DefaultRegisterer); err != nil {
+			return nil, err
+		}
+		policy.policies[i] = metrics
+	}
+
+	return policy, nil
+}
+
+// configuredPolicies creates the policies configured by the server's flags
+func (b *KiamServerBuilder) configuredPolicies(arnResolver sts.ARNResolver, credentials sts.CredentialsExpiration, namespaces k8s.NamespaceFinder) (*CompositeAssumeRolePolicy, error) {
+	namespacePolicy := NewNamespacePermittedRoleNamePolicy(!b.config.DisableStrictNamespaceRegexp, namespaces, arnResolver)
+	if b.config.NamespaceRegexpDelimiter != "" {
+		delimiter, size := utf8.DecodeRuneInString(b.config.NamespaceRegexpDelimiter)
+		if size != len(b.config.NamespaceRegexpDelimiter) {
+			return nil, fmt.Errorf("namespace regexp delimiter must be a single character, was '%s'", b.config.NamespaceRegexpDelimiter)
+		}
+		namespacePolicy.SetExpressionDelimiter(delimiter)
+	}
+	if b.permissions != nil {
+		namespacePolicy.SetClusterRolePermissions(b.permissions)
+	}
+	if b.aliases != nil {
+		namespacePolicy.SetARNAliases(b.aliases)
+	}
+	if err := namespacePolicy.RegisterMetrics(prometheus.DefaultRegisterer); err != nil {
+		return nil, err
+	}
+
+	var namespaceCheck AssumeRolePolicy = namespacePolicy
+	if b.config.NamespacePolicyBreaker.ConsecutiveFailures > 0 {
+		namespaceCheck = NewCircuitBreakerAssumeRolePolicy("namespace", namespacePolicy, b.config.NamespacePolicyBreaker, b.logger)
+	}
+	if b.config.PermitNamespaceWildcard {
+		namespaceCheck = NewWildcardAssumeRolePolicy(namespaces, namespaceCheck)
+	}
+
+	var annotatedRole AssumeRolePolicy = NewRequestingAnnotatedRolePolicy(b.podCache, arnResolver)
+	if b.inheritance != nil {
+		annotatedRole = b.inheritance
+	}
+
+	var policies []AssumeRolePolicy
+	if b.config.RoleAnnotationPrefix != "" {
+		policies = append(policies, NewPodAnnotationPrefixPolicy(b.config.RoleAnnotationPrefix))
+	}
+	policies = append(policies,
+		annotatedRole,
+		namespaceCheck,
+		NewTimeWindowAssumeRolePolicy(namespaces, time.Now),
+		NewMaxSessionDurationAssumeRolePolicy(namespaces),
+		NewRoleSessionNamePolicy(namespaces),
+		NewSessionTagAssumeRolePolicy(namespaces),
+	)
+	policy := Policies(policies...)
+
+	if b.config.MaxRoleChainDepth > 0 {
+		policy.Append(NewChainedRoleAssumeRolePolicy(namespaceCheck, arnResolver, b.config.MaxRoleChainDepth))
+	}
+
+	if b.config.DenyCompletedPods {
+		policy.Append(NewPodPhaseAssumeRolePolicy(b.podCache))
+	}
+
+	if b.config.TerminationGracePeriod > 0 {
+		policy.Append(NewGracePeriodAssumeRolePolicy(b.config.TerminationGracePeriod, time.Now))
+	}
+
+	if b.config.MinCredentialTTL > 0 {
+		credentialTTL := NewCredentialTTLAssumeRolePolicy(credentials, arnResolver, b.config.MinCredentialTTL, time.Now)
+		credentialTTL.SetRoleIdentityOptions(b.roleIdentityOptions())
+		policy.Append(credentialTTL)
+	}
+
+	if limiter := b.adaptiveRateLimiter(); limiter != nil {
+		if err := limiter.RegisterMetrics(prometheus.DefaultRegisterer); err != nil {
+			return nil, err
+		}
+		policy.Append(NewAdaptiveRateLimitingAssumeRolePolicy(limiter, b.config.AssumeRoleRateBurst, 10*time.Minute))
+	} else if b.config.AssumeRoleRateLimit > 0 {
+		policy.Append(NewRateLimitingAssumeRolePolicy(rate.Limit(b.config.AssumeRoleRateLimit), b.config.AssumeRoleRateBurst, 10*time.Minute))
+	}
+
+	if b.config.RoleChangeLimit > 0 {
+		if b.config.RoleHistoryDepth <= b.config.RoleChangeLimit {
+			return nil, fmt.Errorf("role history depth %d must be greater than the role change limit %d", b.config.RoleHistoryDepth, b.config.RoleChangeLimit)
+		}
+		b.roleHistory = NewRoleAnnotationHistory(b.config.RoleHistoryDepth, b.config.RoleChangeWindow)
+		policy.Append(NewRoleChangeRateAssumeRolePolicy(b.roleHistory, b.config.RoleChangeLimit, time.Now))
+	}
+
+	if b.allowList != nil {
+		policy.Append(b.allowList)
+	}
+
+	if b.serviceAccounts != nil {
+		policy.Append(b.serviceAccounts)
+	}
+
+	if b.crossAccount != nil {
+		policy.Append(b.crossAccount)
+	} else if len(b.config.AllowedAccounts) > 0 {
+		crossAccount, err := NewCrossAccountRoleValidator(arnResolver, b.config.AllowedAccounts)
+		if err != nil {
+			return nil, err
+		}
+		policy.Append(crossAccount)
+	}
+
+	if b.config.AllowedPartition != "" {
+		partition, err := NewARNPartitionPolicy(arnResolver, b.config.AllowedPartition)
+		if err != nil {
+			return nil, err
+		}
+		policy.Append(partition)
+	}
+
+	if b.config.DenyListFile != "" {
+		denyList, err := NewDenyListAssumeRolePolicyFromFile(arnResolver, b.config.DenyListFile)
+		if err != nil {
+			return nil, err
+		}
+		b.denyList = denyList
+		policy.Append(denyList)
+	}
+
+	if b.config.OIDC.JWKSURI != "" {
+		policy.Append(NewOIDCFederatedAssumeRolePolicy(b.config.OIDC))
+	}
+
+	if len(b.config.NodeSelectorRoles) > 0 {
+		if b.nodes == nil {
+			return nil, fmt.Errorf("node selector policy requires a kubernetes client")
+		}
+		selector, err := metav1.ParseToLabelSelector(b.config.NodeSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node selector: %v", err)
+		}
+		nodeSelector, err := NewNodeSelectorAssumeRolePolicy(arnResolver, b.nodes, b.config.NodeSelectorRoles, *selector)
+		if err != nil {
+			return nil, err
+		}
+		policy.Append(nodeSelector)
+	}
+
+	if len(b.config.NonRootRoles) > 0 {
+		nonRoot, err := NewPodSecurityContextAssumeRolePolicy(arnResolver, b.config.NonRootRoles)
+		if err != nil {
+			return nil, err
+		}
+		policy.Append(nonRoot)
+	}
+
+	if b.config.PolicyWebhook.URL != "" {
+		webhook, err := NewExternalWebhookAssumeRolePolicy(&b.config.PolicyWebhook)
+		if err != nil {
+			return nil, err
+		}
+		policy.Append(webhook)
+	}
+
+	return policy, nil
+}
+
+// dryRunPolicies wraps the composite's policies named in names with DryRun
+func dryRunPolicies(policy *CompositeAssumeRolePolicy, names []string) error {
+	for _, name := range names {
+		found := false
+		for i, p := range policy.policies {
+			if policyName(p) == name {
+				policy.policies[i] = DryRun(p)
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("no policy named %s to run in dry run mode", name)
+		}
+	}
+	return nil
+}
+
 func (b *KiamServerBuilder) Build() (*KiamServer, error) {
-	arnResolver, err := newRoleARNResolver(b.config)
+	arnResolver, err := newRoleARNResolver(b.config, b.logger)
 	if err != nil {
 		return nil, err
 	}
+	if b.aliases != nil {
+		arnResolver = b.aliases
+	}
+
+	if err := sts.RegisterSTSMetrics(prometheus.DefaultRegisterer); err != nil {
+		return nil, err
+	}
+
+	if b.config.RoleInheritanceDepth > 0 && b.owners != nil {
+		b.inheritance = NewAnnotationInheritancePolicy(b.owners, arnResolver, b.config.RoleInheritanceDepth)
+	}
+
+	cacheOptions := []sts.CacheOption{sts.WithRefreshJitter(b.config.SessionRefreshJitter)}
+	var credentialStream *ServerSentEventsCredentialStream
+	if b.config.CredentialStreamAddress != "" {
+		if b.tlsConfig == nil {
+			return nil, fmt.Errorf("credential stream requires tls")
+		}
+		credentialStream = NewServerSentEventsCredentialStream()
+		cacheOptions = append(cacheOptions, sts.WithCredentialsListener(credentialStream.Publish))
+	}
 
 	credentialsCache := sts.DefaultCache(
 		b.stsGateway,
 		b.config.SessionName,
 		b.config.SessionDuration,
 		b.config.SessionRefresh,
+		cacheOptions...,
 	)
 
+	assumePolicy, err := b.assumeRolePolicy(arnResolver, credentialsCache)
+	if err != nil {
+		return nil, err
+	}
+
+	var checkedPolicy AssumeRolePolicy = assumePolicy
+	var decisionLog *AuditingAssumeRolePolicy
+	var auditLog *os.File
+	if b.config.DecisionLogSize > 0 || b.config.PolicyAuditLog != "" {
+		var w io.Writer
+		if b.config.PolicyAuditLog != "" {
+			auditLog, err = OpenAuditLog(b.config.PolicyAuditLog)
+			if err != nil {
+				return nil, fmt.Errorf("error opening policy audit log: %v", err)
+			}
+			w = auditLog
+		}
+		decisionLog = NewAuditingAssumeRolePolicy(checkedPolicy, arnResolver, w)
+		decisionLog.SetDecisionHistorySize(b.config.DecisionLogSize)
+		checkedPolicy = decisionLog
+	}
+	if b.eventRecorder != nil {
+		checkedPolicy = NewEventEmittingAssumeRolePolicy(checkedPolicy, b.eventRecorder)
+	}
+
+	var credentialsProvider sts.CredentialsProvider = credentialsCache
+	if b.config.CredentialsPerNamespace > 0 {
+		credentialsProvider = sts.NewPerNamespaceQuotaCache(credentialsCache, b.config.CredentialsPerNamespace)
+	}
+
 	listener, err := net.Listen("tcp", b.config.BindAddress)
 	if err != nil {
 		return nil, err
 	}
 
+	manager := prefetch.NewManager(credentialsCache, b.podCache, arnResolver)
+	manager.SetRoleIdentityOptions(b.roleIdentityOptions())
+	if b.annotationPatcher != nil {
+		manager.AddPostAssumeHook(prefetch.AssumedRoleAnnotator(b.annotationPatcher, credentialsCache))
+	}
+
 	srv := &KiamServer{
 		tlsConfig:           b.tlsConfig,
 		listener:            listener,
@@ -206,15 +672,86 @@ func (b *KiamServerBuilder) Build() (*KiamServer, error) {
 		pods:                b.podCache,
 		namespaces:          b.namespaceCache,
 		eventRecorder:       b.eventRecorder,
-		manager:             prefetch.NewManager(credentialsCache, b.podCache, arnResolver),
-		credentialsProvider: credentialsCache,
-		assumePolicy: Policies(
-			NewRequestingAnnotatedRolePolicy(b.podCache, arnResolver),
-			NewNamespacePermittedRoleNamePolicy(!b.config.DisableStrictNamespaceRegexp, b.namespaceCache, arnResolver),
-		),
-		parallelFetchers: b.config.ParallelFetcherProcesses,
-		arnResolver:      arnResolver,
+		manager:             manager,
+		credentialsProvider: credentialsProvider,
+		assumePolicy:        checkedPolicy,
+		parallelFetchers:    b.config.ParallelFetcherProcesses,
+		arnResolver:         arnResolver,
+		identityOptions:     b.roleIdentityOptions(),
+		sessionDuration:     b.config.SessionDuration,
+		namespaceFinder:     b.namespaceFinder,
+		prewarmTimeout:      b.config.NamespacePrewarmTimeout,
+		preloadRoles:        b.config.PreloadRoles,
+		inheritance:         b.inheritance,
+		logger:              b.logger,
+		auditLog:            auditLog,
 	}
-	pb.RegisterKiamServiceServer(b.grpcServer, srv)
+	if b.aliases != nil {
+		srv.watchers = append(srv.watchers, b.aliases)
+	}
+	var conflictDetector *NamespacePolicyConflictDetector
+	if b.permissions != nil {
+		conflictDetector = NewNamespacePolicyConflictDetector(b.permissions, b.permissions.Updates())
+		srv.watchers = append(srv.watchers, b.permissions, conflictDetector)
+	}
+	if b.allowList != nil {
+		srv.watchers = append(srv.watchers, b.allowList)
+	}
+	if b.serviceAccounts != nil {
+		srv.watchers = append(srv.watchers, b.serviceAccounts)
+	}
+	if b.crossAccount != nil {
+		srv.watchers = append(srv.watchers, b.crossAccount)
+	}
+	if b.denyList != nil && b.config.DenyListWatch {
+		srv.watchers = append(srv.watchers, b.denyList)
+	}
+	if b.config.PolicyHealthAddress != "" {
+		handlers := map[string]http.Handler{
+			"/healthz":            &healthHandler{policy: assumePolicy},
+			"/debug/policies":     &policiesHandler{policy: assumePolicy},
+			"/debug/policies.dot": &policiesDOTHandler{policy: assumePolicy},
+			"/debug/cache-stats":  &cacheStatsHandler{stats: manager.Stats},
+		}
+		handlers["/healthz/subsystems"] = HealthzHandler(append(b.healthChecks, PolicyHealthChecks(assumePolicy)...)...)
+		if b.config.DecisionLogSize > 0 {
+			handlers["/debug/decisions"] = decisionLog.DebugDecisionLog()
+		}
+		if conflictDetector != nil {
+			handlers["/debug/policy-conflicts"] = &policyConflictsHandler{detector: conflictDetector}
+		}
+		if b.roleHistory != nil {
+			handlers["/debug/role-history"] = &roleHistoryHandler{history: b.roleHistory, clock: time.Now}
+		}
+		srv.watchers = append(srv.watchers, &healthServer{address: b.config.PolicyHealthAddress, handlers: handlers})
+	}
+	if b.podWatcher != nil {
+		annotationWatch := NewPodAnnotationWatchPolicy(b.podWatcher, credentialsCache, arnResolver)
+		annotationWatch.SetRoleIdentityOptions(b.roleIdentityOptions())
+		srv.watchers = append(srv.watchers, annotationWatch)
+	}
+	if credentialStream != nil {
+		credentialStream.SetAuthorizer(srv)
+		srv.watchers = append(srv.watchers, &credentialStreamServer{address: b.config.CredentialStreamAddress, stream: credentialStream, tlsConfig: b.tlsConfig})
+	}
+	if b.config.PrewarmThreshold > 0 {
+		prewarmer, err := credentialsCache.Prewarmer(b.config.PrewarmThreshold, b.config.PrewarmInterval)
+		if err != nil {
+			return nil, err
+		}
+		srv.watchers = append(srv.watchers, prewarmer)
+	}
+	b.registerServices(srv)
 	return srv, nil
 }
+
+// registerServices registers the server's services with the gRPC server
+func (b *KiamServerBuilder) registerServices(srv *KiamServer) {
+	pb.RegisterKiamServiceServer(b.grpcServer, srv)
+	if b.config.EnableGRPCReflection {
+		// Reflection exposes the schema of the server's services to any client
+		// that can connect, letting tools like grpcurl call them without the
+		// .proto files.
+		reflection.Register(b.grpcServer)
+	}
+}
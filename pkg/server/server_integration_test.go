@@ -122,7 +122,7 @@ func newTestServer(ctx context.Context) (*KiamServer, *kt.FakeControllerSource,
 	source := kt.NewFakeControllerSource()
 	defer source.Shutdown()
 
-	podCache := k8s.NewPodCache(sts.DefaultResolver("arn:account:"), source, time.Second, defaultBuffer)
+	podCache := k8s.NewPodCache(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), source, time.Second, defaultBuffer)
 	podCache.Run(ctx)
 	namespaceCache := k8s.NewNamespaceCache(source, time.Second)
 	namespaceCache.Run(ctx)
@@ -135,3 +135,18 @@ func newTestServer(ctx context.Context) (*KiamServer, *kt.FakeControllerSource,
 
 	return server, source, err
 }
+
+func TestRegistersGRPCReflection(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		grpcServer := grpc.NewServer()
+		NewKiamServerBuilder(&Config{EnableGRPCReflection: enabled}).WithGRPCServer(grpcServer).registerServices(&KiamServer{})
+
+		services := grpcServer.GetServiceInfo()
+		if _, ok := services["kiam.KiamService"]; !ok {
+			t.Error("expected kiam service registered, was", services)
+		}
+		if _, registered := services["grpc.reflection.v1alpha.ServerReflection"]; registered != enabled {
+			t.Errorf("expected reflection registered %t, was %t", enabled, registered)
+		}
+	}
+}
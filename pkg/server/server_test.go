@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -12,8 +13,10 @@ import (
 	"github.com/fortytw2/leaktest"
 	"github.com/uswitch/kiam/pkg/aws/sts"
 	"github.com/uswitch/kiam/pkg/k8s"
+	"github.com/uswitch/kiam/pkg/logging"
 	"github.com/uswitch/kiam/pkg/testutil"
 	pb "github.com/uswitch/kiam/proto"
+	"google.golang.org/grpc/status"
 	kt "k8s.io/client-go/tools/cache/testing"
 )
 
@@ -41,8 +44,8 @@ func TestReturnsErrorWhenPodNotFound(t *testing.T) {
 	source := kt.NewFakeControllerSource()
 	defer source.Shutdown()
 
-	podCache := k8s.NewPodCache(sts.DefaultResolver("arn:account:"), source, time.Second, defaultBuffer)
-	server := &KiamServer{pods: podCache}
+	podCache := k8s.NewPodCache(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), source, time.Second, defaultBuffer)
+	server := &KiamServer{logger: logging.Discard(), pods: podCache}
 
 	_, err := server.GetPodCredentials(context.Background(), &pb.GetPodCredentialsRequest{})
 
@@ -61,15 +64,22 @@ func TestReturnsPolicyErrorWhenForbidden(t *testing.T) {
 	defer source.Shutdown()
 	source.Add(testutil.NewPodWithRole("ns", "name", "192.168.0.1", "Running", "running_role"))
 
-	podCache := k8s.NewPodCache(sts.DefaultResolver("arn:account:"), source, time.Second, defaultBuffer)
+	podCache := k8s.NewPodCache(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), source, time.Second, defaultBuffer)
 	podCache.Run(ctx)
-	server := &KiamServer{pods: podCache, assumePolicy: &forbidPolicy{}, arnResolver: sts.DefaultResolver("prefix")}
+	server := &KiamServer{logger: logging.Discard(), pods: podCache, assumePolicy: &forbidPolicy{}, arnResolver: sts.DefaultResolver("arn:aws:iam::123456789012:role/")}
 
 	_, err := server.GetPodCredentials(ctx, &pb.GetPodCredentialsRequest{Ip: "192.168.0.1"})
 
-	if err != ErrPolicyForbidden {
+	if !errors.Is(err, ErrPolicyForbidden) {
 		t.Error("unexpected error:", err)
 	}
+
+	if reason := DenialReasonFromError(err); reason != ReasonRoleMismatch {
+		t.Error("expected reason in status details, was", reason)
+	}
+	if s, _ := status.FromError(err); s.Message() != ErrPolicyForbidden.Error() {
+		t.Error("unexpected status message:", s.Message())
+	}
 }
 
 func TestReturnsAnnotatedPodRole(t *testing.T) {
@@ -81,10 +91,10 @@ func TestReturnsAnnotatedPodRole(t *testing.T) {
 	defer source.Shutdown()
 	source.Add(testutil.NewPodWithRole("ns", "name", "192.168.0.1", "Running", "running_role"))
 
-	podCache := k8s.NewPodCache(sts.DefaultResolver("arn:account:"), source, time.Second, defaultBuffer)
+	podCache := k8s.NewPodCache(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), source, time.Second, defaultBuffer)
 	podCache.Run(ctx)
 
-	server := &KiamServer{pods: podCache, assumePolicy: &allowPolicy{}, credentialsProvider: &stubCredentialsProvider{accessKey: "A1234"}}
+	server := &KiamServer{logger: logging.Discard(), pods: podCache, assumePolicy: &allowPolicy{}, credentialsProvider: &stubCredentialsProvider{accessKey: "A1234"}}
 
 	r, _ := server.GetPodRole(ctx, &pb.GetPodRoleRequest{Ip: "192.168.0.1"})
 
@@ -102,10 +112,10 @@ func TestReturnsErrorFromGetPodRoleWhenPodNotFound(t *testing.T) {
 	defer source.Shutdown()
 	source.Add(testutil.NewPodWithRole("ns", "name", "192.168.0.1", "Running", "running_role"))
 
-	podCache := k8s.NewPodCache(sts.DefaultResolver("arn:account:"), source, time.Second, defaultBuffer)
+	podCache := k8s.NewPodCache(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), source, time.Second, defaultBuffer)
 	podCache.Run(ctx)
 
-	server := &KiamServer{pods: podCache, assumePolicy: &allowPolicy{}, credentialsProvider: &stubCredentialsProvider{accessKey: "A1234"}}
+	server := &KiamServer{logger: logging.Discard(), pods: podCache, assumePolicy: &allowPolicy{}, credentialsProvider: &stubCredentialsProvider{accessKey: "A1234"}}
 
 	_, e := server.GetPodRole(ctx, &pb.GetPodRoleRequest{Ip: "foo"})
 
@@ -126,9 +136,9 @@ func TestReturnsCredentials(t *testing.T) {
 	defer source.Shutdown()
 	source.Add(testutil.NewPodWithRole("ns", "name", "192.168.0.1", "Running", roleName))
 
-	podCache := k8s.NewPodCache(sts.DefaultResolver("arn:account:"), source, time.Second, defaultBuffer)
+	podCache := k8s.NewPodCache(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), source, time.Second, defaultBuffer)
 	podCache.Run(ctx)
-	server := &KiamServer{pods: podCache, assumePolicy: &allowPolicy{}, credentialsProvider: &stubCredentialsProvider{accessKey: "A1234"}, arnResolver: sts.DefaultResolver("prefix")}
+	server := &KiamServer{logger: logging.Discard(), pods: podCache, assumePolicy: &allowPolicy{}, credentialsProvider: &stubCredentialsProvider{accessKey: "A1234"}, arnResolver: sts.DefaultResolver("arn:aws:iam::123456789012:role/")}
 
 	creds, err := server.GetPodCredentials(ctx, &pb.GetPodCredentialsRequest{Ip: "192.168.0.1", Role: roleName})
 	if err != nil {
@@ -158,9 +168,9 @@ func TestGetPodCredentialsWithSessionName(t *testing.T) {
 	source.Add(testutil.NewPodWithSessionName("ns", "name", "192.168.0.1", "Running", roleName, sessionName))
 
 	credentialsProvider := stubCredentialsProvider{accessKey: "A1234"}
-	podCache := k8s.NewPodCache(sts.DefaultResolver("arn:account:"), source, time.Second, defaultBuffer)
+	podCache := k8s.NewPodCache(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), source, time.Second, defaultBuffer)
 	podCache.Run(ctx)
-	server := &KiamServer{pods: podCache, assumePolicy: &allowPolicy{}, credentialsProvider: &credentialsProvider, arnResolver: sts.DefaultResolver("prefix")}
+	server := &KiamServer{logger: logging.Discard(), pods: podCache, assumePolicy: &allowPolicy{}, credentialsProvider: &credentialsProvider, arnResolver: sts.DefaultResolver("arn:aws:iam::123456789012:role/")}
 
 	_, err := server.GetPodCredentials(ctx, &pb.GetPodCredentialsRequest{Ip: "192.168.0.1", Role: roleName})
 	if err != nil {
@@ -187,9 +197,9 @@ func TestGetPodCredentialsWithExternalID(t *testing.T) {
 	source.Add(testutil.NewPodWithExternalID("ns", "name", "192.168.0.1", "Running", roleName, externalID))
 
 	credentialsProvider := stubCredentialsProvider{accessKey: "A1234"}
-	podCache := k8s.NewPodCache(sts.DefaultResolver("arn:account:"), source, time.Second, defaultBuffer)
+	podCache := k8s.NewPodCache(sts.DefaultResolver("arn:aws:iam::123456789012:role/"), source, time.Second, defaultBuffer)
 	podCache.Run(ctx)
-	server := &KiamServer{pods: podCache, assumePolicy: &allowPolicy{}, credentialsProvider: &credentialsProvider, arnResolver: sts.DefaultResolver("prefix")}
+	server := &KiamServer{logger: logging.Discard(), pods: podCache, assumePolicy: &allowPolicy{}, credentialsProvider: &credentialsProvider, arnResolver: sts.DefaultResolver("arn:aws:iam::123456789012:role/")}
 
 	_, err := server.GetPodCredentials(ctx, &pb.GetPodCredentialsRequest{Ip: "192.168.0.1", Role: roleName})
 	if err != nil {
@@ -219,7 +229,7 @@ type forbidPolicy struct {
 }
 
 func (f *forbidPolicy) IsAllowedAssumeRole(ctx context.Context, roleName string, pod *v1.Pod) (Decision, error) {
-	return &decision{allowed: false, explanation: "uh uh uh"}, nil
+	return &decision{allowed: false, explanation: "uh uh uh", reason: ReasonRoleMismatch}, nil
 }
 
 type allowPolicy struct {
@@ -232,6 +242,7 @@ func (a *allowPolicy) IsAllowedAssumeRole(ctx context.Context, roleName string,
 type decision struct {
 	allowed     bool
 	explanation string
+	reason      DenialReason
 }
 
 func (d *decision) IsAllowed() bool {
@@ -241,3 +252,7 @@ func (d *decision) IsAllowed() bool {
 func (d *decision) Explanation() string {
 	return d.explanation
 }
+
+func (d *decision) Reason() DenialReason {
+	return d.reason
+}
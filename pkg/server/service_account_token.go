@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// ServiceAccountTokenHeader is the request header pods use to present their
+// projected service account token when requesting credentials
+const ServiceAccountTokenHeader = "X-Kiam-Service-Account-Token"
+
+// serviceAccountTokenMetadataKey is the gRPC metadata key the agent forwards the
+// presented token with
+var serviceAccountTokenMetadataKey = strings.ToLower(ServiceAccountTokenHeader)
+
+type serviceAccountTokenKey struct{}
+
+// WithServiceAccountToken returns a context carrying the service account token
+// presented by the pod. Contexts are returned unchanged if token is empty.
+func WithServiceAccountToken(ctx context.Context, token string) context.Context {
+	if token == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, serviceAccountTokenKey{}, token)
+}
+
+// ServiceAccountTokenFromContext returns the service account token presented by
+// the pod, either carried by ctx or forwarded by the agent in the incoming gRPC
+// metadata.
+func ServiceAccountTokenFromContext(ctx context.Context) (string, bool) {
+	if token, ok := ctx.Value(serviceAccountTokenKey{}).(string); ok && token != "" {
+		return token, true
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	values := md.Get(serviceAccountTokenMetadataKey)
+	if len(values) == 0 || values[0] == "" {
+		return "", false
+	}
+	return values[0], true
+}
+
+// forwardServiceAccountToken adds the token carried by ctx, if any, to the
+// outgoing gRPC metadata so the server can verify it.
+func forwardServiceAccountToken(ctx context.Context) context.Context {
+	token, ok := ctx.Value(serviceAccountTokenKey{}).(string)
+	if !ok || token == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, serviceAccountTokenMetadataKey, token)
+}
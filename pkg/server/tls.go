@@ -6,13 +6,13 @@ import (
 	"fmt"
 	"hash/fnv"
 	"io/ioutil"
+	"log/slog"
 	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
 	"gopkg.in/fsnotify.v1"
 )
 
@@ -65,11 +65,11 @@ func (m *tlsMetrics) Collect(ch chan<- prometheus.Metric) {
 	m.expiration.Collect(ch)
 }
 
-func (m *tlsMetrics) notifyFunc(usage x509.ExtKeyUsage) tlsCertsNotifyFunc {
+func (m *tlsMetrics) notifyFunc(usage x509.ExtKeyUsage, logger *slog.Logger) tlsCertsNotifyFunc {
 	m.registerOnce.Do(func() { prometheus.MustRegister(m) })
 	return func(cert *tls.Certificate, pool *x509.CertPool, err error) {
 		if err != nil {
-			log.Errorf("failed to update TLS certificate: %v", err)
+			logger.Error("failed to update TLS certificate", "error", err)
 			m.updateError.Set(1)
 			return
 		}
@@ -77,7 +77,7 @@ func (m *tlsMetrics) notifyFunc(usage x509.ExtKeyUsage) tlsCertsNotifyFunc {
 
 		expiry, err := earliestExpiry(cert, pool, usage)
 		if err != nil {
-			log.Errorf("failed to verify TLS certificate: %v", err)
+			logger.Error("failed to verify TLS certificate", "error", err)
 			m.verifyError.Set(1)
 		} else {
 			m.verifyError.Set(0)
@@ -93,21 +93,31 @@ type tlsCerts struct {
 
 const hashSize = 16 // 128-bit
 
+// tlsRecheckInterval is how often the files are re-read when loading the
+// certificates, in case the watcher missed a change
+const tlsRecheckInterval = 30 * time.Second
+
 type dynamicTLSConfig struct {
 	latest atomic.Value
-	hash   [hashSize]byte // dedupes notify calls
+
+	mu      sync.Mutex     // serialises reads
+	hash    [hashSize]byte // dedupes notify calls
+	checked time.Time      // when the files were last read
+	recheck time.Duration
+	clock   ClockFunc
 
 	certFile string
 	keyFile  string
 	caFile   string
 	notifyFn tlsCertsNotifyFunc
+	logger   *slog.Logger
 
 	close   sync.Once         // protects watcher from multiple calls to Close
 	watcher *fsnotify.Watcher // watches directories containing files
 	done    chan struct{}     // signals end of watch goroutine
 }
 
-func newDynamicTLSConfig(certFile, keyFile, caFile string, notifyFn tlsCertsNotifyFunc) (cfg *dynamicTLSConfig, err error) {
+func newDynamicTLSConfig(certFile, keyFile, caFile string, notifyFn tlsCertsNotifyFunc, logger *slog.Logger) (cfg *dynamicTLSConfig, err error) {
 	w, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
@@ -125,6 +135,9 @@ func newDynamicTLSConfig(certFile, keyFile, caFile string, notifyFn tlsCertsNoti
 		keyFile:  filepath.Clean(keyFile),
 		caFile:   filepath.Clean(caFile),
 		notifyFn: notifyFn,
+		logger:   logger,
+		recheck:  tlsRecheckInterval,
+		clock:    time.Now,
 		watcher:  w,
 		done:     make(chan struct{}),
 	}
@@ -150,7 +163,10 @@ func (cfg *dynamicTLSConfig) Close() error {
 	return nil
 }
 
+// Load returns the latest certificate and CAs, e.g. for each new connection.
+// The files are re-read when they haven't been within the recheck interval.
 func (cfg *dynamicTLSConfig) Load() (*tls.Certificate, *x509.CertPool) {
+	cfg.recheckFiles()
 	v := cfg.latest.Load().(*tlsCerts)
 	return v.cert, v.pool
 }
@@ -165,11 +181,36 @@ func (cfg *dynamicTLSConfig) LoadCACerts() *x509.CertPool {
 	return pool
 }
 
+// recheckFiles reads the files when they haven't been read within the
+// recheck interval. Errors are notified, and the previous certs kept.
+func (cfg *dynamicTLSConfig) recheckFiles() {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	if cfg.clock().Sub(cfg.checked) < cfg.recheck {
+		return
+	}
+	if err := cfg.readLocked(); err != nil {
+		cfg.logger.Error("tls config read error", "error", err)
+		cfg.notifyFn(nil, nil, err)
+	}
+}
+
 // read reads the files from disk, parses them, and returns any error.
 // If there is a change in certs, it stores them and calls notifyFn.
-// All calls to read occur serailly, once from the constructor and
-// then from the watch goroutine as fsnotify events are processed.
+// Reads occur from the constructor, from the watch goroutine as
+// fsnotify events are processed, and when loading certs that haven't
+// been rechecked recently.
 func (cfg *dynamicTLSConfig) read() error {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	return cfg.readLocked()
+}
+
+// readLocked reads the files. Must be called with the lock held.
+func (cfg *dynamicTLSConfig) readLocked() error {
+	cfg.checked = cfg.clock()
+
 	certPEMBlock, err := ioutil.ReadFile(cfg.certFile)
 	if err != nil {
 		return fmt.Errorf("error reading TLS cert: %v", err)
@@ -222,14 +263,14 @@ func (cfg *dynamicTLSConfig) watch() {
 			}
 			// TODO: ignore unrelated events
 			if err := cfg.read(); err != nil {
-				log.Errorf("tls config read error: %v", err)
+				cfg.logger.Error("tls config read error", "error", err)
 				cfg.notifyFn(nil, nil, err)
 			}
 		case err, ok := <-cfg.watcher.Errors:
 			if !ok {
 				return
 			}
-			log.Errorf("tls config watch error: %v", err)
+			cfg.logger.Error("tls config watch error", "error", err)
 		}
 	}
 }
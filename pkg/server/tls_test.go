@@ -16,6 +16,8 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/uswitch/kiam/pkg/logging"
 )
 
 func TestDynamicTLS(t *testing.T) {
@@ -95,6 +97,7 @@ func TestDynamicTLS(t *testing.T) {
 			}
 			ch <- result{cert, pool, err}
 		},
+		logging.Discard(),
 	)
 	check(t, "Failed to initialize config", err)
 	defer cfg.Close()
@@ -113,6 +116,48 @@ func TestDynamicTLS(t *testing.T) {
 	wantCert(cert1)
 }
 
+func TestDynamicTLSRechecksFiles(t *testing.T) {
+	ca, caCertPEMBlock, _ := generateCert(t, nil)
+	cert0, certPEMBlock0, keyPEMBlock0 := generateCert(t, ca)
+	cert1, certPEMBlock1, keyPEMBlock1 := generateCert(t, ca)
+
+	dir, err := ioutil.TempDir("", "")
+	check(t, "Failed to create directory", err)
+	defer os.RemoveAll(dir)
+	certs := filepath.Join(dir, "certs")
+	createDir(t, certs, map[string][]byte{
+		"cert.pem":  certPEMBlock0,
+		"key.pem":   keyPEMBlock0,
+		"roots.pem": caCertPEMBlock,
+	})
+
+	cfg, err := newDynamicTLSConfig(
+		filepath.Join(certs, "cert.pem"),
+		filepath.Join(certs, "key.pem"),
+		filepath.Join(certs, "roots.pem"),
+		nil,
+		logging.Discard(),
+	)
+	check(t, "Failed to initialize config", err)
+	// stop watching, as though the change was missed
+	cfg.Close()
+
+	now := time.Now()
+	cfg.clock = func() time.Time { return now }
+
+	check(t, "Failed to write cert", ioutil.WriteFile(filepath.Join(certs, "cert.pem"), certPEMBlock1, 0600))
+	check(t, "Failed to write key", ioutil.WriteFile(filepath.Join(certs, "key.pem"), keyPEMBlock1, 0600))
+
+	if !reflect.DeepEqual(cfg.LoadCert().Certificate, cert0.Certificate) {
+		t.Error("expected cert to be cached until rechecked")
+	}
+
+	now = now.Add(tlsRecheckInterval)
+	if !reflect.DeepEqual(cfg.LoadCert().Certificate, cert1.Certificate) {
+		t.Error("expected cert to be re-read when rechecked")
+	}
+}
+
 func generateCert(t *testing.T, ca *tls.Certificate) (_ *tls.Certificate, certPEMBlock, keyPEMBlock []byte) {
 	// See: https://golang.org/src/crypto/tls/generate_cert.go
 	t.Helper()
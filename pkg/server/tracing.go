@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	v1 "k8s.io/api/core/v1"
+)
+
+const tracerName = "github.com/uswitch/kiam/pkg/server"
+
+// tracing is embedded in policies to record a span for each decision. The
+// global tracer is used unless one is set with SetTracer.
+type tracing struct {
+	tracer trace.Tracer
+}
+
+// SetTracer sets the tracer used to record policy decision spans
+func (t *tracing) SetTracer(tracer trace.Tracer) {
+	t.tracer = tracer
+}
+
+func (t *tracing) startSpan(ctx context.Context, name, role string, pod *v1.Pod) (context.Context, trace.Span) {
+	tracer := t.tracer
+	if tracer == nil {
+		tracer = otel.Tracer(tracerName)
+	}
+
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("iam.role", role),
+		attribute.String("pod.uid", string(pod.GetUID())),
+	))
+}
+
+func endSpan(span trace.Span, decision Decision, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else if decision != nil {
+		span.SetAttributes(attribute.Bool("policy.allowed", decision.IsAllowed()))
+	}
+	span.End()
+}
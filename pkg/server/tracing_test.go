@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uswitch/kiam/pkg/aws/sts"
+	kt "github.com/uswitch/kiam/pkg/k8s/testing"
+	"github.com/uswitch/kiam/pkg/testutil"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/oteltest"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestPoliciesRecordSpans(t *testing.T) {
+	recorder := new(oteltest.SpanRecorder)
+	tracer := oteltest.NewTracerProvider(oteltest.WithSpanRecorder(recorder)).Tracer("test")
+
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	p.UID = types.UID("abc-123")
+	arnResolver := sts.DefaultResolver("arn:aws:iam::123456789012:role/")
+
+	annotated := NewRequestingAnnotatedRolePolicy(kt.NewStubFinder(p), arnResolver)
+	annotated.SetTracer(tracer)
+	namespace := NewNamespacePermittedRoleNamePolicy(true, kt.NewNamespaceFinder(testutil.NewNamespace("red", ".*")), arnResolver)
+	namespace.SetTracer(tracer)
+	policy := Policies(annotated, namespace)
+	policy.SetTracer(tracer)
+
+	decision, err := policy.IsAllowedAssumeRole(context.Background(), "red_role", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decision.IsAllowed() {
+		t.Fatal("expected to be allowed", decision.Explanation())
+	}
+
+	spans := recorder.Completed()
+	if len(spans) != 3 {
+		t.Fatal("expected 3 spans, was", len(spans))
+	}
+
+	composite := spans[2]
+	if composite.Name() != "CompositeAssumeRolePolicy.IsAllowedAssumeRole" {
+		t.Error("expected composite span to end last, was", composite.Name())
+	}
+
+	for _, span := range spans {
+		if span.Attributes()[attribute.Key("pod.uid")].AsString() != "abc-123" {
+			t.Error("expected pod uid attribute on", span.Name())
+		}
+		if !span.Attributes()[attribute.Key("policy.allowed")].AsBool() {
+			t.Error("expected allowed attribute on", span.Name())
+		}
+	}
+
+	for _, span := range spans[:2] {
+		if span.ParentSpanID() != composite.SpanContext().SpanID() {
+			t.Error("expected span to be child of composite span", span.Name())
+		}
+		if span.Attributes()[attribute.Key("iam.role.arn")].AsString() != "arn:aws:iam::123456789012:role/red_role" {
+			t.Error("expected role arn attribute on", span.Name())
+		}
+	}
+}
+
+func TestPolicySpanRecordsError(t *testing.T) {
+	recorder := new(oteltest.SpanRecorder)
+	tracer := oteltest.NewTracerProvider(oteltest.WithSpanRecorder(recorder)).Tracer("test")
+
+	p := testutil.NewPodWithRole("red", "foo", "192.168.0.1", testutil.PhaseRunning, "red_role")
+	policy := NewNamespacePermittedRoleNamePolicy(true, kt.NewNamespaceFinder(testutil.NewNamespace("red", ".*")), sts.DefaultResolver(""))
+	policy.SetTracer(tracer)
+
+	_, err := policy.IsAllowedAssumeRole(context.Background(), "", p)
+	if err == nil {
+		t.Fatal("expected error resolving empty role")
+	}
+
+	spans := recorder.Completed()
+	if len(spans) != 1 || spans[0].StatusCode() != codes.Error {
+		t.Error("expected span with error status")
+	}
+}
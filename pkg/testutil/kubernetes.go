@@ -71,3 +71,14 @@ func NewPodWithExternalID(namespace, name, ip, phase, role, externalID string) *
 	pod.ObjectMeta.Annotations["iam.amazonaws.com/external-id"] = externalID
 	return pod
 }
+
+func NewConfigMap(namespace, name string, data map[string]string) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       namespace,
+			Name:            name,
+			ResourceVersion: fmt.Sprintf("%d", time.Now().UnixNano()),
+		},
+		Data: data,
+	}
+}